@@ -0,0 +1,298 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/config"
+	"github.com/hervehildenbrand/bgp-radar/pkg/database"
+	"github.com/hervehildenbrand/bgp-radar/pkg/detector"
+	"github.com/hervehildenbrand/bgp-radar/pkg/sink"
+	"github.com/hervehildenbrand/bgp-radar/pkg/watchlist"
+)
+
+// flagEnv maps flags to the environment variables that can set them.
+var flagEnv = map[string]string{
+	"collectors":     "BGP_RADAR_COLLECTORS",
+	"redis":          "BGP_RADAR_REDIS",
+	"database":       "BGP_RADAR_DATABASE",
+	"rpki-vrps":      "BGP_RADAR_RPKI_VRPS",
+	"rpki-rtr":       "BGP_RADAR_RPKI_RTR",
+	"as-rel":         "BGP_RADAR_AS_REL",
+	"watchlist":      "BGP_RADAR_WATCHLIST",
+	"reference-data": "BGP_RADAR_REFERENCE_DATA",
+	"baseline":       "BGP_RADAR_BASELINE",
+	"state":          "BGP_RADAR_STATE",
+	"detectors":      "BGP_RADAR_DETECTORS",
+	"api":            "BGP_RADAR_API",
+	"bmp":            "BGP_RADAR_BMP",
+}
+
+// applyConfig sets the flags given neither on the command line nor by
+// their environment variable to the values of the configuration file, and
+// returns the names of the flags it set.
+func applyConfig(cfg *config.Config) map[string]bool {
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	applied := make(map[string]bool)
+	for name, value := range configFlags(cfg) {
+		if given[name] || (flagEnv[name] != "" && os.Getenv(flagEnv[name]) != "") {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			log.Fatalf("Invalid configuration: %s: %v", name, err)
+		}
+		applied[name] = true
+	}
+	return applied
+}
+
+// configFlags returns the settings of the configuration file as flag
+// values, keyed by flag name. Unset settings are omitted.
+func configFlags(cfg *config.Config) map[string]string {
+	values := make(map[string]string)
+	setString := func(name, value string) {
+		if value != "" {
+			values[name] = value
+		}
+	}
+	setInt := func(name string, value int) {
+		if value > 0 {
+			values[name] = strconv.Itoa(value)
+		}
+	}
+	setDuration := func(name string, value config.Duration) {
+		if value > 0 {
+			values[name] = time.Duration(value).String()
+		}
+	}
+
+	if cfg.Sources.Collectors != nil {
+		values["collectors"] = "none"
+		if len(cfg.Sources.Collectors) > 0 {
+			values["collectors"] = strings.Join(cfg.Sources.Collectors, ",")
+		}
+	}
+	setString("bmp", cfg.Sources.BMP)
+	setInt("buffer", cfg.Sources.Buffer)
+	setInt("workers", cfg.Workers)
+	setInt("worker-queue", cfg.WorkerQueue)
+	setString("redis", cfg.Redis)
+	setString("database", cfg.Database)
+	setString("api", cfg.API)
+	setInt("api-buffer", cfg.APIBuffer)
+	setString("metrics", cfg.Metrics)
+	if cfg.EventTimeout != nil {
+		values["event-timeout"] = time.Duration(*cfg.EventTimeout).String()
+	}
+	setDuration("stats", cfg.Stats)
+	setString("rpki-vrps", cfg.RPKI.VRPs)
+	setString("rpki-rtr", cfg.RPKI.RTR)
+	setDuration("rpki-refresh", cfg.RPKI.Refresh)
+	setString("as-rel", cfg.ASRel)
+	setString("watchlist", cfg.Watchlist)
+	setString("reference-data", strings.Join(cfg.Reference.Files, ","))
+	setString("baseline", strings.Join(cfg.Baseline, ","))
+	setString("state", cfg.State.Path)
+	setDuration("state-ttl", cfg.State.TTL)
+	setDuration("state-snapshot", cfg.State.Snapshot)
+	setString("detectors", strings.Join(cfg.Detectors.Enabled, ","))
+	return values
+}
+
+// mergeOptions returns the detector options of the configuration file
+// overridden by the -detector-opt flags.
+func mergeOptions(configured map[string]map[string]string, flags detectorOptions) map[string]map[string]string {
+	merged := make(map[string]map[string]string)
+	for _, options := range []map[string]map[string]string{configured, flags} {
+		for name, opts := range options {
+			if merged[name] == nil {
+				merged[name] = make(map[string]string)
+			}
+			for option, value := range opts {
+				merged[name][option] = value
+			}
+		}
+	}
+	return merged
+}
+
+// sinkConfigs returns the sinks of the -sink flags, or BGP_RADAR_SINKS, or
+// the configuration file, or a log sink.
+func sinkConfigs(specs []string, cfg *config.Config) ([]sink.Config, error) {
+	if len(specs) == 0 && len(cfg.Sinks) > 0 {
+		return cfg.SinkConfigs()
+	}
+	if len(specs) == 0 {
+		specs = []string{sink.KindLog}
+	}
+	configs := make([]sink.Config, 0, len(specs))
+	for _, spec := range specs {
+		c, err := sink.ParseSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, c)
+	}
+	return configs, nil
+}
+
+// loadReferences applies the comma-separated reference datasets, in order,
+// to the built-in reference data, then adds the entries of the
+// configuration file.
+func loadReferences(paths string, cfg *config.Config) (*detector.References, error) {
+	refs := detector.DefaultReferences()
+	for _, path := range strings.Split(paths, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		d, err := detector.LoadReferenceData(path)
+		if err != nil {
+			return nil, err
+		}
+		refs = refs.Apply(d)
+	}
+	return cfg.References(refs), nil
+}
+
+// buildResolverChain creates a resolver trying the configured resolvers in
+// order. Resolvers that fail to load are skipped with a warning.
+func buildResolverChain(entries []config.Resolver, databaseURL string) database.CountryResolver {
+	resolvers := make([]database.CountryResolver, 0, len(entries))
+	for _, entry := range entries {
+		if entry.File != "" {
+			r, err := database.NewFileResolver(entry.File)
+			if err != nil {
+				log.Printf("Warning: Failed to load ASN data from %s: %v", entry.File, err)
+				continue
+			}
+			resolvers = append(resolvers, r)
+			continue
+		}
+		if databaseURL == "" {
+			log.Printf("Warning: ASN resolver table %s requires a database", entry.Database)
+			continue
+		}
+		db, err := sql.Open("postgres", databaseURL)
+		if err != nil {
+			log.Printf("Warning: ASN resolver database connection failed: %v", err)
+			continue
+		}
+		resolvers = append(resolvers, database.NewDatabaseResolver(db, entry.Database))
+	}
+	return database.NewChainResolver(resolvers...)
+}
+
+// reloader applies the configuration file, and re-reads the reference data
+// and the watchlist, on SIGHUP. Detector options, sinks, reference data and
+// the watchlist change in place; sources keep their connections. Settings
+// that only apply at startup are reported as requiring a restart.
+type reloader struct {
+	path           string         // Configuration file, "" for none
+	config         *config.Config // Applied configuration
+	fromConfig     map[string]bool
+	detectorOpts   detectorOptions // From -detector-opt, overriding the file
+	sinksFromFile  bool            // Sinks not set by -sink or BGP_RADAR_SINKS
+	watchlistPath  string
+	referencePaths string
+
+	detectors *detector.Pipeline
+	sinks     *sink.Switch
+	sinkDeps  sink.Deps
+}
+
+func (r *reloader) reload() {
+	if r.path != "" {
+		if err := r.reloadConfig(); err != nil {
+			log.Printf("[config] Reload failed, keeping the running configuration: %v", err)
+			return
+		}
+	}
+	r.reloadReferences()
+	r.reloadWatchlist()
+}
+
+func (r *reloader) reloadConfig() error {
+	cfg, err := config.Load(r.path)
+	if err != nil {
+		return err
+	}
+	for _, name := range config.RestartRequired(r.config, cfg) {
+		log.Printf("[config] %s changed; restart to apply", name)
+	}
+
+	// Reconfigure only the detectors whose options changed, including
+	// those whose options were removed: they are reset to their defaults
+	previous := mergeOptions(r.config.Detectors.Options, r.detectorOpts)
+	current := mergeOptions(cfg.Detectors.Options, r.detectorOpts)
+	changed := make(map[string]map[string]string)
+	for _, options := range []map[string]map[string]string{previous, current} {
+		for name := range options {
+			if opts := current[name]; !reflect.DeepEqual(previous[name], opts) {
+				changed[name] = opts
+			}
+		}
+	}
+	// Build the new sinks before changing anything, so that a failure
+	// leaves the running configuration untouched
+	var fanout *sink.Fanout
+	if r.sinksFromFile && !reflect.DeepEqual(r.config.Sinks, cfg.Sinks) {
+		configs, err := sinkConfigs(nil, cfg)
+		if err != nil {
+			return err
+		}
+		if fanout, err = buildSinks(configs, r.sinkDeps); err != nil {
+			return fmt.Errorf("sinks: %w", err)
+		}
+	}
+	if err := r.detectors.Configure(changed); err != nil {
+		return err
+	}
+	if fanout != nil {
+		r.sinks.Replace(fanout)
+		log.Printf("[config] Sinks: %s", strings.Join(r.sinks.Names(), ", "))
+	}
+
+	if r.fromConfig["watchlist"] {
+		r.watchlistPath = cfg.Watchlist
+	}
+	if r.fromConfig["reference-data"] {
+		r.referencePaths = strings.Join(cfg.Reference.Files, ",")
+	}
+	r.config = cfg
+	log.Printf("[config] Reloaded %s", r.path)
+	return nil
+}
+
+func (r *reloader) reloadReferences() {
+	refs, err := loadReferences(r.referencePaths, r.config)
+	if err != nil {
+		log.Printf("[config] Reference data reload failed, keeping version %s: %v", detector.CurrentReferences().Version, err)
+		return
+	}
+	detector.SetReferences(refs)
+	log.Printf("[config] Reference data version %s", refs.Version)
+}
+
+func (r *reloader) reloadWatchlist() {
+	d, ok := r.detectors.Get(detector.NameWatchlist).(*detector.WatchlistDetector)
+	if !ok || r.watchlistPath == "" {
+		return
+	}
+	w, err := watchlist.Load(r.watchlistPath)
+	if err != nil {
+		log.Printf("[config] Watchlist reload failed, keeping the running watchlist: %v", err)
+		return
+	}
+	d.SetWatchlist(w)
+	log.Printf("[config] Watching %d prefixes from %s", w.Len(), r.watchlistPath)
+}
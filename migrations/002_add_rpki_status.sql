@@ -0,0 +1,7 @@
+-- BGP Radar - RPKI route origin validation status on events
+
+ALTER TABLE bgp_events ADD COLUMN IF NOT EXISTS rpki_status VARCHAR(20);  -- 'valid', 'invalid', 'not_found', 'unknown'
+
+CREATE INDEX IF NOT EXISTS idx_events_rpki ON bgp_events(rpki_status) WHERE rpki_status = 'invalid';
+
+COMMENT ON COLUMN bgp_events.rpki_status IS 'RPKI origin validation state of the announcement (RFC 6811)';
@@ -0,0 +1,9 @@
+-- BGP Radar - Event lifecycle: when an anomaly ended and how long it lasted
+
+ALTER TABLE bgp_events ADD COLUMN IF NOT EXISTS ended_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE bgp_events ADD COLUMN IF NOT EXISTS duration_seconds INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_events_last_seen ON bgp_events(last_seen_at) WHERE is_active = TRUE;
+
+COMMENT ON COLUMN bgp_events.ended_at IS 'When the anomaly ended (resolution signal or inactivity timeout)';
+COMMENT ON COLUMN bgp_events.duration_seconds IS 'Time between detected_at and ended_at';
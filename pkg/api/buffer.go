@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+// EventBuffer keeps the most recent events in memory, for serving the API
+// without a database. Like the database writer, it merges repeated active
+// events with the same signature and closes them on their end event.
+type EventBuffer struct {
+	mu     sync.RWMutex
+	events []models.BGPEvent
+	next   int  // Slot of the next event
+	full   bool // Whether the buffer wrapped around
+	seq    uint64
+	active map[string]int // Signature of active events -> slot
+}
+
+// NewEventBuffer creates a buffer holding up to size events.
+func NewEventBuffer(size int) *EventBuffer {
+	if size <= 0 {
+		size = 1
+	}
+	return &EventBuffer{
+		events: make([]models.BGPEvent, size),
+		active: make(map[string]int),
+	}
+}
+
+// Add records an event.
+func (b *EventBuffer) Add(event models.BGPEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := signature(event)
+	slot, exists := b.active[key]
+
+	if !event.IsActive {
+		if !exists {
+			return
+		}
+		delete(b.active, key)
+		existing := &b.events[slot]
+		existing.IsActive = false
+		existing.EndedAt = event.EndedAt
+		if existing.EndedAt.IsZero() {
+			existing.EndedAt = event.DetectedAt
+		}
+		if reason, ok := event.Details["resolution"]; ok {
+			existing.Details = copyDetails(existing.Details)
+			existing.Details["resolution"] = reason
+		}
+		return
+	}
+
+	if exists {
+		existing := &b.events[slot]
+		if models.SeverityRank(event.Severity) > models.SeverityRank(existing.Severity) {
+			existing.Severity = event.Severity
+		}
+		return
+	}
+
+	// Overwriting the oldest event: forget it if it is still active
+	if b.full {
+		if old := signature(b.events[b.next]); b.active[old] == b.next {
+			delete(b.active, old)
+		}
+	}
+
+	b.seq++
+	if event.ID == "" {
+		event.ID = strconv.FormatUint(b.seq, 10)
+	}
+	b.events[b.next] = event
+	b.active[key] = b.next
+
+	b.next++
+	if b.next == len(b.events) {
+		b.next = 0
+		b.full = true
+	}
+}
+
+// Events returns the buffered events matching filter, most recent first.
+func (b *EventBuffer) Events(ctx context.Context, filter models.EventFilter) ([]models.BGPEvent, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	count := b.next
+	if b.full {
+		count = len(b.events)
+	}
+
+	var events []models.BGPEvent
+	for i := 1; i <= count; i++ {
+		slot := (b.next - i + len(b.events)) % len(b.events)
+		event := b.events[slot]
+		if !filter.Matches(event) {
+			continue
+		}
+		events = append(events, event)
+		if filter.Limit > 0 && len(events) >= filter.Limit {
+			break
+		}
+	}
+	return events, nil
+}
+
+// Len returns the number of buffered events.
+func (b *EventBuffer) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.full {
+		return len(b.events)
+	}
+	return b.next
+}
+
+// signature identifies the anomaly an event reports, matching the way the
+// database writer closes events: events with neither an affected ASN nor a
+// prefix are told apart by their country.
+func signature(event models.BGPEvent) string {
+	key := event.EventType + "|" + strconv.FormatUint(uint64(event.AffectedASN), 10) + "|" + event.AffectedPrefix
+	if event.AffectedASN == 0 && event.AffectedPrefix == "" {
+		key += "|" + event.CountryCode
+	}
+	return key
+}
+
+func copyDetails(details map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(details)+1)
+	for k, v := range details {
+		out[k] = v
+	}
+	return out
+}
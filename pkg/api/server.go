@@ -0,0 +1,304 @@
+// Package api provides an embedded HTTP server exposing events and detector
+// state as JSON.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/detector"
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+const (
+	defaultLimit    = 100
+	maxLimit        = 1000
+	shutdownTimeout = 5 * time.Second
+)
+
+// EventSource returns stored events, most recent first.
+// Implemented by EventBuffer and database.EventReader.
+type EventSource interface {
+	Events(ctx context.Context, filter models.EventFilter) ([]models.BGPEvent, error)
+}
+
+// OriginLookup returns the known origins of a prefix.
+// Implemented by detector.HijackDetector.
+type OriginLookup interface {
+	Origins(prefix string) (detector.PrefixOrigins, error)
+}
+
+// StatsFunc returns component statistics, e.g. rislive.MultiClient.Stats.
+type StatsFunc func() map[string]interface{}
+
+// Server is the HTTP API server.
+type Server struct {
+	events     EventSource
+	origins    OriginLookup
+	collectors StatsFunc
+	detectors  StatsFunc
+	sinks      StatsFunc
+
+	mux    *http.ServeMux
+	server *http.Server
+}
+
+// NewServer creates an API server listening on addr and serving events from
+// the given source.
+func NewServer(addr string, events EventSource) *Server {
+	s := &Server{events: events, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/healthz", s.handleHealth)
+	s.mux.HandleFunc("/api/events", s.handleEvents)
+	s.mux.HandleFunc("/api/origins", s.handleOrigins)
+	s.mux.HandleFunc("/api/collectors", s.handleStats(func() StatsFunc { return s.collectors }))
+	s.mux.HandleFunc("/api/detectors", s.handleStats(func() StatsFunc { return s.detectors }))
+	s.mux.HandleFunc("/api/sinks", s.handleStats(func() StatsFunc { return s.sinks }))
+	s.server = &http.Server{
+		Addr:              addr,
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+// SetOriginLookup enables the /api/origins endpoint.
+func (s *Server) SetOriginLookup(origins OriginLookup) {
+	s.origins = origins
+}
+
+// SetCollectorStats enables the /api/collectors endpoint.
+func (s *Server) SetCollectorStats(fn StatsFunc) {
+	s.collectors = fn
+}
+
+// SetDetectorStats enables the /api/detectors endpoint.
+func (s *Server) SetDetectorStats(fn StatsFunc) {
+	s.detectors = fn
+}
+
+// SetSinkStats enables the /api/sinks endpoint.
+func (s *Server) SetSinkStats(fn StatsFunc) {
+	s.sinks = fn
+}
+
+// Handle serves an additional endpoint, e.g. /metrics. It must be called
+// before Start.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Handler returns the API request handler.
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// Start begins serving in the background.
+func (s *Server) Start() {
+	go func() {
+		if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("API server error: %v", err)
+		}
+	}()
+	log.Printf("API server listening on %s", s.server.Addr)
+}
+
+// Stop gracefully shuts down the server.
+func (s *Server) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := s.server.Shutdown(ctx); err != nil {
+		log.Printf("API server shutdown error: %v", err)
+	}
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleEvents serves GET /api/events?type=&severity=&min_severity=&country=
+// &asn=&prefix=&active=&since=&limit=
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	filter, err := parseFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	events, err := s.events.Events(r.Context(), filter)
+	if err != nil {
+		log.Printf("API events query failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "event query failed")
+		return
+	}
+
+	out := make([]eventJSON, 0, len(events))
+	for _, event := range events {
+		out = append(out, toEventJSON(event))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"count":  len(out),
+		"events": out,
+	})
+}
+
+// handleOrigins serves GET /api/origins?prefix=
+func (s *Server) handleOrigins(w http.ResponseWriter, r *http.Request) {
+	if s.origins == nil {
+		writeError(w, http.StatusNotFound, "hijack detector not enabled")
+		return
+	}
+	prefix := r.URL.Query().Get("prefix")
+	if prefix == "" {
+		writeError(w, http.StatusBadRequest, "missing prefix")
+		return
+	}
+
+	origins, err := s.origins.Origins(prefix)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid prefix: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, origins)
+}
+
+func (s *Server) handleStats(get func() StatsFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fn := get()
+		if fn == nil {
+			writeError(w, http.StatusNotFound, "not available")
+			return
+		}
+		writeJSON(w, http.StatusOK, fn())
+	}
+}
+
+// parseFilter builds an event filter from query parameters.
+func parseFilter(r *http.Request) (models.EventFilter, error) {
+	q := r.URL.Query()
+	filter := models.EventFilter{
+		Type:        q.Get("type"),
+		Severity:    q.Get("severity"),
+		MinSeverity: q.Get("min_severity"),
+		Country:     strings.ToUpper(q.Get("country")),
+		Prefix:      q.Get("prefix"),
+		Limit:       defaultLimit,
+	}
+
+	if filter.Prefix != "" {
+		if _, err := netip.ParsePrefix(filter.Prefix); err != nil {
+			return filter, errors.New("invalid prefix: " + filter.Prefix)
+		}
+	}
+	for _, severity := range []string{filter.Severity, filter.MinSeverity} {
+		if severity != "" && models.SeverityRank(severity) < 0 {
+			return filter, errors.New("invalid severity: " + severity)
+		}
+	}
+	if v := q.Get("asn"); v != "" {
+		asn, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(v), "AS"), 10, 32)
+		if err != nil {
+			return filter, errors.New("invalid asn: " + v)
+		}
+		filter.ASN = uint32(asn)
+	}
+	if v := q.Get("active"); v != "" {
+		active, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, errors.New("invalid active: " + v)
+		}
+		filter.ActiveOnly = active
+	}
+	if v := q.Get("since"); v != "" {
+		since, err := parseSince(v)
+		if err != nil {
+			return filter, errors.New("invalid since (RFC 3339 time or duration): " + v)
+		}
+		filter.Since = since
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return filter, errors.New("invalid limit: " + v)
+		}
+		if limit > maxLimit {
+			limit = maxLimit
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
+
+// parseSince accepts an RFC 3339 time or a duration relative to now ("1h").
+func parseSince(v string) (time.Time, error) {
+	if d, err := time.ParseDuration(v); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// eventJSON is the API representation of an event.
+type eventJSON struct {
+	ID              string                 `json:"id,omitempty"`
+	Type            string                 `json:"type"`
+	Severity        string                 `json:"severity"`
+	Category        string                 `json:"category"`
+	CountryCode     string                 `json:"country_code,omitempty"`
+	AffectedASN     uint32                 `json:"affected_asn,omitempty"`
+	AffectedPrefix  string                 `json:"affected_prefix,omitempty"`
+	RPKIStatus      string                 `json:"rpki_status,omitempty"`
+	IsCrossBorder   bool                   `json:"is_cross_border,omitempty"`
+	AttackerCountry string                 `json:"attacker_country,omitempty"`
+	VictimCountry   string                 `json:"victim_country,omitempty"`
+	IsActive        bool                   `json:"is_active"`
+	DetectedAt      time.Time              `json:"detected_at"`
+	EndedAt         *time.Time             `json:"ended_at,omitempty"`
+	Details         map[string]interface{} `json:"details,omitempty"`
+}
+
+func toEventJSON(event models.BGPEvent) eventJSON {
+	out := eventJSON{
+		ID:              event.ID,
+		Type:            event.EventType,
+		Severity:        event.Severity,
+		Category:        event.EventCategory,
+		CountryCode:     event.CountryCode,
+		AffectedASN:     event.AffectedASN,
+		AffectedPrefix:  event.AffectedPrefix,
+		RPKIStatus:      event.RPKIStatus,
+		IsCrossBorder:   event.IsCrossBorder,
+		AttackerCountry: event.AttackerCountry,
+		VictimCountry:   event.VictimCountry,
+		IsActive:        event.IsActive,
+		DetectedAt:      event.DetectedAt,
+		Details:         event.Details,
+	}
+	if !event.EndedAt.IsZero() {
+		endedAt := event.EndedAt
+		out.EndedAt = &endedAt
+	}
+	return out
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("API response encoding failed: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/detector"
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+func testEvent(eventType, severity, country string, asn uint32, prefix string) models.BGPEvent {
+	return models.BGPEvent{
+		EventType:      eventType,
+		Severity:       severity,
+		CountryCode:    country,
+		AffectedASN:    asn,
+		AffectedPrefix: prefix,
+		DetectedAt:     time.Now(),
+		IsActive:       true,
+	}
+}
+
+func TestEventBuffer_Lifecycle(t *testing.T) {
+	b := NewEventBuffer(10)
+
+	b.Add(testEvent(models.EventTypeBlackhole, models.SeverityMedium, "US", 13335, "192.0.2.1/32"))
+	b.Add(testEvent(models.EventTypeBlackhole, models.SeverityHigh, "US", 13335, "192.0.2.1/32"))
+	if b.Len() != 1 {
+		t.Fatalf("Expected repeated active events to merge, got %d events", b.Len())
+	}
+
+	end := testEvent(models.EventTypeBlackhole, models.SeverityMedium, "US", 13335, "192.0.2.1/32")
+	end.IsActive = false
+	end.EndedAt = time.Now()
+	end.Details = map[string]interface{}{"resolution": "withdrawn"}
+	b.Add(end)
+
+	events, _ := b.Events(context.Background(), models.EventFilter{})
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(events))
+	}
+	if events[0].IsActive || events[0].EndedAt.IsZero() {
+		t.Error("Expected the event to be closed")
+	}
+	if events[0].Severity != models.SeverityHigh {
+		t.Errorf("Expected severity raised to high, got %s", events[0].Severity)
+	}
+	if events[0].Details["resolution"] != "withdrawn" {
+		t.Errorf("Expected resolution withdrawn, got %v", events[0].Details["resolution"])
+	}
+
+	// A new occurrence after the end is a new event
+	b.Add(testEvent(models.EventTypeBlackhole, models.SeverityMedium, "US", 13335, "192.0.2.1/32"))
+	if b.Len() != 2 {
+		t.Errorf("Expected 2 events, got %d", b.Len())
+	}
+}
+
+func TestEventBuffer_Wraparound(t *testing.T) {
+	b := NewEventBuffer(3)
+	for asn := uint32(1); asn <= 5; asn++ {
+		b.Add(testEvent(models.EventTypeHijack, models.SeverityMedium, "US", asn, "192.0.2.0/24"))
+	}
+
+	events, _ := b.Events(context.Background(), models.EventFilter{})
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(events))
+	}
+	for i, want := range []uint32{5, 4, 3} {
+		if events[i].AffectedASN != want {
+			t.Errorf("Event %d: expected AS%d, got AS%d", i, want, events[i].AffectedASN)
+		}
+	}
+
+	// AS1 was overwritten, so a new AS1 event is added rather than merged
+	b.Add(testEvent(models.EventTypeHijack, models.SeverityMedium, "US", 1, "192.0.2.0/24"))
+	events, _ = b.Events(context.Background(), models.EventFilter{Limit: 1})
+	if len(events) != 1 || events[0].AffectedASN != 1 {
+		t.Errorf("Expected newest event AS1, got %+v", events)
+	}
+}
+
+type eventsResponse struct {
+	Count  int `json:"count"`
+	Events []struct {
+		Type           string `json:"type"`
+		AffectedASN    uint32 `json:"affected_asn"`
+		AffectedPrefix string `json:"affected_prefix"`
+	} `json:"events"`
+}
+
+func TestServer_Events(t *testing.T) {
+	b := NewEventBuffer(100)
+	b.Add(testEvent(models.EventTypeHijack, models.SeverityCritical, "US", 13335, "1.1.1.0/24"))
+	b.Add(testEvent(models.EventTypeLeak, models.SeverityHigh, "BR", 64500, "198.51.100.0/24"))
+	b.Add(testEvent(models.EventTypeBlackhole, models.SeverityMedium, "DE", 3320, "10.1.2.3/32"))
+	b.Add(testEvent(models.EventTypeWithdrawalStorm, models.SeverityHigh, "IR", 0, ""))
+
+	srv := httptest.NewServer(NewServer(":0", b).Handler())
+	defer srv.Close()
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", 4},
+		{"?type=hijack", 1},
+		{"?severity=high", 2},
+		{"?min_severity=high", 3},
+		{"?country=br", 1},
+		{"?asn=AS3320", 1},
+		{"?prefix=10.0.0.0/8", 1},
+		{"?prefix=1.1.1.0/24", 1},
+		{"?active=true&limit=2", 2},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(srv.URL + "/api/events" + tt.query)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", tt.query, err)
+		}
+		var body eventsResponse
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("GET %s: invalid JSON: %v", tt.query, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || body.Count != tt.want {
+			t.Errorf("GET /api/events%s: status %d, %d events, want %d", tt.query, resp.StatusCode, body.Count, tt.want)
+		}
+	}
+
+	for _, query := range []string{"?asn=abc", "?severity=huge", "?prefix=nope", "?limit=-1", "?since=yesterday"} {
+		resp, err := http.Get(srv.URL + "/api/events" + query)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", query, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("GET /api/events%s: expected 400, got %d", query, resp.StatusCode)
+		}
+	}
+}
+
+func TestServer_Origins(t *testing.T) {
+	events := make(chan models.BGPEvent, 10)
+	hijack := detector.NewHijackDetector(events, nil)
+	if err := hijack.Configure(map[string]string{"min-peers": "1"}); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	hijack.Process(models.BGPUpdate{
+		Timestamp:    time.Now(),
+		PeerASN:      6939,
+		Prefix:       "10.0.0.0/16",
+		ASPath:       []uint32{6939, 64500},
+		OriginASN:    64500,
+		Announcement: true,
+		Collector:    "rrc00",
+	})
+
+	s := NewServer(":0", NewEventBuffer(1))
+	s.SetOriginLookup(hijack)
+	s.SetCollectorStats(func() map[string]interface{} {
+		return map[string]interface{}{"total_messages": 42}
+	})
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/origins?prefix=10.0.5.0/24")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	var origins detector.PrefixOrigins
+	if err := json.NewDecoder(resp.Body).Decode(&origins); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	resp.Body.Close()
+	if origins.Origin != 0 || origins.CoveringPrefix != "10.0.0.0/16" || origins.CoveringOrigin != 64500 {
+		t.Errorf("Unexpected origins: %+v", origins)
+	}
+
+	resp, err = http.Get(srv.URL + "/api/collectors")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	var stats map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&stats)
+	resp.Body.Close()
+	if stats["total_messages"] != float64(42) {
+		t.Errorf("Unexpected collector stats: %v", stats)
+	}
+
+	resp, err = http.Get(srv.URL + "/api/detectors")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for unset detector stats, got %d", resp.StatusCode)
+	}
+}
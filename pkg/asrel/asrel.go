@@ -0,0 +1,227 @@
+// Package asrel loads CAIDA AS-relationship data and checks AS paths against
+// the Gao-Rexford valley-free property.
+package asrel
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Relationship is what a neighbor AS is to a given AS.
+type Relationship int8
+
+const (
+	Unknown Relationship = iota
+	Customer
+	Provider
+	Peer
+)
+
+// String returns the relationship name.
+func (r Relationship) String() string {
+	switch r {
+	case Customer:
+		return "customer"
+	case Provider:
+		return "provider"
+	case Peer:
+		return "peer"
+	default:
+		return "unknown"
+	}
+}
+
+// Graph holds AS relationships. A nil Graph knows no relationship.
+// It is read-only once loaded and safe for concurrent use.
+type Graph struct {
+	rels  map[uint64]Relationship
+	links int
+}
+
+// NewGraph creates an empty relationship graph.
+func NewGraph() *Graph {
+	return &Graph{rels: make(map[uint64]Relationship)}
+}
+
+// AddProviderCustomer records that provider is a transit provider of customer.
+func (g *Graph) AddProviderCustomer(provider, customer uint32) {
+	g.add(provider, customer, Customer, Provider)
+}
+
+// AddPeers records a settlement-free peering between a and b.
+func (g *Graph) AddPeers(a, b uint32) {
+	g.add(a, b, Peer, Peer)
+}
+
+func (g *Graph) add(a, b uint32, bToA, aToB Relationship) {
+	if _, ok := g.rels[linkKey(a, b)]; !ok {
+		g.links++
+	}
+	g.rels[linkKey(a, b)] = bToA
+	g.rels[linkKey(b, a)] = aToB
+}
+
+// Relationship returns what neighbor is to asn.
+func (g *Graph) Relationship(asn, neighbor uint32) Relationship {
+	if g == nil {
+		return Unknown
+	}
+	return g.rels[linkKey(asn, neighbor)]
+}
+
+// Len returns the number of AS links in the graph.
+func (g *Graph) Len() int {
+	if g == nil {
+		return 0
+	}
+	return g.links
+}
+
+func linkKey(a, b uint32) uint64 {
+	return uint64(a)<<32 | uint64(b)
+}
+
+// Load reads a CAIDA as-rel or serial-2 file, optionally gzip or bzip2
+// compressed (by .gz or .bz2 extension).
+func Load(path string) (*Graph, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	switch {
+	case strings.HasSuffix(path, ".bz2"):
+		r = bzip2.NewReader(f)
+	case strings.HasSuffix(path, ".gz"):
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("open gzip: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return Parse(r)
+}
+
+// Parse reads relationships in the CAIDA as-rel / serial-2 format:
+//
+//	<provider-as>|<customer-as>|-1[|<source>]
+//	<peer-as>|<peer-as>|0[|<source>]
+//
+// Comment lines start with '#'. Other relationship types and malformed
+// lines are skipped.
+func Parse(r io.Reader) (*Graph, error) {
+	g := NewGraph()
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		fields := strings.Split(line, "|")
+		if len(fields) < 3 {
+			continue
+		}
+		a, err := strconv.ParseUint(fields[0], 10, 32)
+		if err != nil {
+			continue
+		}
+		b, err := strconv.ParseUint(fields[1], 10, 32)
+		if err != nil {
+			continue
+		}
+
+		switch fields[2] {
+		case "-1":
+			g.AddProviderCustomer(uint32(a), uint32(b))
+		case "0":
+			g.AddPeers(uint32(a), uint32(b))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read relationships: %w", err)
+	}
+	return g, nil
+}
+
+// Violation describes an AS path that is not valley-free: the leaking AS
+// learned the route from a provider or peer and exported it to a provider
+// or peer.
+type Violation struct {
+	LeakASN uint32
+	From    uint32       // Neighbor the leaking AS learned the route from
+	FromRel Relationship // What From is to the leaking AS
+	To      uint32       // Neighbor the leaking AS exported the route to
+	ToRel   Relationship // What To is to the leaking AS
+	Segment []uint32     // To, leaking AS, From in AS path order
+}
+
+// CheckValleyFree walks path (collector side first, origin last) in the
+// direction the route propagated and returns the first valley-free violation.
+// Once a route went down to a customer or across a peering, it may only go
+// down to customers. Links with unknown relationships are skipped.
+func (g *Graph) CheckValleyFree(path []uint32) (Violation, bool) {
+	if g == nil {
+		return Violation{}, false
+	}
+	path = dedup(path)
+
+	descending := false
+	for i := len(path) - 1; i > 0; i-- {
+		sender, receiver := path[i], path[i-1]
+
+		switch g.Relationship(sender, receiver) {
+		case Customer:
+			descending = true
+		case Peer:
+			if descending {
+				return g.violation(path, i), true
+			}
+			descending = true
+		case Provider:
+			if descending {
+				return g.violation(path, i), true
+			}
+		}
+	}
+	return Violation{}, false
+}
+
+func (g *Graph) violation(path []uint32, i int) Violation {
+	leak, to := path[i], path[i-1]
+	v := Violation{
+		LeakASN: leak,
+		To:      to,
+		ToRel:   g.Relationship(leak, to),
+	}
+	end := i + 1
+	if i+1 < len(path) {
+		v.From = path[i+1]
+		v.FromRel = g.Relationship(leak, v.From)
+		end = i + 2
+	}
+	v.Segment = append([]uint32(nil), path[i-1:end]...)
+	return v
+}
+
+// dedup removes AS path prepending.
+func dedup(path []uint32) []uint32 {
+	out := make([]uint32, 0, len(path))
+	for i, asn := range path {
+		if i > 0 && asn == path[i-1] {
+			continue
+		}
+		out = append(out, asn)
+	}
+	return out
+}
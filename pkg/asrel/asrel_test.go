@@ -0,0 +1,137 @@
+package asrel
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testRelationships = `# source:topology|BGP
+# 174 and 3356 peer, both provide transit to 64500
+174|3356|0|bgp
+174|64500|-1|bgp
+3356|64500|-1|bgp
+174|64510|-1|bgp
+64500|64501|-1|bgp
+64500|64600|0|bgp
+3356|6939|0
+not|a|line
+`
+
+func testGraph(t *testing.T) *Graph {
+	t.Helper()
+	g, err := Parse(strings.NewReader(testRelationships))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	return g
+}
+
+func TestParse(t *testing.T) {
+	g := testGraph(t)
+
+	if g.Len() != 7 {
+		t.Errorf("Expected 7 links, got %d", g.Len())
+	}
+
+	tests := []struct {
+		asn, neighbor uint32
+		want          Relationship
+	}{
+		{174, 64500, Customer},
+		{64500, 174, Provider},
+		{174, 3356, Peer},
+		{3356, 174, Peer},
+		{64501, 64600, Unknown},
+	}
+	for _, tt := range tests {
+		if got := g.Relationship(tt.asn, tt.neighbor); got != tt.want {
+			t.Errorf("Relationship(%d, %d) = %s, want %s", tt.asn, tt.neighbor, got, tt.want)
+		}
+	}
+}
+
+func TestCheckValleyFree(t *testing.T) {
+	g := testGraph(t)
+
+	tests := []struct {
+		name string
+		path []uint32
+		leak bool
+		want Violation
+	}{
+		{
+			name: "customer route up and across a peering",
+			path: []uint32{6939, 3356, 64500, 64501},
+		},
+		{
+			name: "provider route down to customers",
+			path: []uint32{64501, 64500, 174, 64510},
+		},
+		{
+			name: "unknown links",
+			path: []uint32{65001, 65002, 65003},
+		},
+		{
+			name: "provider route leaked to another provider",
+			path: []uint32{3356, 64500, 174, 64510},
+			leak: true,
+			want: Violation{
+				LeakASN: 64500,
+				From:    174,
+				FromRel: Provider,
+				To:      3356,
+				ToRel:   Provider,
+				Segment: []uint32{3356, 64500, 174},
+			},
+		},
+		{
+			name: "peer route leaked to a provider",
+			path: []uint32{3356, 64500, 64600},
+			leak: true,
+			want: Violation{
+				LeakASN: 64500,
+				From:    64600,
+				FromRel: Peer,
+				To:      3356,
+				ToRel:   Provider,
+				Segment: []uint32{3356, 64500, 64600},
+			},
+		},
+		{
+			name: "prepending",
+			path: []uint32{3356, 64500, 64500, 174, 174, 174, 64510},
+			leak: true,
+			want: Violation{
+				LeakASN: 64500,
+				From:    174,
+				FromRel: Provider,
+				To:      3356,
+				ToRel:   Provider,
+				Segment: []uint32{3356, 64500, 174},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, leak := g.CheckValleyFree(tt.path)
+			if leak != tt.leak {
+				t.Fatalf("Expected leak=%v, got %v (%+v)", tt.leak, leak, got)
+			}
+			if leak && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNilGraph(t *testing.T) {
+	var g *Graph
+	if g.Len() != 0 {
+		t.Error("Expected empty nil graph")
+	}
+	if _, leak := g.CheckValleyFree([]uint32{3356, 64500, 174}); leak {
+		t.Error("Expected no violation from a nil graph")
+	}
+}
@@ -0,0 +1,290 @@
+// Package baseline keeps the origin history of prefixes, so the legitimate
+// origin of a prefix is the one most peers have seen rather than the one
+// that happened to arrive first. Histories are seeded from RIB snapshots or
+// prefix-origin dumps (see Load) and updated from live announcements.
+package baseline
+
+import (
+	"hash/fnv"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MaxPeers bounds the distinct peers counted per prefix origin, keeping a
+// full table history within a few hundred megabytes.
+const MaxPeers = 32
+
+// MaxOrigins bounds the origins kept per prefix: a new origin replaces the
+// least stable one.
+const MaxOrigins = 8
+
+// DefaultMaxPrefixes is the default number of prefixes a history keeps,
+// above the size of the full IPv4 and IPv6 tables.
+const DefaultMaxPrefixes = 2000000
+
+// evictionSamples is the number of prefixes sampled to pick one to evict.
+const evictionSamples = 16
+
+// Origin is an origin AS of a prefix with how widely and how long it was
+// seen.
+type Origin struct {
+	ASN       uint32    `json:"asn"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Peers     int       `json:"peers"`            // Distinct peers, up to MaxPeers for live observations
+	Seeded    bool      `json:"seeded,omitempty"` // Read from a dump, see Seed
+}
+
+// record is the history of one origin of a prefix.
+type record struct {
+	asn       uint32
+	firstSeen time.Time
+	lastSeen  time.Time
+	peers     []uint32 // Hashes of the peers seen announcing it
+	seeded    int      // Peer count from a dump
+}
+
+func (r *record) origin() Origin {
+	return Origin{
+		ASN:       r.asn,
+		FirstSeen: r.firstSeen,
+		LastSeen:  r.lastSeen,
+		Peers:     max(len(r.peers), r.seeded),
+		Seeded:    r.seeded > 0,
+	}
+}
+
+// History is the origin history of prefixes, safe for concurrent use. It
+// keeps up to a maximum number of prefixes: beyond it, a new prefix evicts
+// one of the least stable, so that prefix churn does not grow it.
+type History struct {
+	mu          sync.RWMutex
+	prefixes    map[netip.Prefix][]*record
+	maxPrefixes int
+	evictions   uint64
+	onEvict     func(netip.Prefix)
+}
+
+// NewHistory creates an empty history of up to DefaultMaxPrefixes prefixes.
+func NewHistory() *History {
+	return NewBoundedHistory(DefaultMaxPrefixes)
+}
+
+// NewBoundedHistory creates an empty history of up to maxPrefixes
+// prefixes (0 for no limit).
+func NewBoundedHistory(maxPrefixes int) *History {
+	return &History{prefixes: make(map[netip.Prefix][]*record), maxPrefixes: maxPrefixes}
+}
+
+// OnEvict sets a function called with each prefix evicted from the
+// history, outside of its lock, e.g. to drop the prefix from an index.
+func (h *History) OnEvict(fn func(prefix netip.Prefix)) {
+	h.mu.Lock()
+	h.onEvict = fn
+	h.mu.Unlock()
+}
+
+// find returns the record of origin for prefix, creating it if needed, and
+// the prefix it evicted to make room, if any (otherwise the zero Prefix).
+// The caller must hold the write lock.
+func (h *History) find(prefix netip.Prefix, origin uint32, at time.Time) (*record, netip.Prefix) {
+	records, known := h.prefixes[prefix]
+	for _, r := range records {
+		if r.asn == origin {
+			return r, netip.Prefix{}
+		}
+	}
+
+	r := &record{asn: origin, firstSeen: at, lastSeen: at}
+	if len(records) >= MaxOrigins {
+		records[leastStable(records)] = r
+		return r, netip.Prefix{}
+	}
+	var evicted netip.Prefix
+	if !known && h.maxPrefixes > 0 && len(h.prefixes) >= h.maxPrefixes {
+		evicted = h.evict()
+	}
+	h.prefixes[prefix] = append(records, r)
+	return r, evicted
+}
+
+// evict removes one of the least stable of a sample of prefixes, returning
+// it. The caller must hold the write lock.
+func (h *History) evict() netip.Prefix {
+	var (
+		victim netip.Prefix
+		worst  Origin
+		n      int
+	)
+	for prefix, records := range h.prefixes { // Random order
+		o, _ := baseline(records)
+		if n == 0 || evictBefore(o, worst) {
+			victim, worst = prefix, o
+		}
+		if n++; n == evictionSamples {
+			break
+		}
+	}
+	delete(h.prefixes, victim)
+	h.evictions++
+	return victim
+}
+
+// evictBefore reports whether a prefix of baseline a is evicted before one
+// of baseline b: seen by fewer peers, then seen less recently.
+func evictBefore(a, b Origin) bool {
+	if a.Peers != b.Peers {
+		return a.Peers < b.Peers
+	}
+	return a.LastSeen.Before(b.LastSeen)
+}
+
+// leastStable returns the index of the least stable record.
+func leastStable(records []*record) int {
+	least := 0
+	for i, r := range records {
+		if moreStable(records[least].origin(), r.origin()) {
+			least = i
+		}
+	}
+	return least
+}
+
+// Observe records that peer announced prefix from origin at the given time.
+func (h *History) Observe(prefix netip.Prefix, origin uint32, peer string, at time.Time) {
+	hash := peerHash(peer)
+
+	h.mu.Lock()
+	r, evicted := h.find(prefix, origin, at)
+	if at.Before(r.firstSeen) {
+		r.firstSeen = at
+	}
+	if at.After(r.lastSeen) {
+		r.lastSeen = at
+	}
+	r.addPeer(hash)
+	onEvict := h.onEvict
+	h.mu.Unlock()
+
+	if evicted.IsValid() && onEvict != nil {
+		onEvict(evicted)
+	}
+}
+
+// addPeer counts a peer, up to MaxPeers.
+func (r *record) addPeer(hash uint32) {
+	if len(r.peers) >= MaxPeers {
+		return
+	}
+	for _, p := range r.peers {
+		if p == hash {
+			return
+		}
+	}
+	r.peers = append(r.peers, hash)
+}
+
+// Seed records an origin of prefix from a dump. Zero times default to now
+// and a zero peer count to 1.
+func (h *History) Seed(prefix netip.Prefix, o Origin) {
+	now := time.Now()
+	if o.FirstSeen.IsZero() {
+		o.FirstSeen = now
+	}
+	if o.LastSeen.IsZero() {
+		o.LastSeen = o.FirstSeen
+	}
+	if o.Peers <= 0 {
+		o.Peers = 1
+	}
+
+	h.mu.Lock()
+	r, evicted := h.find(prefix, o.ASN, o.FirstSeen)
+	if o.FirstSeen.Before(r.firstSeen) {
+		r.firstSeen = o.FirstSeen
+	}
+	if o.LastSeen.After(r.lastSeen) {
+		r.lastSeen = o.LastSeen
+	}
+	r.seeded = max(r.seeded, o.Peers)
+	onEvict := h.onEvict
+	h.mu.Unlock()
+
+	if evicted.IsValid() && onEvict != nil {
+		onEvict(evicted)
+	}
+}
+
+// Origins returns the origins seen for prefix, most stable first: by
+// distinct peers, then earliest first seen.
+func (h *History) Origins(prefix netip.Prefix) []Origin {
+	h.mu.RLock()
+	records := h.prefixes[prefix]
+	origins := make([]Origin, len(records))
+	for i, r := range records {
+		origins[i] = r.origin()
+	}
+	h.mu.RUnlock()
+
+	sort.Slice(origins, func(i, j int) bool { return moreStable(origins[i], origins[j]) })
+	return origins
+}
+
+// Baseline returns the most stable origin of prefix, if any was seen.
+func (h *History) Baseline(prefix netip.Prefix) (Origin, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return baseline(h.prefixes[prefix])
+}
+
+func baseline(records []*record) (Origin, bool) {
+	var best Origin
+	for i, r := range records {
+		if o := r.origin(); i == 0 || moreStable(o, best) {
+			best = o
+		}
+	}
+	return best, len(records) > 0
+}
+
+// moreStable reports whether a is a more stable origin than b.
+func moreStable(a, b Origin) bool {
+	if a.Peers != b.Peers {
+		return a.Peers > b.Peers
+	}
+	return a.FirstSeen.Before(b.FirstSeen)
+}
+
+// Walk calls fn with the baseline of every prefix, in no particular order,
+// until fn returns false. The history must not be modified from fn.
+func (h *History) Walk(fn func(prefix netip.Prefix, o Origin) bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for prefix, records := range h.prefixes {
+		if o, ok := baseline(records); ok && !fn(prefix, o) {
+			return
+		}
+	}
+}
+
+// Len returns the number of prefixes with a history.
+func (h *History) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.prefixes)
+}
+
+// Evictions returns the number of prefixes evicted to make room for others.
+func (h *History) Evictions() uint64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.evictions
+}
+
+func peerHash(peer string) uint32 {
+	f := fnv.New32a()
+	f.Write([]byte(peer))
+	return f.Sum32()
+}
@@ -0,0 +1,197 @@
+package baseline
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/bgp"
+	"github.com/hervehildenbrand/bgp-radar/pkg/mrt"
+)
+
+var testPrefix = netip.MustParsePrefix("192.0.2.0/24")
+
+func TestHistory_Baseline(t *testing.T) {
+	h := NewHistory()
+	if _, ok := h.Baseline(testPrefix); ok {
+		t.Fatal("Expected no baseline for an unseen prefix")
+	}
+
+	start := time.Unix(1700000000, 0)
+	h.Observe(testPrefix, 64666, "rrc00:6939", start)
+	h.Observe(testPrefix, 64500, "rrc00:3356", start.Add(time.Minute))
+	h.Observe(testPrefix, 64500, "rrc00:3356", start.Add(2*time.Minute))
+
+	// Tied on peers: the first seen wins
+	if o, _ := h.Baseline(testPrefix); o.ASN != 64666 {
+		t.Errorf("Expected baseline AS64666 on a tie, got AS%d", o.ASN)
+	}
+
+	h.Observe(testPrefix, 64500, "rrc00:174", start.Add(3*time.Minute))
+	o, _ := h.Baseline(testPrefix)
+	if o.ASN != 64500 || o.Peers != 2 {
+		t.Errorf("Expected baseline AS64500 seen by 2 peers, got %+v", o)
+	}
+	if !o.FirstSeen.Equal(start.Add(time.Minute)) || !o.LastSeen.Equal(start.Add(3*time.Minute)) {
+		t.Errorf("Unexpected first/last seen: %v %v", o.FirstSeen, o.LastSeen)
+	}
+
+	origins := h.Origins(testPrefix)
+	if len(origins) != 2 || origins[0].ASN != 64500 || origins[1].ASN != 64666 {
+		t.Errorf("Expected origins AS64500, AS64666, got %+v", origins)
+	}
+	if h.Len() != 1 {
+		t.Errorf("Expected 1 prefix, got %d", h.Len())
+	}
+}
+
+func TestHistory_MaxPeers(t *testing.T) {
+	h := NewHistory()
+	for i := 0; i < MaxPeers+10; i++ {
+		h.Observe(testPrefix, 64500, "rrc00:"+string(rune('a'+i)), time.Now())
+	}
+	if o, _ := h.Baseline(testPrefix); o.Peers != MaxPeers {
+		t.Errorf("Expected peers capped at %d, got %d", MaxPeers, o.Peers)
+	}
+}
+
+func TestHistory_BoundedUnderChurn(t *testing.T) {
+	h := NewBoundedHistory(1000)
+	var evicted int
+	h.OnEvict(func(netip.Prefix) { evicted++ })
+
+	// Stable prefixes seen by many peers outlive the churn
+	start := time.Unix(1700000000, 0)
+	for i := 0; i < 10; i++ {
+		h.Seed(netip.PrefixFrom(netip.AddrFrom4([4]byte{192, 0, byte(i), 0}), 24), Origin{ASN: 64500, Peers: 20, FirstSeen: start})
+	}
+	churn := func(from, to int) {
+		for i := from; i < to; i++ {
+			prefix := netip.PrefixFrom(netip.AddrFrom4([4]byte{10, byte(i >> 16), byte(i >> 8), byte(i)}), 32)
+			h.Observe(prefix, uint32(64600+i%MaxOrigins), "rrc00:6939", start.Add(time.Duration(i)*time.Second))
+		}
+	}
+	heap := func() uint64 {
+		var m runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&m)
+		return m.HeapAlloc
+	}
+
+	churn(0, 10000)
+	before := heap()
+	churn(10000, 110000)
+	after := heap()
+
+	if h.Len() != 1000 {
+		t.Errorf("Expected the history capped at 1000 prefixes, got %d", h.Len())
+	}
+	if evicted != 110000+10-1000 || h.Evictions() != uint64(evicted) {
+		t.Errorf("Expected %d evictions reported, got %d (%d counted)", 110000+10-1000, evicted, h.Evictions())
+	}
+	for i := 0; i < 10; i++ {
+		if _, ok := h.Baseline(netip.PrefixFrom(netip.AddrFrom4([4]byte{192, 0, byte(i), 0}), 24)); !ok {
+			t.Errorf("Expected stable prefix %d kept", i)
+		}
+	}
+	// 100k more prefixes would take over 10 MB if kept
+	if after > before+1<<20 {
+		t.Errorf("Expected memory flat under churn, heap grew from %d to %d bytes", before, after)
+	}
+}
+
+func TestHistory_MaxOrigins(t *testing.T) {
+	h := NewHistory()
+	h.Seed(testPrefix, Origin{ASN: 64500, Peers: 10})
+	for i := 0; i < 100; i++ {
+		h.Observe(testPrefix, uint32(64600+i), "rrc00:6939", time.Now())
+	}
+	origins := h.Origins(testPrefix)
+	if len(origins) != MaxOrigins || origins[0].ASN != 64500 {
+		t.Errorf("Expected %d origins led by AS64500, got %+v", MaxOrigins, origins)
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	h := NewHistory()
+	n, err := ParseCSV(h, strings.NewReader("prefix,origin,peers\n# comment\n192.0.2.0/24,AS64500,40\n2001:db8::/32,64501\n"))
+	if err != nil {
+		t.Fatalf("ParseCSV failed: %v", err)
+	}
+	if n != 2 || h.Len() != 2 {
+		t.Fatalf("Expected 2 entries, got %d (%d prefixes)", n, h.Len())
+	}
+	if o, _ := h.Baseline(testPrefix); o.ASN != 64500 || o.Peers != 40 {
+		t.Errorf("Unexpected baseline: %+v", o)
+	}
+	if o, _ := h.Baseline(netip.MustParsePrefix("2001:db8::/32")); o.ASN != 64501 || o.Peers != 1 {
+		t.Errorf("Unexpected IPv6 baseline: %+v", o)
+	}
+
+	for _, data := range []string{"192.0.2.0/24", "192.0.2.0/33,64500", "192.0.2.0/24,ASX", "192.0.2.0/24,64500,x"} {
+		if _, err := ParseCSV(NewHistory(), strings.NewReader(data)); err == nil {
+			t.Errorf("Expected ParseCSV(%q) to fail", data)
+		}
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	h := NewHistory()
+	n, err := ParseJSON(h, strings.NewReader(`[
+		{"prefix": "192.0.2.0/24", "origin": 64500, "peers": 40, "first_seen": "2023-01-01T00:00:00Z"},
+		{"prefix": "192.0.2.0/24", "origin": 64666, "peers": 2}
+	]`))
+	if err != nil {
+		t.Fatalf("ParseJSON failed: %v", err)
+	}
+	o, _ := h.Baseline(testPrefix)
+	if n != 2 || o.ASN != 64500 || o.FirstSeen.Year() != 2023 {
+		t.Errorf("Unexpected baseline: %+v", o)
+	}
+	if _, err := ParseJSON(NewHistory(), strings.NewReader(`[{"prefix": "192.0.2.0/24"}]`)); err == nil {
+		t.Error("Expected an entry without origin to fail")
+	}
+}
+
+func TestLoad_RIB(t *testing.T) {
+	u16 := func(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+	u32 := func(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+	record := func(subtype uint16, body []byte) []byte {
+		return bytes.Join([][]byte{u32(1700000000), u16(mrt.TypeTableDumpV2), u16(subtype), u32(uint32(len(body))), body}, nil)
+	}
+	entry := func(index uint16, asns ...uint32) []byte {
+		path := []byte{bgp.SegmentASSequence, byte(len(asns))}
+		for _, asn := range asns {
+			path = append(path, u32(asn)...)
+		}
+		attrs := append([]byte{0x40, bgp.AttrASPath, byte(len(path))}, path...)
+		return bytes.Join([][]byte{u16(index), u32(1690000000), u16(uint16(len(attrs))), attrs}, nil)
+	}
+
+	peers := bytes.Join([][]byte{
+		u32(0), u16(0), u16(2),
+		{0x02}, u32(1), {192, 0, 2, 1}, u32(3356),
+		{0x02}, u32(2), {192, 0, 2, 2}, u32(6939),
+	}, nil)
+	rib := bytes.Join([][]byte{u32(0), {24, 192, 0, 2}, u16(2), entry(0, 3356, 64500), entry(1, 6939, 64500)}, nil)
+	path := filepath.Join(t.TempDir(), "bview")
+	if err := os.WriteFile(path, append(record(1, peers), record(2, rib)...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewHistory()
+	n, err := Load(h, path, "rrc00")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	o, _ := h.Baseline(testPrefix)
+	if n != 2 || o.ASN != 64500 || o.Peers != 2 || !o.FirstSeen.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Expected AS64500 seen by 2 peers at dump time, got %d entries, %+v", n, o)
+	}
+}
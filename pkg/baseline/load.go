@@ -0,0 +1,160 @@
+package baseline
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/mrt"
+	"github.com/hervehildenbrand/bgp-radar/pkg/trie"
+)
+
+// Entry is a prefix origin in a JSON dump.
+type Entry struct {
+	Prefix    string    `json:"prefix"`
+	Origin    uint32    `json:"origin"`
+	Peers     int       `json:"peers,omitempty"`
+	FirstSeen time.Time `json:"first_seen,omitempty"`
+	LastSeen  time.Time `json:"last_seen,omitempty"`
+}
+
+// Load seeds h from a file, by extension (before any .gz or .bz2):
+//
+//   - .csv: prefix-origin dump, see ParseCSV
+//   - .json: prefix-origin dump, see ParseJSON
+//   - anything else: MRT TABLE_DUMP_V2 RIB, e.g. a RIS bview or RouteViews
+//     rib file, whose peers are labeled with the given collector
+//
+// It returns the number of prefix origins read.
+func Load(h *History, path, collector string) (int, error) {
+	name := strings.TrimSuffix(strings.TrimSuffix(path, ".gz"), ".bz2")
+	if !strings.HasSuffix(name, ".csv") && !strings.HasSuffix(name, ".json") {
+		return loadRIB(h, path, collector)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	switch {
+	case strings.HasSuffix(path, ".bz2"):
+		r = bzip2.NewReader(f)
+	case strings.HasSuffix(path, ".gz"):
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return 0, fmt.Errorf("open gzip: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	if strings.HasSuffix(name, ".json") {
+		return ParseJSON(h, r)
+	}
+	return ParseCSV(h, r)
+}
+
+// loadRIB seeds h with the routes of an MRT RIB dump, each RIB entry
+// counting as an observation by its peer at the dump time.
+func loadRIB(h *History, path, collector string) (int, error) {
+	r, err := mrt.Open(path, collector)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	n := 0
+	for {
+		update, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if !update.Announcement || update.OriginASN == 0 {
+			continue
+		}
+		prefix, err := trie.ParsePrefix(update.Prefix)
+		if err != nil {
+			continue
+		}
+		peer := update.Collector + ":" + strconv.FormatUint(uint64(update.PeerASN), 10)
+		h.Observe(prefix, update.OriginASN, peer, update.Timestamp)
+		n++
+	}
+}
+
+// ParseCSV seeds h from lines of
+//
+//	prefix,origin[,peers]
+//
+// where origin is 13335 or AS13335. Comment lines start with '#', and a
+// header line is skipped. Malformed lines are errors.
+func ParseCSV(h *History, r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	n, line := 0, 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+
+		fields := strings.Split(text, ",")
+		if line == 1 && strings.EqualFold(strings.TrimSpace(fields[0]), "prefix") {
+			continue
+		}
+		if len(fields) < 2 {
+			return n, fmt.Errorf("line %d: expected prefix,origin", line)
+		}
+		prefix, err := trie.ParsePrefix(strings.TrimSpace(fields[0]))
+		if err != nil {
+			return n, fmt.Errorf("line %d: %w", line, err)
+		}
+		origin, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(fields[1])), "AS"), 10, 32)
+		if err != nil || origin == 0 {
+			return n, fmt.Errorf("line %d: invalid origin %q", line, fields[1])
+		}
+		o := Origin{ASN: uint32(origin)}
+		if len(fields) > 2 {
+			if o.Peers, err = strconv.Atoi(strings.TrimSpace(fields[2])); err != nil {
+				return n, fmt.Errorf("line %d: invalid peer count %q", line, fields[2])
+			}
+		}
+		h.Seed(prefix, o)
+		n++
+	}
+	return n, scanner.Err()
+}
+
+// ParseJSON seeds h from a JSON array of Entry objects, e.g.
+//
+//	[{"prefix": "1.1.1.0/24", "origin": 13335, "peers": 300}]
+func ParseJSON(h *History, r io.Reader) (int, error) {
+	var entries []Entry
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return 0, err
+	}
+	for i, e := range entries {
+		prefix, err := trie.ParsePrefix(e.Prefix)
+		if err != nil {
+			return i, fmt.Errorf("entry %d: %w", i+1, err)
+		}
+		if e.Origin == 0 {
+			return i, fmt.Errorf("entry %d: missing origin", i+1)
+		}
+		h.Seed(prefix, Origin{ASN: e.Origin, Peers: e.Peers, FirstSeen: e.FirstSeen, LastSeen: e.LastSeen})
+	}
+	return len(entries), nil
+}
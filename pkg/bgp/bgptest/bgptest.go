@@ -0,0 +1,63 @@
+// Package bgptest builds raw BGP messages and path attributes for the tests
+// of the BGP decoder and of the sources carrying BGP messages.
+package bgptest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/bgp"
+)
+
+// U16 encodes v in network byte order.
+func U16(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+
+// U32 encodes v in network byte order.
+func U32(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+
+// Concat joins byte slices.
+func Concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+// NLRI encodes prefixes as BGP NLRI.
+func NLRI(prefixes ...string) []byte {
+	var b []byte
+	for _, s := range prefixes {
+		p := netip.MustParsePrefix(s)
+		addr := p.Addr().AsSlice()
+		b = append(b, byte(p.Bits()))
+		b = append(b, addr[:(p.Bits()+7)/8]...)
+	}
+	return b
+}
+
+// Attr encodes a transitive path attribute, with an extended length if
+// value needs one.
+func Attr(code byte, value []byte) []byte {
+	if len(value) > 255 {
+		return Concat([]byte{0x50, code}, U16(uint16(len(value))), value)
+	}
+	return Concat([]byte{0x40, code, byte(len(value))}, value)
+}
+
+// ASPath encodes an AS_SEQUENCE segment of 4-byte ASNs, or of 2-byte ASNs
+// unless as4.
+func ASPath(as4 bool, asns ...uint32) []byte {
+	b := []byte{bgp.SegmentASSequence, byte(len(asns))}
+	for _, asn := range asns {
+		if as4 {
+			b = append(b, U32(asn)...)
+		} else {
+			b = append(b, U16(uint16(asn))...)
+		}
+	}
+	return b
+}
+
+// Update encodes a BGP UPDATE message, header included.
+func Update(withdrawn, attrs, announced []byte) []byte {
+	body := Concat(U16(uint16(len(withdrawn))), withdrawn, U16(uint16(len(attrs))), attrs, announced)
+	return Concat(bytes.Repeat([]byte{0xff}, 16), U16(uint16(bgp.HeaderLen+len(body))), []byte{bgp.MessageUpdate}, body)
+}
@@ -0,0 +1,133 @@
+package bgp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+// ErrTruncated is returned when a field extends past the end of its data.
+var ErrTruncated = errors.New("truncated data")
+
+// Cursor reads big-endian fields from BGP, MRT and BMP encodings. After the
+// first error every read returns zero values and Err returns the error, so
+// fields can be read in sequence and checked once.
+type Cursor struct {
+	b   []byte
+	err error
+}
+
+// NewCursor creates a cursor over b.
+func NewCursor(b []byte) *Cursor {
+	return &Cursor{b: b}
+}
+
+// Err returns the first read error.
+func (c *Cursor) Err() error {
+	return c.err
+}
+
+// Len returns the number of unread bytes.
+func (c *Cursor) Len() int {
+	return len(c.b)
+}
+
+// Rest returns the unread bytes and consumes them.
+func (c *Cursor) Rest() []byte {
+	b := c.b
+	c.b = nil
+	return b
+}
+
+// Bytes reads n bytes.
+func (c *Cursor) Bytes(n int) []byte {
+	if c.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(c.b) {
+		c.err = ErrTruncated
+		c.b = nil
+		return nil
+	}
+	v := c.b[:n]
+	c.b = c.b[n:]
+	return v
+}
+
+// Skip skips n bytes.
+func (c *Cursor) Skip(n int) {
+	c.Bytes(n)
+}
+
+// U8 reads a byte.
+func (c *Cursor) U8() uint8 {
+	if b := c.Bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+// U16 reads a 2-byte integer.
+func (c *Cursor) U16() uint16 {
+	if b := c.Bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+// U32 reads a 4-byte integer.
+func (c *Cursor) U32() uint32 {
+	if b := c.Bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+// Addr reads a 4-byte IPv4 or 16-byte IPv6 address.
+func (c *Cursor) Addr(ipv6 bool) netip.Addr {
+	if ipv6 {
+		if b := c.Bytes(16); b != nil {
+			return netip.AddrFrom16([16]byte(b))
+		}
+		return netip.Addr{}
+	}
+	if b := c.Bytes(4); b != nil {
+		return netip.AddrFrom4([4]byte(b))
+	}
+	return netip.Addr{}
+}
+
+// Prefix reads an NLRI-encoded prefix: a length in bits followed by the
+// minimum number of address bytes.
+func (c *Cursor) Prefix(afi int) netip.Prefix {
+	bits := int(c.U8())
+	if c.err != nil {
+		return netip.Prefix{}
+	}
+
+	var size int
+	switch afi {
+	case AFIIPv4:
+		size = 4
+	case AFIIPv6:
+		size = 16
+	default:
+		c.err = fmt.Errorf("unknown AFI %d", afi)
+		return netip.Prefix{}
+	}
+	if bits > size*8 {
+		c.err = fmt.Errorf("invalid prefix length %d", bits)
+		return netip.Prefix{}
+	}
+
+	var addr [16]byte
+	copy(addr[:], c.Bytes((bits+7)/8))
+	if c.err != nil {
+		return netip.Prefix{}
+	}
+	if size == 4 {
+		return netip.PrefixFrom(netip.AddrFrom4([4]byte(addr[:4])), bits).Masked()
+	}
+	return netip.PrefixFrom(netip.AddrFrom16(addr), bits).Masked()
+}
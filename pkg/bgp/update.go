@@ -0,0 +1,476 @@
+// Package bgp decodes BGP UPDATE messages (RFC 4271) into per-prefix
+// updates, for sources carrying raw BGP messages such as MRT files and BMP.
+package bgp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+// Address families
+const (
+	AFIIPv4 = 1
+	AFIIPv6 = 2
+
+	SAFIUnicast = 1
+)
+
+// BGP message types and path attributes (RFC 4271, RFC 4760, RFC 6793,
+// RFC 4360, RFC 8092)
+const (
+	markerLen     = 16
+	HeaderLen     = 19
+	MessageOpen   = 1
+	MessageUpdate = 2
+
+	AttrOrigin           = 1
+	AttrASPath           = 2
+	AttrNextHop          = 3
+	AttrMED              = 4
+	AttrLocalPref        = 5
+	AttrAggregator       = 7
+	AttrCommunities      = 8
+	AttrMPReachNLRI      = 14
+	AttrMPUnreachNLRI    = 15
+	AttrExtCommunities   = 16
+	AttrAS4Path          = 17
+	AttrAS4Aggregator    = 18
+	AttrLargeCommunities = 32
+
+	attrFlagExtendedLength = 0x10
+
+	SegmentASSet      = 1
+	SegmentASSequence = 2
+
+	// ASTrans stands in for 4-byte ASNs in 2-byte AS paths (RFC 6793)
+	ASTrans = 23456
+)
+
+// Decoder turns BGP messages received from one peer into updates.
+type Decoder struct {
+	Timestamp time.Time
+	PeerASN   uint32
+	Collector string
+	AS4       bool // AS_PATH carries 4-byte ASNs
+	AddPath   bool // NLRI carry path identifiers (RFC 7911)
+}
+
+// attributes are the path attributes of an UPDATE relevant to detection.
+type attributes struct {
+	asPath        []models.ASPathSegment
+	as4Path       []models.ASPathSegment
+	origin        string
+	med           *uint32
+	localPref     *uint32
+	aggregator    *models.Aggregator
+	as4Aggregator *models.Aggregator
+	nextHop       string
+	communities   []string
+	large         []models.LargeCommunity
+	extended      []models.ExtendedCommunity
+
+	mpNextHop string
+	mpReach   []netip.Prefix
+	mpUnreach []netip.Prefix
+}
+
+// ParseMessage parses a BGP message, header included. An UPDATE yields one
+// update per announced or withdrawn unicast prefix, announcements first;
+// other message types yield nothing.
+func (d *Decoder) ParseMessage(b []byte) ([]models.BGPUpdate, error) {
+	if len(b) < HeaderLen {
+		return nil, ErrTruncated
+	}
+	length := int(binary.BigEndian.Uint16(b[markerLen:]))
+	if length < HeaderLen || length > len(b) {
+		return nil, fmt.Errorf("invalid BGP message length %d", length)
+	}
+	if b[markerLen+2] != MessageUpdate {
+		return nil, nil // OPEN, KEEPALIVE, NOTIFICATION
+	}
+
+	c := NewCursor(b[HeaderLen:length])
+	withdrawn := c.Bytes(int(c.U16()))
+	attrs := c.Bytes(int(c.U16()))
+	nlri := c.Rest()
+	if c.Err() != nil {
+		return nil, c.Err()
+	}
+
+	var a attributes
+	if err := d.parseAttributes(attrs, &a, false); err != nil {
+		return nil, err
+	}
+	announced, err := d.parseNLRI(nlri, AFIIPv4)
+	if err != nil {
+		return nil, err
+	}
+	withdrawals, err := d.parseNLRI(withdrawn, AFIIPv4)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make([]models.BGPUpdate, 0, len(announced)+len(a.mpReach)+len(withdrawals)+len(a.mpUnreach))
+	for _, prefix := range announced {
+		updates = append(updates, d.announcement(prefix, &a, a.nextHop))
+	}
+	for _, prefix := range a.mpReach {
+		updates = append(updates, d.announcement(prefix, &a, a.mpNextHop))
+	}
+	for _, prefixes := range [][]netip.Prefix{withdrawals, a.mpUnreach} {
+		for _, prefix := range prefixes {
+			updates = append(updates, models.BGPUpdate{
+				Timestamp:    d.Timestamp,
+				PeerASN:      d.PeerASN,
+				Prefix:       prefix.String(),
+				Announcement: false,
+				Collector:    d.Collector,
+			})
+		}
+	}
+	return updates, nil
+}
+
+// ParseRIBEntry parses the path attributes of an MRT TABLE_DUMP_V2 RIB entry
+// into an announcement of prefix. RIB entries carry an abbreviated
+// MP_REACH_NLRI holding only the next hop (RFC 6396 section 4.3.4).
+func (d *Decoder) ParseRIBEntry(prefix netip.Prefix, attrs []byte) (models.BGPUpdate, error) {
+	var a attributes
+	if err := d.parseAttributes(attrs, &a, true); err != nil {
+		return models.BGPUpdate{}, err
+	}
+	nextHop := a.nextHop
+	if a.mpNextHop != "" {
+		nextHop = a.mpNextHop
+	}
+	return d.announcement(prefix, &a, nextHop), nil
+}
+
+// announcement builds the update announcing prefix with the given attributes.
+func (d *Decoder) announcement(prefix netip.Prefix, a *attributes, nextHop string) models.BGPUpdate {
+	segments := a.path()
+	path, origin := models.SequencePath(segments)
+	return models.BGPUpdate{
+		Timestamp:    d.Timestamp,
+		PeerASN:      d.PeerASN,
+		Prefix:       prefix.String(),
+		ASPath:       path,
+		OriginASN:    origin,
+		Communities:  a.communities,
+		NextHop:      nextHop,
+		Announcement: true,
+		Collector:    d.Collector,
+
+		LargeCommunities:    a.large,
+		ExtendedCommunities: a.extended,
+
+		ASPathSegments: segments,
+		Origin:         a.origin,
+		MED:            a.med,
+		LocalPref:      a.localPref,
+		Aggregator:     a.aggregatorAS4(),
+	}
+}
+
+// aggregatorAS4 returns the aggregator, taking its 4-byte ASN from
+// AS4_AGGREGATOR when a 2-byte speaker replaced it with AS_TRANS
+// (RFC 6793 section 4.2.3).
+func (a *attributes) aggregatorAS4() *models.Aggregator {
+	if a.aggregator != nil && a.aggregator.ASN == ASTrans && a.as4Aggregator != nil {
+		return a.as4Aggregator
+	}
+	return a.aggregator
+}
+
+// path returns the AS path, reconstructing 4-byte ASNs from AS4_PATH when
+// a 2-byte speaker replaced them with AS_TRANS (RFC 6793 section 4.2.3):
+// the leading ASNs of AS_PATH that AS4_PATH lacks are kept.
+func (a *attributes) path() []models.ASPathSegment {
+	n, n4 := pathLength(a.asPath), pathLength(a.as4Path)
+	if len(a.as4Path) == 0 || n4 > n || !containsASN(a.asPath, ASTrans) {
+		return a.asPath
+	}
+	path := make([]models.ASPathSegment, 0, len(a.asPath)+len(a.as4Path))
+	for keep := n - n4; keep > 0; {
+		s := a.asPath[len(path)]
+		if !s.Set && len(s.ASNs) > keep {
+			s.ASNs = s.ASNs[:keep]
+		}
+		path = append(path, s)
+		keep -= pathLength(path[len(path)-1:])
+	}
+	return append(path, a.as4Path...)
+}
+
+// pathLength returns the length of an AS path, counting each AS_SET as one
+// ASN (RFC 4271 section 9.1.2.2).
+func pathLength(path []models.ASPathSegment) int {
+	n := 0
+	for _, s := range path {
+		if s.Set {
+			n++
+		} else {
+			n += len(s.ASNs)
+		}
+	}
+	return n
+}
+
+func containsASN(path []models.ASPathSegment, asn uint32) bool {
+	for _, s := range path {
+		for _, a := range s.ASNs {
+			if a == asn {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseAttributes parses the path attributes of an UPDATE or RIB entry.
+func (d *Decoder) parseAttributes(b []byte, a *attributes, ribEntry bool) error {
+	c := NewCursor(b)
+	for c.Len() > 0 && c.Err() == nil {
+		flags := c.U8()
+		code := c.U8()
+		var length int
+		if flags&attrFlagExtendedLength != 0 {
+			length = int(c.U16())
+		} else {
+			length = int(c.U8())
+		}
+		value := c.Bytes(length)
+		if c.Err() != nil {
+			break
+		}
+
+		var err error
+		switch code {
+		case AttrASPath:
+			asSize := 2
+			if d.AS4 {
+				asSize = 4
+			}
+			a.asPath, err = parseASPath(value, asSize)
+		case AttrAS4Path:
+			if !d.AS4 {
+				a.as4Path, err = parseASPath(value, 4)
+			}
+		case AttrOrigin:
+			if len(value) == 1 && int(value[0]) < len(origins) {
+				a.origin = origins[value[0]]
+			}
+		case AttrMED:
+			a.med = parseUint32(value)
+		case AttrLocalPref:
+			a.localPref = parseUint32(value)
+		case AttrAggregator:
+			asSize := 2
+			if d.AS4 {
+				asSize = 4
+			}
+			a.aggregator = parseAggregator(value, asSize)
+		case AttrAS4Aggregator:
+			if !d.AS4 {
+				a.as4Aggregator = parseAggregator(value, 4)
+			}
+		case AttrNextHop:
+			if len(value) == 4 {
+				a.nextHop = netip.AddrFrom4([4]byte(value)).String()
+			}
+		case AttrCommunities:
+			a.communities = parseCommunities(value)
+		case AttrExtCommunities:
+			a.extended = parseExtendedCommunities(value)
+		case AttrLargeCommunities:
+			a.large = parseLargeCommunities(value)
+		case AttrMPReachNLRI:
+			if ribEntry {
+				v := NewCursor(value)
+				a.mpNextHop = parseNextHop(v.Bytes(int(v.U8())))
+				err = v.Err()
+			} else {
+				err = d.parseMPReach(value, a)
+			}
+		case AttrMPUnreachNLRI:
+			err = d.parseMPUnreach(value, a)
+		}
+		if err != nil {
+			return fmt.Errorf("attribute %d: %w", code, err)
+		}
+	}
+	return c.Err()
+}
+
+// origins are the values of the ORIGIN attribute, by code.
+var origins = []string{models.OriginIGP, models.OriginEGP, models.OriginIncomplete}
+
+// parseASPath parses the AS_SEQUENCE and AS_SET segments of an AS path,
+// merging consecutive sequences. Confederation segments are skipped.
+func parseASPath(b []byte, asSize int) ([]models.ASPathSegment, error) {
+	c := NewCursor(b)
+	var path []models.ASPathSegment
+	for c.Len() > 0 && c.Err() == nil {
+		segmentType := c.U8()
+		count := int(c.U8())
+		asns := make([]uint32, 0, count)
+		for i := 0; i < count; i++ {
+			if asSize == 4 {
+				asns = append(asns, c.U32())
+			} else {
+				asns = append(asns, uint32(c.U16()))
+			}
+		}
+		if c.Err() != nil || count == 0 {
+			continue
+		}
+		switch {
+		case segmentType == SegmentASSet:
+			path = append(path, models.ASPathSegment{Set: true, ASNs: asns})
+		case segmentType != SegmentASSequence:
+			// Confederation segment
+		case len(path) > 0 && !path[len(path)-1].Set:
+			path[len(path)-1].ASNs = append(path[len(path)-1].ASNs, asns...)
+		default:
+			path = append(path, models.ASPathSegment{ASNs: asns})
+		}
+	}
+	return path, c.Err()
+}
+
+// parseUint32 parses a 4-byte attribute such as MED or LOCAL_PREF.
+func parseUint32(b []byte) *uint32 {
+	if len(b) != 4 {
+		return nil
+	}
+	v := binary.BigEndian.Uint32(b)
+	return &v
+}
+
+// parseAggregator parses an AGGREGATOR or AS4_AGGREGATOR attribute: the ASN
+// then the IPv4 address of the aggregating router.
+func parseAggregator(b []byte, asSize int) *models.Aggregator {
+	if len(b) != asSize+4 {
+		return nil
+	}
+	var asn uint32
+	if asSize == 4 {
+		asn = binary.BigEndian.Uint32(b[0:4])
+	} else {
+		asn = uint32(binary.BigEndian.Uint16(b[0:2]))
+	}
+	return &models.Aggregator{ASN: asn, Address: netip.AddrFrom4([4]byte(b[asSize:])).String()}
+}
+
+// parseCommunities converts RFC 1997 communities to "ASN:value" format.
+func parseCommunities(b []byte) []string {
+	communities := make([]string, 0, len(b)/4)
+	for ; len(b) >= 4; b = b[4:] {
+		communities = append(communities,
+			strconv.FormatUint(uint64(binary.BigEndian.Uint16(b[0:2])), 10)+":"+
+				strconv.FormatUint(uint64(binary.BigEndian.Uint16(b[2:4])), 10))
+	}
+	return communities
+}
+
+// parseExtendedCommunities parses RFC 4360 extended communities.
+func parseExtendedCommunities(b []byte) []models.ExtendedCommunity {
+	communities := make([]models.ExtendedCommunity, 0, len(b)/8)
+	for ; len(b) >= 8; b = b[8:] {
+		communities = append(communities, models.ExtendedCommunity{
+			Type:    b[0],
+			Subtype: b[1],
+			Value:   [6]byte(b[2:8]),
+		})
+	}
+	return communities
+}
+
+// parseLargeCommunities parses RFC 8092 large communities.
+func parseLargeCommunities(b []byte) []models.LargeCommunity {
+	communities := make([]models.LargeCommunity, 0, len(b)/12)
+	for ; len(b) >= 12; b = b[12:] {
+		communities = append(communities, models.LargeCommunity{
+			GlobalAdmin: binary.BigEndian.Uint32(b[0:4]),
+			LocalData1:  binary.BigEndian.Uint32(b[4:8]),
+			LocalData2:  binary.BigEndian.Uint32(b[8:12]),
+		})
+	}
+	return communities
+}
+
+// parseMPReach parses MP_REACH_NLRI: AFI, SAFI, next hop, reserved, NLRI.
+func (d *Decoder) parseMPReach(b []byte, a *attributes) error {
+	c := NewCursor(b)
+	afi := int(c.U16())
+	safi := c.U8()
+	nextHop := c.Bytes(int(c.U8()))
+	c.Skip(1) // Reserved
+	if c.Err() != nil {
+		return c.Err()
+	}
+	if safi != SAFIUnicast {
+		return nil
+	}
+
+	prefixes, err := d.parseNLRI(c.Rest(), afi)
+	if err != nil {
+		return err
+	}
+	a.mpNextHop = parseNextHop(nextHop)
+	a.mpReach = append(a.mpReach, prefixes...)
+	return nil
+}
+
+// parseMPUnreach parses MP_UNREACH_NLRI: AFI, SAFI, withdrawn routes.
+func (d *Decoder) parseMPUnreach(b []byte, a *attributes) error {
+	c := NewCursor(b)
+	afi := int(c.U16())
+	safi := c.U8()
+	if c.Err() != nil {
+		return c.Err()
+	}
+	if safi != SAFIUnicast {
+		return nil
+	}
+
+	prefixes, err := d.parseNLRI(c.Rest(), afi)
+	if err != nil {
+		return err
+	}
+	a.mpUnreach = append(a.mpUnreach, prefixes...)
+	return nil
+}
+
+// parseNextHop returns the (global) next hop address. IPv6 next hops may be
+// followed by a link-local address.
+func parseNextHop(b []byte) string {
+	switch {
+	case len(b) == 4:
+		return netip.AddrFrom4([4]byte(b)).String()
+	case len(b) >= 16:
+		return netip.AddrFrom16([16]byte(b[:16])).String()
+	}
+	return ""
+}
+
+// parseNLRI parses a sequence of prefixes of the given address family.
+func (d *Decoder) parseNLRI(b []byte, afi int) ([]netip.Prefix, error) {
+	c := NewCursor(b)
+	var prefixes []netip.Prefix
+	for c.Len() > 0 && c.Err() == nil {
+		if d.AddPath {
+			c.Skip(4) // Path identifier
+		}
+		prefix := c.Prefix(afi)
+		if c.Err() == nil {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes, c.Err()
+}
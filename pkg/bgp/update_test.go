@@ -0,0 +1,174 @@
+package bgp_test
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/bgp"
+	"github.com/hervehildenbrand/bgp-radar/pkg/bgp/bgptest"
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+func TestDecoder_ParseMessage(t *testing.T) {
+	attrs := bgptest.Concat(
+		bgptest.Attr(bgp.AttrASPath, bgptest.ASPath(true, 3356, 4200000000)),
+		bgptest.Attr(bgp.AttrNextHop, []byte{192, 0, 2, 1}),
+		bgptest.Attr(bgp.AttrCommunities, bgptest.Concat(bgptest.U16(65535), bgptest.U16(666))),
+		bgptest.Attr(bgp.AttrLargeCommunities, bgptest.Concat(bgptest.U32(6939), bgptest.U32(666), bgptest.U32(0))),
+		bgptest.Attr(bgp.AttrExtCommunities, bgptest.Concat([]byte{0x00, 0x02}, bgptest.U16(64500), bgptest.U32(100))),
+	)
+	msg := bgptest.Update(bgptest.NLRI("198.51.100.0/24"), attrs, bgptest.NLRI("203.0.113.0/24", "203.0.113.128/25"))
+
+	d := bgp.Decoder{Timestamp: time.Unix(1700000000, 0), PeerASN: 3356, Collector: "rrc00", AS4: true}
+	updates, err := d.ParseMessage(msg)
+	if err != nil {
+		t.Fatalf("ParseMessage failed: %v", err)
+	}
+	if len(updates) != 3 {
+		t.Fatalf("Expected 3 updates, got %d", len(updates))
+	}
+
+	ann := updates[0]
+	if !ann.Announcement || ann.Prefix != "203.0.113.0/24" || updates[1].Prefix != "203.0.113.128/25" {
+		t.Errorf("Unexpected announcements: %+v", updates[:2])
+	}
+	if !reflect.DeepEqual(ann.ASPath, []uint32{3356, 4200000000}) || ann.OriginASN != 4200000000 {
+		t.Errorf("Unexpected AS path %v origin %d", ann.ASPath, ann.OriginASN)
+	}
+	if ann.PeerASN != 3356 || ann.Collector != "rrc00" || ann.NextHop != "192.0.2.1" {
+		t.Errorf("Unexpected peer %d collector %s next hop %s", ann.PeerASN, ann.Collector, ann.NextHop)
+	}
+	if !reflect.DeepEqual(ann.Communities, []string{"65535:666"}) {
+		t.Errorf("Unexpected communities %v", ann.Communities)
+	}
+	if !reflect.DeepEqual(ann.LargeCommunities, []models.LargeCommunity{{GlobalAdmin: 6939, LocalData1: 666}}) {
+		t.Errorf("Unexpected large communities %v", ann.LargeCommunities)
+	}
+	if len(ann.ExtendedCommunities) != 1 || ann.ExtendedCommunities[0].String() != "rt:64500:100" {
+		t.Errorf("Unexpected extended communities %v", ann.ExtendedCommunities)
+	}
+	if !ann.Timestamp.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Unexpected timestamp %v", ann.Timestamp)
+	}
+
+	if w := updates[2]; w.Announcement || w.Prefix != "198.51.100.0/24" || w.ASPath != nil {
+		t.Errorf("Unexpected withdrawal: %+v", w)
+	}
+}
+
+func TestDecoder_MultiprotocolIPv6(t *testing.T) {
+	nextHop := netip.MustParseAddr("2001:db8::1").AsSlice()
+	mpReach := bgptest.Concat(bgptest.U16(bgp.AFIIPv6), []byte{bgp.SAFIUnicast, 16}, nextHop, []byte{0}, bgptest.NLRI("2001:db8:100::/48"))
+	mpUnreach := bgptest.Concat(bgptest.U16(bgp.AFIIPv6), []byte{bgp.SAFIUnicast}, bgptest.NLRI("2001:db8:200::/48"))
+	attrs := bgptest.Concat(
+		bgptest.Attr(bgp.AttrASPath, bgptest.ASPath(true, 6939, 13335)),
+		bgptest.Attr(bgp.AttrMPReachNLRI, mpReach),
+		bgptest.Attr(bgp.AttrMPUnreachNLRI, mpUnreach),
+	)
+
+	d := bgp.Decoder{PeerASN: 6939, AS4: true}
+	updates, err := d.ParseMessage(bgptest.Update(nil, attrs, nil))
+	if err != nil {
+		t.Fatalf("ParseMessage failed: %v", err)
+	}
+	if len(updates) != 2 {
+		t.Fatalf("Expected 2 updates, got %d", len(updates))
+	}
+	if u := updates[0]; !u.Announcement || u.Prefix != "2001:db8:100::/48" || u.NextHop != "2001:db8::1" || u.OriginASN != 13335 {
+		t.Errorf("Unexpected announcement: %+v", u)
+	}
+	if u := updates[1]; u.Announcement || u.Prefix != "2001:db8:200::/48" {
+		t.Errorf("Unexpected withdrawal: %+v", u)
+	}
+}
+
+func TestDecoder_AS4Path(t *testing.T) {
+	attrs := bgptest.Concat(
+		bgptest.Attr(bgp.AttrASPath, bgptest.ASPath(false, 3356, bgp.ASTrans)),
+		bgptest.Attr(bgp.AttrAS4Path, bgptest.ASPath(true, 4200000000)),
+	)
+
+	d := bgp.Decoder{PeerASN: 3356}
+	updates, err := d.ParseMessage(bgptest.Update(nil, attrs, bgptest.NLRI("203.0.113.0/24")))
+	if err != nil {
+		t.Fatalf("ParseMessage failed: %v", err)
+	}
+	if len(updates) != 1 {
+		t.Fatalf("Expected 1 update, got %d", len(updates))
+	}
+	if !reflect.DeepEqual(updates[0].ASPath, []uint32{3356, 4200000000}) {
+		t.Errorf("Expected AS_TRANS replaced from AS4_PATH, got %v", updates[0].ASPath)
+	}
+}
+
+func TestDecoder_PathAttributes(t *testing.T) {
+	set := bgptest.Concat([]byte{bgp.SegmentASSet, 2}, bgptest.U16(64500), bgptest.U16(bgp.ASTrans))
+	attrs := bgptest.Concat(
+		bgptest.Attr(bgp.AttrOrigin, []byte{2}),
+		bgptest.Attr(bgp.AttrASPath, bgptest.Concat(bgptest.ASPath(false, 3356, 174), set)),
+		bgptest.Attr(bgp.AttrAS4Path, bgptest.Concat([]byte{bgp.SegmentASSet, 2}, bgptest.U32(64500), bgptest.U32(4200000000))),
+		bgptest.Attr(bgp.AttrMED, bgptest.U32(50)),
+		bgptest.Attr(bgp.AttrLocalPref, bgptest.U32(100)),
+		bgptest.Attr(bgp.AttrAggregator, bgptest.Concat(bgptest.U16(bgp.ASTrans), []byte{192, 0, 2, 1})),
+		bgptest.Attr(bgp.AttrAS4Aggregator, bgptest.Concat(bgptest.U32(4200000000), []byte{192, 0, 2, 1})),
+	)
+
+	d := bgp.Decoder{PeerASN: 3356}
+	updates, err := d.ParseMessage(bgptest.Update(nil, attrs, bgptest.NLRI("203.0.112.0/23")))
+	if err != nil {
+		t.Fatalf("ParseMessage failed: %v", err)
+	}
+	if len(updates) != 1 {
+		t.Fatalf("Expected 1 update, got %d", len(updates))
+	}
+	u := updates[0]
+	segments := []models.ASPathSegment{{ASNs: []uint32{3356, 174}}, {Set: true, ASNs: []uint32{64500, 4200000000}}}
+	if !reflect.DeepEqual(u.ASPathSegments, segments) {
+		t.Errorf("Expected AS path segments %v, got %v", segments, u.ASPathSegments)
+	}
+	if !reflect.DeepEqual(u.ASPath, []uint32{3356, 174}) || u.OriginASN != 0 || !u.AmbiguousOrigin() {
+		t.Errorf("Expected AS path [3356 174] with an ambiguous origin, got %v origin %d", u.ASPath, u.OriginASN)
+	}
+	if u.Origin != models.OriginIncomplete {
+		t.Errorf("Expected origin incomplete, got %q", u.Origin)
+	}
+	if u.MED == nil || *u.MED != 50 || u.LocalPref == nil || *u.LocalPref != 100 {
+		t.Errorf("Expected MED 50 and local pref 100, got %v %v", u.MED, u.LocalPref)
+	}
+	if u.Aggregator == nil || *u.Aggregator != (models.Aggregator{ASN: 4200000000, Address: "192.0.2.1"}) {
+		t.Errorf("Expected aggregator AS4200000000 192.0.2.1, got %v", u.Aggregator)
+	}
+}
+
+func TestDecoder_ParseRIBEntry(t *testing.T) {
+	// RIB entries carry an abbreviated MP_REACH_NLRI: the next hop only
+	nextHop := netip.MustParseAddr("2001:db8::1").AsSlice()
+	attrs := bgptest.Concat(
+		bgptest.Attr(bgp.AttrASPath, bgptest.ASPath(true, 6939, 13335)),
+		bgptest.Attr(bgp.AttrMPReachNLRI, bgptest.Concat([]byte{16}, nextHop)),
+	)
+
+	d := bgp.Decoder{PeerASN: 6939, AS4: true}
+	u, err := d.ParseRIBEntry(netip.MustParsePrefix("2606:4700::/32"), attrs)
+	if err != nil {
+		t.Fatalf("ParseRIBEntry failed: %v", err)
+	}
+	if !u.Announcement || u.Prefix != "2606:4700::/32" || u.OriginASN != 13335 || u.NextHop != "2001:db8::1" {
+		t.Errorf("Unexpected RIB entry: %+v", u)
+	}
+}
+
+func TestDecoder_Malformed(t *testing.T) {
+	var d bgp.Decoder
+	if _, err := d.ParseMessage([]byte{0xff, 0xff}); err == nil {
+		t.Error("Expected an error for a truncated message")
+	}
+
+	// Attribute length past the end of the attributes
+	msg := bgptest.Update(nil, []byte{0x40, bgp.AttrASPath, 10, bgp.SegmentASSequence}, bgptest.NLRI("203.0.113.0/24"))
+	if _, err := d.ParseMessage(msg); err == nil {
+		t.Error("Expected an error for a truncated attribute")
+	}
+}
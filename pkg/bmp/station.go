@@ -0,0 +1,497 @@
+// Package bmp implements a BGP Monitoring Protocol (RFC 7854) station that
+// accepts connections from routers and feeds the routes they report into
+// the update pipeline.
+package bmp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/bgp"
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+// BMP message types
+const (
+	TypeRouteMonitoring = 0
+	TypeStatistics      = 1
+	TypePeerDown        = 2
+	TypePeerUp          = 3
+	TypeInitiation      = 4
+	TypeTermination     = 5
+	TypeRouteMirroring  = 6
+)
+
+const (
+	version   = 3
+	headerLen = 6
+	// maxMessageLen bounds message allocation on corrupt input
+	maxMessageLen = 1 << 20
+
+	// Peer header flags
+	flagIPv6     = 0x80
+	flagLegacyAS = 0x20 // AS_PATH uses 2-byte ASNs
+
+	// Information TLV carrying the router name in Initiation messages
+	infoSysName = 2
+)
+
+// Station is a BMP monitoring station. Updates are labeled with the router
+// name (its sysName, or its address if none is sent) as the collector.
+type Station struct {
+	updates  chan models.BGPUpdate
+	listener net.Listener
+	done     chan struct{}
+	wg       sync.WaitGroup
+
+	mu      sync.Mutex
+	routers map[net.Conn]*router
+
+	// Stats
+	connections    uint64
+	messages       uint64
+	updatesParsed  uint64
+	updatesDropped uint64
+	parseErrors    uint64
+
+	running atomic.Bool
+}
+
+// router is the state of a connected router.
+type router struct {
+	name        string
+	address     string
+	connectedAt time.Time
+	peers       map[string]*peer // Keyed by distinguisher and address
+
+	messages      uint64
+	updatesParsed uint64
+	peerDowns     uint64
+}
+
+// peer is a BGP peer of a router, as reported in per-peer headers.
+type peer struct {
+	address string
+	asn     uint32
+	up      bool
+	stats   map[uint16]uint64 // Latest Statistics Report counters by type
+}
+
+// perPeerHeader is the header of messages about a router's BGP peer.
+type perPeerHeader struct {
+	flags         uint8
+	distinguisher uint64
+	address       string
+	asn           uint32
+	timestamp     time.Time
+}
+
+// Listen creates a station listening on addr, with the given update
+// channel buffer size. Routers are accepted once the station is started.
+func Listen(addr string, bufferSize int) (*Station, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+	return &Station{
+		updates:  make(chan models.BGPUpdate, bufferSize),
+		listener: listener,
+		done:     make(chan struct{}),
+		routers:  make(map[net.Conn]*router),
+	}, nil
+}
+
+// Updates returns the channel of BGP updates.
+func (s *Station) Updates() <-chan models.BGPUpdate {
+	return s.updates
+}
+
+// Start begins accepting router connections.
+func (s *Station) Start() {
+	if s.running.Swap(true) {
+		return
+	}
+	s.wg.Add(1)
+	go s.acceptLoop()
+	log.Printf("[bmp] Station listening on %s", s.listener.Addr())
+}
+
+// Stop closes the listener and every router connection, then the update
+// channel.
+func (s *Station) Stop() {
+	if !s.running.Swap(false) {
+		return
+	}
+	close(s.done)
+	s.listener.Close()
+
+	s.mu.Lock()
+	for conn := range s.routers {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	close(s.updates)
+	log.Printf("[bmp] Station stopped")
+}
+
+// Addr returns the listening address.
+func (s *Station) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Stats returns station statistics, including each connected router and
+// its peers.
+func (s *Station) Stats() map[string]interface{} {
+	s.mu.Lock()
+	routers := make([]map[string]interface{}, 0, len(s.routers))
+	for _, r := range s.routers {
+		peers := make([]map[string]interface{}, 0, len(r.peers))
+		peersUp := 0
+		for _, p := range r.peers {
+			if p.up {
+				peersUp++
+			}
+			stats := make(map[string]uint64, len(p.stats))
+			for statType, v := range p.stats {
+				stats[statName(statType)] = v
+			}
+			peers = append(peers, map[string]interface{}{
+				"address": p.address,
+				"asn":     p.asn,
+				"up":      p.up,
+				"stats":   stats,
+			})
+		}
+		sort.Slice(peers, func(i, j int) bool {
+			return peers[i]["address"].(string) < peers[j]["address"].(string)
+		})
+		routers = append(routers, map[string]interface{}{
+			"router":            r.name,
+			"address":           r.address,
+			"connected_at":      r.connectedAt.UTC().Format(time.RFC3339),
+			"messages_received": r.messages,
+			"updates_parsed":    r.updatesParsed,
+			"peers_up":          peersUp,
+			"peer_downs":        r.peerDowns,
+			"peers":             peers,
+		})
+	}
+	s.mu.Unlock()
+
+	sort.Slice(routers, func(i, j int) bool {
+		return routers[i]["router"].(string) < routers[j]["router"].(string)
+	})
+	return map[string]interface{}{
+		"listen":            s.listener.Addr().String(),
+		"running":           s.running.Load(),
+		"routers":           routers,
+		"connections":       atomic.LoadUint64(&s.connections),
+		"messages_received": atomic.LoadUint64(&s.messages),
+		"updates_parsed":    atomic.LoadUint64(&s.updatesParsed),
+		"updates_dropped":   atomic.LoadUint64(&s.updatesDropped),
+		"parse_errors":      atomic.LoadUint64(&s.parseErrors),
+		"channel_len":       len(s.updates),
+		"channel_cap":       cap(s.updates),
+	}
+}
+
+func (s *Station) acceptLoop() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+			log.Printf("[bmp] Accept failed: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		atomic.AddUint64(&s.connections, 1)
+
+		host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+		r := &router{
+			name:        host,
+			address:     host,
+			connectedAt: time.Now(),
+			peers:       make(map[string]*peer),
+		}
+		s.mu.Lock()
+		select {
+		case <-s.done:
+			// Stopped while accepting
+			s.mu.Unlock()
+			conn.Close()
+			return
+		default:
+		}
+		s.routers[conn] = r
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.serve(conn, r)
+	}
+}
+
+// serve reads messages from a router until it disconnects.
+func (s *Station) serve(conn net.Conn, r *router) {
+	defer s.wg.Done()
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.routers, conn)
+		s.mu.Unlock()
+	}()
+	log.Printf("[bmp] Router connected from %s", r.address)
+
+	reader := bufio.NewReaderSize(conn, 1<<16)
+	for {
+		msgType, body, err := readMessage(reader)
+		if err != nil {
+			select {
+			case <-s.done:
+			default:
+				if errors.Is(err, io.EOF) {
+					log.Printf("[bmp] Router %s disconnected", s.routerName(r))
+				} else {
+					log.Printf("[bmp] Router %s: %v", s.routerName(r), err)
+				}
+			}
+			return
+		}
+		atomic.AddUint64(&s.messages, 1)
+		s.mu.Lock()
+		r.messages++
+		s.mu.Unlock()
+
+		if msgType == TypeTermination {
+			log.Printf("[bmp] Router %s terminated the session", s.routerName(r))
+			return
+		}
+		if err := s.handle(r, msgType, body); err != nil {
+			if atomic.AddUint64(&s.parseErrors, 1) <= 10 {
+				log.Printf("[bmp] Router %s: message type %d: %v", s.routerName(r), msgType, err)
+			}
+		}
+	}
+}
+
+// readMessage reads a BMP message: version, length, type and body.
+func readMessage(r io.Reader) (uint8, []byte, error) {
+	var header [headerLen]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	if header[0] != version {
+		return 0, nil, fmt.Errorf("unsupported BMP version %d", header[0])
+	}
+	length := binary.BigEndian.Uint32(header[1:5])
+	if length < headerLen || length > maxMessageLen {
+		return 0, nil, fmt.Errorf("invalid message length %d", length)
+	}
+
+	body := make([]byte, length-headerLen)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, fmt.Errorf("read message: %w", io.ErrUnexpectedEOF)
+	}
+	return header[5], body, nil
+}
+
+// handle processes a message from a router.
+func (s *Station) handle(r *router, msgType uint8, body []byte) error {
+	if msgType == TypeInitiation {
+		return s.handleInitiation(r, body)
+	}
+	if msgType > TypeRouteMirroring {
+		return fmt.Errorf("unknown message type")
+	}
+
+	c := bgp.NewCursor(body)
+	h := parsePerPeerHeader(c)
+	if c.Err() != nil {
+		return c.Err()
+	}
+
+	switch msgType {
+	case TypeRouteMonitoring:
+		return s.handleRouteMonitoring(r, h, c.Rest())
+	case TypePeerUp:
+		s.updatePeer(r, h, func(p *peer) { p.up = true })
+		log.Printf("[bmp] Router %s: peer %s AS%d up", s.routerName(r), h.address, h.asn)
+	case TypePeerDown:
+		s.updatePeer(r, h, func(p *peer) { p.up = false })
+		s.mu.Lock()
+		r.peerDowns++
+		s.mu.Unlock()
+		log.Printf("[bmp] Router %s: peer %s AS%d down", s.routerName(r), h.address, h.asn)
+	case TypeStatistics:
+		return s.handleStatistics(r, h, c)
+	}
+	return nil
+}
+
+// handleInitiation records the router name from the sysName TLV.
+func (s *Station) handleInitiation(r *router, body []byte) error {
+	c := bgp.NewCursor(body)
+	for c.Len() > 0 {
+		infoType := c.U16()
+		value := c.Bytes(int(c.U16()))
+		if c.Err() != nil {
+			return c.Err()
+		}
+		if infoType == infoSysName && len(value) > 0 {
+			s.mu.Lock()
+			r.name = string(value)
+			s.mu.Unlock()
+			log.Printf("[bmp] Router %s identified as %s", r.address, value)
+		}
+	}
+	return nil
+}
+
+// handleRouteMonitoring decodes the BGP UPDATE a router received from a peer.
+func (s *Station) handleRouteMonitoring(r *router, h perPeerHeader, msg []byte) error {
+	timestamp := h.timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	decoder := bgp.Decoder{
+		Timestamp: timestamp,
+		PeerASN:   h.asn,
+		Collector: s.routerName(r),
+		AS4:       h.flags&flagLegacyAS == 0,
+	}
+	updates, err := decoder.ParseMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	r.updatesParsed += uint64(len(updates))
+	s.mu.Unlock()
+	for _, update := range updates {
+		parsed := atomic.AddUint64(&s.updatesParsed, 1)
+		// Non-blocking send to channel
+		select {
+		case s.updates <- update:
+		default:
+			if atomic.AddUint64(&s.updatesDropped, 1)%10000 == 1 {
+				log.Printf("[bmp] Update channel full, dropping updates (parsed=%d)", parsed)
+			}
+		}
+	}
+	return nil
+}
+
+// handleStatistics records the counters of a Statistics Report.
+func (s *Station) handleStatistics(r *router, h perPeerHeader, c *bgp.Cursor) error {
+	count := int(c.U32())
+	stats := make(map[uint16]uint64, count)
+	for i := 0; i < count; i++ {
+		statType := c.U16()
+		value := c.Bytes(int(c.U16()))
+		if c.Err() != nil {
+			return c.Err()
+		}
+		switch len(value) {
+		case 4:
+			stats[statType] = uint64(binary.BigEndian.Uint32(value))
+		case 8:
+			stats[statType] = binary.BigEndian.Uint64(value)
+		}
+	}
+	s.updatePeer(r, h, func(p *peer) {
+		for statType, v := range stats {
+			p.stats[statType] = v
+		}
+	})
+	return nil
+}
+
+// updatePeer applies fn to the peer described by h.
+func (s *Station) updatePeer(r *router, h perPeerHeader, fn func(p *peer)) {
+	key := fmt.Sprintf("%d/%s", h.distinguisher, h.address)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := r.peers[key]
+	if !ok {
+		p = &peer{address: h.address, stats: make(map[uint16]uint64)}
+		r.peers[key] = p
+	}
+	p.asn = h.asn
+	fn(p)
+}
+
+func (s *Station) routerName(r *router) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return r.name
+}
+
+// parsePerPeerHeader reads the per-peer header: type, flags, distinguisher,
+// address, AS, BGP ID and timestamp. Peer types are not distinguished.
+func parsePerPeerHeader(c *bgp.Cursor) perPeerHeader {
+	var h perPeerHeader
+	c.Skip(1) // Peer type
+	h.flags = c.U8()
+	h.distinguisher = uint64(c.U32())<<32 | uint64(c.U32())
+	address := c.Bytes(16)
+	h.asn = c.U32()
+	c.Skip(4) // BGP ID
+	seconds := c.U32()
+	micros := c.U32()
+	if c.Err() != nil {
+		return h
+	}
+
+	if h.flags&flagIPv6 != 0 {
+		h.address = bgp.NewCursor(address).Addr(true).String()
+	} else {
+		// IPv4 addresses are in the last 4 bytes
+		h.address = bgp.NewCursor(address[12:]).Addr(false).String()
+	}
+	if seconds != 0 {
+		h.timestamp = time.Unix(int64(seconds), int64(micros)*int64(time.Microsecond))
+	}
+	return h
+}
+
+// statName names the Statistics Report counter types of RFC 7854.
+func statName(statType uint16) string {
+	switch statType {
+	case 0:
+		return "rejected_prefixes"
+	case 1:
+		return "duplicate_advertisements"
+	case 2:
+		return "duplicate_withdraws"
+	case 3:
+		return "invalidated_cluster_list_loop"
+	case 4:
+		return "invalidated_as_path_loop"
+	case 5:
+		return "invalidated_originator_id"
+	case 6:
+		return "invalidated_as_confed_loop"
+	case 7:
+		return "adj_rib_in_routes"
+	case 8:
+		return "loc_rib_routes"
+	}
+	return fmt.Sprintf("type_%d", statType)
+}
@@ -0,0 +1,137 @@
+package bmp
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/bgp"
+	"github.com/hervehildenbrand/bgp-radar/pkg/bgp/bgptest"
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+func message(msgType uint8, body []byte) []byte {
+	return bgptest.Concat([]byte{version}, bgptest.U32(uint32(headerLen+len(body))), []byte{msgType}, body)
+}
+
+func peerHeader(peerASN uint32, address string, ts uint32) []byte {
+	addr := netip.MustParseAddr(address).As16()
+	var flags byte
+	if !netip.MustParseAddr(address).Is4() {
+		flags |= flagIPv6
+	}
+	return bgptest.Concat([]byte{0, flags}, make([]byte, 8), addr[:], bgptest.U32(peerASN), bgptest.U32(0), bgptest.U32(ts), bgptest.U32(0))
+}
+
+// announce builds a BGP UPDATE announcing prefix with a four-byte AS path.
+func announce(prefix string, asPath ...uint32) []byte {
+	return bgptest.Update(nil, bgptest.Attr(bgp.AttrASPath, bgptest.ASPath(true, asPath...)), bgptest.NLRI(prefix))
+}
+
+func startStation(t *testing.T) (*Station, net.Conn) {
+	t.Helper()
+	s, err := Listen("127.0.0.1:0", 10)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	s.Start()
+	t.Cleanup(s.Stop)
+
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return s, conn
+}
+
+func receive(t *testing.T, updates <-chan models.BGPUpdate) models.BGPUpdate {
+	t.Helper()
+	select {
+	case update := <-updates:
+		return update
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for an update")
+	}
+	return models.BGPUpdate{}
+}
+
+func TestStation_RouteMonitoring(t *testing.T) {
+	s, conn := startStation(t)
+	updates := s.Updates()
+
+	sysName := bgptest.Concat(bgptest.U16(infoSysName), bgptest.U16(4), []byte("edge"))
+	conn.Write(message(TypeInitiation, sysName))
+	conn.Write(message(TypePeerUp, peerHeader(64500, "192.0.2.1", 1700000000)))
+	conn.Write(message(TypeRouteMonitoring, bgptest.Concat(peerHeader(64500, "192.0.2.1", 1700000000), announce("1.1.1.0/24", 64500, 13335))))
+	conn.Write(message(TypeRouteMonitoring, bgptest.Concat(peerHeader(64501, "2001:db8::1", 1700000001), bgptest.Update(bgptest.NLRI("8.8.8.0/24"), nil, nil))))
+
+	ann := receive(t, updates)
+	if !ann.Announcement || ann.Prefix != "1.1.1.0/24" || ann.OriginASN != 13335 {
+		t.Errorf("Unexpected announcement: %+v", ann)
+	}
+	if ann.Collector != "edge" || ann.PeerASN != 64500 {
+		t.Errorf("Expected collector edge and peer AS64500, got %s and %d", ann.Collector, ann.PeerASN)
+	}
+	if !ann.Timestamp.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Expected the per-peer header timestamp, got %v", ann.Timestamp)
+	}
+
+	w := receive(t, updates)
+	if w.Announcement || w.Prefix != "8.8.8.0/24" || w.PeerASN != 64501 {
+		t.Errorf("Unexpected withdrawal: %+v", w)
+	}
+
+	stats := s.Stats()
+	routers := stats["routers"].([]map[string]interface{})
+	if len(routers) != 1 || routers[0]["router"] != "edge" || routers[0]["peers_up"] != 1 {
+		t.Errorf("Unexpected router stats: %v", routers)
+	}
+}
+
+func TestStation_PeerDownAndStatistics(t *testing.T) {
+	s, conn := startStation(t)
+	updates := s.Updates()
+
+	header := peerHeader(64500, "192.0.2.1", 1700000000)
+	report := bgptest.Concat(header, bgptest.U32(2), bgptest.U16(7), bgptest.U16(8), make([]byte, 4), bgptest.U32(900000), bgptest.U16(0), bgptest.U16(4), bgptest.U32(3))
+	conn.Write(message(TypePeerUp, header))
+	conn.Write(message(TypeStatistics, report))
+	conn.Write(message(TypePeerDown, bgptest.Concat(header, []byte{2})))
+	// A route monitoring message after the others, to know they were handled
+	conn.Write(message(TypeRouteMonitoring, bgptest.Concat(header, announce("192.0.2.0/24", 64500))))
+	receive(t, updates)
+
+	routers := s.Stats()["routers"].([]map[string]interface{})
+	if len(routers) != 1 {
+		t.Fatalf("Expected 1 router, got %d", len(routers))
+	}
+	r := routers[0]
+	if r["peers_up"] != 0 || r["peer_downs"] != uint64(1) {
+		t.Errorf("Expected the peer down, got %v", r)
+	}
+	peers := r["peers"].([]map[string]interface{})
+	stats := peers[0]["stats"].(map[string]uint64)
+	if stats["adj_rib_in_routes"] != 900000 || stats["rejected_prefixes"] != 3 {
+		t.Errorf("Unexpected peer statistics: %v", stats)
+	}
+}
+
+func TestStation_Termination(t *testing.T) {
+	s, conn := startStation(t)
+
+	conn.Write(message(TypeTermination, nil))
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("Expected the station to close the connection")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.Stats()["routers"].([]map[string]interface{})) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the router to be removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,217 @@
+// Package cache provides a sharded, size-bounded LRU cache with optional
+// expiry, for detector state that must not grow with the routing table.
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultShards is the number of shards used when Config.Shards is 0.
+const DefaultShards = 64
+
+// Config configures a cache.
+type Config struct {
+	MaxEntries int           // Entries kept before the least recently used are evicted (0 for no bound)
+	TTL        time.Duration // Entries expire this long after being set (0 for never)
+	Shards     int           // Rounded up to a power of two (0 for DefaultShards)
+}
+
+// Cache maps string keys to values. Keys are spread over independently
+// locked shards, each evicting its least recently used entries once it
+// holds its share of MaxEntries. It is safe for concurrent use.
+type Cache[V any] struct {
+	shards []*shard[V]
+	mask   uint64
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// shard is a map with a recency list, most recently used first. Counters
+// are kept per shard, under its lock, so that hits on different shards do
+// not contend.
+type shard[V any] struct {
+	mu      sync.Mutex
+	entries map[string]*entry[V]
+	head    *entry[V] // Most recently used
+	tail    *entry[V] // Least recently used
+	max     int
+
+	hits, misses, evictions, expirations uint64
+}
+
+type entry[V any] struct {
+	key        string
+	value      V
+	expires    int64 // Unix nanoseconds, 0 for never
+	prev, next *entry[V]
+}
+
+// New creates a cache.
+func New[V any](cfg Config) *Cache[V] {
+	n := 1
+	for n < cfg.Shards || (cfg.Shards <= 0 && n < DefaultShards) {
+		n <<= 1
+	}
+	perShard := 0
+	if cfg.MaxEntries > 0 {
+		perShard = (cfg.MaxEntries + n - 1) / n
+	}
+
+	c := &Cache[V]{
+		shards: make([]*shard[V], n),
+		mask:   uint64(n - 1),
+		ttl:    cfg.TTL,
+		now:    time.Now,
+	}
+	for i := range c.shards {
+		c.shards[i] = &shard[V]{entries: make(map[string]*entry[V]), max: perShard}
+	}
+	return c
+}
+
+// shardFor returns the shard of key, by its FNV-1a hash.
+func (c *Cache[V]) shardFor(key string) *shard[V] {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+	return c.shards[h&c.mask]
+}
+
+// Get returns the value of key, marking it as recently used.
+func (c *Cache[V]) Get(key string) (V, bool) {
+	var now int64
+	if c.ttl > 0 {
+		now = c.now().UnixNano()
+	}
+
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if ok && e.expires != 0 && e.expires <= now {
+		s.remove(e)
+		s.expirations++
+		ok = false
+	}
+	if !ok {
+		s.misses++
+		var zero V
+		return zero, false
+	}
+	s.moveToFront(e)
+	s.hits++
+	return e.value, true
+}
+
+// Set stores the value of key, evicting the least recently used entry of
+// its shard if the shard is full.
+func (c *Cache[V]) Set(key string, value V) {
+	var expires int64
+	if c.ttl > 0 {
+		expires = c.now().Add(c.ttl).UnixNano()
+	}
+
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok {
+		e.value, e.expires = value, expires
+		s.moveToFront(e)
+		return
+	}
+
+	if s.max > 0 && len(s.entries) >= s.max {
+		s.remove(s.tail)
+		s.evictions++
+	}
+	e := &entry[V]{key: key, value: value, expires: expires}
+	s.entries[key] = e
+	s.pushFront(e)
+}
+
+// Delete removes key.
+func (c *Cache[V]) Delete(key string) {
+	s := c.shardFor(key)
+	s.mu.Lock()
+	if e, ok := s.entries[key]; ok {
+		s.remove(e)
+	}
+	s.mu.Unlock()
+}
+
+// Len returns the number of entries, including expired entries not yet
+// removed.
+func (c *Cache[V]) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		n += len(s.entries)
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// Stats returns cache statistics.
+func (c *Cache[V]) Stats() map[string]interface{} {
+	var entries, max int
+	var hits, misses, evictions, expirations uint64
+	for _, s := range c.shards {
+		s.mu.Lock()
+		entries += len(s.entries)
+		max += s.max
+		hits += s.hits
+		misses += s.misses
+		evictions += s.evictions
+		expirations += s.expirations
+		s.mu.Unlock()
+	}
+	return map[string]interface{}{
+		"entries":     entries,
+		"max_entries": max,
+		"hits":        hits,
+		"misses":      misses,
+		"evictions":   evictions,
+		"expirations": expirations,
+	}
+}
+
+func (s *shard[V]) pushFront(e *entry[V]) {
+	e.prev, e.next = nil, s.head
+	if s.head != nil {
+		s.head.prev = e
+	}
+	s.head = e
+	if s.tail == nil {
+		s.tail = e
+	}
+}
+
+func (s *shard[V]) unlink(e *entry[V]) {
+	if e.prev != nil {
+		e.prev.next = e.next
+	} else {
+		s.head = e.next
+	}
+	if e.next != nil {
+		e.next.prev = e.prev
+	} else {
+		s.tail = e.prev
+	}
+	e.prev, e.next = nil, nil
+}
+
+func (s *shard[V]) moveToFront(e *entry[V]) {
+	if s.head == e {
+		return
+	}
+	s.unlink(e)
+	s.pushFront(e)
+}
+
+func (s *shard[V]) remove(e *entry[V]) {
+	s.unlink(e)
+	delete(s.entries, e.key)
+}
@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCache_LRU(t *testing.T) {
+	c := New[uint32](Config{MaxEntries: 3, Shards: 1})
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3)
+
+	// Using a makes b the least recently used
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("Expected a=1, got %d (%v)", v, ok)
+	}
+	c.Set("d", 4)
+	if _, ok := c.Get("b"); ok {
+		t.Error("Expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("Expected %s to be kept", key)
+		}
+	}
+
+	// Updating a key does not evict
+	c.Set("d", 5)
+	if v, _ := c.Get("d"); v != 5 || c.Len() != 3 {
+		t.Errorf("Expected d=5 with 3 entries, got %d with %d", v, c.Len())
+	}
+
+	stats := c.Stats()
+	if stats["evictions"] != uint64(1) || stats["misses"] != uint64(1) || stats["max_entries"] != 3 {
+		t.Errorf("Unexpected stats: %v", stats)
+	}
+
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.Error("Expected a to be deleted")
+	}
+}
+
+func TestCache_TTL(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	c := New[string](Config{TTL: time.Minute})
+	c.now = func() time.Time { return now }
+
+	c.Set("192.0.2.0/24", "AS64500")
+	now = now.Add(30 * time.Second)
+	if _, ok := c.Get("192.0.2.0/24"); !ok {
+		t.Error("Expected the entry before its TTL")
+	}
+	now = now.Add(31 * time.Second)
+	if _, ok := c.Get("192.0.2.0/24"); ok {
+		t.Error("Expected the entry to expire")
+	}
+	if c.Len() != 0 || c.Stats()["expirations"] != uint64(1) {
+		t.Errorf("Expected the expired entry to be removed, got %v", c.Stats())
+	}
+}
+
+func TestCache_Bounded(t *testing.T) {
+	c := New[int](Config{MaxEntries: 1000})
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 10000; i++ {
+				key := strconv.Itoa(w*10000 + i)
+				c.Set(key, i)
+				c.Get(key)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// Each of the 64 shards holds up to ceil(1000/64) entries
+	if n := c.Len(); n > 1024 {
+		t.Errorf("Expected at most 1024 entries, got %d", n)
+	}
+	if c.Stats()["evictions"].(uint64) == 0 {
+		t.Error("Expected evictions")
+	}
+}
+
+// Benchmarks compare a cache hit with the double sync.Map lookup (value,
+// then insertion time) the hijack detector used to cache prefix origins.
+
+const benchKeys = 100000
+
+func benchPrefixes() []string {
+	keys := make([]string, benchKeys)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%d.%d.%d.0/24", 10+i>>16, (i>>8)&0xff, i&0xff)
+	}
+	return keys
+}
+
+func BenchmarkCache_Get(b *testing.B) {
+	keys := benchPrefixes()
+	c := New[uint32](Config{MaxEntries: 2 * benchKeys, TTL: 5 * time.Minute})
+	for i, key := range keys {
+		c.Set(key, uint32(i))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if _, ok := c.Get(keys[i%benchKeys]); !ok {
+				b.Fatal("miss")
+			}
+			i += 7
+		}
+	})
+}
+
+func BenchmarkSyncMap_Get(b *testing.B) {
+	keys := benchPrefixes()
+	var cache, cacheTime sync.Map
+	ttl := 5 * time.Minute
+	for i, key := range keys {
+		cache.Store(key, uint32(i))
+		cacheTime.Store(key, time.Now())
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := keys[i%benchKeys]
+			hit := false
+			if val, ok := cache.Load(key); ok {
+				if t, ok := cacheTime.Load(key); ok && time.Since(t.(time.Time)) < ttl {
+					_ = val.(uint32)
+					hit = true
+				}
+			}
+			if !hit {
+				b.Fatal("miss")
+			}
+			i += 7
+		}
+	})
+}
@@ -0,0 +1,369 @@
+// Package config loads the bgp-radar configuration file: update sources,
+// detectors and their options, sinks, the ASN-to-country resolver chain and
+// the detector reference data, in YAML.
+//
+//	sources:
+//	  collectors: [rrc00, rrc11]      # RIS Live collectors ([] for BMP only)
+//	  bmp: ":11019"
+//	workers: 8
+//	redis: redis://localhost:6379
+//	resolver:                         # Tried in order
+//	  - file: asn-countries.csv
+//	  - database: asn_countries       # Table of the -database connection
+//	detectors:
+//	  enabled: [all, -ddos]
+//	  options:
+//	    withdrawal: {window: 10m, min-peers: 5}
+//	sinks:
+//	  - kind: log
+//	  - kind: webhook
+//	    url: https://example.net/hook
+//	    min-severity: high
+//	    type: [hijack, leak]          # Filter options may list several values
+//	reference:
+//	  files: [reference.json]         # Versioned datasets, see detector.LoadReferenceData
+//	  tier1: {64500: Example Transit}
+//	  scrubbing: {64501: Example Scrubbing}
+//	  blackhole_communities: {64500: "64500:666", 64502: ["64502:666", "64502:666:0"]}
+//
+// Detector options, sinks, reference data and the watchlist can be reloaded
+// while running; other settings apply at startup.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/detector"
+	"github.com/hervehildenbrand/bgp-radar/pkg/sink"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the configuration file. Zero values leave the defaults of the
+// corresponding command-line flags.
+type Config struct {
+	Sources      Sources    `yaml:"sources"`
+	Workers      int        `yaml:"workers"`
+	WorkerQueue  int        `yaml:"worker_queue"`
+	Redis        string     `yaml:"redis"`
+	Database     string     `yaml:"database"`
+	API          string     `yaml:"api"`
+	APIBuffer    int        `yaml:"api_buffer"`
+	Metrics      string     `yaml:"metrics"`       // Defaults to /metrics on the API address
+	EventTimeout *Duration  `yaml:"event_timeout"` // 0 disables the timeout
+	Stats        Duration   `yaml:"stats"`
+	Resolver     []Resolver `yaml:"resolver"`
+	RPKI         RPKI       `yaml:"rpki"`
+	ASRel        string     `yaml:"as_rel"`
+	Watchlist    string     `yaml:"watchlist"`
+	Baseline     []string   `yaml:"baseline"`
+	State        State      `yaml:"state"`
+	Detectors    Detectors  `yaml:"detectors"`
+	Sinks        []Sink     `yaml:"sinks"`
+	Reference    Reference  `yaml:"reference"`
+}
+
+// Sources configures the update sources.
+type Sources struct {
+	Collectors []string `yaml:"collectors"` // nil for the default, empty for none
+	BMP        string   `yaml:"bmp"`
+	Buffer     int      `yaml:"buffer"`
+}
+
+// Resolver is an ASN-to-country resolver: a CSV file or a database table.
+type Resolver struct {
+	File     string `yaml:"file"`
+	Database string `yaml:"database"` // Table name, read over the database connection
+}
+
+// RPKI configures RPKI validation.
+type RPKI struct {
+	VRPs    string   `yaml:"vrps"`
+	RTR     string   `yaml:"rtr"`
+	Refresh Duration `yaml:"refresh"`
+}
+
+// State configures the local detector state store.
+type State struct {
+	Path     string   `yaml:"path"`
+	TTL      Duration `yaml:"ttl"`
+	Snapshot Duration `yaml:"snapshot"`
+}
+
+// Detectors selects the detectors and sets their options.
+type Detectors struct {
+	Enabled []string                     `yaml:"enabled"` // e.g. [all, -ddos]
+	Options map[string]map[string]string `yaml:"options"` // Detector -> option -> value
+}
+
+// Reference is detector reference data: versioned dataset files applied
+// to the built-in lists in order, then entries added to the result.
+type Reference struct {
+	Files                []string            `yaml:"files"` // JSON or CSV datasets
+	Tier1                map[ASN]string      `yaml:"tier1"`
+	Scrubbing            map[ASN]string      `yaml:"scrubbing"`
+	BlackholeCommunities map[ASN]Communities `yaml:"blackhole_communities"` // Provider ASN -> communities
+}
+
+// Communities is a community or a list of communities.
+type Communities []string
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (c *Communities) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		*c = Communities{node.Value}
+		return nil
+	case yaml.SequenceNode:
+		var list []string
+		if err := node.Decode(&list); err != nil {
+			return err
+		}
+		*c = list
+		return nil
+	}
+	return fmt.Errorf("line %d: expected a community or a list of communities", node.Line)
+}
+
+// Sink is a sink as its kind and options, in file order.
+type Sink []sink.Option
+
+// UnmarshalYAML implements yaml.Unmarshaler, reading a mapping whose values
+// are scalars or lists of scalars.
+func (s *Sink) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: expected a mapping of sink options", node.Line)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		values := []*yaml.Node{value}
+		if value.Kind == yaml.SequenceNode {
+			values = value.Content
+		}
+		for _, v := range values {
+			if v.Kind != yaml.ScalarNode {
+				return fmt.Errorf("line %d: sink option %s: expected a value or a list of values", v.Line, key.Value)
+			}
+			*s = append(*s, sink.Option{Key: key.Value, Value: v.Value})
+		}
+	}
+	return nil
+}
+
+// Kind returns the kind option of the sink.
+func (s Sink) Kind() string {
+	for _, option := range s {
+		if option.Key == "kind" {
+			return option.Value
+		}
+	}
+	return ""
+}
+
+// Config returns the sink config.
+func (s Sink) Config() (sink.Config, error) {
+	options := make([]sink.Option, 0, len(s))
+	for _, option := range s {
+		if option.Key != "kind" {
+			options = append(options, option)
+		}
+	}
+	return sink.NewConfig(s.Kind(), options)
+}
+
+// Duration is a duration written as "10m" or "1h30m".
+type Duration time.Duration
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	v, err := time.ParseDuration(node.Value)
+	if err != nil || v < 0 {
+		return fmt.Errorf("line %d: invalid duration %q", node.Line, node.Value)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// ASN is an AS number written as 64500 or "AS64500".
+type ASN uint32
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (a *ASN) UnmarshalYAML(node *yaml.Node) error {
+	s := strings.TrimPrefix(strings.ToUpper(node.Value), "AS")
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || n == 0 {
+		return fmt.Errorf("line %d: invalid ASN %q", node.Line, node.Value)
+	}
+	*a = ASN(n)
+	return nil
+}
+
+// Load reads and validates a configuration file.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Parse parses and validates a configuration. Unknown keys are errors, so
+// that misspelled settings are not silently ignored.
+func Parse(data []byte) (*Config, error) {
+	var c Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Validate checks the settings that can be checked without connecting to
+// anything.
+func (c *Config) Validate() error {
+	for name, n := range map[string]int{
+		"workers":        c.Workers,
+		"worker_queue":   c.WorkerQueue,
+		"api_buffer":     c.APIBuffer,
+		"sources.buffer": c.Sources.Buffer,
+	} {
+		if n < 0 {
+			return fmt.Errorf("%s: must be positive, got %d", name, n)
+		}
+	}
+	for i, collector := range c.Sources.Collectors {
+		if strings.TrimSpace(collector) == "" || collector == "none" {
+			return fmt.Errorf("sources.collectors[%d]: invalid collector %q (use [] for none)", i, collector)
+		}
+	}
+
+	for i, r := range c.Resolver {
+		if (r.File == "") == (r.Database == "") {
+			return fmt.Errorf("resolver[%d]: set exactly one of file or database", i)
+		}
+	}
+
+	registry := detector.DefaultRegistry()
+	if _, err := registry.Select(strings.Join(c.Detectors.Enabled, ",")); err != nil {
+		return fmt.Errorf("detectors.enabled: %w", err)
+	}
+	if err := ValidateDetectorOptions(c.Detectors.Options); err != nil {
+		return fmt.Errorf("detectors.options: %w", err)
+	}
+
+	names := make(map[string]bool)
+	for i, s := range c.Sinks {
+		if s.Kind() == "" {
+			return fmt.Errorf("sinks[%d]: missing kind", i)
+		}
+		cfg, err := s.Config()
+		if err != nil {
+			return fmt.Errorf("sinks[%d]: %w", i, err)
+		}
+		if names[cfg.Name] {
+			return fmt.Errorf("sinks[%d]: duplicate sink name %q (set name)", i, cfg.Name)
+		}
+		names[cfg.Name] = true
+	}
+
+	for asn, communities := range c.Reference.BlackholeCommunities {
+		for _, community := range communities {
+			if !detector.ValidCommunity(community) {
+				return fmt.Errorf("reference.blackhole_communities: AS%d: invalid community %q (want asn:value, asn:value:value or an extended community)", asn, community)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateDetectorOptions checks detector options, keyed by detector name
+// then option name, without building any detector.
+func ValidateDetectorOptions(options map[string]map[string]string) error {
+	return detector.DefaultRegistry().ValidateOptions(options)
+}
+
+// SinkConfigs returns the configs of the sinks.
+func (c *Config) SinkConfigs() ([]sink.Config, error) {
+	configs := make([]sink.Config, 0, len(c.Sinks))
+	for i, s := range c.Sinks {
+		cfg, err := s.Config()
+		if err != nil {
+			return nil, fmt.Errorf("sinks[%d]: %w", i, err)
+		}
+		configs = append(configs, cfg)
+	}
+	return configs, nil
+}
+
+// References returns base, e.g. the reference data loaded from the dataset
+// files, extended with the entries of the reference section.
+func (c *Config) References(base *detector.References) *detector.References {
+	return base.With(
+		asnNames(c.Reference.Tier1),
+		asnNames(c.Reference.Scrubbing),
+		asnCommunities(c.Reference.BlackholeCommunities),
+	)
+}
+
+func asnCommunities(m map[ASN]Communities) map[uint32][]string {
+	n := make(map[uint32][]string, len(m))
+	for asn, communities := range m {
+		n[uint32(asn)] = communities
+	}
+	return n
+}
+
+func asnNames(m map[ASN]string) map[uint32]string {
+	n := make(map[uint32]string, len(m))
+	for asn, name := range m {
+		n[uint32(asn)] = name
+	}
+	return n
+}
+
+// RestartRequired returns the settings that differ between two
+// configurations and only apply at startup.
+func RestartRequired(old, new *Config) []string {
+	var changed []string
+	for _, f := range []struct {
+		name     string
+		old, new interface{}
+	}{
+		{"sources", old.Sources, new.Sources},
+		{"workers", old.Workers, new.Workers},
+		{"worker_queue", old.WorkerQueue, new.WorkerQueue},
+		{"redis", old.Redis, new.Redis},
+		{"database", old.Database, new.Database},
+		{"api", old.API, new.API},
+		{"api_buffer", old.APIBuffer, new.APIBuffer},
+		{"metrics", old.Metrics, new.Metrics},
+		{"event_timeout", old.EventTimeout, new.EventTimeout},
+		{"stats", old.Stats, new.Stats},
+		{"resolver", old.Resolver, new.Resolver},
+		{"rpki", old.RPKI, new.RPKI},
+		{"as_rel", old.ASRel, new.ASRel},
+		{"baseline", old.Baseline, new.Baseline},
+		{"state", old.State, new.State},
+		{"detectors.enabled", old.Detectors.Enabled, new.Detectors.Enabled},
+	} {
+		if !reflect.DeepEqual(f.old, f.new) {
+			changed = append(changed, f.name)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/detector"
+)
+
+const testConfig = `
+sources:
+  collectors: [rrc00, rrc11]
+  bmp: ":11019"
+workers: 4
+event_timeout: 0s
+resolver:
+  - file: asn.csv
+  - database: asn_countries
+state:
+  ttl: 24h
+detectors:
+  enabled: [all, -ddos]
+  options:
+    withdrawal:
+      window: 10m
+      min-peers: 5
+sinks:
+  - kind: log
+  - kind: webhook
+    url: https://example.net/hook
+    min-severity: high
+    type: [hijack, leak]
+reference:
+  tier1: {64500: Example Transit}
+  scrubbing: {AS64501: Example Scrubbing}
+  blackhole_communities: {64500: "64500:666", 6939: ["6939:666:1"]}
+`
+
+func TestParse(t *testing.T) {
+	c, err := Parse([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if len(c.Sources.Collectors) != 2 || c.Sources.BMP != ":11019" || c.Workers != 4 {
+		t.Errorf("Unexpected sources: %+v, workers %d", c.Sources, c.Workers)
+	}
+	if c.EventTimeout == nil || *c.EventTimeout != 0 {
+		t.Errorf("Expected a zero event timeout, got %v", c.EventTimeout)
+	}
+	if time.Duration(c.State.TTL) != 24*time.Hour {
+		t.Errorf("Expected state TTL 24h, got %s", time.Duration(c.State.TTL))
+	}
+	if len(c.Resolver) != 2 || c.Resolver[1].Database != "asn_countries" {
+		t.Errorf("Unexpected resolver chain: %+v", c.Resolver)
+	}
+	if c.Detectors.Options["withdrawal"]["min-peers"] != "5" {
+		t.Errorf("Unexpected detector options: %v", c.Detectors.Options)
+	}
+
+	sinks, err := c.SinkConfigs()
+	if err != nil {
+		t.Fatalf("SinkConfigs failed: %v", err)
+	}
+	if len(sinks) != 2 || sinks[1].Options["url"] != "https://example.net/hook" {
+		t.Fatalf("Unexpected sinks: %+v", sinks)
+	}
+	if f := sinks[1].Filter; f.MinSeverity != "high" || len(f.Types) != 2 {
+		t.Errorf("Unexpected webhook filter: %+v", f)
+	}
+
+	refs := c.References(detector.DefaultReferences())
+	if refs.Tier1ASNs[64500] != "Example Transit" || refs.Tier1ASNs[174] == "" {
+		t.Errorf("Expected Tier-1 ASNs added to the built-in list")
+	}
+	if refs.ScrubbingASNs[64501] != "Example Scrubbing" {
+		t.Errorf("Expected scrubbing ASN written as AS64501")
+	}
+	if c := refs.ProviderBlackholeCommunities[64500]; len(c) != 1 || c[0] != "64500:666" {
+		t.Errorf("Expected blackhole community added, got %v", c)
+	}
+	if c := refs.ProviderBlackholeCommunities[6939]; len(c) != 3 || c[2] != "6939:666:1" {
+		t.Errorf("Expected blackhole community added to the built-in ones of AS6939, got %v", c)
+	}
+	if _, ok := detector.Tier1ASNs[64500]; ok {
+		t.Errorf("Expected the built-in list to be unchanged")
+	}
+}
+
+func TestParse_Empty(t *testing.T) {
+	c, err := Parse(nil)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if c.Sources.Collectors != nil || len(c.Sinks) != 0 {
+		t.Errorf("Expected an empty configuration, got %+v", c)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		name, config, want string
+	}{
+		{"unknown key", "wokers: 4", "field wokers not found"},
+		{"negative", "workers: -1", "workers: must be positive"},
+		{"duration", "stats: soon", `invalid duration "soon"`},
+		{"collector", "sources: {collectors: [none]}", "sources.collectors[0]"},
+		{"resolver", "resolver: [{}]", "resolver[0]: set exactly one"},
+		{"detector", "detectors: {enabled: [hijack, bogus]}", `detectors.enabled: unknown detector "bogus"`},
+		{"detector option", "detectors: {options: {withdrawal: {window: soon}}}", "detectors.options: detector withdrawal: option window"},
+		{"option detector", "detectors: {options: {bogus: {window: 10m}}}", `options for unknown detector "bogus"`},
+		{"sink kind", "sinks: [{url: x}]", "sinks[0]: missing kind"},
+		{"sink option", "sinks: [{kind: log}, {kind: webhook, bogus: 1}]", `sinks[1]: sink webhook: unknown option "bogus"`},
+		{"sink name", "sinks: [{kind: log}, {kind: log}]", `sinks[1]: duplicate sink name "log"`},
+		{"asn", "reference: {tier1: {ASX: x}}", `invalid ASN "ASX"`},
+		{"community", `reference: {blackhole_communities: {64500: "64500"}}`, "reference.blackhole_communities: AS64500"},
+		{"community in list", `reference: {blackhole_communities: {64500: ["64500:666", "64500"]}}`, "reference.blackhole_communities: AS64500"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.config))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestRestartRequired(t *testing.T) {
+	old, err := Parse([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	changed := strings.Replace(testConfig, "rrc11", "rrc12", 1)
+	changed = strings.Replace(changed, "window: 10m", "window: 15m", 1)
+	changed = strings.Replace(changed, "min-severity: high", "min-severity: critical", 1)
+	c, err := Parse([]byte(changed))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	got := RestartRequired(old, c)
+	if len(got) != 1 || got[0] != "sources" {
+		t.Errorf("Expected only sources to require a restart, got %v", got)
+	}
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+// EventReader queries events stored in bgp_events.
+type EventReader struct {
+	db *sql.DB
+}
+
+// NewEventReader creates a reader over an open database connection.
+func NewEventReader(db *sql.DB) *EventReader {
+	return &EventReader{db: db}
+}
+
+// Events returns the events matching filter, most recent first.
+func (r *EventReader) Events(ctx context.Context, filter models.EventFilter) ([]models.BGPEvent, error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", "$"+strconv.Itoa(len(args))))
+	}
+
+	if filter.Type != "" {
+		where("event_type = ?", filter.Type)
+	}
+	if filter.Severity != "" {
+		where("severity = ?", filter.Severity)
+	}
+	if filter.MinSeverity != "" {
+		where(`CASE severity WHEN 'low' THEN 0 WHEN 'medium' THEN 1 WHEN 'high' THEN 2 WHEN 'critical' THEN 3 ELSE -1 END >= ?`,
+			models.SeverityRank(filter.MinSeverity))
+	}
+	if filter.Country != "" {
+		where("country_code = ?", filter.Country)
+	}
+	if filter.ASN != 0 {
+		where("affected_asn = ?", filter.ASN)
+	}
+	if filter.Prefix != "" {
+		// Events without a prefix (withdrawal storms) cannot be cast to inet
+		where("CASE WHEN affected_prefix LIKE '%/%' THEN affected_prefix::inet <<= ?::inet ELSE false END", filter.Prefix)
+	}
+	if filter.ActiveOnly {
+		conditions = append(conditions, "is_active = true")
+	}
+	if !filter.Since.IsZero() {
+		where("detected_at >= ?", filter.Since)
+	}
+
+	query := `
+		SELECT id, country_code, event_type, severity, event_category,
+			rpki_status, is_cross_border, attacker_country, victim_country,
+			affected_asn, affected_prefix, details, detected_at, ended_at, is_active
+		FROM bgp_events`
+	if len(conditions) > 0 {
+		query += "\n\t\tWHERE " + strings.Join(conditions, " AND ")
+	}
+	query += "\n\t\tORDER BY detected_at DESC"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.BGPEvent
+	for rows.Next() {
+		var (
+			event                          models.BGPEvent
+			id                             int64
+			country, category, rpkiStatus  sql.NullString
+			attackerCountry, victimCountry sql.NullString
+			prefix                         sql.NullString
+			asn                            sql.NullInt64
+			crossBorder, active            sql.NullBool
+			details                        []byte
+			endedAt                        sql.NullTime
+		)
+		if err := rows.Scan(&id, &country, &event.EventType, &event.Severity, &category,
+			&rpkiStatus, &crossBorder, &attackerCountry, &victimCountry,
+			&asn, &prefix, &details, &event.DetectedAt, &endedAt, &active); err != nil {
+			return nil, fmt.Errorf("scan event: %w", err)
+		}
+
+		event.ID = strconv.FormatInt(id, 10)
+		event.CountryCode = country.String
+		event.EventCategory = category.String
+		event.RPKIStatus = rpkiStatus.String
+		event.IsCrossBorder = crossBorder.Bool
+		event.AttackerCountry = attackerCountry.String
+		event.VictimCountry = victimCountry.String
+		event.AffectedASN = uint32(asn.Int64)
+		event.AffectedPrefix = prefix.String
+		event.EndedAt = endedAt.Time
+		event.IsActive = active.Bool
+		if len(details) > 0 {
+			if err := json.Unmarshal(details, &event.Details); err != nil {
+				event.Details = nil
+			}
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -53,6 +53,7 @@ type FileResolver struct {
 	filePath string
 	mapping  map[int]string
 	mu       sync.RWMutex
+	loadedAt time.Time
 }
 
 // NewFileResolver creates a resolver that loads mappings from a CSV file.
@@ -109,6 +110,7 @@ func (r *FileResolver) load() error {
 		}
 	}
 
+	r.loadedAt = time.Now()
 	log.Printf("FileResolver: Loaded %d ASN mappings from %s", len(r.mapping), r.filePath)
 	return nil
 }
@@ -136,6 +138,13 @@ func (r *FileResolver) Count() int {
 	return len(r.mapping)
 }
 
+// LastRefresh returns when the mapping was loaded.
+func (r *FileResolver) LastRefresh() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.loadedAt
+}
+
 func (r *FileResolver) Start() {}
 func (r *FileResolver) Stop()  {}
 
@@ -221,6 +230,13 @@ func (r *DatabaseResolver) Count() int {
 	return len(r.mapping)
 }
 
+// LastRefresh returns when the mapping was last loaded successfully.
+func (r *DatabaseResolver) LastRefresh() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.lastUpdate
+}
+
 // refresh loads the ASN-to-country mapping from the database.
 func (r *DatabaseResolver) refresh() {
 	start := time.Now()
@@ -258,6 +274,62 @@ func (r *DatabaseResolver) refresh() {
 	log.Printf("DatabaseResolver: Loaded %d ASN mappings in %v", len(newMapping), time.Since(start))
 }
 
+// ChainResolver resolves ASNs with a list of resolvers, in order: the first
+// resolver knowing an ASN wins, e.g. a curated CSV file before the database.
+type ChainResolver struct {
+	resolvers []CountryResolver
+}
+
+// NewChainResolver creates a resolver trying each resolver in order.
+func NewChainResolver(resolvers ...CountryResolver) *ChainResolver {
+	return &ChainResolver{resolvers: resolvers}
+}
+
+// Resolve returns the country code for an ASN, or "" if unknown.
+func (r *ChainResolver) Resolve(asn uint32) string {
+	for _, resolver := range r.resolvers {
+		if country := resolver.Resolve(asn); country != "" {
+			return country
+		}
+	}
+	return ""
+}
+
+// ResolveFromPath returns the first known country code from an AS path.
+func (r *ChainResolver) ResolveFromPath(asPath []int) string {
+	for _, asn := range asPath {
+		if country := r.Resolve(uint32(asn)); country != "" {
+			return country
+		}
+	}
+	return ""
+}
+
+// Count returns the number of ASNs in the largest mapping.
+func (r *ChainResolver) Count() int {
+	count := 0
+	for _, resolver := range r.resolvers {
+		if n := resolver.Count(); n > count {
+			count = n
+		}
+	}
+	return count
+}
+
+// Start starts every resolver.
+func (r *ChainResolver) Start() {
+	for _, resolver := range r.resolvers {
+		resolver.Start()
+	}
+}
+
+// Stop stops every resolver.
+func (r *ChainResolver) Stop() {
+	for _, resolver := range r.resolvers {
+		resolver.Stop()
+	}
+}
+
 // ASNResolver is an alias for backwards compatibility.
 // Deprecated: Use DatabaseResolver instead.
 type ASNResolver = DatabaseResolver
@@ -145,3 +145,37 @@ func TestCountryResolverInterface(t *testing.T) {
 	var _ CountryResolver = (*FileResolver)(nil)
 	var _ CountryResolver = (*DatabaseResolver)(nil)
 }
+
+func TestChainResolver(t *testing.T) {
+	tmpDir := t.TempDir()
+	first := filepath.Join(tmpDir, "first.csv")
+	second := filepath.Join(tmpDir, "second.csv")
+	if err := os.WriteFile(first, []byte("13335,GB\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test CSV: %v", err)
+	}
+	if err := os.WriteFile(second, []byte("13335,US\n6939,US\n3320,DE\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test CSV: %v", err)
+	}
+	r1, err := NewFileResolver(first)
+	if err != nil {
+		t.Fatalf("NewFileResolver() error = %v", err)
+	}
+	r2, err := NewFileResolver(second)
+	if err != nil {
+		t.Fatalf("NewFileResolver() error = %v", err)
+	}
+
+	r := NewChainResolver(NewNullResolver(), r1, r2)
+	if got := r.Resolve(13335); got != "GB" {
+		t.Errorf("ChainResolver.Resolve(13335) = %q, want GB from the first resolver", got)
+	}
+	if got := r.Resolve(6939); got != "US" {
+		t.Errorf("ChainResolver.Resolve(6939) = %q, want US from the second resolver", got)
+	}
+	if got := r.ResolveFromPath([]int{99999, 3320, 13335}); got != "DE" {
+		t.Errorf("ChainResolver.ResolveFromPath() = %q, want DE", got)
+	}
+	if got := r.Count(); got != 3 {
+		t.Errorf("ChainResolver.Count() = %d, want 3", got)
+	}
+}
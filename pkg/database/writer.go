@@ -4,8 +4,10 @@ package database
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hervehildenbrand/bgp-radar/pkg/models"
@@ -16,6 +18,9 @@ const (
 	batchSize     = 50
 	batchInterval = 2 * time.Second
 	queueSize     = 10000
+
+	// expireInterval is how often active events are checked for inactivity.
+	expireInterval = time.Minute
 )
 
 // EventWriter handles batch writing of BGP events to PostgreSQL.
@@ -27,10 +32,22 @@ type EventWriter struct {
 	running  bool
 	mu       sync.Mutex
 
-	// Stats
+	// Active events not seen for this long are closed (0 disables)
+	inactivityTimeout time.Duration
+
+	// Clock of the events written, which lags wall time on replay
+	// (writer goroutine only)
+	clock eventClock
+
+	// Called with the duration of each committed batch (optional)
+	observeBatch func(time.Duration)
+
+	// Stats, updated atomically
 	eventsWritten uint64
 	eventsDropped uint64
 	batchesWritten uint64
+	eventsResolved uint64
+	eventsExpired  uint64
 }
 
 // NewEventWriter creates a new database event writer.
@@ -60,6 +77,19 @@ func NewEventWriter(databaseURL string) (*EventWriter, error) {
 	}, nil
 }
 
+// SetInactivityTimeout closes active events whose last_seen_at is older than
+// timeout, for anomalies that never get an explicit end. It must be called
+// before Start.
+func (w *EventWriter) SetInactivityTimeout(timeout time.Duration) {
+	w.inactivityTimeout = timeout
+}
+
+// SetBatchObserver registers a function called with the duration of each
+// committed batch, e.g. to export batch latency. It must be called before Start.
+func (w *EventWriter) SetBatchObserver(observe func(time.Duration)) {
+	w.observeBatch = observe
+}
+
 // Start begins the background writer goroutine.
 func (w *EventWriter) Start() {
 	w.mu.Lock()
@@ -89,28 +119,34 @@ func (w *EventWriter) Stop() {
 	w.wg.Wait()
 	w.db.Close()
 	log.Printf("Database event writer stopped (written=%d, dropped=%d, batches=%d)",
-		w.eventsWritten, w.eventsDropped, w.batchesWritten)
+		atomic.LoadUint64(&w.eventsWritten), atomic.LoadUint64(&w.eventsDropped), atomic.LoadUint64(&w.batchesWritten))
 }
 
+// ErrQueueFull is returned by Write when the event was dropped.
+var ErrQueueFull = errors.New("event queue full")
+
 // Write queues an event for batch writing.
-func (w *EventWriter) Write(event models.BGPEvent) {
+func (w *EventWriter) Write(event models.BGPEvent) error {
 	select {
 	case w.queue <- event:
+		return nil
 	default:
 		// Queue full, drop event
-		w.eventsDropped++
-		if w.eventsDropped%1000 == 0 {
-			log.Printf("Event queue full, dropped %d events", w.eventsDropped)
+		if dropped := atomic.AddUint64(&w.eventsDropped, 1); dropped%1000 == 0 {
+			log.Printf("Event queue full, dropped %d events", dropped)
 		}
+		return ErrQueueFull
 	}
 }
 
 // Stats returns writer statistics.
 func (w *EventWriter) Stats() map[string]interface{} {
 	return map[string]interface{}{
-		"events_written":  w.eventsWritten,
-		"events_dropped":  w.eventsDropped,
-		"batches_written": w.batchesWritten,
+		"events_written":  atomic.LoadUint64(&w.eventsWritten),
+		"events_dropped":  atomic.LoadUint64(&w.eventsDropped),
+		"batches_written": atomic.LoadUint64(&w.batchesWritten),
+		"events_resolved": atomic.LoadUint64(&w.eventsResolved),
+		"events_expired":  atomic.LoadUint64(&w.eventsExpired),
 		"queue_len":       len(w.queue),
 		"queue_cap":       cap(w.queue),
 	}
@@ -123,9 +159,17 @@ func (w *EventWriter) writerLoop() {
 	ticker := time.NewTicker(batchInterval)
 	defer ticker.Stop()
 
+	var expire <-chan time.Time
+	if w.inactivityTimeout > 0 {
+		expireTicker := time.NewTicker(expireInterval)
+		defer expireTicker.Stop()
+		expire = expireTicker.C
+	}
+
 	for {
 		select {
 		case event := <-w.queue:
+			w.clock.observe(event.DetectedAt)
 			batch = append(batch, event)
 			if len(batch) >= batchSize {
 				w.writeBatch(batch)
@@ -138,6 +182,9 @@ func (w *EventWriter) writerLoop() {
 				batch = batch[:0]
 			}
 
+		case <-expire:
+			w.expireInactive()
+
 		case <-w.done:
 			// Flush remaining events
 			close(w.queue)
@@ -160,6 +207,7 @@ func (w *EventWriter) writeBatch(batch []models.BGPEvent) {
 	if len(batch) == 0 {
 		return
 	}
+	start := time.Now()
 
 	tx, err := w.db.Begin()
 	if err != nil {
@@ -180,11 +228,18 @@ func (w *EventWriter) writeBatch(batch []models.BGPEvent) {
 		return
 	}
 
-	w.eventsWritten += uint64(written)
-	w.batchesWritten++
+	atomic.AddUint64(&w.eventsWritten, uint64(written))
+	atomic.AddUint64(&w.batchesWritten, 1)
+	if w.observeBatch != nil {
+		w.observeBatch(time.Since(start))
+	}
 }
 
 func (w *EventWriter) writeEvent(tx *sql.Tx, event models.BGPEvent) bool {
+	if !event.IsActive {
+		return w.closeEvent(tx, event)
+	}
+
 	// Check for existing active event with same signature (deduplication)
 	var existingID int
 	var existingSeverity string
@@ -235,8 +290,8 @@ func (w *EventWriter) writeEvent(tx *sql.Tx, event models.BGPEvent) bool {
 			country_code, event_type, severity, event_category,
 			affected_asn, affected_prefix, details,
 			detected_at, last_seen_at, is_active,
-			is_cross_border, attacker_country, victim_country
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			is_cross_border, attacker_country, victim_country, rpki_status
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 	`,
 		event.CountryCode,
 		event.EventType,
@@ -247,10 +302,11 @@ func (w *EventWriter) writeEvent(tx *sql.Tx, event models.BGPEvent) bool {
 		detailsJSON,
 		event.DetectedAt,
 		event.DetectedAt,
-		true,
+		event.IsActive,
 		event.IsCrossBorder,
 		event.AttackerCountry,
 		event.VictimCountry,
+		event.RPKIStatus,
 	)
 
 	if err != nil {
@@ -260,3 +316,98 @@ func (w *EventWriter) writeEvent(tx *sql.Tx, event models.BGPEvent) bool {
 
 	return true
 }
+
+// closeEvent marks the active event matching an end signal as inactive and
+// records when it ended and how long it lasted. Events with neither an
+// affected ASN nor a prefix, such as country withdrawal storms, are matched
+// on their country.
+func (w *EventWriter) closeEvent(tx *sql.Tx, event models.BGPEvent) bool {
+	endedAt := event.EndedAt
+	if endedAt.IsZero() {
+		endedAt = event.DetectedAt
+	}
+
+	resolutionJSON, err := json.Marshal(map[string]interface{}{
+		"resolution": event.Details["resolution"],
+	})
+	if err != nil {
+		resolutionJSON = []byte("{}")
+	}
+
+	result, err := tx.Exec(`
+		UPDATE bgp_events
+		SET is_active = false,
+			ended_at = $1::timestamptz,
+			duration_seconds = GREATEST(0, EXTRACT(EPOCH FROM ($1::timestamptz - detected_at)))::INTEGER,
+			last_seen_at = GREATEST(last_seen_at, $1::timestamptz),
+			details = COALESCE(details, '{}'::jsonb) || $5::jsonb
+		WHERE event_type = $2
+		AND affected_asn = $3
+		AND affected_prefix = $4
+		AND (affected_asn <> 0 OR affected_prefix <> '' OR country_code = $6)
+		AND is_active = true
+	`, endedAt, event.EventType, event.AffectedASN, event.AffectedPrefix, resolutionJSON, event.CountryCode)
+	if err != nil {
+		log.Printf("Failed to close event: %v", err)
+		return false
+	}
+
+	// No matching row: the event started before the database was connected
+	// or was already closed by the inactivity timeout
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return false
+	}
+	atomic.AddUint64(&w.eventsResolved, 1)
+	return true
+}
+
+// expireInactive closes active events not seen within the inactivity timeout
+// on the event clock, so that replayed events are not expired against wall
+// time. Their end is the last time they were seen.
+func (w *EventWriter) expireInactive() {
+	result, err := w.db.Exec(`
+		UPDATE bgp_events
+		SET is_active = false,
+			ended_at = last_seen_at,
+			duration_seconds = GREATEST(0, EXTRACT(EPOCH FROM (last_seen_at - detected_at)))::INTEGER,
+			details = COALESCE(details, '{}'::jsonb) || '{"resolution": "timeout"}'::jsonb
+		WHERE is_active = true
+		AND last_seen_at < $1
+	`, w.clock.now().Add(-w.inactivityTimeout))
+	if err != nil {
+		log.Printf("Failed to expire inactive events: %v", err)
+		return
+	}
+
+	if n, err := result.RowsAffected(); err == nil && n > 0 {
+		atomic.AddUint64(&w.eventsExpired, uint64(n))
+		log.Printf("Closed %d events inactive for more than %s", n, w.inactivityTimeout)
+	}
+}
+
+// eventClock tells the time of the events: the latest event time seen, plus
+// the wall time elapsed since it was seen. Live events keep it at the
+// current time; replayed events at the time of the replayed updates.
+type eventClock struct {
+	latest time.Time // Latest event time seen
+	seenAt time.Time // Wall time it was seen
+}
+
+// observe sets the clock to t on the first event, then advances it to t
+// if t is later.
+func (c *eventClock) observe(t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	if c.latest.IsZero() || t.After(c.now()) {
+		c.latest, c.seenAt = t, time.Now()
+	}
+}
+
+// now returns the current event time, the wall time before any event.
+func (c *eventClock) now() time.Time {
+	if c.latest.IsZero() {
+		return time.Now()
+	}
+	return c.latest.Add(time.Since(c.seenAt))
+}
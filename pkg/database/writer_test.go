@@ -0,0 +1,33 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventClock(t *testing.T) {
+	var c eventClock
+	if d := time.Since(c.now()); d < 0 || d > time.Second {
+		t.Errorf("Expected wall time before any event, got %v", c.now())
+	}
+
+	// Replayed events set the clock to their own time
+	replayed := time.Date(2021, 10, 4, 15, 40, 0, 0, time.UTC)
+	c.observe(replayed)
+	if d := c.now().Sub(replayed); d < 0 || d > time.Second {
+		t.Errorf("Expected the clock at %v, got %v", replayed, c.now())
+	}
+
+	// Later events advance it, earlier ones do not move it back
+	c.observe(replayed.Add(time.Hour))
+	c.observe(replayed.Add(time.Minute))
+	if d := c.now().Sub(replayed.Add(time.Hour)); d < 0 || d > time.Second {
+		t.Errorf("Expected the clock at %v, got %v", replayed.Add(time.Hour), c.now())
+	}
+
+	// An event seen an hour before the clock is within a 6h timeout
+	cutoff := c.now().Add(-6 * time.Hour)
+	if !replayed.After(cutoff) {
+		t.Errorf("Expected %v to be within the timeout of %v", replayed, c.now())
+	}
+}
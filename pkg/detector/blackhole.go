@@ -4,30 +4,71 @@ import (
 	"time"
 
 	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+	"github.com/hervehildenbrand/bgp-radar/pkg/rpki"
 )
 
 // BlackholeDetector detects blackhole announcements via BGP communities.
+// A blackhole ends once every peer that saw it withdraws the prefix or
+// announces it without a blackhole community.
 type BlackholeDetector struct {
-	events chan<- models.BGPEvent
+	emitter
+	rpki *rpki.Validator
+
+	// Blackholes reported and not yet ended, by prefix
+	active *activeEvents
 }
 
 // NewBlackholeDetector creates a new blackhole detector.
 func NewBlackholeDetector(events chan<- models.BGPEvent) *BlackholeDetector {
-	return &BlackholeDetector{events: events}
+	return &BlackholeDetector{
+		emitter: emitter{events: events},
+		active:  newActiveEvents(),
+	}
+}
+
+// Name returns the detector name.
+func (d *BlackholeDetector) Name() string { return NameBlackhole }
+
+// Start is a no-op: the detector has no background work.
+func (d *BlackholeDetector) Start() {}
+
+// Stop is a no-op.
+func (d *BlackholeDetector) Stop() {}
+
+// Stats returns detector statistics.
+func (d *BlackholeDetector) Stats() map[string]interface{} {
+	return d.withCounters(map[string]interface{}{
+		"active_blackholes": d.active.count(),
+	})
+}
+
+// SetRPKIValidator enables RPKI origin validation of blackhole routes.
+func (d *BlackholeDetector) SetRPKIValidator(v *rpki.Validator) {
+	d.rpki = v
+}
+
+// SetEventTimeout sets how long a reported blackhole may go unseen before it expires
+// (DefaultEventTimeout by default; 0 disables expiry).
+func (d *BlackholeDetector) SetEventTimeout(timeout time.Duration) {
+	d.active.setTimeout(timeout)
 }
 
 // Process checks a BGP update for blackhole communities.
 func (d *BlackholeDetector) Process(update models.BGPUpdate) {
+	d.expireActive(d.active, update)
+
 	if !update.Announcement {
-		return // Withdrawals don't have communities
+		d.end(update, ResolutionWithdrawn)
+		return
 	}
 
-	if !HasBlackholeCommunity(update.Communities) {
+	// Get blackhole communities found, of any kind
+	blackholeCommunities := FindBlackholeCommunities(update)
+	if len(blackholeCommunities) == 0 {
+		d.end(update, ResolutionCommunityRemoved)
 		return
 	}
 
-	// Get blackhole communities found
-	blackholeCommunities := GetBlackholeCommunities(update.Communities)
 	prefixLen := getPrefixLength(update.Prefix)
 	isHostRoute := (prefixLen == 32) || (prefixLen == 128)
 
@@ -54,25 +95,50 @@ func (d *BlackholeDetector) Process(update models.BGPUpdate) {
 		EventCategory:  models.CategoryDefense,
 		AffectedASN:    update.OriginASN,
 		AffectedPrefix: update.Prefix,
-		DetectedAt:     time.Now(),
+		DetectedAt:     updateTime(update),
 		IsActive:       true,
 		Details: map[string]interface{}{
-			"communities":          update.Communities,
+			"communities":           update.Communities,
 			"blackhole_communities": blackholeCommunities,
-			"as_path":              update.ASPath,
-			"peer_asn":             update.PeerASN,
-			"collector":            update.Collector,
-			"signal":               "blackhole_community",
-			"is_host_route":        isHostRoute,
-			"confidence":           confidence,
+			"as_path":               update.ASPath,
+			"peer_asn":              update.PeerASN,
+			"collector":             update.Collector,
+			"source":                update.Source,
+			"signal":                "blackhole_community",
+			"is_host_route":         isHostRoute,
+			"confidence":            confidence,
 		},
 	}
+	tagAmbiguousOrigin(event.Details, update)
+	if len(update.LargeCommunities) > 0 {
+		event.Details["large_communities"] = update.LargeCommunities
+	}
+	if len(update.ExtendedCommunities) > 0 {
+		event.Details["extended_communities"] = update.ExtendedCommunities
+	}
+
+	// Blackhole host routes almost always exceed the ROA maxLength, so only
+	// an unauthorized origin is treated as suspicious.
+	rpkiResult := d.rpki.Validate(update.Prefix, update.OriginASN)
+	stampRPKI(&event, rpkiResult)
+	if rpkiResult.Status == models.RPKIInvalid && rpkiResult.Reason == rpki.ReasonASN {
+		escalateRPKIInvalid(&event, "rpki_invalid_origin")
+	}
+
+	d.emit(event)
+	d.active.observe(update.Prefix, peerKey(update), event, updateTime(update))
+}
 
-	// Non-blocking send
-	select {
-	case d.events <- event:
-	default:
+// end removes the update peer from an active blackhole on the prefix and
+// emits the end of the blackhole once no peer sees it anymore.
+func (d *BlackholeDetector) end(update models.BGPUpdate, reason string) {
+	event, ended := d.active.leave(update.Prefix, peerKey(update), update, reason)
+	if !ended {
+		return
 	}
+	event.Details["signal"] = "blackhole_community"
+
+	d.emit(event)
 }
 
 func getPrefixLength(prefix string) int {
@@ -1,10 +1,12 @@
 package detector
 
 import (
+	"net/netip"
 	"testing"
 	"time"
 
 	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+	"github.com/hervehildenbrand/bgp-radar/pkg/rpki"
 )
 
 func TestBlackholeDetector_RFC7999(t *testing.T) {
@@ -123,6 +125,56 @@ func TestBlackholeDetector_Withdrawal(t *testing.T) {
 	}
 }
 
+func TestBlackholeDetector_RPKI(t *testing.T) {
+	validator := rpki.NewValidator()
+	validator.Replace(rpki.NewTable([]rpki.VRP{
+		{Prefix: netip.MustParsePrefix("203.0.113.0/24"), MaxLength: 24, ASN: 12345},
+	}, "test"))
+
+	tests := []struct {
+		name     string
+		origin   uint32
+		status   string
+		severity string
+	}{
+		// /32 exceeds maxLength but the origin is authorized: normal blackhole
+		{"authorized origin", 12345, models.RPKIInvalid, models.SeverityMedium},
+		// Unauthorized origin blackholing someone else's space
+		{"unauthorized origin", 64512, models.RPKIInvalid, models.SeverityHigh},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := make(chan models.BGPEvent, 10)
+			d := NewBlackholeDetector(events)
+			d.SetRPKIValidator(validator)
+
+			d.Process(models.BGPUpdate{
+				Timestamp:    time.Now(),
+				PeerASN:      6939,
+				Prefix:       "203.0.113.10/32",
+				ASPath:       []uint32{6939, tt.origin},
+				OriginASN:    tt.origin,
+				Communities:  []string{"65535:666"},
+				Announcement: true,
+				Collector:    "rrc00",
+			})
+
+			select {
+			case event := <-events:
+				if event.RPKIStatus != tt.status {
+					t.Errorf("Expected RPKI status %s, got %s", tt.status, event.RPKIStatus)
+				}
+				if event.Severity != tt.severity {
+					t.Errorf("Expected severity %s, got %s", tt.severity, event.Severity)
+				}
+			case <-time.After(100 * time.Millisecond):
+				t.Error("Expected blackhole event, got none")
+			}
+		})
+	}
+}
+
 func TestHasBlackholeCommunity(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -223,3 +275,111 @@ func TestGetBlackholeCommunities(t *testing.T) {
 		}
 	}
 }
+
+func TestBlackholeDetector_End(t *testing.T) {
+	events := make(chan models.BGPEvent, 10)
+	d := NewBlackholeDetector(events)
+
+	blackholed := announce("192.0.2.1/32", 6939, 13335)
+	blackholed.Communities = []string{"65535:666"}
+	d.Process(blackholed)
+	<-events
+
+	// Same route without the blackhole community
+	d.Process(announce("192.0.2.1/32", 6939, 13335))
+
+	select {
+	case event := <-events:
+		if event.IsActive {
+			t.Error("Expected inactive end event")
+		}
+		if event.EventType != models.EventTypeBlackhole || event.AffectedASN != 13335 {
+			t.Errorf("Unexpected end event %s for AS%d", event.EventType, event.AffectedASN)
+		}
+		if event.Details["resolution"] != ResolutionCommunityRemoved {
+			t.Errorf("Expected resolution %s, got %v", ResolutionCommunityRemoved, event.Details["resolution"])
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Error("Expected blackhole end event, got none")
+	}
+}
+
+func TestSetReferences(t *testing.T) {
+	defer SetReferences(DefaultReferences())
+
+	SetReferences(DefaultReferences().With(
+		map[uint32]string{64500: "Test Transit"},
+		map[uint32]string{64501: "Test Scrubbing"},
+		map[uint32][]string{3356: {"3356:666"}},
+	))
+	if !IsTier1(64500) || !IsTier1(174) {
+		t.Errorf("Expected added and built-in Tier-1 ASNs")
+	}
+	if ScrubbingName(64501) != "Test Scrubbing" {
+		t.Errorf("Expected added scrubbing center, got %q", ScrubbingName(64501))
+	}
+	if !IsBlackholeCommunity("3356:666") || !IsBlackholeCommunity("3356:9999") {
+		t.Errorf("Expected the added and built-in provider communities to be used")
+	}
+	if !IsBlackholeCommunity(RFC7999Blackhole) {
+		t.Errorf("Expected the RFC 7999 community to always match")
+	}
+	if _, ok := Tier1ASNs[64500]; ok {
+		t.Errorf("Expected the built-in defaults to be unchanged")
+	}
+}
+
+func TestBlackholeDetector_LargeAndExtendedCommunities(t *testing.T) {
+	defer SetReferences(DefaultReferences())
+	SetReferences(DefaultReferences().With(nil, nil, map[uint32][]string{
+		64500: {"rt:64500:666"},
+	}))
+
+	tests := []struct {
+		name     string
+		update   models.BGPUpdate
+		expected string
+	}{
+		{
+			name:     "standard community",
+			update:   models.BGPUpdate{Communities: []string{"6939:100", "6939:666"}},
+			expected: "6939:666",
+		},
+		{
+			name:     "large community",
+			update:   models.BGPUpdate{LargeCommunities: []models.LargeCommunity{{GlobalAdmin: 6939, LocalData1: 666}}},
+			expected: "6939:666:0",
+		},
+		{
+			name: "extended community",
+			update: models.BGPUpdate{ExtendedCommunities: []models.ExtendedCommunity{
+				{Type: models.ExtTypeTwoOctetAS, Subtype: models.ExtSubtypeRouteTarget, Value: [6]byte{0xfb, 0xf4, 0, 0, 0x02, 0x9a}},
+			}},
+			expected: "rt:64500:666",
+		},
+	}
+	for _, tt := range tests {
+		events := make(chan models.BGPEvent, 10)
+		d := NewBlackholeDetector(events)
+		update := tt.update
+		update.Prefix = "192.0.2.1/32"
+		update.ASPath = []uint32{6939, 64500}
+		update.OriginASN = 64500
+		update.Announcement = true
+		d.Process(update)
+
+		select {
+		case event := <-events:
+			found, _ := event.Details["blackhole_communities"].([]string)
+			if len(found) != 1 || found[0] != tt.expected {
+				t.Errorf("%s: expected blackhole communities [%s], got %v", tt.name, tt.expected, found)
+			}
+		case <-time.After(100 * time.Millisecond):
+			t.Errorf("%s: expected blackhole event, got none", tt.name)
+		}
+	}
+
+	if FindBlackholeCommunities(models.BGPUpdate{LargeCommunities: []models.LargeCommunity{{GlobalAdmin: 6939, LocalData1: 100}}}) != nil {
+		t.Error("Expected no blackhole community for 6939:100:0")
+	}
+}
@@ -1,6 +1,8 @@
 // Package detector provides BGP anomaly detection logic.
 package detector
 
+import "github.com/hervehildenbrand/bgp-radar/pkg/models"
+
 // Tier1ASNs contains the ASNs of known Tier-1 transit providers.
 // Used for leak detection (SmallAS between two Tier-1s is suspicious).
 var Tier1ASNs = map[uint32]string{
@@ -80,58 +82,58 @@ var ScrubbingASNs = map[uint32]string{
 // RFC7999Blackhole is the well-known blackhole community from RFC 7999.
 const RFC7999Blackhole = "65535:666"
 
-// ProviderBlackholeCommunities maps provider ASNs to their blackhole community.
+// ProviderBlackholeCommunities maps provider ASNs to their blackhole
+// communities: standard communities, and the large communities (RFC 8092)
+// "ASN:666:0" of providers that accept them.
 // CRITICAL: Many providers use NON-STANDARD suffixes (not :666)!
 // Using pattern matching would cause false positives.
-var ProviderBlackholeCommunities = map[uint32]string{
+var ProviderBlackholeCommunities = map[uint32][]string{
 	// NON-STANDARD suffixes
-	3356: "3356:9999", // Lumen/Level3 (NOT 666!)
-	1299: "1299:999",  // Arelion/Telia (NOT 666!)
-	3491: "3491:999",  // PCCW (NOT 666!)
-	286:  "286:66",    // KPN (NOT 666!)
+	3356: {"3356:9999"}, // Lumen/Level3 (NOT 666!)
+	1299: {"1299:999"},  // Arelion/Telia (NOT 666!)
+	3491: {"3491:999"},  // PCCW (NOT 666!)
+	286:  {"286:66"},    // KPN (NOT 666!)
 	// Standard :666 suffix
-	2914:  "2914:666",  // NTT
-	3257:  "3257:666",  // GTT
-	7018:  "7018:666",  // AT&T
-	6939:  "6939:666",  // Hurricane Electric
-	3320:  "3320:666",  // Deutsche Telekom
-	6453:  "6453:666",  // Tata
-	6461:  "6461:666",  // Zayo
-	701:   "701:666",   // Verizon
-	1239:  "1239:666",  // Sprint
-	12956: "12956:666", // Telefonica
-	6762:  "6762:666",  // Telecom Italia Sparkle
-	6830:  "6830:666",  // Liberty Global
-	9002:  "9002:666",  // RETN
-	20804: "20804:666", // Exatel
+	2914:  {"2914:666", "2914:666:0"},   // NTT
+	3257:  {"3257:666", "3257:666:0"},   // GTT
+	7018:  {"7018:666"},                 // AT&T
+	6939:  {"6939:666", "6939:666:0"},   // Hurricane Electric
+	3320:  {"3320:666"},                 // Deutsche Telekom
+	6453:  {"6453:666"},                 // Tata
+	6461:  {"6461:666", "6461:666:0"},   // Zayo
+	701:   {"701:666"},                  // Verizon
+	1239:  {"1239:666"},                 // Sprint
+	12956: {"12956:666"},                // Telefonica
+	6762:  {"6762:666"},                 // Telecom Italia Sparkle
+	6830:  {"6830:666"},                 // Liberty Global
+	9002:  {"9002:666", "9002:666:0"},   // RETN
+	20804: {"20804:666", "20804:666:0"}, // Exatel
 }
 
-// KnownBlackholeCommunities is a set of all known blackhole communities for O(1) lookup.
-var KnownBlackholeCommunities map[string]bool
-
 func init() {
-	KnownBlackholeCommunities = make(map[string]bool)
-	KnownBlackholeCommunities[RFC7999Blackhole] = true
-	for _, community := range ProviderBlackholeCommunities {
-		KnownBlackholeCommunities[community] = true
-	}
+	references.Store(DefaultReferences())
 }
 
 // IsTier1 checks if an ASN is a known Tier-1 provider.
 func IsTier1(asn uint32) bool {
-	_, ok := Tier1ASNs[asn]
+	_, ok := references.Load().Tier1ASNs[asn]
 	return ok
 }
 
 // IsScrubbing checks if an ASN is a known scrubbing/DDoS mitigation center.
 func IsScrubbing(asn uint32) bool {
-	_, ok := ScrubbingASNs[asn]
+	_, ok := references.Load().ScrubbingASNs[asn]
 	return ok
 }
 
+// ScrubbingName returns the name of a scrubbing center, or "" if unknown.
+func ScrubbingName(asn uint32) string {
+	return references.Load().ScrubbingASNs[asn]
+}
+
 // IsBlackholeCommunity checks if a community string indicates blackholing.
 func IsBlackholeCommunity(community string) bool {
-	return KnownBlackholeCommunities[community]
+	return references.Load().blackhole[community]
 }
 
 // HasBlackholeCommunity checks if any community in the list is a blackhole.
@@ -154,6 +156,23 @@ func HasScrubbingCenter(asPath []uint32) bool {
 	return false
 }
 
+// FindBlackholeCommunities returns the standard, large and extended
+// communities of an update that indicate blackholing.
+func FindBlackholeCommunities(update models.BGPUpdate) []string {
+	found := GetBlackholeCommunities(update.Communities)
+	for _, c := range update.LargeCommunities {
+		if s := c.String(); IsBlackholeCommunity(s) {
+			found = append(found, s)
+		}
+	}
+	for _, c := range update.ExtendedCommunities {
+		if s := c.String(); IsBlackholeCommunity(s) {
+			found = append(found, s)
+		}
+	}
+	return found
+}
+
 // GetBlackholeCommunities returns the list of blackhole communities found.
 func GetBlackholeCommunities(communities []string) []string {
 	var blackholeCommunities []string
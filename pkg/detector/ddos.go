@@ -0,0 +1,186 @@
+package detector
+
+import (
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/cache"
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+	"github.com/hervehildenbrand/bgp-radar/pkg/rpki"
+)
+
+// DDoSDetector detects DDoS mitigation diversions: a prefix whose path
+// suddenly starts going through a scrubbing center (Prolexic, Magic Transit,
+// Radware...), or that a scrubbing center starts originating.
+//
+// Prefixes first seen through a scrubbing center are always-on customers and
+// are not reported. A diversion ends once every peer that saw it announces a
+// clean path again or withdraws the prefix.
+type DDoSDetector struct {
+	emitter
+	rpki *rpki.Validator
+
+	// Last origin seen on a clean path, by prefix; 0 for prefixes first
+	// seen through a scrubbing center. Prefix affinity in the dispatcher
+	// keeps each prefix on one worker, so no lock is needed around it.
+	clean *cache.Cache[uint32]
+
+	// Diversions reported and not yet ended, by prefix
+	active *activeEvents
+}
+
+// DDoS prefix state bounds: prefixes not announced for scrubbingStateTTL,
+// or least recently seen beyond DefaultOriginCacheSize, are forgotten and
+// learned again from their next announcement.
+const scrubbingStateTTL = 48 * time.Hour
+
+// NewDDoSDetector creates a new DDoS diversion detector.
+func NewDDoSDetector(events chan<- models.BGPEvent) *DDoSDetector {
+	return &DDoSDetector{
+		emitter: emitter{events: events},
+		clean:   cache.New[uint32](cache.Config{MaxEntries: DefaultOriginCacheSize, TTL: scrubbingStateTTL}),
+		active:  newActiveEvents(),
+	}
+}
+
+// SetRPKIValidator sets the RPKI validator used to stamp diversion events.
+func (d *DDoSDetector) SetRPKIValidator(v *rpki.Validator) {
+	d.rpki = v
+}
+
+// SetEventTimeout sets how long a reported diversion may go unseen before it expires
+// (DefaultEventTimeout by default; 0 disables expiry).
+func (d *DDoSDetector) SetEventTimeout(timeout time.Duration) {
+	d.active.setTimeout(timeout)
+}
+
+// Name returns the detector name.
+func (d *DDoSDetector) Name() string { return NameDDoS }
+
+// Start is a no-op: the detector has no background work.
+func (d *DDoSDetector) Start() {}
+
+// Stop is a no-op.
+func (d *DDoSDetector) Stop() {}
+
+// Process checks a BGP update for scrubbing center diversions.
+func (d *DDoSDetector) Process(update models.BGPUpdate) {
+	d.expireActive(d.active, update)
+
+	now := updateTime(update)
+	peer := peerKey(update)
+
+	if !update.Announcement {
+		if _, ok := d.active.get(update.Prefix); ok {
+			d.leave(update, peer, ResolutionWithdrawn)
+		} else {
+			d.clean.Delete(update.Prefix)
+		}
+		return
+	}
+	if update.OriginASN == 0 {
+		return
+	}
+
+	scrubbingASN, mode := findScrubbing(update)
+	if scrubbingASN == 0 {
+		// Clean path
+		d.clean.Set(update.Prefix, update.OriginASN)
+		d.leave(update, peer, ResolutionPathRestored)
+		return
+	}
+
+	cleanOrigin, seen := d.clean.Get(update.Prefix)
+	if !seen {
+		// First seen through a scrubbing center: always-on protection
+		d.clean.Set(update.Prefix, 0)
+		return
+	}
+	if cleanOrigin == 0 {
+		return
+	}
+	if _, ok := d.active.get(update.Prefix); ok {
+		d.active.observe(update.Prefix, peer, models.BGPEvent{}, now)
+		return
+	}
+
+	confidence := 0.8
+	if mode == "origin" {
+		confidence = 0.85 // Scrubbing center took over origination
+	}
+
+	event := models.BGPEvent{
+		EventType:      models.EventTypeDDoS,
+		Severity:       models.SeverityMedium,
+		EventCategory:  models.CategoryDefense,
+		AffectedASN:    cleanOrigin,
+		AffectedPrefix: update.Prefix,
+		DetectedAt:     now,
+		IsActive:       true,
+		Details: map[string]interface{}{
+			"signal":             "scrubbing_diversion",
+			"mode":               mode,
+			"scrubbing_asn":      scrubbingASN,
+			"scrubbing_provider": ScrubbingName(scrubbingASN),
+			"original_origin":    cleanOrigin,
+			"started_at":         now.UTC().Format(time.RFC3339),
+			"as_path":            update.ASPath,
+			"peer_asn":           update.PeerASN,
+			"collector":          update.Collector,
+			"source":             update.Source,
+			"confidence":         confidence,
+		},
+	}
+
+	rpkiResult := d.rpki.Validate(update.Prefix, update.OriginASN)
+	stampRPKI(&event, rpkiResult)
+	if rpkiResult.Status == models.RPKIInvalid {
+		escalateRPKIInvalid(&event, "rpki_invalid")
+	}
+
+	d.active.observe(update.Prefix, peer, event, now)
+	d.emit(event)
+}
+
+// Stats returns detector statistics.
+func (d *DDoSDetector) Stats() map[string]interface{} {
+	return d.withCounters(map[string]interface{}{
+		"tracked_prefixes":  d.clean.Len(),
+		"active_diversions": d.active.count(),
+		"prefix_cache":      d.clean.Stats(),
+	})
+}
+
+// leave removes a peer from a diversion and reports the end of the diversion
+// once no peer sees it anymore.
+func (d *DDoSDetector) leave(update models.BGPUpdate, peer, reason string) {
+	diversion, ok := d.active.get(update.Prefix)
+	if !ok {
+		return
+	}
+	event, ended := d.active.leave(update.Prefix, peer, update, reason)
+	if !ended {
+		return
+	}
+
+	event.Severity = models.SeverityLow
+	event.Details["signal"] = "scrubbing_diversion_ended"
+	for _, key := range []string{"mode", "scrubbing_asn", "scrubbing_provider", "original_origin"} {
+		event.Details[key] = diversion.Details[key]
+	}
+
+	d.emit(event)
+}
+
+// findScrubbing returns the scrubbing center ASN in the update and whether it
+// originates the prefix ("origin") or only transits it ("path").
+func findScrubbing(update models.BGPUpdate) (uint32, string) {
+	if IsScrubbing(update.OriginASN) {
+		return update.OriginASN, "origin"
+	}
+	for _, asn := range update.ASPath {
+		if IsScrubbing(asn) {
+			return asn, "path"
+		}
+	}
+	return 0, ""
+}
@@ -0,0 +1,202 @@
+package detector
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+	"github.com/hervehildenbrand/bgp-radar/pkg/rpki"
+)
+
+func TestDDoSDetector_Diversion(t *testing.T) {
+	events := make(chan models.BGPEvent, 10)
+	d := NewDDoSDetector(events)
+
+	// Normal path, then Prolexic appears in the path
+	d.Process(announce("203.0.113.0/24", 6939, 3356, 64500))
+	d.Process(announce("203.0.113.0/24", 6939, 32787, 64500))
+
+	select {
+	case event := <-events:
+		if event.EventType != models.EventTypeDDoS {
+			t.Errorf("Expected event type %s, got %s", models.EventTypeDDoS, event.EventType)
+		}
+		if event.EventCategory != models.CategoryDefense {
+			t.Errorf("Expected category %s, got %s", models.CategoryDefense, event.EventCategory)
+		}
+		if event.AffectedASN != 64500 {
+			t.Errorf("Expected affected ASN 64500, got %d", event.AffectedASN)
+		}
+		if event.Details["scrubbing_provider"] != "Akamai Prolexic" {
+			t.Errorf("Expected provider Akamai Prolexic, got %v", event.Details["scrubbing_provider"])
+		}
+		if event.Details["mode"] != "path" {
+			t.Errorf("Expected mode path, got %v", event.Details["mode"])
+		}
+		if !event.IsActive {
+			t.Error("Expected active event")
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Expected ddos event, got none")
+	}
+
+	// Path restored by the same peer ends the diversion
+	d.Process(announce("203.0.113.0/24", 6939, 3356, 64500))
+
+	select {
+	case event := <-events:
+		if event.IsActive {
+			t.Error("Expected inactive end event")
+		}
+		if event.Details["resolution"] != ResolutionPathRestored {
+			t.Errorf("Expected resolution path_restored, got %v", event.Details["resolution"])
+		}
+		if _, ok := event.Details["ended_at"]; !ok {
+			t.Error("Expected ended_at in details")
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Expected diversion end event, got none")
+	}
+}
+
+func TestDDoSDetector_ScrubbingOrigin(t *testing.T) {
+	events := make(chan models.BGPEvent, 10)
+	d := NewDDoSDetector(events)
+
+	// Magic Transit: Cloudflare starts originating the customer prefix
+	d.Process(announce("198.51.100.0/24", 174, 64500))
+	d.Process(announce("198.51.100.0/24", 174, 13335))
+
+	select {
+	case event := <-events:
+		if event.Details["mode"] != "origin" {
+			t.Errorf("Expected mode origin, got %v", event.Details["mode"])
+		}
+		if event.Details["scrubbing_asn"] != uint32(13335) {
+			t.Errorf("Expected scrubbing ASN 13335, got %v", event.Details["scrubbing_asn"])
+		}
+		if event.AffectedASN != 64500 {
+			t.Errorf("Expected affected ASN 64500, got %d", event.AffectedASN)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Expected ddos event, got none")
+	}
+}
+
+func TestDDoSDetector_AlwaysOn(t *testing.T) {
+	events := make(chan models.BGPEvent, 10)
+	d := NewDDoSDetector(events)
+
+	// Prefix always announced through a scrubbing center
+	d.Process(announce("1.1.1.0/24", 6939, 13335))
+	d.Process(announce("1.1.1.0/24", 174, 13335))
+
+	select {
+	case event := <-events:
+		t.Errorf("Expected no event for always-on protection, got %+v", event.Details)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDDoSDetector_WithdrawalForgetsPrefix(t *testing.T) {
+	events := make(chan models.BGPEvent, 10)
+	d := NewDDoSDetector(events)
+
+	d.Process(announce("203.0.113.0/24", 6939, 3356, 64500))
+	d.Process(withdraw("203.0.113.0/24", 6939, time.Now()))
+
+	if tracked := d.Stats()["tracked_prefixes"]; tracked != 0 {
+		t.Fatalf("Expected withdrawn prefix to be forgotten, got %v tracked", tracked)
+	}
+
+	// Announced again through a scrubbing center: learned as always-on
+	d.Process(announce("203.0.113.0/24", 6939, 32787, 64500))
+
+	select {
+	case event := <-events:
+		t.Errorf("Expected no event after the prefix was forgotten, got %+v", event.Details)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDDoSDetector_RPKI(t *testing.T) {
+	validator := rpki.NewValidator()
+	validator.Replace(rpki.NewTable([]rpki.VRP{
+		{Prefix: netip.MustParsePrefix("203.0.113.0/24"), MaxLength: 24, ASN: 64500},
+	}, "test"))
+
+	tests := []struct {
+		name     string
+		path     []uint32
+		status   string
+		severity string
+	}{
+		{"path diversion", []uint32{6939, 32787, 64500}, models.RPKIValid, models.SeverityMedium},
+		{"origin takeover", []uint32{174, 13335}, models.RPKIInvalid, models.SeverityHigh},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := make(chan models.BGPEvent, 10)
+			d := NewDDoSDetector(events)
+			d.SetRPKIValidator(validator)
+
+			d.Process(announce("203.0.113.0/24", 6939, 3356, 64500))
+			d.Process(announce("203.0.113.0/24", tt.path...))
+
+			select {
+			case event := <-events:
+				if event.RPKIStatus != tt.status {
+					t.Errorf("Expected RPKI status %s, got %s", tt.status, event.RPKIStatus)
+				}
+				if event.Severity != tt.severity {
+					t.Errorf("Expected severity %s, got %s", tt.severity, event.Severity)
+				}
+			case <-time.After(100 * time.Millisecond):
+				t.Fatal("Expected ddos event, got none")
+			}
+		})
+	}
+}
+
+func TestDDoSDetector_Expiry(t *testing.T) {
+	events := make(chan models.BGPEvent, 10)
+	d := NewDDoSDetector(events)
+	d.SetEventTimeout(time.Hour)
+
+	at := func(update models.BGPUpdate, offset time.Duration) models.BGPUpdate {
+		update.Timestamp = time.Unix(1705320000, 0).Add(offset)
+		return update
+	}
+	d.Process(at(announce("203.0.113.0/24", 6939, 3356, 64500), 0))
+	d.Process(at(announce("203.0.113.0/24", 6939, 32787, 64500), time.Minute))
+	<-events
+
+	// No peer seen with the diversion for over an hour: it expires
+	d.Process(at(announce("198.51.100.0/24", 6939, 3356, 64501), 2*time.Hour))
+
+	select {
+	case event := <-events:
+		if event.IsActive || event.Details["resolution"] != ResolutionTimeout {
+			t.Errorf("Expected a timeout end event, got active=%v resolution=%v", event.IsActive, event.Details["resolution"])
+		}
+		if !event.EndedAt.Equal(time.Unix(1705320000, 0).Add(time.Minute)) {
+			t.Errorf("Expected the diversion to end when last seen, got %v", event.EndedAt)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Expected diversion expiry event, got none")
+	}
+
+	// Still diverted: reported again
+	d.Process(at(announce("203.0.113.0/24", 6939, 32787, 64500), 2*time.Hour+time.Minute))
+
+	select {
+	case event := <-events:
+		if !event.IsActive {
+			t.Error("Expected the diversion to be reported again")
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Expected ddos event, got none")
+	}
+}
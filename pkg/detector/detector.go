@@ -0,0 +1,128 @@
+package detector
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+// Detector inspects BGP updates and reports anomalies as events.
+// Process is called concurrently from several workers.
+type Detector interface {
+	// Name returns the name the detector is registered under.
+	Name() string
+	// Process inspects a single BGP update.
+	Process(update models.BGPUpdate)
+	// Start begins any background work.
+	Start()
+	// Stop stops any background work.
+	Stop()
+	// Stats returns detector statistics.
+	Stats() map[string]interface{}
+}
+
+// Configurable is implemented by detectors accepting options by name,
+// e.g. "window" = "10m" for the withdrawal storm detector. Configure sets
+// every option: those not given are reset to their defaults, so a running
+// detector can be reconfigured with the options of a reloaded file.
+type Configurable interface {
+	Configure(options map[string]string) error
+}
+
+// EventKind identifies events by type and severity.
+type EventKind struct {
+	Type     string
+	Severity string
+}
+
+// EventCounter is implemented by detectors counting the events they emit.
+type EventCounter interface {
+	// EventCounts returns the number of events emitted per kind.
+	EventCounts() map[EventKind]uint64
+	// DroppedEvents returns the number of events dropped because the events
+	// channel was full.
+	DroppedEvents() uint64
+}
+
+// emitter sends events to the events channel without blocking and counts
+// events emitted and dropped because the channel was full. Detectors embed
+// it to implement EventCounter.
+type emitter struct {
+	events  chan<- models.BGPEvent
+	emitted atomic.Uint64
+	dropped atomic.Uint64
+
+	kindsMu sync.Mutex
+	kinds   map[EventKind]uint64
+}
+
+// emit sends an event, dropping it if the events channel is full.
+func (e *emitter) emit(event models.BGPEvent) {
+	// Non-blocking send
+	select {
+	case e.events <- event:
+		e.emitted.Add(1)
+	default:
+		e.dropped.Add(1)
+		return
+	}
+
+	e.kindsMu.Lock()
+	if e.kinds == nil {
+		e.kinds = make(map[EventKind]uint64)
+	}
+	e.kinds[EventKind{Type: event.EventType, Severity: event.Severity}]++
+	e.kindsMu.Unlock()
+}
+
+// EventCounts returns the number of events emitted per kind.
+func (e *emitter) EventCounts() map[EventKind]uint64 {
+	e.kindsMu.Lock()
+	defer e.kindsMu.Unlock()
+
+	counts := make(map[EventKind]uint64, len(e.kinds))
+	for kind, n := range e.kinds {
+		counts[kind] = n
+	}
+	return counts
+}
+
+// DroppedEvents returns the number of events dropped on a full channel.
+func (e *emitter) DroppedEvents() uint64 {
+	return e.dropped.Load()
+}
+
+// withCounters adds the emitted and dropped counters to detector stats.
+func (e *emitter) withCounters(stats map[string]interface{}) map[string]interface{} {
+	stats["events_emitted"] = e.emitted.Load()
+	stats["events_dropped"] = e.dropped.Load()
+	return stats
+}
+
+func parseDurationOption(name, value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("option %s: invalid duration %q", name, value)
+	}
+	return d, nil
+}
+
+func parseIntOption(name, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("option %s: invalid positive integer %q", name, value)
+	}
+	return n, nil
+}
+
+func parseFractionOption(name, value string) (float64, error) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f <= 0 || f > 1 {
+		return 0, fmt.Errorf("option %s: invalid fraction %q (want 0 < f <= 1)", name, value)
+	}
+	return f, nil
+}
@@ -0,0 +1,129 @@
+package detector
+
+import (
+	"net/netip"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+// DefaultWorkerQueueSize is the default queue size of each worker.
+const DefaultWorkerQueueSize = 1000
+
+// Dispatcher processes updates on a pool of workers with prefix affinity:
+// updates are routed by the block of their prefix (the covering /16 for
+// IPv4, /32 for IPv6) onto per-worker queues, so the updates of a prefix and
+// of its nearby more-specifics are always processed in order by the same
+// worker. Detectors may then read and update per-prefix state without
+// racing with other workers.
+type Dispatcher struct {
+	process func(models.BGPUpdate)
+	queues  []chan models.BGPUpdate
+	updates []atomic.Uint64 // Per worker
+	wg      sync.WaitGroup
+	done    chan struct{}
+}
+
+// NewDispatcher creates a dispatcher calling process from the given number
+// of workers, each with a queue of queueSize updates (0 for
+// DefaultWorkerQueueSize).
+func NewDispatcher(process func(models.BGPUpdate), workers, queueSize int) *Dispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize <= 0 {
+		queueSize = DefaultWorkerQueueSize
+	}
+	d := &Dispatcher{
+		process: process,
+		queues:  make([]chan models.BGPUpdate, workers),
+		updates: make([]atomic.Uint64, workers),
+		done:    make(chan struct{}),
+	}
+	for i := range d.queues {
+		d.queues[i] = make(chan models.BGPUpdate, queueSize)
+	}
+	return d
+}
+
+// Start starts the workers and routes the updates read from in to them.
+// When in is closed, the workers finish their queues and Done is closed.
+func (d *Dispatcher) Start(in <-chan models.BGPUpdate) {
+	for i, queue := range d.queues {
+		d.wg.Add(1)
+		go d.work(i, queue)
+	}
+	go func() {
+		for update := range in {
+			// Blocks while the worker is busy: a hot block slows the
+			// dispatch rather than reordering its updates
+			d.queues[d.worker(update.Prefix)] <- update
+		}
+		for _, queue := range d.queues {
+			close(queue)
+		}
+		d.wg.Wait()
+		close(d.done)
+	}()
+}
+
+func (d *Dispatcher) work(i int, queue <-chan models.BGPUpdate) {
+	defer d.wg.Done()
+	for update := range queue {
+		d.updates[i].Add(1)
+		d.process(update)
+	}
+}
+
+// Done is closed once the input channel is closed and every queued update
+// has been processed.
+func (d *Dispatcher) Done() <-chan struct{} {
+	return d.done
+}
+
+// worker returns the worker of a prefix, by the hash of its block.
+func (d *Dispatcher) worker(prefix string) int {
+	if len(d.queues) == 1 {
+		return 0
+	}
+	return int(blockHash(prefix) % uint32(len(d.queues)))
+}
+
+// blockHash hashes the /16 (IPv4) or /32 (IPv6) block of a prefix, or the
+// prefix string itself if it does not parse.
+func blockHash(prefix string) uint32 {
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return fnv32a([]byte(prefix))
+	}
+	a := p.Addr().As16()
+	if p.Addr().Is4() {
+		return fnv32a(a[12:14])
+	}
+	return fnv32a(a[0:4])
+}
+
+// fnv32a is FNV-1a, inlined to hash without allocating.
+func fnv32a(b []byte) uint32 {
+	h := uint32(2166136261)
+	for _, c := range b {
+		h ^= uint32(c)
+		h *= 16777619
+	}
+	return h
+}
+
+// Stats returns per-worker queue depth and processed update counts.
+func (d *Dispatcher) Stats() map[string]interface{} {
+	workers := make([]map[string]interface{}, len(d.queues))
+	for i, queue := range d.queues {
+		workers[i] = map[string]interface{}{
+			"worker":    i,
+			"queue_len": len(queue),
+			"queue_cap": cap(queue),
+			"updates":   d.updates[i].Load(),
+		}
+	}
+	return map[string]interface{}{"workers": workers}
+}
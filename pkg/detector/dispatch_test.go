@@ -0,0 +1,67 @@
+package detector
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+func TestDispatcher_PrefixAffinity(t *testing.T) {
+	var mu sync.Mutex
+	workerOf := make(map[string]int)
+	seen := make(map[string][]uint32)
+
+	d := NewDispatcher(func(update models.BGPUpdate) {
+		mu.Lock()
+		defer mu.Unlock()
+		seen[update.Prefix] = append(seen[update.Prefix], update.PeerASN)
+	}, 4, 10)
+
+	in := make(chan models.BGPUpdate)
+	d.Start(in)
+	prefixes := []string{"10.1.0.0/16", "10.1.5.0/24", "10.2.0.0/24", "2001:db8::/32", "2001:db8:1::/48", "invalid"}
+	for i := uint32(1); i <= 100; i++ {
+		for _, prefix := range prefixes {
+			in <- models.BGPUpdate{Prefix: prefix, PeerASN: i}
+		}
+	}
+	close(in)
+
+	select {
+	case <-d.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Dispatcher did not finish")
+	}
+
+	// Updates of a prefix are processed in order
+	for _, prefix := range prefixes {
+		peers := seen[prefix]
+		if len(peers) != 100 {
+			t.Fatalf("%s: expected 100 updates, got %d", prefix, len(peers))
+		}
+		for i, peer := range peers {
+			if peer != uint32(i+1) {
+				t.Fatalf("%s: update %d processed out of order (peer %d)", prefix, i, peer)
+			}
+		}
+		workerOf[prefix] = d.worker(prefix)
+	}
+
+	// More-specifics share the worker of their block
+	if workerOf["10.1.0.0/16"] != workerOf["10.1.5.0/24"] || workerOf["2001:db8::/32"] != workerOf["2001:db8:1::/48"] {
+		t.Errorf("Expected more-specifics on the worker of their block: %v", workerOf)
+	}
+
+	var total uint64
+	for _, w := range d.Stats()["workers"].([]map[string]interface{}) {
+		total += w["updates"].(uint64)
+		if w["queue_cap"] != 10 {
+			t.Errorf("Expected queue capacity 10, got %v", w["queue_cap"])
+		}
+	}
+	if total != 600 {
+		t.Errorf("Expected 600 processed updates, got %d", total)
+	}
+}
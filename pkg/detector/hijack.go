@@ -1,39 +1,223 @@
 package detector
 
 import (
-	"context"
-	"log"
+	"fmt"
+	"net/netip"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/hervehildenbrand/bgp-radar/pkg/baseline"
+	"github.com/hervehildenbrand/bgp-radar/pkg/cache"
 	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+	"github.com/hervehildenbrand/bgp-radar/pkg/rpki"
+	"github.com/hervehildenbrand/bgp-radar/pkg/state"
+	"github.com/hervehildenbrand/bgp-radar/pkg/trie"
 	"github.com/redis/go-redis/v9"
 )
 
 // HijackDetector detects BGP origin hijacks by tracking prefix origins.
+// It reports both exact-prefix origin changes and sub-prefix hijacks, where a
+// more-specific is announced by a different origin than its covering prefix.
+//
+// The known origin of a prefix is its baseline: the origin seen by the most
+// distinct peers in its origin history, which may be seeded from a RIB
+// snapshot with SetBaseline. Unseeded baselines are only trusted once seen by
+// min-peers peers. Origins reported as hijacking are not recorded.
+// Prefixes without a history fall back to the origin in the state store,
+// which also holds the MOAS origins accepted for each prefix.
+//
+// A hijack ends once every peer that saw the hijacking origin withdraws the
+// prefix or announces it from another origin.
 type HijackDetector struct {
-	events chan<- models.BGPEvent
-	redis  *redis.Client
-	ctx    context.Context
+	emitter
+	rpki *rpki.Validator
 
-	// Local cache for performance (prefix -> origin ASN)
-	cache     sync.Map
+	// Known origins and MOAS sets; ownStore is set for the default
+	// in-memory store, which the detector compacts and closes
+	store    state.Store
+	ownStore bool
+
+	// Origin history of each prefix, and the distinct peers a baseline
+	// origin needs before origin changes are reported against it
+	history  *baseline.History
+	minPeers atomic.Int64
+
+	// Known origins of recently seen prefixes, in front of the history
+	// and the store. Replaced by Configure while Origins may read it.
+	cache     atomic.Pointer[cache.Cache[uint32]]
+	cacheSize int
 	cacheTTL  time.Duration
-	cacheTime sync.Map // prefix -> time.Time
+
+	// Longest-prefix-match index of known origins for sub-prefix detection
+	originsMu sync.RWMutex
+	origins   *trie.Trie[uint32]
+
+	// Hijacks reported and not yet ended, by prefix
+	active *activeEvents
 }
 
-// NewHijackDetector creates a new hijack detector.
+// NewHijackDetector creates a new hijack detector. State is kept in Redis
+// if a client is given, otherwise in memory until SetStore is called.
 func NewHijackDetector(events chan<- models.BGPEvent, redisClient *redis.Client) *HijackDetector {
-	return &HijackDetector{
-		events:   events,
-		redis:    redisClient,
-		ctx:      context.Background(),
-		cacheTTL: 5 * time.Minute,
+	d := &HijackDetector{
+		emitter:   emitter{events: events},
+		history:   baseline.NewHistory(),
+		cacheSize: DefaultOriginCacheSize,
+		cacheTTL:  defaultOriginCacheTTL,
+		origins:   trie.New[uint32](),
+		active:    newActiveEvents(),
+	}
+	d.minPeers.Store(defaultHijackMinPeers)
+	d.cache.Store(d.newCache())
+	d.history.OnEvict(d.unindexOrigin)
+	if redisClient != nil {
+		d.store = state.NewRedisStore(redisClient, state.DefaultTTL)
+	} else {
+		d.store, d.ownStore = state.NewMemoryStore(state.DefaultTTL), true
+	}
+	return d
+}
+
+// Name returns the detector name.
+func (d *HijackDetector) Name() string { return NameHijack }
+
+// Start indexes the baseline origins for sub-prefix detection and, with
+// the default in-memory store, starts its compaction.
+func (d *HijackDetector) Start() {
+	if local, ok := d.store.(*state.LocalStore); ok && d.ownStore {
+		local.Start()
+	}
+
+	d.originsMu.Lock()
+	d.history.Walk(func(prefix netip.Prefix, o baseline.Origin) bool {
+		if d.established(o) {
+			d.origins.Insert(prefix, o.ASN)
+		}
+		return true
+	})
+	d.originsMu.Unlock()
+}
+
+// Stop closes the default in-memory store; a store set with SetStore is
+// closed by its owner.
+func (d *HijackDetector) Stop() {
+	if d.ownStore {
+		d.store.Close()
+	}
+}
+
+// SetStore replaces the state store, e.g. with a store shared with other
+// detectors. It must be called before Start.
+func (d *HijackDetector) SetStore(s state.Store) {
+	d.store, d.ownStore = s, false
+}
+
+// DefaultOriginCacheSize is the default number of known origins cached.
+const DefaultOriginCacheSize = 500000
+
+const (
+	defaultOriginCacheTTL = 5 * time.Minute
+	defaultHijackMinPeers = 3
+)
+
+// newCache creates the known origin cache with the configured size and TTL.
+func (d *HijackDetector) newCache() *cache.Cache[uint32] {
+	return cache.New[uint32](cache.Config{MaxEntries: d.cacheSize, TTL: d.cacheTTL})
+}
+
+// Configure sets options by name: min-peers, the distinct peers a baseline
+// origin must have been seen by before origin changes are reported (3 by
+// default; seeded baselines need none), and
+// cache-size and cache-ttl, the number of known origins cached and how long
+// before they are looked up again. Options not given are reset to their
+// defaults. It must not be called concurrently with Process, but may be
+// while Origins is. The known origin cache is only rebuilt, empty, when its
+// size or TTL change; a new min-peers applies to cached origins once they
+// expire.
+func (d *HijackDetector) Configure(options map[string]string) error {
+	o, err := parseHijackOptions(options)
+	if err != nil {
+		return err
+	}
+
+	d.minPeers.Store(o.minPeers)
+	if o.cacheSize != d.cacheSize || o.cacheTTL != d.cacheTTL {
+		d.cacheSize, d.cacheTTL = o.cacheSize, o.cacheTTL
+		d.cache.Store(d.newCache())
 	}
+	return nil
+}
+
+// hijackOptions are the options of the hijack detector (see Configure).
+type hijackOptions struct {
+	minPeers  int64
+	cacheSize int
+	cacheTTL  time.Duration
+}
+
+// parseHijackOptions parses hijack detector options, defaulting those not
+// given.
+func parseHijackOptions(options map[string]string) (hijackOptions, error) {
+	o := hijackOptions{
+		minPeers:  defaultHijackMinPeers,
+		cacheSize: DefaultOriginCacheSize,
+		cacheTTL:  defaultOriginCacheTTL,
+	}
+	for name, value := range options {
+		switch name {
+		case "min-peers":
+			n, err := parseIntOption(name, value)
+			if err != nil {
+				return o, err
+			}
+			o.minPeers = int64(n)
+		case "cache-size":
+			n, err := parseIntOption(name, value)
+			if err != nil {
+				return o, err
+			}
+			o.cacheSize = n
+		case "cache-ttl":
+			ttl, err := parseDurationOption(name, value)
+			if err != nil {
+				return o, err
+			}
+			o.cacheTTL = ttl
+		default:
+			return o, fmt.Errorf("unknown option %q", name)
+		}
+	}
+	return o, nil
+}
+
+// SetRPKIValidator enables RPKI origin validation of announcements.
+func (d *HijackDetector) SetRPKIValidator(v *rpki.Validator) {
+	d.rpki = v
+}
+
+// SetBaseline replaces the origin history, e.g. with one seeded from a RIB
+// snapshot. It must be called before Start.
+func (d *HijackDetector) SetBaseline(h *baseline.History) {
+	d.history = h
+	h.OnEvict(d.unindexOrigin)
+}
+
+// SetEventTimeout sets how long a reported hijack may go unseen before it expires
+// (DefaultEventTimeout by default; 0 disables expiry).
+func (d *HijackDetector) SetEventTimeout(timeout time.Duration) {
+	d.active.setTimeout(timeout)
 }
 
 // Process checks a BGP update for origin hijacks.
 func (d *HijackDetector) Process(update models.BGPUpdate) {
+	d.expireActive(d.active, update)
+
+	if d.trackActive(update) {
+		return // Hijack already reported, another peer sees it
+	}
+
+	// An AS_SET origin is ambiguous: no origin to compare
 	if !update.Announcement || update.OriginASN == 0 {
 		return
 	}
@@ -46,19 +230,31 @@ func (d *HijackDetector) Process(update models.BGPUpdate) {
 	// Get known origin for this prefix
 	knownOrigin := d.getKnownOrigin(update.Prefix)
 	if knownOrigin == 0 {
-		// First time seeing this prefix, store it
-		d.setKnownOrigin(update.Prefix, update.OriginASN)
+		// No baseline yet: check it against its covering prefix, then record it
+		if !d.checkSubPrefix(update) {
+			d.record(update, knownOrigin)
+		}
 		return
 	}
 
 	// Same origin, nothing to report
 	if knownOrigin == update.OriginASN {
+		d.record(update, knownOrigin)
 		return
 	}
 
 	// Origin changed - potential hijack!
 	// Check if it's a known MOAS (Multiple Origin AS)
 	if d.isKnownMOAS(update.Prefix, update.OriginASN) {
+		d.record(update, knownOrigin)
+		return
+	}
+
+	// An origin authorized by a ROA is a legitimate MOAS, not a hijack
+	rpkiResult := d.rpki.Validate(update.Prefix, update.OriginASN)
+	if rpkiResult.Status == models.RPKIValid {
+		d.addKnownMOAS(update.Prefix, update.OriginASN)
+		d.record(update, knownOrigin)
 		return
 	}
 
@@ -87,7 +283,7 @@ func (d *HijackDetector) Process(update models.BGPUpdate) {
 		EventCategory:  models.CategoryAttack,
 		AffectedASN:    knownOrigin,
 		AffectedPrefix: update.Prefix,
-		DetectedAt:     time.Now(),
+		DetectedAt:     updateTime(update),
 		IsActive:       true,
 		Details: map[string]interface{}{
 			"original_origin": knownOrigin,
@@ -95,75 +291,291 @@ func (d *HijackDetector) Process(update models.BGPUpdate) {
 			"as_path":         update.ASPath,
 			"peer_asn":        update.PeerASN,
 			"collector":       update.Collector,
+			"source":          update.Source,
 			"flags":           flags,
 			"confidence":      confidence,
 		},
 	}
 
-	// Non-blocking send
-	select {
-	case d.events <- event:
-	default:
+	stampRPKI(&event, rpkiResult)
+	if rpkiResult.Status == models.RPKIInvalid {
+		escalateRPKIInvalid(&event, "rpki_invalid")
 	}
 
+	d.emit(event)
+	d.active.observe(update.Prefix, peerKey(update), event, updateTime(update))
+
 	// Add to MOAS list (might be legitimate)
 	d.addKnownMOAS(update.Prefix, update.OriginASN)
 }
 
-func (d *HijackDetector) getKnownOrigin(prefix string) uint32 {
-	// Check local cache first
-	if val, ok := d.cache.Load(prefix); ok {
-		if t, ok := d.cacheTime.Load(prefix); ok {
-			if time.Since(t.(time.Time)) < d.cacheTTL {
-				return val.(uint32)
-			}
+// checkSubPrefix reports a new more-specific announced by a different origin
+// than the most specific known covering prefix, returning true if it did.
+func (d *HijackDetector) checkSubPrefix(update models.BGPUpdate) bool {
+	prefix, err := trie.ParsePrefix(update.Prefix)
+	if err != nil {
+		return false
+	}
+
+	coveringPrefix, coveringOrigin, found := d.findCovering(prefix)
+	if !found || coveringOrigin == update.OriginASN {
+		return false
+	}
+
+	// The covering origin transiting the more-specific is a provider
+	// announcing a customer route (or a customer de-aggregating), not a hijack
+	for _, asn := range update.ASPath {
+		if asn == coveringOrigin {
+			return false
 		}
 	}
 
-	// Check Redis
-	if d.redis != nil {
-		key := "bgp:prefix:" + prefix + ":origin"
-		val, err := d.redis.Get(d.ctx, key).Uint64()
-		if err == nil {
-			origin := uint32(val)
-			d.cache.Store(prefix, origin)
-			d.cacheTime.Store(prefix, time.Now())
-			return origin
+	covering := coveringPrefix.String()
+	if d.isKnownMOAS(covering, update.OriginASN) {
+		return false
+	}
+
+	rpkiResult := d.rpki.ValidatePrefix(prefix, update.OriginASN)
+	if rpkiResult.Status == models.RPKIValid {
+		return false
+	}
+
+	// A more-specific wins longest-prefix match everywhere it propagates,
+	// so sub-prefix hijacks are more severe than exact origin changes.
+	severity := models.SeverityHigh
+	confidence := 0.75
+	flags := []string{"subprefix"}
+	if IsTier1(coveringOrigin) || IsTier1(update.OriginASN) {
+		severity = models.SeverityCritical
+		confidence = 0.85
+		flags = append(flags, "tier1_involved")
+	}
+
+	event := models.BGPEvent{
+		EventType:      models.EventTypeHijack,
+		Severity:       severity,
+		EventCategory:  models.CategoryAttack,
+		AffectedASN:    coveringOrigin,
+		AffectedPrefix: update.Prefix,
+		DetectedAt:     updateTime(update),
+		IsActive:       true,
+		Details: map[string]interface{}{
+			"subprefix":       true,
+			"covering_prefix": covering,
+			"covering_origin": coveringOrigin,
+			"original_origin": coveringOrigin,
+			"hijacking_asn":   update.OriginASN,
+			"as_path":         update.ASPath,
+			"peer_asn":        update.PeerASN,
+			"collector":       update.Collector,
+			"source":          update.Source,
+			"flags":           flags,
+			"confidence":      confidence,
+		},
+	}
+
+	stampRPKI(&event, rpkiResult)
+	if rpkiResult.Status == models.RPKIInvalid {
+		escalateRPKIInvalid(&event, "rpki_invalid")
+	}
+
+	d.emit(event)
+	d.active.observe(update.Prefix, peerKey(update), event, updateTime(update))
+	return true
+}
+
+// trackActive follows a hijack in progress on the update prefix and emits its
+// end once no peer sees the hijacking origin anymore. It returns true if the
+// update announces either side of the hijack, which needs no further checks.
+func (d *HijackDetector) trackActive(update models.BGPUpdate) bool {
+	active, ok := d.active.get(update.Prefix)
+	if !ok {
+		return false
+	}
+	hijacker, _ := active.Details["hijacking_asn"].(uint32)
+	peer := peerKey(update)
+
+	reason := ResolutionOriginReplaced
+	switch {
+	case !update.Announcement:
+		reason = ResolutionWithdrawn
+	case update.OriginASN == hijacker:
+		d.active.observe(update.Prefix, peer, active, updateTime(update))
+		return true
+	case update.OriginASN == active.AffectedASN:
+		reason = ResolutionOriginRestored
+	}
+
+	event, ended := d.active.leave(update.Prefix, peer, update, reason)
+	if ended {
+		event.Details["hijacking_asn"] = hijacker
+		d.emit(event)
+	}
+	if reason != ResolutionOriginRestored {
+		return false
+	}
+	d.record(update, d.getKnownOrigin(update.Prefix))
+	return true
+}
+
+// record adds an announcement to the origin history of its prefix, storing
+// the prefix baseline as its known origin if it differs from known.
+func (d *HijackDetector) record(update models.BGPUpdate, known uint32) {
+	prefix, err := trie.ParsePrefix(update.Prefix)
+	if err != nil {
+		return
+	}
+	d.history.Observe(prefix, update.OriginASN, peerKey(update), updateTime(update))
+	if o, ok := d.history.Baseline(prefix); ok && d.established(o) && o.ASN != known {
+		d.setKnownOrigin(update.Prefix, o.ASN)
+	}
+}
+
+// Stats returns detector statistics.
+func (d *HijackDetector) Stats() map[string]interface{} {
+	d.originsMu.RLock()
+	indexed := d.origins.Len()
+	d.originsMu.RUnlock()
+
+	return d.withCounters(map[string]interface{}{
+		"indexed_prefixes":  indexed,
+		"baseline_prefixes": d.history.Len(),
+		"baseline_evicted":  d.history.Evictions(),
+		"active_hijacks":    d.active.count(),
+		"state":             d.store.Stats(),
+		"origin_cache":      d.cache.Load().Stats(),
+	})
+}
+
+// PrefixOrigins describes what the hijack detector knows about a prefix.
+type PrefixOrigins struct {
+	Prefix         string   `json:"prefix"`
+	Origin         uint32   `json:"origin,omitempty"`          // Known origin
+	MOAS           []uint32 `json:"moas,omitempty"`            // Other origins accepted as legitimate
+	CoveringPrefix string   `json:"covering_prefix,omitempty"` // Most specific less-specific prefix
+	CoveringOrigin uint32   `json:"covering_origin,omitempty"`
+	HijackingASN   uint32   `json:"hijacking_asn,omitempty"` // Origin of a hijack in progress
+
+	// Origin history, most stable first
+	History []baseline.Origin `json:"history,omitempty"`
+}
+
+// Origins returns the known origin(s) of a prefix.
+func (d *HijackDetector) Origins(prefix string) (PrefixOrigins, error) {
+	p, err := trie.ParsePrefix(prefix)
+	if err != nil {
+		return PrefixOrigins{}, err
+	}
+	info := PrefixOrigins{Prefix: p.String()}
+
+	info.Origin = d.getKnownOrigin(info.Prefix)
+	if info.Origin == 0 {
+		d.originsMu.RLock()
+		info.Origin, _ = d.origins.Get(p)
+		d.originsMu.RUnlock()
+	}
+	info.MOAS = d.knownMOAS(info.Prefix)
+	info.History = d.history.Origins(p)
+
+	if covering, origin, found := d.findCovering(p); found {
+		info.CoveringPrefix = covering.String()
+		info.CoveringOrigin = origin
+	}
+	if active, ok := d.active.get(info.Prefix); ok {
+		info.HijackingASN, _ = active.Details["hijacking_asn"].(uint32)
+	}
+	return info, nil
+}
+
+// findCovering returns the most specific known prefix strictly covering prefix.
+func (d *HijackDetector) findCovering(prefix netip.Prefix) (netip.Prefix, uint32, bool) {
+	var (
+		covering netip.Prefix
+		origin   uint32
+		found    bool
+	)
+
+	d.originsMu.RLock()
+	defer d.originsMu.RUnlock()
+	d.origins.WalkCovering(prefix, func(p netip.Prefix, asn uint32) bool {
+		if p.Bits() < prefix.Bits() {
+			covering, origin, found = p, asn, true
 		}
+		return true
+	})
+	return covering, origin, found
+}
+
+// getKnownOrigin returns the origin changes of prefix are checked against:
+// its baseline once seen by enough peers, or for prefixes without a
+// history, the origin in the state store. It returns 0 if there is none.
+func (d *HijackDetector) getKnownOrigin(prefix string) uint32 {
+	known := d.cache.Load()
+	if origin, ok := known.Get(prefix); ok {
+		return origin
 	}
 
+	if p, err := trie.ParsePrefix(prefix); err == nil {
+		if o, ok := d.history.Baseline(p); ok {
+			if !d.established(o) {
+				return 0
+			}
+			known.Set(prefix, o.ASN)
+			return o.ASN
+		}
+	}
+
+	if origin, ok := d.store.Origin(prefix); ok {
+		known.Set(prefix, origin)
+		d.indexOrigin(prefix, origin)
+		return origin
+	}
 	return 0
 }
 
+// established reports whether origin changes are checked against a
+// baseline: one seeded from a dump, or seen by min-peers distinct peers, so
+// that the first origin to arrive after an unseeded start is not trusted on
+// the word of a single peer.
+func (d *HijackDetector) established(o baseline.Origin) bool {
+	return o.Seeded || int64(o.Peers) >= d.minPeers.Load()
+}
+
 func (d *HijackDetector) setKnownOrigin(prefix string, origin uint32) {
-	// Update local cache
-	d.cache.Store(prefix, origin)
-	d.cacheTime.Store(prefix, time.Now())
-
-	// Update Redis
-	if d.redis != nil {
-		key := "bgp:prefix:" + prefix + ":origin"
-		if err := d.redis.Set(d.ctx, key, origin, 48*time.Hour).Err(); err != nil {
-			log.Printf("Redis set error: %v", err)
-		}
+	d.cache.Load().Set(prefix, origin)
+	d.indexOrigin(prefix, origin)
+	d.store.SetOrigin(prefix, origin)
+}
+
+// indexOrigin records a prefix origin in the longest-prefix-match index.
+func (d *HijackDetector) indexOrigin(prefix string, origin uint32) {
+	p, err := trie.ParsePrefix(prefix)
+	if err != nil {
+		return
 	}
+	d.originsMu.Lock()
+	d.origins.Insert(p, origin)
+	d.originsMu.Unlock()
+}
+
+// unindexOrigin forgets the known origin of a prefix evicted from the
+// history, so that the index and the cache stay within its size.
+func (d *HijackDetector) unindexOrigin(prefix netip.Prefix) {
+	d.cache.Load().Delete(prefix.String())
+	d.originsMu.Lock()
+	d.origins.Delete(prefix)
+	d.originsMu.Unlock()
 }
 
 func (d *HijackDetector) isKnownMOAS(prefix string, origin uint32) bool {
-	if d.redis == nil {
-		return false
-	}
+	return d.store.IsMOAS(prefix, origin)
+}
 
-	key := "bgp:prefix:" + prefix + ":origins"
-	return d.redis.SIsMember(d.ctx, key, origin).Val()
+// knownMOAS returns the origins recorded as legitimate MOAS for prefix.
+func (d *HijackDetector) knownMOAS(prefix string) []uint32 {
+	return d.store.MOAS(prefix)
 }
 
 func (d *HijackDetector) addKnownMOAS(prefix string, origin uint32) {
-	if d.redis == nil {
-		return
-	}
-
-	key := "bgp:prefix:" + prefix + ":origins"
-	d.redis.SAdd(d.ctx, key, origin)
-	d.redis.Expire(d.ctx, key, 48*time.Hour)
+	d.store.AddMOAS(prefix, origin)
 }
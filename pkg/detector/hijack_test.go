@@ -0,0 +1,423 @@
+package detector
+
+import (
+	"fmt"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/baseline"
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+	"github.com/hervehildenbrand/bgp-radar/pkg/state"
+)
+
+func announce(prefix string, path ...uint32) models.BGPUpdate {
+	return models.BGPUpdate{
+		Timestamp:    time.Now(),
+		PeerASN:      path[0],
+		Prefix:       prefix,
+		ASPath:       path,
+		OriginASN:    path[len(path)-1],
+		Announcement: true,
+		Collector:    "rrc00",
+	}
+}
+
+// newHijackDetector creates a hijack detector whose baselines are
+// established by a single peer, as most tests announce from one.
+func newHijackDetector(t *testing.T, events chan models.BGPEvent) *HijackDetector {
+	t.Helper()
+	d := NewHijackDetector(events, nil)
+	if err := d.Configure(map[string]string{"min-peers": "1"}); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	return d
+}
+
+// announceSet returns an announcement of an aggregate whose AS path ends
+// with the AS_SET set.
+func announceSet(prefix string, path []uint32, set ...uint32) models.BGPUpdate {
+	update := announce(prefix, path...)
+	update.ASPathSegments = []models.ASPathSegment{{ASNs: path}, {Set: true, ASNs: set}}
+	update.ASPath, update.OriginASN = models.SequencePath(update.ASPathSegments)
+	return update
+}
+
+func TestHijackDetector_AmbiguousOrigin(t *testing.T) {
+	events := make(chan models.BGPEvent, 10)
+	d := newHijackDetector(t, events)
+
+	d.Process(announce("192.0.2.0/24", 6939, 64500))
+	d.Process(announceSet("192.0.2.0/24", []uint32{6939, 64510}, 64500, 64666))
+
+	select {
+	case event := <-events:
+		t.Fatalf("Expected no hijack for an AS_SET origin, got %v", event.Details)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if origin := d.getKnownOrigin("192.0.2.0/24"); origin != 64500 {
+		t.Errorf("Expected known origin 64500 to be kept, got %d", origin)
+	}
+}
+
+func TestHijackDetector_OriginChange(t *testing.T) {
+	events := make(chan models.BGPEvent, 10)
+	d := newHijackDetector(t, events)
+
+	d.Process(announce("192.0.2.0/24", 6939, 64500))
+	d.Process(announce("192.0.2.0/24", 6939, 64500))
+
+	select {
+	case <-events:
+		t.Fatal("Expected no event for stable origin")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	d.Process(announce("192.0.2.0/24", 6939, 64666))
+
+	select {
+	case event := <-events:
+		if event.EventType != models.EventTypeHijack {
+			t.Errorf("Expected event type %s, got %s", models.EventTypeHijack, event.EventType)
+		}
+		if event.AffectedASN != 64500 {
+			t.Errorf("Expected affected ASN 64500, got %d", event.AffectedASN)
+		}
+		if event.Details["hijacking_asn"] != uint32(64666) {
+			t.Errorf("Expected hijacking ASN 64666, got %v", event.Details["hijacking_asn"])
+		}
+		if _, ok := event.Details["subprefix"]; ok {
+			t.Error("Exact-prefix hijack should not be flagged as subprefix")
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Error("Expected hijack event, got none")
+	}
+}
+
+func TestHijackDetector_SubPrefix(t *testing.T) {
+	events := make(chan models.BGPEvent, 10)
+	d := newHijackDetector(t, events)
+
+	d.Process(announce("10.0.0.0/16", 6939, 64500))
+	d.Process(announce("10.0.5.0/24", 6939, 64666))
+
+	select {
+	case event := <-events:
+		if event.EventType != models.EventTypeHijack {
+			t.Errorf("Expected event type %s, got %s", models.EventTypeHijack, event.EventType)
+		}
+		if event.Details["subprefix"] != true {
+			t.Error("Expected subprefix flag")
+		}
+		if event.Details["covering_prefix"] != "10.0.0.0/16" {
+			t.Errorf("Expected covering prefix 10.0.0.0/16, got %v", event.Details["covering_prefix"])
+		}
+		if event.Details["covering_origin"] != uint32(64500) {
+			t.Errorf("Expected covering origin 64500, got %v", event.Details["covering_origin"])
+		}
+		if event.AffectedASN != 64500 || event.AffectedPrefix != "10.0.5.0/24" {
+			t.Errorf("Unexpected affected ASN/prefix: %d %s", event.AffectedASN, event.AffectedPrefix)
+		}
+		if event.Severity != models.SeverityHigh {
+			t.Errorf("Expected severity %s, got %s", models.SeverityHigh, event.Severity)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Error("Expected sub-prefix hijack event, got none")
+	}
+}
+
+func TestHijackDetector_SubPrefixLegitimate(t *testing.T) {
+	tests := []struct {
+		name   string
+		update models.BGPUpdate
+	}{
+		{"same origin de-aggregation", announce("10.0.5.0/24", 6939, 64500)},
+		{"customer route via covering origin", announce("10.0.6.0/24", 6939, 64500, 64501)},
+		{"unrelated prefix", announce("10.1.0.0/24", 6939, 64666)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := make(chan models.BGPEvent, 10)
+			d := newHijackDetector(t, events)
+
+			d.Process(announce("10.0.0.0/16", 6939, 64500))
+			d.Process(tt.update)
+
+			select {
+			case event := <-events:
+				t.Errorf("Expected no event, got %+v", event.Details)
+			case <-time.After(50 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestHijackDetector_EndWithdrawn(t *testing.T) {
+	events := make(chan models.BGPEvent, 10)
+	d := newHijackDetector(t, events)
+
+	d.Process(announce("192.0.2.0/24", 6939, 64500))
+	d.Process(announce("192.0.2.0/24", 6939, 64666))
+	d.Process(announce("192.0.2.0/24", 3333, 64666))
+	<-events
+
+	// The hijack is still seen by AS3333
+	d.Process(withdraw("192.0.2.0/24", 6939, time.Now()))
+	select {
+	case event := <-events:
+		t.Fatalf("Expected no event while a peer still sees the hijack, got %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	d.Process(withdraw("192.0.2.0/24", 3333, time.Now()))
+	select {
+	case event := <-events:
+		if event.IsActive {
+			t.Error("Expected inactive end event")
+		}
+		if event.EndedAt.IsZero() {
+			t.Error("Expected ended_at to be set")
+		}
+		if event.AffectedASN != 64500 || event.AffectedPrefix != "192.0.2.0/24" {
+			t.Errorf("Unexpected affected ASN/prefix: %d %s", event.AffectedASN, event.AffectedPrefix)
+		}
+		if event.Details["resolution"] != ResolutionWithdrawn {
+			t.Errorf("Expected resolution %s, got %v", ResolutionWithdrawn, event.Details["resolution"])
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Error("Expected hijack end event, got none")
+	}
+}
+
+func TestHijackDetector_EndOriginRestored(t *testing.T) {
+	events := make(chan models.BGPEvent, 10)
+	d := newHijackDetector(t, events)
+
+	d.Process(announce("10.0.0.0/16", 6939, 64500))
+	d.Process(announce("10.0.5.0/24", 6939, 64666))
+	<-events
+
+	d.Process(announce("10.0.5.0/24", 6939, 64500))
+	select {
+	case event := <-events:
+		if event.IsActive {
+			t.Error("Expected inactive end event")
+		}
+		if event.Details["resolution"] != ResolutionOriginRestored {
+			t.Errorf("Expected resolution %s, got %v", ResolutionOriginRestored, event.Details["resolution"])
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Expected hijack end event, got none")
+	}
+
+	// The legitimate origin is the known origin again
+	d.Process(announce("10.0.5.0/24", 6939, 64500))
+	select {
+	case event := <-events:
+		t.Errorf("Expected no event after restoration, got %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestHijackDetector_SeededBaseline(t *testing.T) {
+	events := make(chan models.BGPEvent, 10)
+	d := NewHijackDetector(events, nil)
+	h := baseline.NewHistory()
+	h.Seed(netip.MustParsePrefix("192.0.2.0/24"), baseline.Origin{ASN: 64500}) // Trusted below min-peers
+	h.Seed(netip.MustParsePrefix("10.0.0.0/16"), baseline.Origin{ASN: 64500, Peers: 40})
+	d.SetBaseline(h)
+	d.Start()
+
+	// The hijacker arriving first is not mistaken for the owner
+	d.Process(announce("192.0.2.0/24", 6939, 64666))
+	select {
+	case event := <-events:
+		if event.AffectedASN != 64500 || event.Details["hijacking_asn"] != uint32(64666) {
+			t.Errorf("Expected AS64666 hijacking AS64500, got %d: %v", event.AffectedASN, event.Details)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Expected hijack event, got none")
+	}
+
+	// Seeded baselines are indexed for sub-prefix detection
+	d.Process(announce("10.0.5.0/24", 6939, 64666))
+	select {
+	case event := <-events:
+		if event.Details["subprefix"] != true || event.Details["covering_origin"] != uint32(64500) {
+			t.Errorf("Expected a sub-prefix hijack of 10.0.0.0/16, got %v", event.Details)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Expected sub-prefix hijack event, got none")
+	}
+}
+
+func TestHijackDetector_MinPeers(t *testing.T) {
+	events := make(chan models.BGPEvent, 10)
+	d := newHijackDetector(t, events)
+	if err := d.Configure(map[string]string{"min-peers": "2"}); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+
+	// A single peer is not enough to establish a baseline: the origin seen
+	// by more peers becomes the baseline regardless of arrival order
+	d.Process(announce("192.0.2.0/24", 6939, 64666))
+	d.Process(announce("192.0.2.0/24", 3356, 64500))
+	d.Process(announce("192.0.2.0/24", 174, 64500))
+	select {
+	case event := <-events:
+		t.Fatalf("Expected no event before a baseline is established, got %+v", event.Details)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	info, err := d.Origins("192.0.2.0/24")
+	if err != nil {
+		t.Fatalf("Origins failed: %v", err)
+	}
+	if info.Origin != 64500 || len(info.History) != 2 || info.History[0].ASN != 64500 || info.History[0].Peers != 2 {
+		t.Errorf("Expected baseline AS64500 seen by 2 peers, got %+v", info)
+	}
+
+	d.Process(announce("192.0.2.0/24", 3333, 64666))
+	select {
+	case event := <-events:
+		if event.AffectedASN != 64500 || event.Details["hijacking_asn"] != uint32(64666) {
+			t.Errorf("Expected AS64666 hijacking AS64500, got %d: %v", event.AffectedASN, event.Details)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Expected hijack event, got none")
+	}
+}
+
+func TestHijackDetector_UnseededStart(t *testing.T) {
+	events := make(chan models.BGPEvent, 10)
+	d := NewHijackDetector(events, nil)
+	d.Start()
+	defer d.Stop()
+
+	// Without a seed, the hijacker arriving first does not become the
+	// baseline, and the owner is not reported as hijacking its prefix
+	d.Process(announce("192.0.2.0/24", 6939, 64666))
+	d.Process(announce("192.0.2.0/24", 3356, 64500))
+	d.Process(announce("192.0.2.0/24", 174, 64500))
+	d.Process(announce("192.0.2.0/24", 1299, 64500))
+	select {
+	case event := <-events:
+		t.Fatalf("Expected no event before a baseline is established, got %d: %v", event.AffectedASN, event.Details)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	d.Process(announce("192.0.2.0/24", 2914, 64666))
+	select {
+	case event := <-events:
+		if event.AffectedASN != 64500 || event.Details["hijacking_asn"] != uint32(64666) {
+			t.Errorf("Expected AS64666 hijacking AS64500, got %d: %v", event.AffectedASN, event.Details)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Expected hijack event, got none")
+	}
+}
+
+func TestHijackDetector_MOASWithoutRedis(t *testing.T) {
+	events := make(chan models.BGPEvent, 10)
+	d := newHijackDetector(t, events)
+	store := state.NewMemoryStore(time.Hour)
+	d.SetStore(store)
+
+	store.AddMOAS("192.0.2.0/24", 64501)
+	d.Process(announce("192.0.2.0/24", 6939, 64500))
+	d.Process(announce("192.0.2.0/24", 3356, 64501))
+	select {
+	case event := <-events:
+		t.Fatalf("Expected no event for a known MOAS origin, got %+v", event.Details)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if origin, ok := store.Origin("192.0.2.0/24"); !ok || origin != 64500 {
+		t.Errorf("Expected the known origin in the store, got %d (%v)", origin, ok)
+	}
+	d.Process(announce("192.0.2.0/24", 3356, 64666))
+	<-events
+	if !store.IsMOAS("192.0.2.0/24", 64666) {
+		t.Error("Expected the reported origin to be recorded as MOAS")
+	}
+}
+
+func TestHijackDetector_OriginCache(t *testing.T) {
+	events := make(chan models.BGPEvent, 10)
+	d := newHijackDetector(t, events)
+	if err := d.Configure(map[string]string{"min-peers": "1", "cache-size": "64", "cache-ttl": "1m"}); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+
+	for i := 0; i < 256; i++ {
+		d.Process(announce(fmt.Sprintf("10.%d.0.0/16", i), 6939, 64500))
+	}
+	stats := d.Stats()["origin_cache"].(map[string]interface{})
+	if stats["entries"].(int) > 64 || stats["evictions"].(uint64) == 0 {
+		t.Errorf("Expected the cache bounded to 64 entries, got %v", stats)
+	}
+
+	// Evicted origins are still known from the history
+	d.Process(announce("10.0.0.0/16", 6939, 64666))
+	select {
+	case event := <-events:
+		if event.AffectedASN != 64500 {
+			t.Errorf("Expected AS64500 hijacked, got AS%d", event.AffectedASN)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Expected hijack event, got none")
+	}
+}
+
+func TestHijackDetector_Reconfigure(t *testing.T) {
+	events := make(chan models.BGPEvent, 10)
+	d := newHijackDetector(t, events)
+	for i := 0; i < 16; i++ {
+		d.Process(announce(fmt.Sprintf("10.%d.0.0/16", i), 6939, 64500))
+	}
+
+	// Origins may be looked up while the detector is reconfigured
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			d.Origins("10.0.0.0/16")
+		}
+	}()
+	if err := d.Configure(map[string]string{"min-peers": "1"}); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	entries := d.Stats()["origin_cache"].(map[string]interface{})["entries"].(int)
+	if entries != 16 {
+		t.Errorf("Expected the origin cache kept when its size and TTL are unchanged, got %d entries", entries)
+	}
+	if err := d.Configure(map[string]string{"cache-size": "1000"}); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	<-done
+	stats := d.Stats()["origin_cache"].(map[string]interface{})
+	if stats["entries"].(int) > 1 || stats["max_entries"].(int) < 1000 {
+		t.Errorf("Expected a new origin cache for a new size, got %v", stats)
+	}
+}
+
+func TestHijackDetector_BoundedUnderChurn(t *testing.T) {
+	events := make(chan models.BGPEvent, 10)
+	d := newHijackDetector(t, events)
+	d.SetBaseline(baseline.NewBoundedHistory(1000))
+
+	for i := 0; i < 20000; i++ {
+		d.Process(announce(fmt.Sprintf("10.%d.%d.0/24", i>>8, i&0xff), 6939, 64500))
+	}
+	stats := d.Stats()
+	if n := stats["baseline_prefixes"].(int); n != 1000 {
+		t.Errorf("Expected the baseline capped at 1000 prefixes, got %d", n)
+	}
+	if n := stats["indexed_prefixes"].(int); n != 1000 {
+		t.Errorf("Expected evicted prefixes dropped from the index, got %d indexed", n)
+	}
+	if n := stats["origin_cache"].(map[string]interface{})["entries"].(int); n > 1000 {
+		t.Errorf("Expected evicted prefixes dropped from the cache, got %d cached", n)
+	}
+}
@@ -3,57 +3,180 @@ package detector
 import (
 	"time"
 
+	"github.com/hervehildenbrand/bgp-radar/pkg/asrel"
 	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+	"github.com/hervehildenbrand/bgp-radar/pkg/rpki"
 )
 
 // LeakDetector detects BGP route leaks.
-// A leak is when a small AS appears to be providing transit between two Tier-1s.
+// With AS relationship data loaded, a leak is any AS path violating the
+// valley-free property: an AS exporting a route learned from a provider or
+// peer to another provider or peer. Without it, a leak is when a small AS
+// appears to be providing transit between two Tier-1s.
+// A leak ends once every peer that saw the leaked path withdraws the prefix or
+// announces it over a path without the leak.
 type LeakDetector struct {
-	events chan<- models.BGPEvent
+	emitter
+	rpki          *rpki.Validator
+	relationships *asrel.Graph
+
+	// Leaks reported and not yet ended, by prefix
+	active *activeEvents
 }
 
 // NewLeakDetector creates a new leak detector.
 func NewLeakDetector(events chan<- models.BGPEvent) *LeakDetector {
-	return &LeakDetector{events: events}
+	return &LeakDetector{
+		emitter: emitter{events: events},
+		active:  newActiveEvents(),
+	}
+}
+
+// Name returns the detector name.
+func (d *LeakDetector) Name() string { return NameLeak }
+
+// Start is a no-op: the detector has no background work.
+func (d *LeakDetector) Start() {}
+
+// Stop is a no-op.
+func (d *LeakDetector) Stop() {}
+
+// Stats returns detector statistics.
+func (d *LeakDetector) Stats() map[string]interface{} {
+	return d.withCounters(map[string]interface{}{
+		"active_leaks":       d.active.count(),
+		"relationship_links": d.relationships.Len(),
+	})
+}
+
+// SetRPKIValidator enables RPKI origin validation of leaked routes.
+func (d *LeakDetector) SetRPKIValidator(v *rpki.Validator) {
+	d.rpki = v
+}
+
+// SetEventTimeout sets how long a reported leak may go unseen before it expires
+// (DefaultEventTimeout by default; 0 disables expiry).
+func (d *LeakDetector) SetEventTimeout(timeout time.Duration) {
+	d.active.setTimeout(timeout)
+}
+
+// SetRelationships enables valley-free leak detection from CAIDA AS
+// relationship data.
+func (d *LeakDetector) SetRelationships(g *asrel.Graph) {
+	d.relationships = g
 }
 
 // Process checks a BGP update for route leak patterns.
 func (d *LeakDetector) Process(update models.BGPUpdate) {
-	if !update.Announcement || len(update.ASPath) < 3 {
-		return // Need at least 3 ASNs for leak pattern
+	d.expireActive(d.active, update)
+
+	if !update.Announcement {
+		d.end(update, ResolutionWithdrawn)
+		return
 	}
 
-	// Look for pattern: Tier1 -> SmallAS -> Tier1
-	// This would mean SmallAS is acting as transit between two Tier-1s
-	leakASN, tier1Before, tier1After := d.findLeakPattern(update.ASPath)
+	var (
+		leakASN uint32
+		details map[string]interface{}
+	)
+	if d.relationships.Len() > 0 {
+		leakASN, details = d.findValleyViolation(update.ASPath)
+	} else {
+		leakASN, details = d.findTier1Leak(update.ASPath)
+	}
+	if active, ok := d.active.get(update.Prefix); ok && active.AffectedASN != leakASN {
+		d.end(update, ResolutionPathChanged)
+	}
 	if leakASN == 0 {
 		return
 	}
 
+	details["leaking_asn"] = leakASN
+	details["as_path"] = update.ASPath
+	details["peer_asn"] = update.PeerASN
+	details["collector"] = update.Collector
+	details["source"] = update.Source
+	tagAmbiguousOrigin(details, update)
+
 	event := models.BGPEvent{
 		EventType:      models.EventTypeLeak,
 		Severity:       models.SeverityHigh,
 		EventCategory:  models.CategoryMisconfiguration,
 		AffectedASN:    leakASN,
 		AffectedPrefix: update.Prefix,
-		DetectedAt:     time.Now(),
+		DetectedAt:     updateTime(update),
 		IsActive:       true,
-		Details: map[string]interface{}{
-			"pattern":          "tier1_transit_leak",
-			"leaking_asn":      leakASN,
-			"upstream_tier1":   tier1Before,
-			"downstream_tier1": tier1After,
-			"as_path":          update.ASPath,
-			"peer_asn":         update.PeerASN,
-			"collector":        update.Collector,
-			"confidence":       0.85, // High confidence for Tier-1 transit pattern
-		},
+		Details:        details,
+	}
+
+	rpkiResult := d.rpki.Validate(update.Prefix, update.OriginASN)
+	stampRPKI(&event, rpkiResult)
+	if rpkiResult.Status == models.RPKIInvalid {
+		escalateRPKIInvalid(&event, "rpki_invalid")
+	}
+
+	d.emit(event)
+
+	// A different leak of a prefix whose previous leak is still seen by
+	// other peers is reported but not tracked.
+	if active, ok := d.active.get(update.Prefix); !ok || active.AffectedASN == leakASN {
+		d.active.observe(update.Prefix, peerKey(update), event, updateTime(update))
+	}
+}
+
+// end removes the update peer from an active leak on the prefix and emits
+// the end of the leak once no peer sees the leaked path anymore.
+func (d *LeakDetector) end(update models.BGPUpdate, reason string) {
+	event, ended := d.active.leave(update.Prefix, peerKey(update), update, reason)
+	if !ended {
+		return
 	}
+	event.Details["leaking_asn"] = event.AffectedASN
+
+	d.emit(event)
+}
 
-	// Non-blocking send
-	select {
-	case d.events <- event:
-	default:
+// findValleyViolation checks the AS path against the valley-free property.
+// Returns the leaking ASN (0 if none) and the event details describing it.
+func (d *LeakDetector) findValleyViolation(asPath []uint32) (uint32, map[string]interface{}) {
+	v, found := d.relationships.CheckValleyFree(asPath)
+	if !found || IsScrubbing(v.LeakASN) {
+		return 0, nil
+	}
+
+	// Without the relationship to the AS the route was learned from, the
+	// violation rests on a link further along the path.
+	confidence := 0.9
+	if v.FromRel == asrel.Unknown {
+		confidence = 0.7
+	}
+
+	return v.LeakASN, map[string]interface{}{
+		"pattern":      "valley_free_violation",
+		"leak_type":    v.FromRel.String() + "_to_" + v.ToRel.String(),
+		"learned_from": v.From,
+		"learned_rel":  v.FromRel.String(),
+		"exported_to":  v.To,
+		"exported_rel": v.ToRel.String(),
+		"path_segment": v.Segment,
+		"confidence":   confidence,
+	}
+}
+
+// findTier1Leak looks for the Tier1 -> SmallAS -> Tier1 pattern, meaning
+// SmallAS is acting as transit between two Tier-1s.
+// Returns the leaking ASN (0 if none) and the event details describing it.
+func (d *LeakDetector) findTier1Leak(asPath []uint32) (uint32, map[string]interface{}) {
+	leakASN, tier1Before, tier1After := d.findLeakPattern(asPath)
+	if leakASN == 0 {
+		return 0, nil
+	}
+	return leakASN, map[string]interface{}{
+		"pattern":          "tier1_transit_leak",
+		"upstream_tier1":   tier1Before,
+		"downstream_tier1": tier1After,
+		"path_segment":     []uint32{tier1Before, leakASN, tier1After},
+		"confidence":       0.85, // High confidence for Tier-1 transit pattern
 	}
 }
 
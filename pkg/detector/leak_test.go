@@ -0,0 +1,95 @@
+package detector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/asrel"
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+func TestLeakDetector_Tier1Pattern(t *testing.T) {
+	events := make(chan models.BGPEvent, 10)
+	d := NewLeakDetector(events)
+
+	d.Process(announce("192.0.2.0/24", 6939, 174, 64500, 3356, 64501))
+
+	select {
+	case event := <-events:
+		if event.EventType != models.EventTypeLeak {
+			t.Errorf("Expected event type %s, got %s", models.EventTypeLeak, event.EventType)
+		}
+		if event.AffectedASN != 64500 {
+			t.Errorf("Expected leaking ASN 64500, got %d", event.AffectedASN)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Error("Expected leak event, got none")
+	}
+}
+
+func TestLeakDetector_End(t *testing.T) {
+	events := make(chan models.BGPEvent, 10)
+	d := NewLeakDetector(events)
+
+	d.Process(announce("192.0.2.0/24", 6939, 174, 64500, 3356, 64501))
+	<-events
+
+	// The peer now reaches the prefix without the leaking AS
+	d.Process(announce("192.0.2.0/24", 6939, 3356, 64501))
+
+	select {
+	case event := <-events:
+		if event.IsActive {
+			t.Error("Expected inactive end event")
+		}
+		if event.AffectedASN != 64500 {
+			t.Errorf("Expected leaking ASN 64500, got %d", event.AffectedASN)
+		}
+		if event.Details["resolution"] != ResolutionPathChanged {
+			t.Errorf("Expected resolution %s, got %v", ResolutionPathChanged, event.Details["resolution"])
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Error("Expected leak end event, got none")
+	}
+}
+
+func TestLeakDetector_ValleyFree(t *testing.T) {
+	g := asrel.NewGraph()
+	g.AddProviderCustomer(174, 64500)
+	g.AddProviderCustomer(3356, 64500)
+	g.AddProviderCustomer(64520, 64500)
+	g.AddProviderCustomer(64500, 64501)
+	g.AddProviderCustomer(174, 64510)
+
+	events := make(chan models.BGPEvent, 10)
+	d := NewLeakDetector(events)
+	d.SetRelationships(g)
+
+	// Customer route exported to a provider
+	d.Process(announce("198.51.100.0/24", 6939, 3356, 64500, 64501))
+	// 64500 exports a route from its provider 174 to its provider 64520,
+	// which the Tier-1 pattern misses
+	d.Process(announce("192.0.2.0/24", 6939, 64520, 64500, 174, 64510))
+
+	select {
+	case event := <-events:
+		if event.AffectedPrefix != "192.0.2.0/24" {
+			t.Fatalf("Expected leak of 192.0.2.0/24, got %s", event.AffectedPrefix)
+		}
+		if event.AffectedASN != 64500 {
+			t.Errorf("Expected leaking ASN 64500, got %d", event.AffectedASN)
+		}
+		if event.Details["pattern"] != "valley_free_violation" {
+			t.Errorf("Expected valley_free_violation, got %v", event.Details["pattern"])
+		}
+		if event.Details["leak_type"] != "provider_to_provider" {
+			t.Errorf("Expected provider_to_provider, got %v", event.Details["leak_type"])
+		}
+		segment, _ := event.Details["path_segment"].([]uint32)
+		if len(segment) != 3 || segment[0] != 64520 || segment[1] != 64500 || segment[2] != 174 {
+			t.Errorf("Expected segment [64520 64500 174], got %v", event.Details["path_segment"])
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Error("Expected leak event, got none")
+	}
+}
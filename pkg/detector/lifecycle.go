@@ -0,0 +1,211 @@
+package detector
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+// Resolution reasons reported when an event ends.
+const (
+	ResolutionWithdrawn        = "withdrawn"
+	ResolutionOriginRestored   = "origin_restored"
+	ResolutionOriginReplaced   = "origin_replaced"
+	ResolutionCommunityRemoved = "community_removed"
+	ResolutionPathChanged      = "path_changed"
+	ResolutionPathRestored     = "path_restored"
+	ResolutionVisible          = "visibility_restored"
+	ResolutionWindowExpired    = "window_expired"
+	ResolutionRPKIValid        = "rpki_valid"
+	ResolutionTimeout          = "timeout"
+)
+
+// DefaultEventTimeout is how long an active event may go unseen before it
+// expires, matching the default inactivity timeout of the database writer.
+const DefaultEventTimeout = 6 * time.Hour
+
+// activeSweepInterval is how often, on the update clock, active events are
+// checked for expiry.
+const activeSweepInterval = time.Minute
+
+// activeEvents tracks the events a detector has reported as active, keyed by
+// prefix, together with the collector peers still seeing the anomaly.
+// An event ends once the last of those peers stops seeing it, or expires
+// once no peer has been seen with it for the timeout.
+type activeEvents struct {
+	mu      sync.RWMutex
+	events  map[string]*activeEvent
+	timeout atomic.Int64 // Nanoseconds; 0 disables expiry
+	swept   atomic.Int64 // Update clock of the last expiry sweep, in Unix nanoseconds
+}
+
+type activeEvent struct {
+	event    models.BGPEvent
+	started  time.Time
+	lastSeen time.Time
+	peers    map[string]struct{}
+}
+
+func newActiveEvents() *activeEvents {
+	a := &activeEvents{events: make(map[string]*activeEvent)}
+	a.setTimeout(DefaultEventTimeout)
+	return a
+}
+
+// setTimeout sets how long an active event may go unseen before it expires;
+// 0 disables expiry.
+func (a *activeEvents) setTimeout(timeout time.Duration) {
+	a.timeout.Store(int64(timeout))
+}
+
+// observe records that peer sees the anomaly described by event. It returns
+// true if the event was not active yet.
+func (a *activeEvents) observe(key, peer string, event models.BGPEvent, now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if active, ok := a.events[key]; ok {
+		active.peers[peer] = struct{}{}
+		if now.After(active.lastSeen) {
+			active.lastSeen = now
+		}
+		return false
+	}
+	a.events[key] = &activeEvent{
+		event:    event,
+		started:  now,
+		lastSeen: now,
+		peers:    map[string]struct{}{peer: {}},
+	}
+	return true
+}
+
+// get returns a copy of the active event for key.
+func (a *activeEvents) get(key string) (models.BGPEvent, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if active, ok := a.events[key]; ok {
+		return active.event, true
+	}
+	return models.BGPEvent{}, false
+}
+
+// leave records that peer no longer sees the anomaly for key. When no peer
+// sees it anymore, the event is removed and its resolution is returned.
+func (a *activeEvents) leave(key, peer string, update models.BGPUpdate, reason string) (models.BGPEvent, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	active, ok := a.events[key]
+	if !ok {
+		return models.BGPEvent{}, false
+	}
+	if _, seen := active.peers[peer]; !seen {
+		return models.BGPEvent{}, false
+	}
+	delete(active.peers, peer)
+	if len(active.peers) > 0 {
+		return models.BGPEvent{}, false
+	}
+	delete(a.events, key)
+	return resolution(active, update, reason), true
+}
+
+// expire removes the events not seen for the timeout before the update and
+// returns their resolutions, ended when they were last seen. It sweeps at
+// most once per activeSweepInterval of the update clock.
+func (a *activeEvents) expire(update models.BGPUpdate) []models.BGPEvent {
+	timeout := time.Duration(a.timeout.Load())
+	now := updateTime(update)
+	swept := a.swept.Load()
+	if timeout <= 0 || now.UnixNano()-swept < int64(activeSweepInterval) {
+		return nil
+	}
+	if !a.swept.CompareAndSwap(swept, now.UnixNano()) {
+		return nil // Another worker is sweeping
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var expired []models.BGPEvent
+	for key, active := range a.events {
+		if now.Sub(active.lastSeen) > timeout {
+			delete(a.events, key)
+			expired = append(expired, resolutionAt(active, active.lastSeen, ResolutionTimeout))
+		}
+	}
+	return expired
+}
+
+// expireActive emits the resolutions of the events of active expired by
+// update.
+func (e *emitter) expireActive(active *activeEvents, update models.BGPUpdate) {
+	for _, event := range active.expire(update) {
+		e.emit(event)
+	}
+}
+
+// count returns the number of active events.
+func (a *activeEvents) count() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return len(a.events)
+}
+
+// resolution builds the event that closes an active event on update.
+func resolution(active *activeEvent, update models.BGPUpdate, reason string) models.BGPEvent {
+	event := resolutionAt(active, updateTime(update), reason)
+	event.Details["peer_asn"] = update.PeerASN
+	event.Details["collector"] = update.Collector
+	event.Details["source"] = update.Source
+	return event
+}
+
+// resolutionAt builds the event that closes an active event at ended.
+func resolutionAt(active *activeEvent, ended time.Time, reason string) models.BGPEvent {
+	event := active.event
+
+	return models.BGPEvent{
+		EventType:      event.EventType,
+		Severity:       event.Severity,
+		EventCategory:  event.EventCategory,
+		RPKIStatus:     event.RPKIStatus,
+		CountryCode:    event.CountryCode,
+		AffectedASN:    event.AffectedASN,
+		AffectedPrefix: event.AffectedPrefix,
+		DetectedAt:     ended,
+		EndedAt:        ended,
+		IsActive:       false,
+		Details: map[string]interface{}{
+			"resolution":       reason,
+			"started_at":       active.started.UTC().Format(time.RFC3339),
+			"ended_at":         ended.UTC().Format(time.RFC3339),
+			"duration_seconds": int64(ended.Sub(active.started).Seconds()),
+		},
+	}
+}
+
+// updateTime returns the update timestamp, or the current time if unset.
+func updateTime(update models.BGPUpdate) time.Time {
+	if update.Timestamp.IsZero() {
+		return time.Now()
+	}
+	return update.Timestamp
+}
+
+// OriginAmbiguous is the origin reported for routes whose AS path ends with
+// an AS_SET: they have no single origin ASN, and their OriginASN is 0.
+const OriginAmbiguous = "ambiguous"
+
+// tagAmbiguousOrigin adds the AS_SET origin of the update, if any, to the
+// event details.
+func tagAmbiguousOrigin(details map[string]interface{}, update models.BGPUpdate) {
+	if set := update.OriginSet(); set != nil {
+		details["origin"] = OriginAmbiguous
+		details["origin_set"] = set
+	}
+}
@@ -0,0 +1,439 @@
+package detector
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+// BuiltinVersion is the version of the built-in reference data.
+const BuiltinVersion = "builtin"
+
+// References is the reference data the detectors look up: Tier-1 and
+// scrubbing ASNs and provider blackhole communities. The package-level maps
+// in constants.go are the built-in defaults; the detectors use the
+// References set by SetReferences. A References must not be modified once
+// set.
+type References struct {
+	Tier1ASNs                    map[uint32]string
+	ScrubbingASNs                map[uint32]string
+	ProviderBlackholeCommunities map[uint32][]string
+
+	Version  string    // BuiltinVersion, or the versions of the applied datasets
+	Sources  []string  // Files of the applied datasets
+	LoadedAt time.Time // When the last dataset was applied
+
+	blackhole map[string]bool // Every blackhole community, canonical, including RFC 7999
+}
+
+var references atomic.Pointer[References]
+
+// DefaultReferences returns a copy of the built-in reference data.
+func DefaultReferences() *References {
+	return NewReferences(Tier1ASNs, ScrubbingASNs, ProviderBlackholeCommunities)
+}
+
+// NewReferences creates reference data from copies of the given maps.
+func NewReferences(tier1, scrubbing map[uint32]string, blackhole map[uint32][]string) *References {
+	r := &References{
+		Tier1ASNs:                    copyNames(tier1),
+		ScrubbingASNs:                copyNames(scrubbing),
+		ProviderBlackholeCommunities: copyCommunities(blackhole),
+		Version:                      BuiltinVersion,
+	}
+	r.index()
+	return r
+}
+
+// clone returns a copy of r.
+func (r *References) clone() *References {
+	c := NewReferences(r.Tier1ASNs, r.ScrubbingASNs, r.ProviderBlackholeCommunities)
+	c.Version = r.Version
+	c.Sources = append([]string(nil), r.Sources...)
+	c.LoadedAt = r.LoadedAt
+	return c
+}
+
+// With returns a copy of r with the given entries added: names replace
+// those of the same ASNs and communities are added to those of the same
+// ASNs.
+func (r *References) With(tier1, scrubbing map[uint32]string, blackhole map[uint32][]string) *References {
+	c := r.clone()
+	merge(c.Tier1ASNs, tier1)
+	merge(c.ScrubbingASNs, scrubbing)
+	mergeCommunities(c.ProviderBlackholeCommunities, blackhole)
+	c.index()
+	return c
+}
+
+// Apply returns a copy of r with a dataset applied: its lists extend those
+// of r, or replace them if the dataset is in replace mode. Lists absent
+// from the dataset are kept.
+func (r *References) Apply(d *ReferenceData) *References {
+	c := r.clone()
+	for _, list := range []struct {
+		dst *map[uint32]string
+		src map[uint32]string
+	}{
+		{&c.Tier1ASNs, d.Tier1},
+		{&c.ScrubbingASNs, d.Scrubbing},
+	} {
+		if list.src == nil {
+			continue
+		}
+		if d.Replace {
+			*list.dst = make(map[uint32]string, len(list.src))
+		}
+		merge(*list.dst, list.src)
+	}
+	if d.BlackholeCommunities != nil {
+		if d.Replace {
+			c.ProviderBlackholeCommunities = make(map[uint32][]string, len(d.BlackholeCommunities))
+		}
+		mergeCommunities(c.ProviderBlackholeCommunities, d.BlackholeCommunities)
+	}
+	c.index()
+
+	if c.Version == BuiltinVersion {
+		c.Version = d.Version
+	} else {
+		c.Version += "+" + d.Version
+	}
+	if d.Source != "" {
+		c.Sources = append(c.Sources, d.Source)
+	}
+	c.LoadedAt = time.Now()
+	return c
+}
+
+func (r *References) index() {
+	r.blackhole = map[string]bool{RFC7999Blackhole: true}
+	for _, communities := range r.ProviderBlackholeCommunities {
+		for _, community := range communities {
+			if c, ok := models.CanonicalCommunity(community); ok {
+				community = c
+			}
+			r.blackhole[community] = true
+		}
+	}
+}
+
+// Stats returns the version and size of the reference data.
+func (r *References) Stats() map[string]interface{} {
+	stats := map[string]interface{}{
+		"version":               r.Version,
+		"tier1_asns":            len(r.Tier1ASNs),
+		"scrubbing_asns":        len(r.ScrubbingASNs),
+		"blackhole_communities": r.CommunityCount(),
+	}
+	if len(r.Sources) > 0 {
+		stats["sources"] = r.Sources
+		stats["loaded_at"] = r.LoadedAt.UTC().Format(time.RFC3339)
+	}
+	return stats
+}
+
+func copyNames(m map[uint32]string) map[uint32]string {
+	c := make(map[uint32]string, len(m))
+	merge(c, m)
+	return c
+}
+
+func merge(dst, src map[uint32]string) {
+	for k, v := range src {
+		dst[k] = v
+	}
+}
+
+// CommunityCount returns the number of provider blackhole communities.
+func (r *References) CommunityCount() int {
+	n := 0
+	for _, communities := range r.ProviderBlackholeCommunities {
+		n += len(communities)
+	}
+	return n
+}
+
+func copyCommunities(m map[uint32][]string) map[uint32][]string {
+	c := make(map[uint32][]string, len(m))
+	mergeCommunities(c, m)
+	return c
+}
+
+// mergeCommunities adds the communities of src to those of the same ASNs
+// in dst, skipping duplicates.
+func mergeCommunities(dst, src map[uint32][]string) {
+	for asn, communities := range src {
+		list := append([]string(nil), dst[asn]...)
+		for _, community := range communities {
+			if !containsString(list, community) {
+				list = append(list, community)
+			}
+		}
+		dst[asn] = list
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// SetReferences replaces the reference data used by the detectors. It is
+// safe to call while updates are processed.
+func SetReferences(r *References) {
+	references.Store(r)
+}
+
+// CurrentReferences returns the reference data used by the detectors.
+func CurrentReferences() *References {
+	return references.Load()
+}
+
+// ReferenceData is a versioned reference dataset read from a file. Nil
+// lists are absent from the file.
+type ReferenceData struct {
+	Version              string
+	Replace              bool // Replace the lists instead of extending them
+	Tier1                map[uint32]string
+	Scrubbing            map[uint32]string
+	BlackholeCommunities map[uint32][]string // Provider ASN -> communities
+	Source               string              // File the dataset was read from
+}
+
+// Reference dataset modes
+const (
+	ModeExtend  = "extend"
+	ModeReplace = "replace"
+)
+
+// referenceJSON is the JSON layout of a reference dataset, where each
+// provider has a blackhole community or a list of them:
+//
+//	{
+//	  "version": "2024-06-01",
+//	  "mode": "extend",
+//	  "tier1": {"174": "Cogent Communications"},
+//	  "scrubbing": {"AS13335": "Cloudflare Inc"},
+//	  "blackhole_communities": {"3356": "3356:9999", "6939": ["6939:666", "6939:666:0"]}
+//	}
+type referenceJSON struct {
+	Version              string                   `json:"version"`
+	Mode                 string                   `json:"mode"`
+	Tier1                map[string]string        `json:"tier1"`
+	Scrubbing            map[string]string        `json:"scrubbing"`
+	BlackholeCommunities map[string]communityList `json:"blackhole_communities"`
+}
+
+// communityList is a JSON community or list of communities.
+type communityList []string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (l *communityList) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*l = communityList{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.New("blackhole_communities: expected a community or a list of communities")
+	}
+	*l = list
+	return nil
+}
+
+// LoadReferenceData reads a reference dataset from a .json or .csv file.
+func LoadReferenceData(path string) (*ReferenceData, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var d *ReferenceData
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		d, err = ParseReferenceJSON(data)
+	case ".csv":
+		d, err = ParseReferenceCSV(data)
+	default:
+		return nil, fmt.Errorf("%s: unknown reference data format (want .json or .csv)", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	d.Source = path
+	return d, nil
+}
+
+// ParseReferenceJSON parses a JSON reference dataset.
+func ParseReferenceJSON(data []byte) (*ReferenceData, error) {
+	var f referenceJSON
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	d, err := newReferenceData(f.Version, f.Mode)
+	if err != nil {
+		return nil, err
+	}
+	for _, list := range []struct {
+		name string
+		src  map[string]string
+		dst  *map[uint32]string
+	}{
+		{"tier1", f.Tier1, &d.Tier1},
+		{"scrubbing", f.Scrubbing, &d.Scrubbing},
+	} {
+		if list.src == nil {
+			continue
+		}
+		*list.dst = make(map[uint32]string, len(list.src))
+		for key, value := range list.src {
+			if err := d.add(list.name, key, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if f.BlackholeCommunities != nil {
+		d.BlackholeCommunities = make(map[uint32][]string, len(f.BlackholeCommunities))
+		for key, communities := range f.BlackholeCommunities {
+			for _, community := range communities {
+				if err := d.add("blackhole_communities", key, community); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return d, nil
+}
+
+// ParseReferenceCSV parses a CSV reference dataset of list,asn,value rows,
+// where list is tier1, scrubbing or blackhole. A provider with several
+// blackhole communities has a row for each. The version and mode are set by
+// comment lines:
+//
+//	# version: 2024-06-01
+//	# mode: replace
+//	list,asn,value
+//	tier1,174,Cogent Communications
+//	blackhole,3356,3356:9999
+//	blackhole,6939,6939:666
+//	blackhole,6939,6939:666:0
+func ParseReferenceCSV(data []byte) (*ReferenceData, error) {
+	var version, mode string
+	lines := strings.Split(string(data), "\n")
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimSpace(strings.TrimPrefix(line, "#")), ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "version":
+			version = strings.TrimSpace(value)
+		case "mode":
+			mode = strings.TrimSpace(value)
+		}
+	}
+	d, err := newReferenceData(version, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	r := csv.NewReader(strings.NewReader(string(data)))
+	r.Comment = '#'
+	r.FieldsPerRecord = 3
+	r.TrimLeadingSpace = true
+	for first := true; ; first = false {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if first && record[0] == "list" {
+			continue // Header
+		}
+		line, _ := r.FieldPos(0)
+		name := strings.TrimSpace(record[0])
+		if name == "blackhole" {
+			name = "blackhole_communities"
+		}
+		if err := d.add(name, record[1], strings.TrimSpace(record[2])); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	return d, nil
+}
+
+func newReferenceData(version, mode string) (*ReferenceData, error) {
+	if version == "" {
+		return nil, errors.New("missing version")
+	}
+	switch mode {
+	case "", ModeExtend:
+		return &ReferenceData{Version: version}, nil
+	case ModeReplace:
+		return &ReferenceData{Version: version, Replace: true}, nil
+	}
+	return nil, fmt.Errorf("invalid mode %q (want %s or %s)", mode, ModeExtend, ModeReplace)
+}
+
+// add adds an entry to the named list. Blackhole communities are added to
+// those of the ASN; other entries replace it.
+func (d *ReferenceData) add(list, key, value string) error {
+	asn, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(key)), "AS"), 10, 32)
+	if err != nil || asn == 0 {
+		return fmt.Errorf("%s: invalid ASN %q", list, key)
+	}
+	var dst *map[uint32]string
+	switch list {
+	case "tier1":
+		dst = &d.Tier1
+	case "scrubbing":
+		dst = &d.Scrubbing
+	case "blackhole_communities":
+		if !ValidCommunity(value) {
+			return fmt.Errorf("%s: AS%d: invalid community %q (want asn:value, asn:value:value or an extended community)", list, asn, value)
+		}
+		if d.BlackholeCommunities == nil {
+			d.BlackholeCommunities = make(map[uint32][]string)
+		}
+		if !containsString(d.BlackholeCommunities[uint32(asn)], value) {
+			d.BlackholeCommunities[uint32(asn)] = append(d.BlackholeCommunities[uint32(asn)], value)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown list %q (want tier1, scrubbing or blackhole)", list)
+	}
+	if *dst == nil {
+		*dst = make(map[uint32]string)
+	}
+	(*dst)[uint32(asn)] = value
+	return nil
+}
+
+// ValidCommunity reports whether s is a standard ("asn:value"), large
+// ("asn:value:value") or extended ("rt:asn:value", "0xTTSS:data")
+// community.
+func ValidCommunity(s string) bool {
+	_, ok := models.CanonicalCommunity(s)
+	return ok
+}
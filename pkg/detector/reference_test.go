@@ -0,0 +1,145 @@
+package detector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseReferenceJSON(t *testing.T) {
+	d, err := ParseReferenceJSON([]byte(`{
+		"version": "2024-06-01",
+		"tier1": {"AS64500": "Example Transit"},
+		"blackhole_communities": {"64501": "64501:666", "64502": ["64502:666", "64502:666:0"]}
+	}`))
+	if err != nil {
+		t.Fatalf("ParseReferenceJSON: %v", err)
+	}
+	if d.Version != "2024-06-01" || d.Replace {
+		t.Errorf("Expected version 2024-06-01 in extend mode, got %q replace=%v", d.Version, d.Replace)
+	}
+	if d.Tier1[64500] != "Example Transit" {
+		t.Errorf("Expected AS64500 in tier1, got %v", d.Tier1)
+	}
+	if c := d.BlackholeCommunities[64501]; len(c) != 1 || c[0] != "64501:666" {
+		t.Errorf("Expected 64501:666 for AS64501, got %v", d.BlackholeCommunities)
+	}
+	if c := d.BlackholeCommunities[64502]; len(c) != 2 || c[1] != "64502:666:0" {
+		t.Errorf("Expected 64502:666 and 64502:666:0 for AS64502, got %v", d.BlackholeCommunities)
+	}
+	if d.Scrubbing != nil {
+		t.Errorf("Expected absent scrubbing list, got %v", d.Scrubbing)
+	}
+}
+
+func TestParseReferenceCSV(t *testing.T) {
+	d, err := ParseReferenceCSV([]byte(`# version: v7
+# mode: replace
+list,asn,value
+tier1,174,Cogent Communications
+scrubbing,AS64501,Example Scrubbing
+blackhole,3356,3356:9999
+blackhole,3356,3356:9999:0
+`))
+	if err != nil {
+		t.Fatalf("ParseReferenceCSV: %v", err)
+	}
+	if d.Version != "v7" || !d.Replace {
+		t.Errorf("Expected version v7 in replace mode, got %q replace=%v", d.Version, d.Replace)
+	}
+	if d.Tier1[174] != "Cogent Communications" || d.Scrubbing[64501] != "Example Scrubbing" || len(d.BlackholeCommunities[3356]) != 2 {
+		t.Errorf("Unexpected lists: %v %v %v", d.Tier1, d.Scrubbing, d.BlackholeCommunities)
+	}
+}
+
+func TestParseReference_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		csv  bool
+		want string
+	}{
+		{"missing version", `{"tier1": {"174": "Cogent"}}`, false, "missing version"},
+		{"invalid mode", `{"version": "1", "mode": "merge"}`, false, "invalid mode"},
+		{"invalid ASN", `{"version": "1", "tier1": {"ASX": "Bad"}}`, false, "invalid ASN"},
+		{"invalid community", `{"version": "1", "blackhole_communities": {"3356": "3356"}}`, false, "invalid community"},
+		{"invalid community list", `{"version": "1", "blackhole_communities": {"3356": ["3356:9999", "3356"]}}`, false, "invalid community"},
+		{"community object", `{"version": "1", "blackhole_communities": {"3356": {"a": "b"}}}`, false, "expected a community or a list"},
+		{"unknown list", "# version: 1\ntier2,174,Cogent\n", true, "line 2: unknown list"},
+		{"wrong field count", "# version: 1\ntier1,174\n", true, "wrong number of fields"},
+	}
+	for _, tt := range tests {
+		var err error
+		if tt.csv {
+			_, err = ParseReferenceCSV([]byte(tt.data))
+		} else {
+			_, err = ParseReferenceJSON([]byte(tt.data))
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestReferences_Apply(t *testing.T) {
+	extend := &ReferenceData{Version: "a", Tier1: map[uint32]string{64500: "Example Transit"}}
+	replace := &ReferenceData{Version: "b", Replace: true, BlackholeCommunities: map[uint32][]string{64500: {"64500:666"}}}
+
+	refs := DefaultReferences().Apply(extend).Apply(replace)
+	if refs.Version != "a+b" {
+		t.Errorf("Expected version a+b, got %q", refs.Version)
+	}
+	if len(refs.Tier1ASNs) != len(Tier1ASNs)+1 || refs.Tier1ASNs[64500] == "" {
+		t.Errorf("Expected the built-in Tier-1 ASNs and AS64500, got %d", len(refs.Tier1ASNs))
+	}
+	if len(refs.ScrubbingASNs) != len(ScrubbingASNs) {
+		t.Errorf("Expected the built-in scrubbing ASNs to be kept, got %d", len(refs.ScrubbingASNs))
+	}
+	if len(refs.ProviderBlackholeCommunities) != 1 {
+		t.Errorf("Expected the blackhole communities to be replaced, got %v", refs.ProviderBlackholeCommunities)
+	}
+
+	SetReferences(refs)
+	defer SetReferences(DefaultReferences())
+	if !IsTier1(64500) || !IsBlackholeCommunity("64500:666") || IsBlackholeCommunity("3356:9999") {
+		t.Error("Expected the lookups to use the applied reference data")
+	}
+	if !IsBlackholeCommunity(RFC7999Blackhole) {
+		t.Error("Expected RFC 7999 BLACKHOLE to be kept")
+	}
+	if DefaultReferences().Tier1ASNs[64500] != "" {
+		t.Error("Expected the built-in reference data to be unchanged")
+	}
+}
+
+func TestLoadReferenceData(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "reference.csv")
+	if err := os.WriteFile(path, []byte("# version: 3\ntier1,64500,Example\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d, err := LoadReferenceData(path)
+	if err != nil {
+		t.Fatalf("LoadReferenceData: %v", err)
+	}
+
+	stats := DefaultReferences().Apply(d).Stats()
+	if stats["version"] != "3" {
+		t.Errorf("Expected version 3 in stats, got %v", stats["version"])
+	}
+	if sources, _ := stats["sources"].([]string); len(sources) != 1 || sources[0] != path {
+		t.Errorf("Expected sources [%s], got %v", path, stats["sources"])
+	}
+	if DefaultReferences().Stats()["version"] != BuiltinVersion {
+		t.Errorf("Expected version %s for the built-in data", BuiltinVersion)
+	}
+
+	path = filepath.Join(dir, "reference.txt")
+	if err := os.WriteFile(path, []byte("tier1,64500,Example\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadReferenceData(path); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,414 @@
+package detector
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/asrel"
+	"github.com/hervehildenbrand/bgp-radar/pkg/baseline"
+	"github.com/hervehildenbrand/bgp-radar/pkg/database"
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+	"github.com/hervehildenbrand/bgp-radar/pkg/rpki"
+	"github.com/hervehildenbrand/bgp-radar/pkg/state"
+	"github.com/hervehildenbrand/bgp-radar/pkg/watchlist"
+	"github.com/redis/go-redis/v9"
+)
+
+// Names of the built-in detectors.
+const (
+	NameBlackhole  = "blackhole"
+	NameHijack     = "hijack"
+	NameLeak       = "leak"
+	NameWithdrawal = "withdrawal"
+	NameDDoS       = "ddos"
+	NameWatchlist  = "watchlist"
+)
+
+// Deps are the shared resources detectors are built with. Optional
+// resources may be nil.
+type Deps struct {
+	Events        chan<- models.BGPEvent
+	Redis         *redis.Client
+	Resolver      database.CountryResolver
+	RPKI          *rpki.Validator
+	Relationships *asrel.Graph
+	Watchlist     *watchlist.Watchlist
+	Baseline      *baseline.History // Seeded prefix origin history
+	State         state.Store       // Shared detector state
+	EventTimeout  time.Duration     // Active events not seen for this long expire (0 disables)
+}
+
+// Factory builds a detector from the shared resources.
+type Factory func(deps Deps) Detector
+
+// OptionValidator checks the options of a Configurable detector, as given
+// to Configure, without building or changing any detector.
+type OptionValidator func(options map[string]string) error
+
+// Registry maps detector names to factories. Detectors run in registration
+// order.
+type Registry struct {
+	names      []string
+	factories  map[string]Factory
+	validators map[string]OptionValidator
+}
+
+// NewRegistry creates an empty detector registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		factories:  make(map[string]Factory),
+		validators: make(map[string]OptionValidator),
+	}
+}
+
+// DefaultRegistry returns a registry of the built-in detectors.
+func DefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.Register(NameBlackhole, func(deps Deps) Detector {
+		d := NewBlackholeDetector(deps.Events)
+		d.SetRPKIValidator(deps.RPKI)
+		d.SetEventTimeout(deps.EventTimeout)
+		return d
+	})
+	r.Register(NameHijack, func(deps Deps) Detector {
+		d := NewHijackDetector(deps.Events, deps.Redis)
+		d.SetRPKIValidator(deps.RPKI)
+		d.SetEventTimeout(deps.EventTimeout)
+		if deps.Baseline != nil {
+			d.SetBaseline(deps.Baseline)
+		}
+		if deps.State != nil {
+			d.SetStore(deps.State)
+		}
+		return d
+	})
+	r.Register(NameLeak, func(deps Deps) Detector {
+		d := NewLeakDetector(deps.Events)
+		d.SetRPKIValidator(deps.RPKI)
+		d.SetEventTimeout(deps.EventTimeout)
+		d.SetRelationships(deps.Relationships)
+		return d
+	})
+	r.Register(NameWithdrawal, func(deps Deps) Detector {
+		return NewWithdrawalStormDetector(deps.Events, deps.Resolver)
+	})
+	r.RegisterOptions(NameHijack, func(options map[string]string) error {
+		_, err := parseHijackOptions(options)
+		return err
+	})
+	r.RegisterOptions(NameWithdrawal, func(options map[string]string) error {
+		_, err := parseStormThresholds(options)
+		return err
+	})
+	r.Register(NameDDoS, func(deps Deps) Detector {
+		d := NewDDoSDetector(deps.Events)
+		d.SetRPKIValidator(deps.RPKI)
+		d.SetEventTimeout(deps.EventTimeout)
+		return d
+	})
+	r.Register(NameWatchlist, func(deps Deps) Detector {
+		d := NewWatchlistDetector(deps.Events, deps.Watchlist)
+		d.SetRPKIValidator(deps.RPKI)
+		d.SetEventTimeout(deps.EventTimeout)
+		return d
+	})
+	return r
+}
+
+// Register adds a detector factory under name, replacing any factory
+// already registered under that name.
+func (r *Registry) Register(name string, factory Factory) {
+	if _, ok := r.factories[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.factories[name] = factory
+}
+
+// RegisterOptions sets the validator of the options of the detector
+// registered under name. Detectors without one accept no options.
+func (r *Registry) RegisterOptions(name string, validate OptionValidator) {
+	r.validators[name] = validate
+}
+
+// ValidateOptions checks options, keyed by detector name then option name,
+// with the registered validators, without building any detector.
+func (r *Registry) ValidateOptions(options map[string]map[string]string) error {
+	for name := range options {
+		if _, ok := r.factories[name]; !ok {
+			return fmt.Errorf("options for unknown detector %q", name)
+		}
+	}
+	for _, name := range r.names {
+		opts, listed := options[name]
+		if !listed {
+			continue
+		}
+		validate, ok := r.validators[name]
+		if !ok {
+			if len(opts) > 0 {
+				return fmt.Errorf("detector %s: has no options", name)
+			}
+			continue
+		}
+		if err := validate(opts); err != nil {
+			return fmt.Errorf("detector %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Names returns the registered detector names in registration order.
+func (r *Registry) Names() []string {
+	return append([]string(nil), r.names...)
+}
+
+// Select resolves a comma-separated detector selection into detector names.
+// An empty selection or "all" selects every detector; names prefixed with
+// '-' are removed from the selection, e.g. "all,-ddos" or "-ddos".
+func (r *Registry) Select(spec string) ([]string, error) {
+	enabled := make(map[string]bool)
+	explicit := false
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if item == "all" {
+			for _, name := range r.names {
+				enabled[name] = true
+			}
+			explicit = true
+			continue
+		}
+
+		disable := strings.HasPrefix(item, "-")
+		name := strings.TrimPrefix(item, "-")
+		if _, ok := r.factories[name]; !ok {
+			return nil, fmt.Errorf("unknown detector %q (available: %s)", name, strings.Join(r.names, ", "))
+		}
+		if disable {
+			if !explicit {
+				// Disabling only: start from every detector
+				for _, n := range r.names {
+					enabled[n] = true
+				}
+				explicit = true
+			}
+			delete(enabled, name)
+			continue
+		}
+		enabled[name] = true
+		explicit = true
+	}
+
+	if !explicit {
+		return r.Names(), nil
+	}
+	names := make([]string, 0, len(enabled))
+	for _, name := range r.names {
+		if enabled[name] {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// Build creates the named detectors and applies their options, keyed by
+// detector name then option name.
+func (r *Registry) Build(names []string, options map[string]map[string]string, deps Deps) (*Pipeline, error) {
+	for name := range options {
+		if _, ok := r.factories[name]; !ok {
+			return nil, fmt.Errorf("options for unknown detector %q", name)
+		}
+	}
+
+	p := &Pipeline{}
+	for _, name := range names {
+		factory, ok := r.factories[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown detector %q", name)
+		}
+		d := factory(deps)
+
+		if opts := options[name]; len(opts) > 0 {
+			c, ok := d.(Configurable)
+			if !ok {
+				return nil, fmt.Errorf("detector %s: has no options", name)
+			}
+			if err := c.Configure(opts); err != nil {
+				return nil, fmt.Errorf("detector %s: %w", name, err)
+			}
+		}
+		p.detectors = append(p.detectors, &instance{Detector: d, validate: r.validators[name]})
+	}
+	return p, nil
+}
+
+// Pipeline runs a set of detectors over each update.
+type Pipeline struct {
+	// Held for writing while detectors are reconfigured, so that options
+	// never change under a running Process
+	mu        sync.RWMutex
+	detectors []*instance
+}
+
+type instance struct {
+	Detector
+	validate  OptionValidator // nil if not registered
+	inspected atomic.Uint64
+}
+
+// Process runs every detector on the update.
+func (p *Pipeline) Process(update models.BGPUpdate) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, d := range p.detectors {
+		d.inspected.Add(1)
+		d.Process(update)
+	}
+}
+
+// Start starts every detector.
+func (p *Pipeline) Start() {
+	for _, d := range p.detectors {
+		d.Start()
+	}
+}
+
+// Stop stops every detector.
+func (p *Pipeline) Stop() {
+	for _, d := range p.detectors {
+		d.Stop()
+	}
+}
+
+// Configure applies options, keyed by detector name then option name, to
+// the running detectors, e.g. on a configuration reload. The options of a
+// listed detector replace its current ones: those it is not given, or all
+// of them for an empty map, are reset to their defaults. Unlisted detectors
+// and options of detectors that are not running are ignored. Processing
+// pauses while the options change. The options of every listed detector
+// are validated before any is applied, so an invalid option leaves all the
+// detectors unchanged; only detectors built without a registered
+// OptionValidator may fail once others are reconfigured.
+func (p *Pipeline) Configure(options map[string]map[string]string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	configure := make(map[*instance]Configurable)
+	for _, d := range p.detectors {
+		opts, listed := options[d.Name()]
+		if !listed {
+			continue
+		}
+		c, ok := d.Detector.(Configurable)
+		if !ok {
+			if len(opts) == 0 {
+				continue // Nothing to reset
+			}
+			return fmt.Errorf("detector %s: has no options", d.Name())
+		}
+		if d.validate != nil {
+			if err := d.validate(opts); err != nil {
+				return fmt.Errorf("detector %s: %w", d.Name(), err)
+			}
+		}
+		configure[d] = c
+	}
+
+	for _, d := range p.detectors {
+		c, ok := configure[d]
+		if !ok {
+			continue
+		}
+		if err := c.Configure(options[d.Name()]); err != nil {
+			return fmt.Errorf("detector %s: %w", d.Name(), err)
+		}
+	}
+	return nil
+}
+
+// Get returns the running detector with the given name, or nil.
+func (p *Pipeline) Get(name string) Detector {
+	for _, d := range p.detectors {
+		if d.Name() == name {
+			return d.Detector
+		}
+	}
+	return nil
+}
+
+// Names returns the names of the running detectors.
+func (p *Pipeline) Names() []string {
+	names := make([]string, 0, len(p.detectors))
+	for _, d := range p.detectors {
+		names = append(names, d.Name())
+	}
+	return names
+}
+
+// Stats returns the statistics of every detector, keyed by detector name,
+// including the number of updates each one inspected, and those of the
+// reference data under "references".
+func (p *Pipeline) Stats() map[string]interface{} {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	stats := make(map[string]interface{}, len(p.detectors)+1)
+	for _, d := range p.detectors {
+		s := d.Stats()
+		s["updates_inspected"] = d.inspected.Load()
+		stats[d.Name()] = s
+	}
+	stats["references"] = CurrentReferences().Stats()
+	return stats
+}
+
+// Counters are the activity counters of a running detector.
+type Counters struct {
+	Detector  string
+	Inspected uint64               // Updates inspected
+	Emitted   map[EventKind]uint64 // Events emitted per kind
+	Dropped   uint64               // Events dropped on a full events channel
+}
+
+// Counters returns the activity counters of every detector.
+func (p *Pipeline) Counters() []Counters {
+	counters := make([]Counters, 0, len(p.detectors))
+	for _, d := range p.detectors {
+		c := Counters{
+			Detector:  d.Name(),
+			Inspected: d.inspected.Load(),
+		}
+		if ec, ok := d.Detector.(EventCounter); ok {
+			c.Emitted = ec.EventCounts()
+			c.Dropped = ec.DroppedEvents()
+		}
+		counters = append(counters, c)
+	}
+	return counters
+}
+
+// FormatStats formats per-detector counters for a single log line, e.g.
+// "hijack=12/0 leak=3/1" (events emitted/dropped).
+func FormatStats(stats map[string]interface{}) string {
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		s, ok := stats[name].(map[string]interface{})
+		if _, counted := s["events_emitted"]; !ok || !counted {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s=%v/%v", name, s["events_emitted"], s["events_dropped"]))
+	}
+	return strings.Join(parts, " ")
+}
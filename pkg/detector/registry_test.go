@@ -0,0 +1,180 @@
+package detector
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+func TestRegistry_Select(t *testing.T) {
+	r := DefaultRegistry()
+	all := []string{NameBlackhole, NameHijack, NameLeak, NameWithdrawal, NameDDoS, NameWatchlist}
+
+	tests := []struct {
+		spec    string
+		want    []string
+		wantErr bool
+	}{
+		{"", all, false},
+		{"all", all, false},
+		{"hijack,leak", []string{NameHijack, NameLeak}, false},
+		{"leak, hijack", []string{NameHijack, NameLeak}, false},
+		{"-ddos", []string{NameBlackhole, NameHijack, NameLeak, NameWithdrawal, NameWatchlist}, false},
+		{"all,-ddos,-leak", []string{NameBlackhole, NameHijack, NameWithdrawal, NameWatchlist}, false},
+		{"hijack,bogus", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := r.Select(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Select(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Select(%q) = %v, want %v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistry_BuildOptions(t *testing.T) {
+	r := DefaultRegistry()
+	deps := Deps{Events: make(chan models.BGPEvent, 1)}
+
+	p, err := r.Build([]string{NameWithdrawal}, map[string]map[string]string{
+		NameWithdrawal: {"window": "10m", "min-peers": "3"},
+	}, deps)
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	d := p.Get(NameWithdrawal).(*WithdrawalStormDetector)
+	if d.window.Minutes() != 10 || d.minPeers != 3 {
+		t.Errorf("Options not applied: window=%s min-peers=%d", d.window, d.minPeers)
+	}
+
+	badOptions := []map[string]map[string]string{
+		{NameWithdrawal: {"window": "soon"}},
+		{NameWithdrawal: {"bogus": "1"}},
+		{NameDDoS: {"window": "10m"}},
+		{"bogus": {"window": "10m"}},
+	}
+	for _, options := range badOptions {
+		if _, err := r.Build([]string{NameWithdrawal, NameDDoS}, options, deps); err == nil {
+			t.Errorf("Expected error for options %v", options)
+		}
+	}
+}
+
+func TestRegistry_ValidateOptions(t *testing.T) {
+	r := DefaultRegistry()
+
+	valid := map[string]map[string]string{
+		NameHijack:     {"min-peers": "2", "cache-size": "1000"},
+		NameWithdrawal: {"window": "10m"},
+		NameDDoS:       {},
+	}
+	if err := r.ValidateOptions(valid); err != nil {
+		t.Errorf("Expected valid options, got %v", err)
+	}
+
+	badOptions := []map[string]map[string]string{
+		{NameHijack: {"cache-ttl": "never"}},
+		{NameWithdrawal: {"bogus": "1"}},
+		{NameDDoS: {"window": "10m"}},
+		{"bogus": {"window": "10m"}},
+	}
+	for _, options := range badOptions {
+		if err := r.ValidateOptions(options); err == nil {
+			t.Errorf("Expected error for options %v", options)
+		}
+	}
+}
+
+func TestPipeline_Counters(t *testing.T) {
+	events := make(chan models.BGPEvent, 1)
+	p, err := DefaultRegistry().Build([]string{NameBlackhole, NameLeak}, nil, Deps{Events: events})
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	for _, prefix := range []string{"192.0.2.1/32", "192.0.2.2/32"} {
+		update := announce(prefix, 6939, 13335)
+		update.Communities = []string{"65535:666"}
+		p.Process(update)
+	}
+
+	stats := p.Stats()
+	blackhole := stats[NameBlackhole].(map[string]interface{})
+	if blackhole["updates_inspected"] != uint64(2) {
+		t.Errorf("Expected 2 updates inspected, got %v", blackhole["updates_inspected"])
+	}
+	if blackhole["events_emitted"] != uint64(1) {
+		t.Errorf("Expected 1 event emitted, got %v", blackhole["events_emitted"])
+	}
+	if blackhole["events_dropped"] != uint64(1) {
+		t.Errorf("Expected 1 event dropped on the full channel, got %v", blackhole["events_dropped"])
+	}
+
+	leak := stats[NameLeak].(map[string]interface{})
+	if leak["updates_inspected"] != uint64(2) || leak["events_emitted"] != uint64(0) {
+		t.Errorf("Unexpected leak counters: %v", leak)
+	}
+}
+
+func TestPipeline_Configure(t *testing.T) {
+	p, err := DefaultRegistry().Build([]string{NameWithdrawal, NameDDoS}, nil, Deps{Events: make(chan models.BGPEvent, 1)})
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	err = p.Configure(map[string]map[string]string{
+		NameWithdrawal: {"window": "15m"},
+		NameHijack:     {"min-peers": "2"}, // Not running
+	})
+	if err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	if d := p.Get(NameWithdrawal).(*WithdrawalStormDetector); d.window.Minutes() != 15 {
+		t.Errorf("Expected window 15m, got %s", d.window)
+	}
+
+	if err := p.Configure(map[string]map[string]string{NameDDoS: {"window": "10m"}}); err == nil {
+		t.Errorf("Expected error for a detector without options")
+	}
+
+	// Options not given again are reset to their defaults
+	if err := p.Configure(map[string]map[string]string{NameWithdrawal: {"min-peers": "3"}}); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	d := p.Get(NameWithdrawal).(*WithdrawalStormDetector)
+	if d.window != stormWindow || d.minPeers != 3 {
+		t.Errorf("Expected window reset to %s and min-peers 3, got %s and %d", stormWindow, d.window, d.minPeers)
+	}
+	if err := p.Configure(map[string]map[string]string{NameWithdrawal: nil, NameDDoS: nil}); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	if d.minPeers != stormMinPeers {
+		t.Errorf("Expected min-peers reset to %d, got %d", stormMinPeers, d.minPeers)
+	}
+}
+
+func TestPipeline_ConfigureInvalid(t *testing.T) {
+	p, err := DefaultRegistry().Build([]string{NameHijack, NameWithdrawal}, nil, Deps{Events: make(chan models.BGPEvent, 1)})
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	// The hijack detector runs first: its options must not be applied
+	// when those of a later detector are invalid
+	err = p.Configure(map[string]map[string]string{
+		NameHijack:     {"min-peers": "5"},
+		NameWithdrawal: {"window": "soon"},
+	})
+	if err == nil {
+		t.Fatal("Expected error for an invalid window")
+	}
+	if d := p.Get(NameHijack).(*HijackDetector); d.minPeers.Load() != defaultHijackMinPeers {
+		t.Errorf("Expected min-peers unchanged at %d, got %d", defaultHijackMinPeers, d.minPeers.Load())
+	}
+}
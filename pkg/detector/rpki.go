@@ -0,0 +1,44 @@
+package detector
+
+import (
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+	"github.com/hervehildenbrand/bgp-radar/pkg/rpki"
+)
+
+// rpkiInvalidConfidence is the minimum confidence of an event whose origin
+// is RPKI invalid. A ROA mismatch is the strongest signal we have.
+const rpkiInvalidConfidence = 0.95
+
+// stampRPKI records the RPKI validation result on an event.
+func stampRPKI(event *models.BGPEvent, result rpki.Result) {
+	event.RPKIStatus = result.Status
+	event.Details["rpki_status"] = result.Status
+	if result.Reason != "" {
+		event.Details["rpki_reason"] = result.Reason
+	}
+}
+
+// escalateRPKIInvalid raises severity by one level and confidence to at least
+// rpkiInvalidConfidence for an event whose origin is RPKI invalid.
+func escalateRPKIInvalid(event *models.BGPEvent, flag string) {
+	event.Severity = raiseSeverity(event.Severity)
+
+	if confidence, ok := event.Details["confidence"].(float64); !ok || confidence < rpkiInvalidConfidence {
+		event.Details["confidence"] = rpkiInvalidConfidence
+	}
+
+	flags, _ := event.Details["flags"].([]string)
+	event.Details["flags"] = append(flags, flag)
+}
+
+// raiseSeverity returns the next severity level, capped at critical.
+func raiseSeverity(severity string) string {
+	switch severity {
+	case models.SeverityLow:
+		return models.SeverityMedium
+	case models.SeverityMedium:
+		return models.SeverityHigh
+	default:
+		return models.SeverityCritical
+	}
+}
@@ -0,0 +1,323 @@
+package detector
+
+import (
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+	"github.com/hervehildenbrand/bgp-radar/pkg/rpki"
+	"github.com/hervehildenbrand/bgp-radar/pkg/trie"
+	"github.com/hervehildenbrand/bgp-radar/pkg/watchlist"
+)
+
+// WatchlistDetector checks announcements of the operator's own prefixes
+// against a watchlist of authorized origins, upstreams and prefix lengths,
+// rather than learning origins from what it sees first. It reports:
+//
+//   - unauthorized_origin: a watched prefix or more-specific announced by an
+//     origin not listed for it
+//   - unexpected_more_specific: an authorized origin announcing a prefix
+//     longer than the entry's max_length
+//   - unexpected_upstream: the origin's neighbor in the path is not an
+//     allowed upstream
+//   - rpki_invalid: the announcement is RPKI invalid
+//   - visibility_loss: fewer peers than the entry's min_peers see the prefix
+//     from an authorized origin, after at least that many did
+//
+// Events carry the entry's owner in details.owner, for routing alerts per
+// team. Like hijacks, the first four end once no peer sees the anomaly;
+// visibility losses end once enough peers see the prefix again.
+type WatchlistDetector struct {
+	emitter
+	watchlist atomic.Pointer[watchlist.Watchlist]
+	rpki      *rpki.Validator
+
+	// Anomalies reported and not yet ended, by event type and prefix
+	active *activeEvents
+
+	// Peers seeing each watched prefix
+	visibilityMu sync.Mutex
+	visibility   map[netip.Prefix]*prefixVisibility
+}
+
+// prefixVisibility tracks the peers seeing a watched prefix.
+type prefixVisibility struct {
+	peers   map[string]struct{}
+	reached bool         // min_peers was reached at least once
+	lost    *activeEvent // Visibility loss in progress
+}
+
+// NewWatchlistDetector creates a watchlist detector. With a nil watchlist
+// it reports nothing until SetWatchlist is called.
+func NewWatchlistDetector(events chan<- models.BGPEvent, w *watchlist.Watchlist) *WatchlistDetector {
+	d := &WatchlistDetector{
+		emitter:    emitter{events: events},
+		active:     newActiveEvents(),
+		visibility: make(map[netip.Prefix]*prefixVisibility),
+	}
+	d.watchlist.Store(w)
+	return d
+}
+
+// Name returns the detector name.
+func (d *WatchlistDetector) Name() string { return NameWatchlist }
+
+// Start is a no-op: the detector has no background work.
+func (d *WatchlistDetector) Start() {}
+
+// Stop is a no-op.
+func (d *WatchlistDetector) Stop() {}
+
+// SetRPKIValidator enables the rpki_invalid check.
+func (d *WatchlistDetector) SetRPKIValidator(v *rpki.Validator) {
+	d.rpki = v
+}
+
+// SetEventTimeout sets how long a reported anomaly may go unseen before it expires
+// (DefaultEventTimeout by default; 0 disables expiry).
+func (d *WatchlistDetector) SetEventTimeout(timeout time.Duration) {
+	d.active.setTimeout(timeout)
+}
+
+// SetWatchlist replaces the watchlist. It is safe to call while updates are
+// processed.
+func (d *WatchlistDetector) SetWatchlist(w *watchlist.Watchlist) {
+	d.watchlist.Store(w)
+}
+
+// Process checks an update of a watched prefix.
+func (d *WatchlistDetector) Process(update models.BGPUpdate) {
+	d.expireActive(d.active, update)
+
+	w := d.watchlist.Load()
+	if w == nil {
+		return
+	}
+	prefix, err := trie.ParsePrefix(update.Prefix)
+	if err != nil {
+		return
+	}
+	entry, ok := w.Match(prefix)
+	if !ok {
+		return
+	}
+	peer := peerKey(update)
+
+	if !update.Announcement {
+		for _, eventType := range []string{
+			models.EventTypeUnauthorizedOrigin, models.EventTypeMoreSpecific,
+			models.EventTypeUnexpectedUpstream, models.EventTypeRPKIInvalid,
+		} {
+			d.resolve(eventType, entry, update, peer, ResolutionWithdrawn)
+		}
+		d.trackVisibility(entry, prefix, update, peer, false)
+		return
+	}
+
+	authorized := authorizedOrigin(entry, update)
+	var upstream uint32
+	if !update.AmbiguousOrigin() {
+		upstream = originNeighbor(update.ASPath, update.OriginASN)
+	}
+	rpkiResult := d.rpki.ValidatePrefix(prefix, update.OriginASN)
+
+	d.check(models.EventTypeUnauthorizedOrigin, !authorized, entry, update, peer, rpkiResult, ResolutionOriginRestored)
+	d.check(models.EventTypeMoreSpecific, authorized && prefix.Bits() > entry.MaxLength,
+		entry, update, peer, rpkiResult, ResolutionOriginReplaced)
+	d.check(models.EventTypeUnexpectedUpstream, authorized && upstream != 0 && !entry.AllowedUpstream(upstream),
+		entry, update, peer, rpkiResult, ResolutionPathChanged)
+	d.check(models.EventTypeRPKIInvalid, rpkiResult.Status == models.RPKIInvalid,
+		entry, update, peer, rpkiResult, ResolutionRPKIValid)
+	d.trackVisibility(entry, prefix, update, peer, authorized)
+}
+
+// check reports the anomaly of the given type if the update shows it, and
+// otherwise records that the update's peer no longer sees it.
+func (d *WatchlistDetector) check(eventType string, anomalous bool, entry *watchlist.Entry,
+	update models.BGPUpdate, peer string, rpkiResult rpki.Result, reason string) {
+	key := eventType + "|" + update.Prefix
+	if !anomalous {
+		d.resolve(eventType, entry, update, peer, reason)
+		return
+	}
+	event := d.newEvent(eventType, entry, update)
+	stampRPKI(&event, rpkiResult)
+	if d.active.observe(key, peer, event, updateTime(update)) {
+		d.emit(event)
+	}
+}
+
+// resolve records that peer no longer sees the anomaly of the given type,
+// emitting its end once no peer does.
+func (d *WatchlistDetector) resolve(eventType string, entry *watchlist.Entry, update models.BGPUpdate, peer, reason string) {
+	event, ended := d.active.leave(eventType+"|"+update.Prefix, peer, update, reason)
+	if ended {
+		tagEntry(&event, entry)
+		d.emit(event)
+	}
+}
+
+// newEvent builds the event reporting an anomaly on a watched prefix.
+func (d *WatchlistDetector) newEvent(eventType string, entry *watchlist.Entry, update models.BGPUpdate) models.BGPEvent {
+	event := models.BGPEvent{
+		EventType:      eventType,
+		AffectedASN:    entry.Origins[0],
+		AffectedPrefix: update.Prefix,
+		DetectedAt:     updateTime(update),
+		IsActive:       true,
+		Details: map[string]interface{}{
+			"origin_asn":         update.OriginASN,
+			"authorized_origins": entry.Origins,
+			"as_path":            update.ASPath,
+			"peer_asn":           update.PeerASN,
+			"collector":          update.Collector,
+			"source":             update.Source,
+		},
+	}
+	if entry.AuthorizedOrigin(update.OriginASN) {
+		event.AffectedASN = update.OriginASN
+	}
+	tagAmbiguousOrigin(event.Details, update)
+
+	switch eventType {
+	case models.EventTypeUnauthorizedOrigin:
+		event.Severity = models.SeverityCritical
+		event.EventCategory = models.CategoryAttack
+	case models.EventTypeMoreSpecific:
+		event.Severity = models.SeverityMedium
+		event.EventCategory = models.CategoryMisconfiguration
+		event.Details["max_length"] = entry.MaxLength
+	case models.EventTypeUnexpectedUpstream:
+		event.Severity = models.SeverityHigh
+		event.EventCategory = models.CategoryAttack
+		event.Details["upstream_asn"] = originNeighbor(update.ASPath, update.OriginASN)
+		event.Details["allowed_upstreams"] = entry.Upstreams
+	case models.EventTypeRPKIInvalid:
+		event.Severity = models.SeverityHigh
+		event.EventCategory = models.CategoryMisconfiguration
+	}
+	tagEntry(&event, entry)
+	return event
+}
+
+// trackVisibility updates the peers seeing a watched prefix from an
+// authorized origin, reporting the loss and return of its visibility.
+// More-specifics of the entry are not tracked.
+func (d *WatchlistDetector) trackVisibility(entry *watchlist.Entry, prefix netip.Prefix, update models.BGPUpdate, peer string, visible bool) {
+	if prefix != entry.Prefix {
+		return
+	}
+
+	d.visibilityMu.Lock()
+	v, ok := d.visibility[prefix]
+	if !ok {
+		v = &prefixVisibility{peers: make(map[string]struct{})}
+		d.visibility[prefix] = v
+	}
+	if visible {
+		v.peers[peer] = struct{}{}
+	} else {
+		delete(v.peers, peer)
+	}
+
+	var event models.BGPEvent
+	var changed bool
+	switch {
+	case len(v.peers) >= entry.MinPeers:
+		v.reached = true
+		if v.lost != nil {
+			event, changed = resolution(v.lost, update, ResolutionVisible), true
+			tagEntry(&event, entry)
+			v.lost = nil
+		}
+	case v.reached && v.lost == nil:
+		event = models.BGPEvent{
+			EventType:      models.EventTypeVisibilityLoss,
+			Severity:       models.SeverityHigh,
+			EventCategory:  models.CategoryOutage,
+			AffectedASN:    entry.Origins[0],
+			AffectedPrefix: update.Prefix,
+			DetectedAt:     updateTime(update),
+			IsActive:       true,
+			Details: map[string]interface{}{
+				"visible_peers":      len(v.peers),
+				"min_peers":          entry.MinPeers,
+				"authorized_origins": entry.Origins,
+				"peer_asn":           update.PeerASN,
+				"collector":          update.Collector,
+				"source":             update.Source,
+			},
+		}
+		tagEntry(&event, entry)
+		v.lost = &activeEvent{event: event, started: updateTime(update)}
+		changed = true
+	}
+	d.visibilityMu.Unlock()
+
+	if changed {
+		d.emit(event)
+	}
+}
+
+// tagEntry records the watchlist entry an event is about.
+func tagEntry(event *models.BGPEvent, entry *watchlist.Entry) {
+	event.Details["watched_prefix"] = entry.Prefix.String()
+	if entry.Owner != "" {
+		event.Details["owner"] = entry.Owner
+	}
+	if entry.Description != "" {
+		event.Details["description"] = entry.Description
+	}
+}
+
+// authorizedOrigin reports whether the origin of an announcement is
+// authorized by the entry. An ambiguous AS_SET origin is authorized only if
+// every ASN of the set is.
+func authorizedOrigin(entry *watchlist.Entry, update models.BGPUpdate) bool {
+	set := update.OriginSet()
+	if set == nil {
+		return entry.AuthorizedOrigin(update.OriginASN)
+	}
+	for _, asn := range set {
+		if !entry.AuthorizedOrigin(asn) {
+			return false
+		}
+	}
+	return true
+}
+
+// originNeighbor returns the ASN before the origin in the path, skipping
+// origin prepends, or 0 if the path holds only the origin.
+func originNeighbor(path []uint32, origin uint32) uint32 {
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] != origin {
+			return path[i]
+		}
+	}
+	return 0
+}
+
+// Stats returns detector statistics.
+func (d *WatchlistDetector) Stats() map[string]interface{} {
+	watched := 0
+	if w := d.watchlist.Load(); w != nil {
+		watched = w.Len()
+	}
+
+	d.visibilityMu.Lock()
+	lost := 0
+	for _, v := range d.visibility {
+		if v.lost != nil {
+			lost++
+		}
+	}
+	d.visibilityMu.Unlock()
+
+	return d.withCounters(map[string]interface{}{
+		"watched_prefixes": watched,
+		"active_anomalies": d.active.count(),
+		"visibility_lost":  lost,
+	})
+}
@@ -0,0 +1,176 @@
+package detector
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+	"github.com/hervehildenbrand/bgp-radar/pkg/rpki"
+	"github.com/hervehildenbrand/bgp-radar/pkg/watchlist"
+)
+
+const testWatchlist = `
+owner: noc
+prefixes:
+  - prefix: 192.0.2.0/23
+    owner: dns-team
+    origins: [AS64500]
+    upstreams: [174, 3356]
+    max_length: 24
+    min_peers: 2
+`
+
+func newTestWatchlistDetector(t *testing.T) (*WatchlistDetector, chan models.BGPEvent) {
+	t.Helper()
+	w, err := watchlist.Parse([]byte(testWatchlist))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	events := make(chan models.BGPEvent, 10)
+	return NewWatchlistDetector(events, w), events
+}
+
+func receiveEvent(t *testing.T, events <-chan models.BGPEvent) models.BGPEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Expected an event, got none")
+	}
+	return models.BGPEvent{}
+}
+
+func expectNoEvent(t *testing.T, events <-chan models.BGPEvent) {
+	t.Helper()
+	select {
+	case event := <-events:
+		t.Fatalf("Expected no event, got %s: %v", event.EventType, event.Details)
+	default:
+	}
+}
+
+func TestWatchlistDetector_UnauthorizedOrigin(t *testing.T) {
+	d, events := newTestWatchlistDetector(t)
+
+	d.Process(announce("192.0.2.0/24", 6939, 174, 64500))
+	d.Process(announce("198.51.100.0/24", 6939, 64666)) // Not watched
+	expectNoEvent(t, events)
+
+	d.Process(announce("192.0.2.0/24", 6939, 64666))
+	event := receiveEvent(t, events)
+	if event.EventType != models.EventTypeUnauthorizedOrigin || event.Severity != models.SeverityCritical {
+		t.Errorf("Unexpected event %s/%s", event.EventType, event.Severity)
+	}
+	if event.AffectedASN != 64500 || event.Details["origin_asn"] != uint32(64666) {
+		t.Errorf("Expected AS64666 hijacking AS64500, got %v", event.Details)
+	}
+	if event.Details["owner"] != "dns-team" || event.Details["watched_prefix"] != "192.0.2.0/23" {
+		t.Errorf("Expected the entry tags, got %v", event.Details)
+	}
+
+	// A second peer seeing it reports nothing new; the event ends when both
+	// peers see the authorized origin again
+	other := announce("192.0.2.0/24", 3356, 64666)
+	d.Process(other)
+	expectNoEvent(t, events)
+	d.Process(announce("192.0.2.0/24", 6939, 174, 64500))
+	expectNoEvent(t, events)
+	d.Process(announce("192.0.2.0/24", 3356, 64500))
+	ended := receiveEvent(t, events)
+	if ended.IsActive || ended.EventType != models.EventTypeUnauthorizedOrigin || ended.Details["owner"] != "dns-team" {
+		t.Errorf("Expected the end of the unauthorized origin, got %+v", ended)
+	}
+	if ended.Details["resolution"] != ResolutionOriginRestored {
+		t.Errorf("Expected resolution %s, got %v", ResolutionOriginRestored, ended.Details["resolution"])
+	}
+}
+
+func TestWatchlistDetector_AmbiguousOrigin(t *testing.T) {
+	d, events := newTestWatchlistDetector(t)
+
+	// An aggregate of authorized origins only is authorized, whatever the
+	// upstream
+	d.Process(announceSet("192.0.2.0/24", []uint32{6939, 64510}, 64500))
+	expectNoEvent(t, events)
+
+	d.Process(announceSet("192.0.2.0/24", []uint32{6939, 174}, 64500, 64666))
+	event := receiveEvent(t, events)
+	if event.EventType != models.EventTypeUnauthorizedOrigin {
+		t.Fatalf("Expected %s, got %s", models.EventTypeUnauthorizedOrigin, event.EventType)
+	}
+	if event.Details["origin"] != OriginAmbiguous || event.Details["origin_asn"] != uint32(0) {
+		t.Errorf("Expected an ambiguous origin, got %v", event.Details)
+	}
+	if set, _ := event.Details["origin_set"].([]uint32); len(set) != 2 {
+		t.Errorf("Expected the origin set, got %v", event.Details["origin_set"])
+	}
+}
+
+func TestWatchlistDetector_MoreSpecificAndUpstream(t *testing.T) {
+	d, events := newTestWatchlistDetector(t)
+
+	d.Process(announce("192.0.2.0/25", 6939, 174, 64500))
+	event := receiveEvent(t, events)
+	if event.EventType != models.EventTypeMoreSpecific || event.Details["max_length"] != 24 {
+		t.Errorf("Expected an unexpected more-specific, got %s: %v", event.EventType, event.Details)
+	}
+
+	// Prepends are skipped to find the upstream
+	d.Process(announce("192.0.2.0/24", 6939, 64999, 64500, 64500))
+	event = receiveEvent(t, events)
+	if event.EventType != models.EventTypeUnexpectedUpstream || event.Details["upstream_asn"] != uint32(64999) {
+		t.Errorf("Expected an unexpected upstream AS64999, got %s: %v", event.EventType, event.Details)
+	}
+
+	d.Process(withdraw("192.0.2.0/25", 6939, time.Now()))
+	if ended := receiveEvent(t, events); ended.IsActive || ended.EventType != models.EventTypeMoreSpecific {
+		t.Errorf("Expected the more-specific to end, got %+v", ended)
+	}
+}
+
+func TestWatchlistDetector_RPKIInvalid(t *testing.T) {
+	d, events := newTestWatchlistDetector(t)
+	validator := rpki.NewValidator()
+	validator.Replace(rpki.NewTable([]rpki.VRP{
+		{Prefix: netip.MustParsePrefix("192.0.2.0/23"), MaxLength: 23, ASN: 64500},
+	}, "test"))
+	d.SetRPKIValidator(validator)
+
+	d.Process(announce("192.0.2.0/23", 6939, 174, 64500))
+	expectNoEvent(t, events)
+
+	// Allowed by the watchlist, but longer than the ROA max length
+	d.Process(announce("192.0.2.0/24", 6939, 174, 64500))
+	event := receiveEvent(t, events)
+	if event.EventType != models.EventTypeRPKIInvalid || event.RPKIStatus != models.RPKIInvalid {
+		t.Errorf("Expected an RPKI invalid event, got %s (%s)", event.EventType, event.RPKIStatus)
+	}
+}
+
+func TestWatchlistDetector_Visibility(t *testing.T) {
+	d, events := newTestWatchlistDetector(t)
+
+	// One peer is below min_peers, but visibility was never reached
+	d.Process(announce("192.0.2.0/23", 6939, 174, 64500))
+	d.Process(withdraw("192.0.2.0/23", 6939, time.Now()))
+	expectNoEvent(t, events)
+
+	d.Process(announce("192.0.2.0/23", 6939, 174, 64500))
+	d.Process(announce("192.0.2.0/23", 3356, 64500))
+	d.Process(withdraw("192.0.2.0/23", 3356, time.Now()))
+	event := receiveEvent(t, events)
+	if event.EventType != models.EventTypeVisibilityLoss || event.Details["visible_peers"] != 1 {
+		t.Errorf("Expected a visibility loss with 1 peer, got %s: %v", event.EventType, event.Details)
+	}
+	d.Process(withdraw("192.0.2.0/23", 6939, time.Now()))
+	expectNoEvent(t, events)
+
+	d.Process(announce("192.0.2.0/23", 6939, 174, 64500))
+	d.Process(announce("192.0.2.0/23", 3356, 64500))
+	ended := receiveEvent(t, events)
+	if ended.IsActive || ended.EventType != models.EventTypeVisibilityLoss || ended.Details["resolution"] != ResolutionVisible {
+		t.Errorf("Expected visibility to be restored, got %+v", ended)
+	}
+}
@@ -0,0 +1,715 @@
+package detector
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/database"
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+const (
+	stormWindow          = 5 * time.Minute
+	stormCooldown        = 30 * time.Minute
+	stormMinPeers        = 2    // Distinct peers that must withdraw a prefix
+	stormMinASNPrefixes  = 10   // Minimum withdrawn prefixes for an AS storm
+	stormMinASNFraction  = 0.5  // Fraction of an AS's prefixes that must disappear
+	stormMinCountryPfx   = 50   // Minimum withdrawn prefixes for a country storm
+	stormMinCountryFrac  = 0.1  // Fraction of a country's prefixes that must disappear
+	peerResetThreshold   = 5000 // Withdrawals per window that indicate a peer session reset
+	peerCounterBuckets   = 30
+	stormSamplePrefixes  = 10
+	stormMaxAffectedASNs = 10
+)
+
+// WithdrawalStormDetector detects mass withdrawals: a large fraction of an
+// origin AS's or a country's prefixes disappearing within a few minutes.
+// This is the signature of a network outage or a state-ordered shutdown.
+//
+// Withdrawals carry no AS path, so the detector learns each prefix's origin
+// from announcements. A prefix only counts as gone once several distinct
+// collector peers withdraw it, and peers withdrawing a full table (a session
+// reset at the collector) are ignored. Prefixes still withdrawn by min-peers
+// peers once the window has passed are forgotten until announced again.
+//
+// A storm ends, with an inactive event, once the prefixes withdrawn within
+// the window no longer reach its thresholds.
+type WithdrawalStormDetector struct {
+	emitter
+	resolver database.CountryResolver
+
+	mu              sync.Mutex
+	prefixOrigin    map[string]uint32 // prefix -> last announced origin
+	asnPrefixes     map[uint32]int    // origin -> announced prefix count
+	countryPrefixes map[string]int    // country -> announced prefix count
+	byASN           map[uint32]*withdrawalWindow
+	byCountry       map[string]*withdrawalWindow
+	byPeer          map[string]*slidingCounter
+	lastAlert       map[string]time.Time
+	lastSweep       time.Time
+	storms          map[string]*activeEvent // Storms reported and not yet ended, by alert key
+
+	// Thresholds, defaulting to the storm* constants (see Configure)
+	stormThresholds
+
+	// Stats
+	stormsDetected uint64
+	peerResets     uint64
+}
+
+// NewWithdrawalStormDetector creates a new withdrawal storm detector.
+// resolver maps origin ASNs to countries; use database.NewNullResolver() to
+// disable per-country tracking.
+func NewWithdrawalStormDetector(events chan<- models.BGPEvent, resolver database.CountryResolver) *WithdrawalStormDetector {
+	if resolver == nil {
+		resolver = database.NewNullResolver()
+	}
+	return &WithdrawalStormDetector{
+		emitter:         emitter{events: events},
+		resolver:        resolver,
+		prefixOrigin:    make(map[string]uint32),
+		asnPrefixes:     make(map[uint32]int),
+		countryPrefixes: make(map[string]int),
+		byASN:           make(map[uint32]*withdrawalWindow),
+		byCountry:       make(map[string]*withdrawalWindow),
+		byPeer:          make(map[string]*slidingCounter),
+		lastAlert:       make(map[string]time.Time),
+		storms:          make(map[string]*activeEvent),
+		stormThresholds: defaultStormThresholds(),
+	}
+}
+
+// stormThresholds are the thresholds of the withdrawal storm detector.
+type stormThresholds struct {
+	window             time.Duration
+	cooldown           time.Duration
+	minPeers           int
+	minASNPrefixes     int
+	minASNFraction     float64
+	minCountryPrefixes int
+	minCountryFraction float64
+	peerReset          int
+}
+
+func defaultStormThresholds() stormThresholds {
+	return stormThresholds{
+		window:             stormWindow,
+		cooldown:           stormCooldown,
+		minPeers:           stormMinPeers,
+		minASNPrefixes:     stormMinASNPrefixes,
+		minASNFraction:     stormMinASNFraction,
+		minCountryPrefixes: stormMinCountryPfx,
+		minCountryFraction: stormMinCountryFrac,
+		peerReset:          peerResetThreshold,
+	}
+}
+
+// Name returns the detector name.
+func (d *WithdrawalStormDetector) Name() string { return NameWithdrawal }
+
+// Start is a no-op: state is pruned while processing updates.
+func (d *WithdrawalStormDetector) Start() {}
+
+// Stop is a no-op.
+func (d *WithdrawalStormDetector) Stop() {}
+
+// Configure sets detection thresholds by name: window, cooldown, min-peers,
+// min-asn-prefixes, min-asn-fraction, min-country-prefixes,
+// min-country-fraction and peer-reset. Thresholds not given are reset to
+// their defaults. It may be called while updates are processed. A new
+// min-peers applies to the withdrawals already tracked; a new window
+// restarts the per-peer session reset counts.
+func (d *WithdrawalStormDetector) Configure(options map[string]string) error {
+	t, err := parseStormThresholds(options)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if t.window != d.window {
+		d.byPeer = make(map[string]*slidingCounter)
+	}
+	if t.minPeers != d.minPeers {
+		for _, w := range d.byASN {
+			w.setMinPeers(t.minPeers)
+		}
+		for _, w := range d.byCountry {
+			w.setMinPeers(t.minPeers)
+		}
+	}
+	d.stormThresholds = t
+	return nil
+}
+
+// parseStormThresholds parses withdrawal storm detector options, defaulting
+// those not given.
+func parseStormThresholds(options map[string]string) (stormThresholds, error) {
+	t := defaultStormThresholds()
+	for name, value := range options {
+		var err error
+		switch name {
+		case "window":
+			t.window, err = parseDurationOption(name, value)
+		case "cooldown":
+			t.cooldown, err = parseDurationOption(name, value)
+		case "min-peers":
+			t.minPeers, err = parseIntOption(name, value)
+		case "min-asn-prefixes":
+			t.minASNPrefixes, err = parseIntOption(name, value)
+		case "min-asn-fraction":
+			t.minASNFraction, err = parseFractionOption(name, value)
+		case "min-country-prefixes":
+			t.minCountryPrefixes, err = parseIntOption(name, value)
+		case "min-country-fraction":
+			t.minCountryFraction, err = parseFractionOption(name, value)
+		case "peer-reset":
+			t.peerReset, err = parseIntOption(name, value)
+		default:
+			err = fmt.Errorf("unknown option %q", name)
+		}
+		if err != nil {
+			return t, err
+		}
+	}
+	return t, nil
+}
+
+// Process tracks announcements and withdrawals for storm detection.
+func (d *WithdrawalStormDetector) Process(update models.BGPUpdate) {
+	now := updateTime(update)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if update.Announcement {
+		d.trackAnnouncement(update)
+		d.endStorms(update, ResolutionVisible)
+		return
+	}
+
+	peer := peerKey(update)
+	counter := d.byPeer[peer]
+	if counter == nil {
+		counter = newSlidingCounter(d.window, peerCounterBuckets)
+		d.byPeer[peer] = counter
+	}
+	before := counter.total(now)
+	counter.add(now, 1)
+
+	// A peer withdrawing this many prefixes lost its session with the
+	// collector; its withdrawals say nothing about the origins.
+	if before >= uint64(d.peerReset) {
+		if before == uint64(d.peerReset) {
+			atomic.AddUint64(&d.peerResets, 1)
+		}
+		return
+	}
+
+	origin, ok := d.prefixOrigin[update.Prefix]
+	if !ok {
+		return
+	}
+	country := d.resolver.Resolve(origin)
+
+	asnWindow := d.byASN[origin]
+	if asnWindow == nil {
+		asnWindow = newWithdrawalWindow(d.minPeers)
+		d.byASN[origin] = asnWindow
+	}
+	asnWindow.add(now, update.Prefix, peer)
+	d.checkASN(origin, country, asnWindow, now)
+
+	if country != "" {
+		countryWindow := d.byCountry[country]
+		if countryWindow == nil {
+			countryWindow = newWithdrawalWindow(d.minPeers)
+			d.byCountry[country] = countryWindow
+		}
+		countryWindow.add(now, update.Prefix, peer)
+		d.checkCountry(country, countryWindow, now)
+	}
+
+	if now.Sub(d.lastSweep) > d.window {
+		d.sweep(now)
+	}
+	d.endStorms(update, ResolutionWindowExpired)
+}
+
+// Stats returns detector statistics.
+func (d *WithdrawalStormDetector) Stats() map[string]interface{} {
+	d.mu.Lock()
+	tracked := len(d.prefixOrigin)
+	origins := len(d.asnPrefixes)
+	storms := len(d.storms)
+	d.mu.Unlock()
+
+	return d.withCounters(map[string]interface{}{
+		"tracked_prefixes": tracked,
+		"tracked_origins":  origins,
+		"active_storms":    storms,
+		"storms_detected":  atomic.LoadUint64(&d.stormsDetected),
+		"peer_resets":      atomic.LoadUint64(&d.peerResets),
+	})
+}
+
+// trackAnnouncement records the prefix origin and clears any pending
+// withdrawal of the prefix, since it is visible again.
+func (d *WithdrawalStormDetector) trackAnnouncement(update models.BGPUpdate) {
+	if update.OriginASN == 0 {
+		return
+	}
+
+	previous, known := d.prefixOrigin[update.Prefix]
+	if known {
+		d.clearWithdrawn(update.Prefix, previous)
+		if previous == update.OriginASN {
+			return
+		}
+
+		// Origin changed: move the prefix to its new origin and country
+		d.untrack(update.Prefix, previous)
+	}
+
+	d.prefixOrigin[update.Prefix] = update.OriginASN
+	d.asnPrefixes[update.OriginASN]++
+	if country := d.resolver.Resolve(update.OriginASN); country != "" {
+		d.countryPrefixes[country]++
+	}
+}
+
+// untrack removes prefix from the prefixes announced by origin.
+func (d *WithdrawalStormDetector) untrack(prefix string, origin uint32) {
+	delete(d.prefixOrigin, prefix)
+	d.asnPrefixes[origin]--
+	if d.asnPrefixes[origin] <= 0 {
+		delete(d.asnPrefixes, origin)
+	}
+	if country := d.resolver.Resolve(origin); country != "" {
+		d.countryPrefixes[country]--
+		if d.countryPrefixes[country] <= 0 {
+			delete(d.countryPrefixes, country)
+		}
+	}
+}
+
+// pruneASN expires the withdrawals of asn older than the window. Prefixes
+// that were gone are forgotten: they no longer count in the totals until
+// announced again.
+func (d *WithdrawalStormDetector) pruneASN(asn uint32, w *withdrawalWindow, now time.Time) {
+	for _, prefix := range w.prune(now, d.window) {
+		if origin, ok := d.prefixOrigin[prefix]; ok && origin == asn {
+			d.untrack(prefix, asn)
+		}
+	}
+}
+
+// clearWithdrawn removes prefix from the withdrawal windows of its origin.
+func (d *WithdrawalStormDetector) clearWithdrawn(prefix string, origin uint32) {
+	if w := d.byASN[origin]; w != nil {
+		w.remove(prefix)
+	}
+	if country := d.resolver.Resolve(origin); country != "" {
+		if w := d.byCountry[country]; w != nil {
+			w.remove(prefix)
+		}
+	}
+}
+
+func (d *WithdrawalStormDetector) checkASN(asn uint32, country string, w *withdrawalWindow, now time.Time) {
+	d.pruneASN(asn, w, now)
+	gone := w.gone()
+	total := d.asnPrefixes[asn]
+	if gone < d.minASNPrefixes || total == 0 {
+		return
+	}
+	fraction := float64(gone) / float64(total)
+	if fraction < d.minASNFraction {
+		return
+	}
+
+	key := "asn:" + strconv.FormatUint(uint64(asn), 10)
+	if !d.shouldAlert(key, now) {
+		return
+	}
+
+	severity := models.SeverityMedium
+	if fraction >= 0.9 {
+		severity = models.SeverityHigh
+	}
+	if IsTier1(asn) {
+		severity = models.SeverityCritical
+	}
+
+	d.emit(key, models.BGPEvent{
+		EventType:     models.EventTypeWithdrawalStorm,
+		Severity:      severity,
+		EventCategory: models.CategoryOutage,
+		CountryCode:   country,
+		AffectedASN:   asn,
+		DetectedAt:    now,
+		IsActive:      true,
+		Details: map[string]interface{}{
+			"scope":              "asn",
+			"withdrawn_prefixes": gone,
+			"total_prefixes":     total,
+			"fraction":           fraction,
+			"window_seconds":     int(d.window.Seconds()),
+			"peers":              w.peerCount(),
+			"sample_prefixes":    w.sample(stormSamplePrefixes),
+			"confidence":         stormConfidence(fraction, w.peerCount()),
+		},
+	})
+}
+
+func (d *WithdrawalStormDetector) checkCountry(country string, w *withdrawalWindow, now time.Time) {
+	w.prune(now, d.window)
+	gone := w.gone()
+	total := d.countryPrefixes[country]
+	if gone < d.minCountryPrefixes || total == 0 {
+		return
+	}
+	fraction := float64(gone) / float64(total)
+	if fraction < d.minCountryFraction {
+		return
+	}
+
+	key := "country:" + country
+	if !d.shouldAlert(key, now) {
+		return
+	}
+
+	// A country losing a large share of its routes at once is rare outside
+	// of government-ordered shutdowns and major cable cuts
+	severity := models.SeverityHigh
+	if fraction >= 0.5 {
+		severity = models.SeverityCritical
+	}
+
+	d.emit(key, models.BGPEvent{
+		EventType:     models.EventTypeWithdrawalStorm,
+		Severity:      severity,
+		EventCategory: models.CategoryOutage,
+		CountryCode:   country,
+		DetectedAt:    now,
+		IsActive:      true,
+		Details: map[string]interface{}{
+			"scope":              "country",
+			"withdrawn_prefixes": gone,
+			"total_prefixes":     total,
+			"fraction":           fraction,
+			"window_seconds":     int(d.window.Seconds()),
+			"peers":              w.peerCount(),
+			"affected_asns":      d.topOrigins(w, stormMaxAffectedASNs),
+			"sample_prefixes":    w.sample(stormSamplePrefixes),
+			"confidence":         stormConfidence(fraction, w.peerCount()),
+		},
+	})
+}
+
+func (d *WithdrawalStormDetector) shouldAlert(key string, now time.Time) bool {
+	if last, ok := d.lastAlert[key]; ok && now.Sub(last) < d.cooldown {
+		return false
+	}
+	d.lastAlert[key] = now
+	return true
+}
+
+// emit reports a storm and tracks it as active under key.
+func (d *WithdrawalStormDetector) emit(key string, event models.BGPEvent) {
+	atomic.AddUint64(&d.stormsDetected, 1)
+	if _, ok := d.storms[key]; !ok {
+		d.storms[key] = &activeEvent{event: event, started: event.DetectedAt}
+	}
+	d.emitter.emit(event)
+}
+
+// endStorms reports the end of the active storms whose withdrawals no
+// longer reach their thresholds, for the given reason.
+func (d *WithdrawalStormDetector) endStorms(update models.BGPUpdate, reason string) {
+	for key, storm := range d.storms {
+		var gone, total int
+		var ongoing bool
+		if storm.event.Details["scope"] == "asn" {
+			if w := d.byASN[storm.event.AffectedASN]; w != nil {
+				gone = w.gone()
+			}
+			total = d.asnPrefixes[storm.event.AffectedASN]
+			ongoing = stormOngoing(gone, total, d.minASNPrefixes, d.minASNFraction)
+		} else {
+			if w := d.byCountry[storm.event.CountryCode]; w != nil {
+				gone = w.gone()
+			}
+			total = d.countryPrefixes[storm.event.CountryCode]
+			ongoing = stormOngoing(gone, total, d.minCountryPrefixes, d.minCountryFraction)
+		}
+		if ongoing {
+			continue
+		}
+		delete(d.storms, key)
+
+		event := resolution(storm, update, reason)
+		event.Details["scope"] = storm.event.Details["scope"]
+		event.Details["withdrawn_prefixes"] = gone
+		event.Details["total_prefixes"] = total
+		d.emitter.emit(event)
+	}
+}
+
+// stormOngoing reports whether gone of total prefixes still reach the
+// given thresholds.
+func stormOngoing(gone, total, minPrefixes int, minFraction float64) bool {
+	return total > 0 && gone >= minPrefixes && float64(gone)/float64(total) >= minFraction
+}
+
+// topOrigins returns the origins with the most withdrawn prefixes in w.
+func (d *WithdrawalStormDetector) topOrigins(w *withdrawalWindow, limit int) []uint32 {
+	counts := make(map[uint32]int)
+	for prefix, entry := range w.prefixes {
+		if len(entry.peers) < w.minPeers {
+			continue
+		}
+		if origin, ok := d.prefixOrigin[prefix]; ok {
+			counts[origin]++
+		}
+	}
+
+	origins := make([]uint32, 0, len(counts))
+	for asn := range counts {
+		origins = append(origins, asn)
+	}
+	sort.Slice(origins, func(i, j int) bool {
+		if counts[origins[i]] != counts[origins[j]] {
+			return counts[origins[i]] > counts[origins[j]]
+		}
+		return origins[i] < origins[j]
+	})
+	if len(origins) > limit {
+		origins = origins[:limit]
+	}
+	return origins
+}
+
+// sweep drops expired windows so idle keys do not accumulate.
+func (d *WithdrawalStormDetector) sweep(now time.Time) {
+	for asn, w := range d.byASN {
+		if d.pruneASN(asn, w, now); w.len() == 0 {
+			delete(d.byASN, asn)
+		}
+	}
+	for country, w := range d.byCountry {
+		if w.prune(now, d.window); w.len() == 0 {
+			delete(d.byCountry, country)
+		}
+	}
+	for peer, c := range d.byPeer {
+		if c.total(now) == 0 {
+			delete(d.byPeer, peer)
+		}
+	}
+	for key, last := range d.lastAlert {
+		if now.Sub(last) > d.cooldown {
+			delete(d.lastAlert, key)
+		}
+	}
+	d.lastSweep = now
+}
+
+// stormConfidence grows with the withdrawn fraction and peer agreement.
+func stormConfidence(fraction float64, peers int) float64 {
+	confidence := 0.5 + fraction*0.3
+	if peers >= 5 {
+		confidence += 0.15
+	} else if peers >= 3 {
+		confidence += 0.1
+	}
+	if confidence > 0.95 {
+		confidence = 0.95
+	}
+	return confidence
+}
+
+// peerKey identifies a collector BGP session.
+func peerKey(update models.BGPUpdate) string {
+	return update.Collector + ":" + strconv.FormatUint(uint64(update.PeerASN), 10)
+}
+
+// withdrawalWindow tracks prefixes withdrawn within a sliding window.
+// Entries are expired in insertion order, so pruning is amortized O(1).
+type withdrawalWindow struct {
+	minPeers int
+	prefixes map[string]*withdrawnPrefix
+	order    []windowEntry
+	goneN    int // prefixes withdrawn by at least minPeers peers
+}
+
+type withdrawnPrefix struct {
+	at    time.Time
+	peers map[string]struct{}
+}
+
+type windowEntry struct {
+	prefix string
+	at     time.Time
+}
+
+func newWithdrawalWindow(minPeers int) *withdrawalWindow {
+	return &withdrawalWindow{
+		minPeers: minPeers,
+		prefixes: make(map[string]*withdrawnPrefix),
+	}
+}
+
+func (w *withdrawalWindow) add(now time.Time, prefix, peer string) {
+	entry := w.prefixes[prefix]
+	if entry == nil {
+		entry = &withdrawnPrefix{at: now, peers: make(map[string]struct{}, w.minPeers)}
+		w.prefixes[prefix] = entry
+		w.order = append(w.order, windowEntry{prefix: prefix, at: now})
+	}
+	if _, seen := entry.peers[peer]; seen {
+		return
+	}
+	entry.peers[peer] = struct{}{}
+	if len(entry.peers) == w.minPeers {
+		w.goneN++
+	}
+}
+
+// setMinPeers changes the peers a prefix must be withdrawn by to count as
+// gone.
+func (w *withdrawalWindow) setMinPeers(minPeers int) {
+	w.minPeers = minPeers
+	w.goneN = 0
+	for _, entry := range w.prefixes {
+		if len(entry.peers) >= minPeers {
+			w.goneN++
+		}
+	}
+}
+
+func (w *withdrawalWindow) remove(prefix string) {
+	entry := w.prefixes[prefix]
+	if entry == nil {
+		return
+	}
+	if len(entry.peers) >= w.minPeers {
+		w.goneN--
+	}
+	delete(w.prefixes, prefix)
+}
+
+// prune expires the withdrawals older than window and returns the expired
+// prefixes that were gone.
+func (w *withdrawalWindow) prune(now time.Time, window time.Duration) []string {
+	var expired []string
+	i := 0
+	for ; i < len(w.order) && now.Sub(w.order[i].at) > window; i++ {
+		// Skip stale entries for prefixes re-announced and withdrawn again
+		prefix := w.order[i].prefix
+		if entry := w.prefixes[prefix]; entry != nil && entry.at.Equal(w.order[i].at) {
+			if len(entry.peers) >= w.minPeers {
+				expired = append(expired, prefix)
+			}
+			w.remove(prefix)
+		}
+	}
+	if i > 0 {
+		w.order = append(w.order[:0], w.order[i:]...)
+	}
+	return expired
+}
+
+func (w *withdrawalWindow) len() int {
+	return len(w.prefixes)
+}
+
+// gone returns the number of prefixes withdrawn by at least minPeers peers.
+func (w *withdrawalWindow) gone() int {
+	return w.goneN
+}
+
+// peerCount returns the number of distinct peers that withdrew prefixes.
+func (w *withdrawalWindow) peerCount() int {
+	peers := make(map[string]struct{})
+	for _, entry := range w.prefixes {
+		for peer := range entry.peers {
+			peers[peer] = struct{}{}
+		}
+	}
+	return len(peers)
+}
+
+// sample returns up to limit withdrawn prefixes, sorted.
+func (w *withdrawalWindow) sample(limit int) []string {
+	prefixes := make([]string, 0, limit)
+	for prefix, entry := range w.prefixes {
+		if len(entry.peers) >= w.minPeers {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	sort.Strings(prefixes)
+	if len(prefixes) > limit {
+		prefixes = prefixes[:limit]
+	}
+	return prefixes
+}
+
+// slidingCounter counts events over a sliding window using fixed buckets.
+type slidingCounter struct {
+	width    time.Duration
+	buckets  []uint64
+	head     int
+	headTime time.Time
+}
+
+func newSlidingCounter(window time.Duration, buckets int) *slidingCounter {
+	width := window / time.Duration(buckets)
+	if width <= 0 {
+		width = 1 // Windows shorter than one nanosecond per bucket
+	}
+	return &slidingCounter{
+		width:   width,
+		buckets: make([]uint64, buckets),
+	}
+}
+
+func (c *slidingCounter) advance(now time.Time) {
+	if c.headTime.IsZero() {
+		c.headTime = now.Truncate(c.width)
+		return
+	}
+	steps := int(now.Sub(c.headTime) / c.width)
+	if steps <= 0 {
+		return // Same bucket, or out-of-order timestamp
+	}
+	if steps >= len(c.buckets) {
+		for i := range c.buckets {
+			c.buckets[i] = 0
+		}
+	} else {
+		for i := 0; i < steps; i++ {
+			c.head = (c.head + 1) % len(c.buckets)
+			c.buckets[c.head] = 0
+		}
+	}
+	c.headTime = now.Truncate(c.width)
+}
+
+func (c *slidingCounter) add(now time.Time, n uint64) {
+	c.advance(now)
+	c.buckets[c.head] += n
+}
+
+func (c *slidingCounter) total(now time.Time) uint64 {
+	c.advance(now)
+	var sum uint64
+	for _, n := range c.buckets {
+		sum += n
+	}
+	return sum
+}
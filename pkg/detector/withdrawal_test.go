@@ -0,0 +1,276 @@
+package detector
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+// staticResolver maps every ASN to a fixed country.
+type staticResolver map[uint32]string
+
+func (r staticResolver) Resolve(asn uint32) string    { return r[asn] }
+func (r staticResolver) ResolveFromPath([]int) string { return "" }
+func (r staticResolver) Count() int                   { return len(r) }
+func (r staticResolver) Start()                       {}
+func (r staticResolver) Stop()                        {}
+
+func withdraw(prefix string, peer uint32, at time.Time) models.BGPUpdate {
+	return models.BGPUpdate{
+		Timestamp:    at,
+		PeerASN:      peer,
+		Prefix:       prefix,
+		Announcement: false,
+		Collector:    "rrc00",
+	}
+}
+
+func TestWithdrawalStormDetector_ASN(t *testing.T) {
+	events := make(chan models.BGPEvent, 10)
+	d := NewWithdrawalStormDetector(events, staticResolver{64500: "AA"})
+
+	start := time.Unix(1705320000, 0)
+	for i := 0; i < 20; i++ {
+		d.Process(announce(fmt.Sprintf("10.0.%d.0/24", i), 6939, 64500))
+	}
+
+	// 15 of 20 prefixes withdrawn by two peers within a minute
+	for i := 0; i < 15; i++ {
+		at := start.Add(time.Duration(i) * time.Second)
+		d.Process(withdraw(fmt.Sprintf("10.0.%d.0/24", i), 6939, at))
+		d.Process(withdraw(fmt.Sprintf("10.0.%d.0/24", i), 174, at))
+	}
+
+	select {
+	case event := <-events:
+		if event.EventType != models.EventTypeWithdrawalStorm {
+			t.Errorf("Expected event type %s, got %s", models.EventTypeWithdrawalStorm, event.EventType)
+		}
+		if event.EventCategory != models.CategoryOutage {
+			t.Errorf("Expected category %s, got %s", models.CategoryOutage, event.EventCategory)
+		}
+		if event.AffectedASN != 64500 || event.CountryCode != "AA" {
+			t.Errorf("Unexpected affected ASN/country: %d %s", event.AffectedASN, event.CountryCode)
+		}
+		if event.Details["scope"] != "asn" {
+			t.Errorf("Expected scope asn, got %v", event.Details["scope"])
+		}
+		if event.Details["total_prefixes"] != 20 {
+			t.Errorf("Expected 20 total prefixes, got %v", event.Details["total_prefixes"])
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Expected withdrawal storm event, got none")
+	}
+
+	// Cooldown suppresses repeat alerts for the same AS
+	for i := 15; i < 20; i++ {
+		d.Process(withdraw(fmt.Sprintf("10.0.%d.0/24", i), 6939, start.Add(time.Minute)))
+		d.Process(withdraw(fmt.Sprintf("10.0.%d.0/24", i), 174, start.Add(time.Minute)))
+	}
+	select {
+	case event := <-events:
+		t.Errorf("Expected no repeat event during cooldown, got %+v", event.Details)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWithdrawalStormDetector_SinglePeer(t *testing.T) {
+	events := make(chan models.BGPEvent, 10)
+	d := NewWithdrawalStormDetector(events, nil)
+
+	start := time.Unix(1705320000, 0)
+	for i := 0; i < 20; i++ {
+		d.Process(announce(fmt.Sprintf("10.0.%d.0/24", i), 6939, 64500))
+	}
+	// Withdrawals seen by a single peer only: a local routing change, not an outage
+	for i := 0; i < 20; i++ {
+		d.Process(withdraw(fmt.Sprintf("10.0.%d.0/24", i), 6939, start))
+	}
+
+	select {
+	case event := <-events:
+		t.Errorf("Expected no event for single-peer withdrawals, got %+v", event.Details)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWithdrawalStormDetector_ReconfigureMinPeers(t *testing.T) {
+	events := make(chan models.BGPEvent, 10)
+	d := NewWithdrawalStormDetector(events, nil)
+
+	start := time.Unix(1705320000, 0)
+	for i := 0; i < 20; i++ {
+		d.Process(announce(fmt.Sprintf("10.0.%d.0/24", i), 6939, 64500))
+	}
+	for i := 0; i < 15; i++ {
+		d.Process(withdraw(fmt.Sprintf("10.0.%d.0/24", i), 6939, start))
+	}
+
+	// The withdrawals already tracked count once min-peers is lowered
+	if err := d.Configure(map[string]string{"min-peers": "1"}); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	d.Process(withdraw("10.0.15.0/24", 6939, start.Add(time.Second)))
+
+	select {
+	case event := <-events:
+		if event.Details["withdrawn_prefixes"] != 16 {
+			t.Errorf("Expected 16 withdrawn prefixes, got %v", event.Details["withdrawn_prefixes"])
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Expected withdrawal storm event, got none")
+	}
+}
+
+func TestWithdrawalStormDetector_Reannounced(t *testing.T) {
+	events := make(chan models.BGPEvent, 10)
+	d := NewWithdrawalStormDetector(events, nil)
+
+	start := time.Unix(1705320000, 0)
+	for i := 0; i < 20; i++ {
+		d.Process(announce(fmt.Sprintf("10.0.%d.0/24", i), 6939, 64500))
+	}
+	// Each prefix flaps: withdrawn and immediately re-announced
+	for i := 0; i < 20; i++ {
+		prefix := fmt.Sprintf("10.0.%d.0/24", i)
+		d.Process(withdraw(prefix, 6939, start))
+		d.Process(announce(prefix, 6939, 64500))
+		d.Process(withdraw(prefix, 174, start))
+		d.Process(announce(prefix, 174, 64500))
+	}
+
+	select {
+	case event := <-events:
+		t.Errorf("Expected no event for flapping prefixes, got %+v", event.Details)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// startStorm withdraws 15 of the 20 prefixes of AS64500 at start and waits
+// for the storm event.
+func startStorm(t *testing.T, d *WithdrawalStormDetector, events <-chan models.BGPEvent, start time.Time) {
+	t.Helper()
+	for i := 0; i < 20; i++ {
+		d.Process(announce(fmt.Sprintf("10.0.%d.0/24", i), 6939, 64500))
+	}
+	for i := 0; i < 15; i++ {
+		d.Process(withdraw(fmt.Sprintf("10.0.%d.0/24", i), 6939, start))
+		d.Process(withdraw(fmt.Sprintf("10.0.%d.0/24", i), 174, start))
+	}
+
+	select {
+	case <-events:
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Expected withdrawal storm event, got none")
+	}
+}
+
+func TestWithdrawalStormDetector_EndReannounced(t *testing.T) {
+	events := make(chan models.BGPEvent, 10)
+	d := NewWithdrawalStormDetector(events, nil)
+	startStorm(t, d, events, time.Unix(1705320000, 0))
+
+	// 10 prefixes back: 5 withdrawn is below the minimum of 10
+	for i := 0; i < 10; i++ {
+		d.Process(announce(fmt.Sprintf("10.0.%d.0/24", i), 6939, 64500))
+	}
+
+	select {
+	case event := <-events:
+		if event.IsActive {
+			t.Error("Expected inactive end event")
+		}
+		if event.AffectedASN != 64500 || event.Details["scope"] != "asn" {
+			t.Errorf("Unexpected end event for AS%d, scope %v", event.AffectedASN, event.Details["scope"])
+		}
+		if event.Details["resolution"] != ResolutionVisible {
+			t.Errorf("Expected resolution %s, got %v", ResolutionVisible, event.Details["resolution"])
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Expected storm end event, got none")
+	}
+	if storms := d.Stats()["active_storms"]; storms != 0 {
+		t.Errorf("Expected no active storm, got %v", storms)
+	}
+}
+
+func TestWithdrawalStormDetector_EndWindowExpired(t *testing.T) {
+	events := make(chan models.BGPEvent, 10)
+	d := NewWithdrawalStormDetector(events, nil)
+	start := time.Unix(1705320000, 0)
+	startStorm(t, d, events, start)
+
+	// A later withdrawal expires the window: the prefixes still gone are
+	// forgotten and no longer count in the AS total
+	d.Process(withdraw("10.0.19.0/24", 6939, start.Add(stormWindow+time.Minute)))
+
+	select {
+	case event := <-events:
+		if event.IsActive {
+			t.Error("Expected inactive end event")
+		}
+		if event.Details["resolution"] != ResolutionWindowExpired {
+			t.Errorf("Expected resolution %s, got %v", ResolutionWindowExpired, event.Details["resolution"])
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Expected storm end event, got none")
+	}
+	if tracked := d.Stats()["tracked_prefixes"]; tracked != 5 {
+		t.Errorf("Expected 5 tracked prefixes, got %v", tracked)
+	}
+}
+
+func TestWithdrawalStormDetector_Country(t *testing.T) {
+	events := make(chan models.BGPEvent, 100)
+	resolver := staticResolver{}
+	d := NewWithdrawalStormDetector(events, resolver)
+
+	// 10 ASNs with 20 prefixes each, all in country "BB"
+	for asn := uint32(64500); asn < 64510; asn++ {
+		resolver[asn] = "BB"
+		for i := 0; i < 20; i++ {
+			d.Process(announce(fmt.Sprintf("10.%d.%d.0/24", asn-64500, i), 6939, asn))
+		}
+	}
+
+	// 6 prefixes per AS disappear: below the per-AS threshold, 30% of the country
+	start := time.Unix(1705320000, 0)
+	for asn := 0; asn < 10; asn++ {
+		for i := 0; i < 6; i++ {
+			prefix := fmt.Sprintf("10.%d.%d.0/24", asn, i)
+			d.Process(withdraw(prefix, 6939, start))
+			d.Process(withdraw(prefix, 174, start))
+		}
+	}
+
+	var countryEvent *models.BGPEvent
+	for len(events) > 0 {
+		event := <-events
+		if event.Details["scope"] == "country" {
+			countryEvent = &event
+		}
+	}
+	if countryEvent == nil {
+		t.Fatal("Expected country withdrawal storm event")
+	}
+	if countryEvent.CountryCode != "BB" {
+		t.Errorf("Expected country BB, got %s", countryEvent.CountryCode)
+	}
+	if countryEvent.Severity != models.SeverityHigh {
+		t.Errorf("Expected severity %s, got %s", models.SeverityHigh, countryEvent.Severity)
+	}
+}
+
+func TestSlidingCounter_ShortWindow(t *testing.T) {
+	// A window shorter than one nanosecond per bucket must not panic
+	c := newSlidingCounter(10*time.Nanosecond, peerCounterBuckets)
+	now := time.Now()
+	c.add(now, 1)
+	c.add(now.Add(5*time.Nanosecond), 1)
+
+	if total := c.total(now.Add(5 * time.Nanosecond)); total != 2 {
+		t.Errorf("Expected 2 events in the window, got %d", total)
+	}
+}
@@ -0,0 +1,303 @@
+// Package metrics exports bgp-radar statistics in the Prometheus format.
+//
+// Most metrics are read from the existing component statistics at scrape
+// time, so components keep their own counters and need no Prometheus
+// dependency.
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/database"
+	"github.com/hervehildenbrand/bgp-radar/pkg/detector"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "bgp_radar"
+
+// StatsFunc returns component statistics, e.g. source.Merger.Stats.
+type StatsFunc func() map[string]interface{}
+
+// Refresher is implemented by resolvers reporting when their mapping was
+// last loaded.
+type Refresher interface {
+	LastRefresh() time.Time
+}
+
+var (
+	collectorLabels = []string{"collector"}
+
+	messagesDesc = prometheus.NewDesc(namespace+"_collector_messages_received_total",
+		"RIS Live messages received.", collectorLabels, nil)
+	updatesDesc = prometheus.NewDesc(namespace+"_collector_updates_parsed_total",
+		"BGP updates parsed from RIS Live messages.", collectorLabels, nil)
+	parseErrorsDesc = prometheus.NewDesc(namespace+"_collector_parse_errors_total",
+		"RIS Live messages that failed to parse.", collectorLabels, nil)
+	updatesDroppedDesc = prometheus.NewDesc(namespace+"_collector_updates_dropped_total",
+		"Updates dropped because the update channel was full.", collectorLabels, nil)
+	reconnectsDesc = prometheus.NewDesc(namespace+"_collector_reconnects_total",
+		"Reconnections to RIS Live.", collectorLabels, nil)
+	connectedDesc = prometheus.NewDesc(namespace+"_collector_connected",
+		"Whether the collector is connected (1) or not (0).", collectorLabels, nil)
+
+	sourceUpdatesDesc = prometheus.NewDesc(namespace+"_source_updates_total",
+		"Updates forwarded to the detectors per update source.", []string{"source"}, nil)
+	channelLenDesc = prometheus.NewDesc(namespace+"_update_channel_length",
+		"Updates waiting in the update channel.", nil, nil)
+	channelCapDesc = prometheus.NewDesc(namespace+"_update_channel_capacity",
+		"Capacity of the update channel.", nil, nil)
+
+	workerQueueDesc = prometheus.NewDesc(namespace+"_worker_queue_length",
+		"Updates waiting in each detector worker queue.", []string{"worker"}, nil)
+	workerUpdatesDesc = prometheus.NewDesc(namespace+"_worker_updates_total",
+		"Updates processed by each detector worker.", []string{"worker"}, nil)
+
+	inspectedDesc = prometheus.NewDesc(namespace+"_detector_updates_inspected_total",
+		"Updates inspected by the detector.", []string{"detector"}, nil)
+	emittedDesc = prometheus.NewDesc(namespace+"_detector_events_total",
+		"Events emitted by the detector.", []string{"detector", "type", "severity"}, nil)
+	eventsDroppedDesc = prometheus.NewDesc(namespace+"_detector_events_dropped_total",
+		"Events dropped because the events channel was full.", []string{"detector"}, nil)
+
+	sinkLabels = []string{"sink"}
+
+	sinkWrittenDesc = prometheus.NewDesc(namespace+"_sink_events_written_total",
+		"Events delivered to the sink.", sinkLabels, nil)
+	sinkFilteredDesc = prometheus.NewDesc(namespace+"_sink_events_filtered_total",
+		"Events not matching the sink filter.", sinkLabels, nil)
+	sinkDroppedDesc = prometheus.NewDesc(namespace+"_sink_events_dropped_total",
+		"Events dropped because the sink queue was full.", sinkLabels, nil)
+	sinkErrorsDesc = prometheus.NewDesc(namespace+"_sink_errors_total",
+		"Events the sink failed to deliver.", sinkLabels, nil)
+	sinkQueueDesc = prometheus.NewDesc(namespace+"_sink_queue_length",
+		"Events waiting in the sink queue.", sinkLabels, nil)
+
+	writerWrittenDesc = prometheus.NewDesc(namespace+"_writer_events_written_total",
+		"Events written to PostgreSQL.", nil, nil)
+	writerDroppedDesc = prometheus.NewDesc(namespace+"_writer_events_dropped_total",
+		"Events dropped because the writer queue was full.", nil, nil)
+	writerBatchesDesc = prometheus.NewDesc(namespace+"_writer_batches_total",
+		"Batches committed to PostgreSQL.", nil, nil)
+	writerResolvedDesc = prometheus.NewDesc(namespace+"_writer_events_resolved_total",
+		"Active events closed by an end event.", nil, nil)
+	writerExpiredDesc = prometheus.NewDesc(namespace+"_writer_events_expired_total",
+		"Active events closed after the inactivity timeout.", nil, nil)
+	writerQueueDesc = prometheus.NewDesc(namespace+"_writer_queue_length",
+		"Events waiting in the writer queue.", nil, nil)
+
+	resolverSizeDesc = prometheus.NewDesc(namespace+"_resolver_asns",
+		"ASNs in the ASN-to-country mapping.", nil, nil)
+	resolverRefreshDesc = prometheus.NewDesc(namespace+"_resolver_last_refresh_timestamp_seconds",
+		"Unix time the ASN-to-country mapping was last loaded.", nil, nil)
+)
+
+// Exporter serves metrics for the configured components. Components are
+// optional; metrics of unset components are omitted.
+type Exporter struct {
+	registry     *prometheus.Registry
+	batchLatency prometheus.Histogram
+
+	sources   StatsFunc
+	workers   StatsFunc
+	detectors *detector.Pipeline
+	sinks     StatsFunc
+	writer    StatsFunc
+	resolver  database.CountryResolver
+}
+
+// NewExporter creates an exporter, including Go runtime and process metrics.
+func NewExporter() *Exporter {
+	e := &Exporter{
+		registry: prometheus.NewRegistry(),
+		batchLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "writer_batch_duration_seconds",
+			Help:      "Time to write and commit a batch of events.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14), // 1ms to ~8s
+		}),
+	}
+	e.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		e.batchLatency,
+		e,
+	)
+	return e
+}
+
+// SetSourceStats exports update source and RIS Live collector metrics from
+// source.Merger.Stats.
+func (e *Exporter) SetSourceStats(fn StatsFunc) {
+	e.sources = fn
+}
+
+// SetWorkerStats exports detector worker metrics from
+// detector.Dispatcher.Stats.
+func (e *Exporter) SetWorkerStats(fn StatsFunc) {
+	e.workers = fn
+}
+
+// SetDetectors exports detector metrics.
+func (e *Exporter) SetDetectors(p *detector.Pipeline) {
+	e.detectors = p
+}
+
+// SetSinkStats exports event sink metrics from sink.Fanout.Stats.
+func (e *Exporter) SetSinkStats(fn StatsFunc) {
+	e.sinks = fn
+}
+
+// SetWriterStats exports database writer metrics from
+// database.EventWriter.Stats.
+func (e *Exporter) SetWriterStats(fn StatsFunc) {
+	e.writer = fn
+}
+
+// SetResolver exports the ASN-to-country mapping size and, if the resolver
+// implements Refresher, its last refresh time.
+func (e *Exporter) SetResolver(r database.CountryResolver) {
+	e.resolver = r
+}
+
+// ObserveBatch records the duration of a database batch. It is meant for
+// database.EventWriter.SetBatchObserver.
+func (e *Exporter) ObserveBatch(d time.Duration) {
+	e.batchLatency.Observe(d.Seconds())
+}
+
+// Handler returns the /metrics request handler.
+func (e *Exporter) Handler() http.Handler {
+	return promhttp.HandlerFor(e.registry, promhttp.HandlerOpts{})
+}
+
+// Describe implements prometheus.Collector.
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		messagesDesc, updatesDesc, parseErrorsDesc, updatesDroppedDesc, reconnectsDesc, connectedDesc,
+		sourceUpdatesDesc, channelLenDesc, channelCapDesc,
+		workerQueueDesc, workerUpdatesDesc,
+		inspectedDesc, emittedDesc, eventsDroppedDesc,
+		sinkWrittenDesc, sinkFilteredDesc, sinkDroppedDesc, sinkErrorsDesc, sinkQueueDesc,
+		writerWrittenDesc, writerDroppedDesc, writerBatchesDesc, writerResolvedDesc, writerExpiredDesc, writerQueueDesc,
+		resolverSizeDesc, resolverRefreshDesc,
+	} {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector, reading component statistics.
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	if e.sources != nil {
+		e.collectSources(ch, e.sources())
+	}
+	if e.workers != nil {
+		workers, _ := e.workers()["workers"].([]map[string]interface{})
+		for _, w := range workers {
+			worker := fmt.Sprint(w["worker"])
+			gauge(ch, workerQueueDesc, w["queue_len"], worker)
+			counter(ch, workerUpdatesDesc, w["updates"], worker)
+		}
+	}
+	if e.detectors != nil {
+		e.collectDetectors(ch)
+	}
+	if e.sinks != nil {
+		sinks, _ := e.sinks()["sinks"].(map[string]interface{})
+		for name, s := range sinks {
+			s, _ := s.(map[string]interface{})
+			counter(ch, sinkWrittenDesc, s["events_written"], name)
+			counter(ch, sinkFilteredDesc, s["events_filtered"], name)
+			counter(ch, sinkDroppedDesc, s["events_dropped"], name)
+			counter(ch, sinkErrorsDesc, s["errors"], name)
+			gauge(ch, sinkQueueDesc, s["queue_len"], name)
+		}
+	}
+	if e.writer != nil {
+		stats := e.writer()
+		counter(ch, writerWrittenDesc, stats["events_written"])
+		counter(ch, writerDroppedDesc, stats["events_dropped"])
+		counter(ch, writerBatchesDesc, stats["batches_written"])
+		counter(ch, writerResolvedDesc, stats["events_resolved"])
+		counter(ch, writerExpiredDesc, stats["events_expired"])
+		gauge(ch, writerQueueDesc, stats["queue_len"])
+	}
+	if e.resolver != nil {
+		gauge(ch, resolverSizeDesc, e.resolver.Count())
+		if r, ok := e.resolver.(Refresher); ok {
+			if t := r.LastRefresh(); !t.IsZero() {
+				gauge(ch, resolverRefreshDesc, float64(t.UnixNano())/1e9)
+			}
+		}
+	}
+}
+
+func (e *Exporter) collectSources(ch chan<- prometheus.Metric, stats map[string]interface{}) {
+	sources, _ := stats["sources"].(map[string]interface{})
+	for name, s := range sources {
+		s, _ := s.(map[string]interface{})
+		counter(ch, sourceUpdatesDesc, s["updates_forwarded"], name)
+		e.collectCollectors(ch, s)
+	}
+	gauge(ch, channelLenDesc, stats["channel_len"])
+	gauge(ch, channelCapDesc, stats["channel_cap"])
+}
+
+// collectCollectors exports the per-collector metrics of a RIS Live source.
+func (e *Exporter) collectCollectors(ch chan<- prometheus.Metric, stats map[string]interface{}) {
+	clients, _ := stats["collectors"].([]map[string]interface{})
+	for _, c := range clients {
+		name, _ := c["collector"].(string)
+		counter(ch, messagesDesc, c["messages_received"], name)
+		counter(ch, updatesDesc, c["updates_parsed"], name)
+		counter(ch, parseErrorsDesc, c["parse_errors"], name)
+		counter(ch, updatesDroppedDesc, c["updates_dropped"], name)
+		counter(ch, reconnectsDesc, c["reconnects"], name)
+		gauge(ch, connectedDesc, c["connected"], name)
+	}
+}
+
+func (e *Exporter) collectDetectors(ch chan<- prometheus.Metric) {
+	for _, c := range e.detectors.Counters() {
+		counter(ch, inspectedDesc, c.Inspected, c.Detector)
+		counter(ch, eventsDroppedDesc, c.Dropped, c.Detector)
+		for kind, n := range c.Emitted {
+			counter(ch, emittedDesc, n, c.Detector, kind.Type, kind.Severity)
+		}
+	}
+}
+
+func counter(ch chan<- prometheus.Metric, desc *prometheus.Desc, v interface{}, labels ...string) {
+	if f, ok := toFloat(v); ok {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, f, labels...)
+	}
+}
+
+func gauge(ch chan<- prometheus.Metric, desc *prometheus.Desc, v interface{}, labels ...string) {
+	if f, ok := toFloat(v); ok {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, f, labels...)
+	}
+}
+
+// toFloat converts a statistics value to a metric value.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case uint64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case bool:
+		if n {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/database"
+	"github.com/hervehildenbrand/bgp-radar/pkg/detector"
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+type countingDetector struct{}
+
+func (countingDetector) Name() string                  { return "test" }
+func (countingDetector) Process(models.BGPUpdate)      {}
+func (countingDetector) Start()                        {}
+func (countingDetector) Stop()                         {}
+func (countingDetector) Stats() map[string]interface{} { return map[string]interface{}{} }
+func (countingDetector) DroppedEvents() uint64         { return 2 }
+func (countingDetector) EventCounts() map[detector.EventKind]uint64 {
+	return map[detector.EventKind]uint64{
+		{Type: models.EventTypeHijack, Severity: models.SeverityHigh}: 3,
+	}
+}
+
+func scrape(t *testing.T, e *Exporter) string {
+	t.Helper()
+	srv := httptest.NewServer(e.Handler())
+	defer srv.Close()
+
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatalf("scrape failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	return string(body)
+}
+
+func TestExporter(t *testing.T) {
+	registry := detector.NewRegistry()
+	registry.Register("test", func(detector.Deps) detector.Detector { return countingDetector{} })
+	pipeline, err := registry.Build([]string{"test"}, nil, detector.Deps{})
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	pipeline.Process(models.BGPUpdate{})
+
+	e := NewExporter()
+	e.SetDetectors(pipeline)
+	e.SetSourceStats(func() map[string]interface{} {
+		return map[string]interface{}{
+			"sources": map[string]interface{}{
+				"ris": map[string]interface{}{
+					"collectors": []map[string]interface{}{{
+						"collector":         "rrc00",
+						"connected":         true,
+						"messages_received": uint64(10),
+						"updates_parsed":    uint64(8),
+						"updates_dropped":   uint64(0),
+						"parse_errors":      uint64(1),
+						"reconnects":        uint64(4),
+					}},
+					"updates_forwarded": uint64(8),
+				},
+				"bmp": map[string]interface{}{"updates_forwarded": uint64(3)},
+			},
+			"channel_len": 5,
+			"channel_cap": 100,
+		}
+	})
+	e.SetWorkerStats(func() map[string]interface{} {
+		return map[string]interface{}{"workers": []map[string]interface{}{
+			{"worker": 0, "queue_len": 3, "queue_cap": 1000, "updates": uint64(12)},
+			{"worker": 1, "queue_len": 0, "queue_cap": 1000, "updates": uint64(9)},
+		}}
+	})
+	e.SetSinkStats(func() map[string]interface{} {
+		return map[string]interface{}{"sinks": map[string]interface{}{
+			"webhook": map[string]interface{}{"events_written": uint64(4), "events_dropped": uint64(1), "queue_len": 2},
+		}}
+	})
+	e.SetWriterStats(func() map[string]interface{} {
+		return map[string]interface{}{"events_written": uint64(7), "batches_written": uint64(2)}
+	})
+	e.SetResolver(database.NewNullResolver())
+	e.ObserveBatch(20 * time.Millisecond)
+
+	body := scrape(t, e)
+	for _, want := range []string{
+		`bgp_radar_collector_messages_received_total{collector="rrc00"} 10`,
+		`bgp_radar_collector_parse_errors_total{collector="rrc00"} 1`,
+		`bgp_radar_collector_reconnects_total{collector="rrc00"} 4`,
+		`bgp_radar_collector_connected{collector="rrc00"} 1`,
+		`bgp_radar_source_updates_total{source="ris"} 8`,
+		`bgp_radar_source_updates_total{source="bmp"} 3`,
+		`bgp_radar_update_channel_length 5`,
+		`bgp_radar_update_channel_capacity 100`,
+		`bgp_radar_worker_queue_length{worker="0"} 3`,
+		`bgp_radar_worker_updates_total{worker="1"} 9`,
+		`bgp_radar_detector_updates_inspected_total{detector="test"} 1`,
+		`bgp_radar_detector_events_total{detector="test",severity="high",type="hijack"} 3`,
+		`bgp_radar_detector_events_dropped_total{detector="test"} 2`,
+		`bgp_radar_sink_events_written_total{sink="webhook"} 4`,
+		`bgp_radar_sink_events_dropped_total{sink="webhook"} 1`,
+		`bgp_radar_sink_queue_length{sink="webhook"} 2`,
+		`bgp_radar_writer_events_written_total 7`,
+		`bgp_radar_writer_batches_total 2`,
+		`bgp_radar_writer_batch_duration_seconds_count 1`,
+		`bgp_radar_resolver_asns 0`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected metrics to contain %q", want)
+		}
+	}
+	if strings.Contains(body, "bgp_radar_resolver_last_refresh_timestamp_seconds") {
+		t.Errorf("Expected no last refresh time for the null resolver")
+	}
+}
+
+func TestExporter_Empty(t *testing.T) {
+	body := scrape(t, NewExporter())
+	if strings.Contains(body, "bgp_radar_collector_") {
+		t.Errorf("Expected no collector metrics without a collector source")
+	}
+}
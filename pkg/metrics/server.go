@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+const shutdownTimeout = 5 * time.Second
+
+// Server serves the metrics of an Exporter at /metrics on a listen address
+// of its own, e.g. to keep them off the public API.
+type Server struct {
+	server *http.Server
+}
+
+// NewServer creates a metrics server listening on addr.
+func NewServer(addr string, e *Exporter) *Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e.Handler())
+	return &Server{server: &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}}
+}
+
+// Start begins serving in the background.
+func (s *Server) Start() {
+	go func() {
+		if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Metrics server error: %v", err)
+		}
+	}()
+	log.Printf("Metrics server listening on %s", s.server.Addr)
+}
+
+// Stop gracefully shuts down the server.
+func (s *Server) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := s.server.Shutdown(ctx); err != nil {
+		log.Printf("Metrics server shutdown error: %v", err)
+	}
+}
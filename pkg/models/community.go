@@ -0,0 +1,195 @@
+package models
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// LargeCommunity is a BGP large community (RFC 8092), written
+// "ASN:value1:value2".
+type LargeCommunity struct {
+	GlobalAdmin uint32 // ASN of the community owner
+	LocalData1  uint32
+	LocalData2  uint32
+}
+
+// String returns the community as "ASN:value1:value2".
+func (c LargeCommunity) String() string {
+	return strconv.FormatUint(uint64(c.GlobalAdmin), 10) + ":" +
+		strconv.FormatUint(uint64(c.LocalData1), 10) + ":" +
+		strconv.FormatUint(uint64(c.LocalData2), 10)
+}
+
+// MarshalText implements encoding.TextMarshaler, so that events and stored
+// updates carry the string form.
+func (c LargeCommunity) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// ParseLargeCommunity parses a large community written "ASN:value1:value2".
+func ParseLargeCommunity(s string) (LargeCommunity, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return LargeCommunity{}, fmt.Errorf("invalid large community %q (want asn:value:value)", s)
+	}
+	var values [3]uint32
+	for i, part := range parts {
+		v, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return LargeCommunity{}, fmt.Errorf("invalid large community %q (want asn:value:value)", s)
+		}
+		values[i] = uint32(v)
+	}
+	return LargeCommunity{GlobalAdmin: values[0], LocalData1: values[1], LocalData2: values[2]}, nil
+}
+
+// Extended community types and subtypes (RFC 4360, RFC 5668)
+const (
+	ExtTypeTwoOctetAS  = 0x00
+	ExtTypeIPv4Address = 0x01
+	ExtTypeFourOctetAS = 0x02
+
+	ExtSubtypeRouteTarget = 0x02
+	ExtSubtypeRouteOrigin = 0x03
+)
+
+// extendedSubtypeNames are the names of the subtypes written in the
+// "name:admin:value" form.
+var extendedSubtypeNames = map[uint8]string{
+	ExtSubtypeRouteTarget: "rt",
+	ExtSubtypeRouteOrigin: "soo",
+}
+
+// ExtendedCommunity is a BGP extended community (RFC 4360): an 8-byte value
+// of a type, a subtype and 6 bytes of data. Route targets and route origins
+// of the transitive AS and IPv4 address types are written
+// "rt:admin:value" and "soo:admin:value"; others "0xTTSS:data" in hex.
+type ExtendedCommunity struct {
+	Type    uint8
+	Subtype uint8
+	Value   [6]byte
+}
+
+// String returns the community as "rt:admin:value", "soo:admin:value" or
+// "0xTTSS:data".
+func (c ExtendedCommunity) String() string {
+	if name, ok := extendedSubtypeNames[c.Subtype]; ok {
+		v := c.Value[:]
+		switch c.Type {
+		case ExtTypeTwoOctetAS:
+			return fmt.Sprintf("%s:%d:%d", name, binary.BigEndian.Uint16(v[0:2]), binary.BigEndian.Uint32(v[2:6]))
+		case ExtTypeIPv4Address:
+			return fmt.Sprintf("%s:%s:%d", name, netip.AddrFrom4([4]byte(v[0:4])), binary.BigEndian.Uint16(v[4:6]))
+		case ExtTypeFourOctetAS:
+			return fmt.Sprintf("%s:%d:%d", name, binary.BigEndian.Uint32(v[0:4]), binary.BigEndian.Uint16(v[4:6]))
+		}
+	}
+	return fmt.Sprintf("0x%02x%02x:%x", c.Type, c.Subtype, c.Value[:])
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (c ExtendedCommunity) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// ParseExtendedCommunity parses an extended community in the form returned
+// by String. The two-octet AS type is used for route targets and route
+// origins whose ASN fits in 16 bits, as routers do.
+func ParseExtendedCommunity(s string) (ExtendedCommunity, error) {
+	invalid := fmt.Errorf("invalid extended community %q (want rt:admin:value, soo:admin:value or 0xTTSS:data)", s)
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		if len(parts) == 2 && strings.HasPrefix(parts[0], "0x") {
+			return parseRawExtendedCommunity(parts[0][2:], parts[1], invalid)
+		}
+		return ExtendedCommunity{}, invalid
+	}
+
+	var c ExtendedCommunity
+	switch parts[0] {
+	case "rt":
+		c.Subtype = ExtSubtypeRouteTarget
+	case "soo":
+		c.Subtype = ExtSubtypeRouteOrigin
+	default:
+		return ExtendedCommunity{}, invalid
+	}
+	v := c.Value[:]
+	if addr, err := netip.ParseAddr(parts[1]); err == nil && addr.Is4() {
+		value, err := strconv.ParseUint(parts[2], 10, 16)
+		if err != nil {
+			return ExtendedCommunity{}, invalid
+		}
+		c.Type = ExtTypeIPv4Address
+		ip := addr.As4()
+		copy(v[0:4], ip[:])
+		binary.BigEndian.PutUint16(v[4:6], uint16(value))
+		return c, nil
+	}
+	asn, err1 := strconv.ParseUint(parts[1], 10, 32)
+	value, err2 := strconv.ParseUint(parts[2], 10, 32)
+	if err1 != nil || err2 != nil {
+		return ExtendedCommunity{}, invalid
+	}
+	if asn <= 0xffff {
+		c.Type = ExtTypeTwoOctetAS
+		binary.BigEndian.PutUint16(v[0:2], uint16(asn))
+		binary.BigEndian.PutUint32(v[2:6], uint32(value))
+		return c, nil
+	}
+	if value > 0xffff {
+		return ExtendedCommunity{}, invalid
+	}
+	c.Type = ExtTypeFourOctetAS
+	binary.BigEndian.PutUint32(v[0:4], uint32(asn))
+	binary.BigEndian.PutUint16(v[4:6], uint16(value))
+	return c, nil
+}
+
+// parseRawExtendedCommunity parses the "TTSS" and "data" hex parts of an
+// extended community written "0xTTSS:data".
+func parseRawExtendedCommunity(typ, data string, invalid error) (ExtendedCommunity, error) {
+	t, err1 := strconv.ParseUint(typ, 16, 16)
+	d, err2 := strconv.ParseUint(data, 16, 48)
+	if len(typ) != 4 || len(data) != 12 || err1 != nil || err2 != nil {
+		return ExtendedCommunity{}, invalid
+	}
+	c := ExtendedCommunity{Type: uint8(t >> 8), Subtype: uint8(t)}
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], d)
+	copy(c.Value[:], b[2:])
+	return c, nil
+}
+
+// ValidStandardCommunity reports whether s is a standard community
+// (RFC 1997), "ASN:value" with 16-bit parts.
+func ValidStandardCommunity(s string) bool {
+	high, low, ok := strings.Cut(s, ":")
+	if !ok {
+		return false
+	}
+	_, err1 := strconv.ParseUint(high, 10, 16)
+	_, err2 := strconv.ParseUint(low, 10, 16)
+	return err1 == nil && err2 == nil
+}
+
+// CanonicalCommunity returns the standard, large or extended community s in
+// the form produced by the parsers, or false if s is none of them.
+func CanonicalCommunity(s string) (string, bool) {
+	if ValidStandardCommunity(s) {
+		high, low, _ := strings.Cut(s, ":")
+		h, _ := strconv.ParseUint(high, 10, 16)
+		l, _ := strconv.ParseUint(low, 10, 16)
+		return strconv.FormatUint(h, 10) + ":" + strconv.FormatUint(l, 10), true
+	}
+	if c, err := ParseLargeCommunity(s); err == nil {
+		return c.String(), true
+	}
+	if c, err := ParseExtendedCommunity(s); err == nil {
+		return c.String(), true
+	}
+	return "", false
+}
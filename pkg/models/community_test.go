@@ -0,0 +1,31 @@
+package models
+
+import "testing"
+
+func TestCanonicalCommunity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		valid    bool
+	}{
+		{"65535:666", "65535:666", true},
+		{"03356:0666", "3356:666", true},
+		{"6939:666:0", "6939:666:0", true},
+		{"4200000000:666:0", "4200000000:666:0", true},
+		{"rt:64500:100", "rt:64500:100", true},
+		{"soo:192.0.2.1:7", "soo:192.0.2.1:7", true},
+		{"rt:4200000000:666", "rt:4200000000:666", true},
+		{"0x0002:fbf400000064", "rt:64500:100", true},
+		{"0x8006:000000000000", "0x8006:000000000000", true},
+		{"65536:666", "", false},
+		{"rt:4200000000:65536", "", false},
+		{"no-export", "", false},
+		{"0x0002:fbf4", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := CanonicalCommunity(tt.input)
+		if ok != tt.valid || got != tt.expected {
+			t.Errorf("CanonicalCommunity(%q): expected %q %v, got %q %v", tt.input, tt.expected, tt.valid, got, ok)
+		}
+	}
+}
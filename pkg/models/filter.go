@@ -0,0 +1,82 @@
+package models
+
+import (
+	"net/netip"
+	"time"
+)
+
+// EventFilter selects events by their attributes. Zero fields match any event.
+type EventFilter struct {
+	Type        string
+	Severity    string // Exact severity
+	MinSeverity string // Severity or above
+	Country     string
+	ASN         uint32 // Affected ASN
+	Prefix      string // Affected prefix or one of its more-specifics
+	ActiveOnly  bool
+	Since       time.Time // Detected at or after
+	Limit       int       // Maximum number of events returned (0 for no limit)
+}
+
+// SeverityRank orders severities from low (0) to critical (3). Unknown
+// severities rank as -1.
+func SeverityRank(severity string) int {
+	switch severity {
+	case SeverityLow:
+		return 0
+	case SeverityMedium:
+		return 1
+	case SeverityHigh:
+		return 2
+	case SeverityCritical:
+		return 3
+	default:
+		return -1
+	}
+}
+
+// Matches reports whether event is selected by the filter. Limit is not
+// applied.
+func (f EventFilter) Matches(event BGPEvent) bool {
+	if f.Type != "" && event.EventType != f.Type {
+		return false
+	}
+	if f.Severity != "" && event.Severity != f.Severity {
+		return false
+	}
+	if f.MinSeverity != "" && SeverityRank(event.Severity) < SeverityRank(f.MinSeverity) {
+		return false
+	}
+	if f.Country != "" && event.CountryCode != f.Country {
+		return false
+	}
+	if f.ASN != 0 && event.AffectedASN != f.ASN {
+		return false
+	}
+	if f.ActiveOnly && !event.IsActive {
+		return false
+	}
+	if !f.Since.IsZero() && event.DetectedAt.Before(f.Since) {
+		return false
+	}
+	if f.Prefix != "" && !prefixCovers(f.Prefix, event.AffectedPrefix) {
+		return false
+	}
+	return true
+}
+
+// prefixCovers reports whether prefix is outer or one of its more-specifics.
+func prefixCovers(outer, prefix string) bool {
+	if outer == prefix {
+		return true
+	}
+	o, err := netip.ParsePrefix(outer)
+	if err != nil {
+		return false
+	}
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return false
+	}
+	return p.Bits() >= o.Bits() && o.Masked().Contains(p.Addr())
+}
@@ -3,16 +3,81 @@ package models
 
 import "time"
 
-// BGPUpdate represents a parsed BGP update from RIS Live.
+// BGPUpdate represents a parsed BGP update for one prefix, from RIS Live,
+// BMP or an MRT file.
 type BGPUpdate struct {
 	Timestamp    time.Time
 	PeerASN      uint32
 	Prefix       string
-	ASPath       []uint32
-	OriginASN    uint32
-	Communities  []string // Format: "ASN:value"
+	ASPath       []uint32 // ASNs of the AS_SEQUENCE segments
+	OriginASN    uint32   // 0 when the origin is ambiguous (an AS_SET)
+	Communities  []string // Standard communities (RFC 1997), format: "ASN:value"
+	NextHop      string   // Next hop address for announcements
 	Announcement bool     // true=announcement, false=withdrawal
 	Collector    string   // e.g., "rrc00"
+	Source       string   // Feed the update came from, e.g. "ris" or "bmp"
+
+	LargeCommunities    []LargeCommunity    // RFC 8092, e.g. 6939:666:0
+	ExtendedCommunities []ExtendedCommunity // RFC 4360, e.g. rt:64500:100
+
+	// Path attributes of announcements. ASPathSegments is the AS path as
+	// received, including AS_SETs; MED, LocalPref and Aggregator are nil
+	// when absent.
+	ASPathSegments []ASPathSegment
+	Origin         string // OriginIGP, OriginEGP, OriginIncomplete or ""
+	MED            *uint32
+	LocalPref      *uint32
+	Aggregator     *Aggregator
+}
+
+// ASPathSegment is an AS_SEQUENCE, or an AS_SET of the ASNs of aggregated
+// routes in no particular order (RFC 4271).
+type ASPathSegment struct {
+	Set  bool
+	ASNs []uint32
+}
+
+// Aggregator is the AS and router that aggregated a route.
+type Aggregator struct {
+	ASN     uint32 `json:"asn"`
+	Address string `json:"address"`
+}
+
+// Origin attribute values (RFC 4271)
+const (
+	OriginIGP        = "igp"
+	OriginEGP        = "egp"
+	OriginIncomplete = "incomplete"
+)
+
+// SequencePath returns the ASNs of the AS_SEQUENCE segments, and the origin
+// ASN: the last of them, or 0 if the path ends with an AS_SET.
+func SequencePath(segments []ASPathSegment) ([]uint32, uint32) {
+	var path []uint32
+	for _, s := range segments {
+		if !s.Set {
+			path = append(path, s.ASNs...)
+		}
+	}
+	if len(segments) == 0 || segments[len(segments)-1].Set || len(path) == 0 {
+		return path, 0
+	}
+	return path, path[len(path)-1]
+}
+
+// OriginSet returns the ASNs of the AS_SET ending the AS path, or nil if
+// the origin is a single ASN.
+func (u BGPUpdate) OriginSet() []uint32 {
+	if n := len(u.ASPathSegments); n > 0 && u.ASPathSegments[n-1].Set {
+		return u.ASPathSegments[n-1].ASNs
+	}
+	return nil
+}
+
+// AmbiguousOrigin reports whether the AS path ends with an AS_SET, so that
+// the route has no single origin ASN.
+func (u BGPUpdate) AmbiguousOrigin() bool {
+	return u.OriginSet() != nil
 }
 
 // BGPEvent represents a detected BGP anomaly.
@@ -21,7 +86,7 @@ type BGPEvent struct {
 	CountryCode     string
 	EventType       string // hijack, leak, blackhole, withdrawal_storm
 	Severity        string // low, medium, high, critical
-	EventCategory   string // attack, defense, misconfiguration
+	EventCategory   string // attack, defense, misconfiguration, outage
 	RPKIStatus      string // valid, invalid, not_found, unknown
 	IsCrossBorder   bool
 	AttackerCountry string
@@ -30,7 +95,8 @@ type BGPEvent struct {
 	AffectedPrefix  string
 	Details         map[string]interface{}
 	DetectedAt      time.Time
-	IsActive        bool
+	EndedAt         time.Time // When the anomaly ended; zero while active
+	IsActive        bool      // false marks the end of a previously reported event
 }
 
 // Severity levels
@@ -48,6 +114,21 @@ const (
 	EventTypeBlackhole       = "blackhole"
 	EventTypeWithdrawalStorm = "withdrawal_storm"
 	EventTypeDDoS            = "ddos"
+
+	// Watchlist checks on the operator's own prefixes
+	EventTypeUnauthorizedOrigin = "unauthorized_origin"
+	EventTypeMoreSpecific       = "unexpected_more_specific"
+	EventTypeUnexpectedUpstream = "unexpected_upstream"
+	EventTypeVisibilityLoss     = "visibility_loss"
+	EventTypeRPKIInvalid        = "rpki_invalid"
+)
+
+// RPKI route origin validation states (RFC 6811)
+const (
+	RPKIValid    = "valid"
+	RPKIInvalid  = "invalid"
+	RPKINotFound = "not_found"
+	RPKIUnknown  = "unknown"
 )
 
 // Event categories
@@ -55,4 +136,5 @@ const (
 	CategoryAttack           = "attack"
 	CategoryDefense          = "defense"
 	CategoryMisconfiguration = "misconfiguration"
+	CategoryOutage           = "outage"
 )
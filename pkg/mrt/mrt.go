@@ -0,0 +1,347 @@
+// Package mrt reads BGP updates from MRT routing information export files
+// (RFC 6396), such as the RIPE RIS and RouteViews update and RIB archives.
+//
+// Supported records are BGP4MP and BGP4MP_ET messages (including the AS4
+// and ADD-PATH variants) and TABLE_DUMP_V2 unicast RIB entries. Other
+// records are skipped.
+package mrt
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/bgp"
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+// MRT record types
+const (
+	TypeTableDumpV2 = 13
+	TypeBGP4MP      = 16
+	TypeBGP4MPET    = 17
+)
+
+// TABLE_DUMP_V2 subtypes
+const (
+	subtypePeerIndexTable        = 1
+	subtypeRIBIPv4Unicast        = 2
+	subtypeRIBIPv6Unicast        = 4
+	subtypeRIBIPv4UnicastAddPath = 8
+	subtypeRIBIPv6UnicastAddPath = 10
+)
+
+// BGP4MP subtypes
+const (
+	subtypeMessage           = 1
+	subtypeMessageAS4        = 4
+	subtypeMessageAddPath    = 8
+	subtypeMessageAS4AddPath = 9
+)
+
+const (
+	headerLen = 12
+	// maxRecordLen bounds record allocation on corrupt input
+	maxRecordLen = 16 << 20
+)
+
+// Reader reads BGP updates from an MRT stream.
+type Reader struct {
+	r         *bufio.Reader
+	closers   []io.Closer
+	collector string
+
+	peers   []peer // TABLE_DUMP_V2 peer index table
+	pending []models.BGPUpdate
+
+	// Stats
+	records     uint64
+	skipped     uint64
+	parseErrors uint64
+	updates     uint64
+}
+
+// peer is an entry of the TABLE_DUMP_V2 peer index table.
+type peer struct {
+	asn uint32
+}
+
+// NewReader creates a reader over an uncompressed MRT stream. Updates are
+// labeled with the given collector name.
+func NewReader(r io.Reader, collector string) *Reader {
+	return &Reader{
+		r:         bufio.NewReaderSize(r, 1<<16),
+		collector: collector,
+	}
+}
+
+// Open opens an MRT file, decompressing .gz and .bz2 files.
+func Open(path, collector string) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.Reader = f
+	closers := []io.Closer{f}
+	switch {
+	case strings.HasSuffix(path, ".bz2"):
+		r = bzip2.NewReader(f)
+	case strings.HasSuffix(path, ".gz"):
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("open gzip: %w", err)
+		}
+		r = gz
+		closers = append([]io.Closer{gz}, closers...)
+	}
+
+	reader := NewReader(r, collector)
+	reader.closers = closers
+	return reader, nil
+}
+
+// Close closes the underlying file, if the reader was opened with Open.
+func (r *Reader) Close() error {
+	var err error
+	for _, c := range r.closers {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	r.closers = nil
+	return err
+}
+
+// Next returns the next BGP update, or io.EOF at the end of the stream.
+// A record may carry many prefixes, so one update is returned per announced
+// or withdrawn prefix, announcements first. Malformed records are counted
+// and skipped; a truncated stream returns an error.
+func (r *Reader) Next() (models.BGPUpdate, error) {
+	for len(r.pending) == 0 {
+		if err := r.readRecord(); err != nil {
+			return models.BGPUpdate{}, err
+		}
+	}
+
+	update := r.pending[0]
+	r.pending = r.pending[1:]
+	r.updates++
+	return update, nil
+}
+
+// Stats returns reader statistics.
+func (r *Reader) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"records":      r.records,
+		"skipped":      r.skipped,
+		"parse_errors": r.parseErrors,
+		"updates":      r.updates,
+	}
+}
+
+// readRecord reads one MRT record, queueing the updates it carries.
+func (r *Reader) readRecord() error {
+	var header [headerLen]byte
+	if _, err := io.ReadFull(r.r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("read record header: %w", err)
+		}
+		return err // io.EOF on a record boundary
+	}
+
+	timestamp := time.Unix(int64(binary.BigEndian.Uint32(header[0:4])), 0)
+	recordType := binary.BigEndian.Uint16(header[4:6])
+	subtype := binary.BigEndian.Uint16(header[6:8])
+	length := binary.BigEndian.Uint32(header[8:12])
+	if length > maxRecordLen {
+		return fmt.Errorf("record length %d exceeds limit", length)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r.r, body); err != nil {
+		return fmt.Errorf("read record body: %w", io.ErrUnexpectedEOF)
+	}
+	r.records++
+
+	var err error
+	switch recordType {
+	case TypeBGP4MP, TypeBGP4MPET:
+		if recordType == TypeBGP4MPET {
+			if len(body) < 4 {
+				err = bgp.ErrTruncated
+				break
+			}
+			micros := binary.BigEndian.Uint32(body[0:4])
+			timestamp = timestamp.Add(time.Duration(micros) * time.Microsecond)
+			body = body[4:]
+		}
+		err = r.parseBGP4MP(subtype, timestamp, body)
+	case TypeTableDumpV2:
+		err = r.parseTableDumpV2(subtype, timestamp, body)
+	default:
+		r.skipped++
+	}
+
+	if err != nil {
+		r.parseErrors++
+		r.pending = r.pending[:0]
+	}
+	return nil
+}
+
+// parseBGP4MP parses a BGP4MP message record:
+//
+//	peer AS, local AS (2 or 4 bytes), interface index, AFI,
+//	peer IP, local IP (4 or 16 bytes), BGP message
+func (r *Reader) parseBGP4MP(subtype uint16, timestamp time.Time, body []byte) error {
+	var as4, addPath bool
+	switch subtype {
+	case subtypeMessage:
+	case subtypeMessageAS4:
+		as4 = true
+	case subtypeMessageAddPath:
+		addPath = true
+	case subtypeMessageAS4AddPath:
+		as4, addPath = true, true
+	default:
+		// State changes and locally generated messages
+		r.skipped++
+		return nil
+	}
+
+	c := bgp.NewCursor(body)
+	var peerASN uint32
+	if as4 {
+		peerASN = c.U32()
+		c.Skip(4) // Local AS
+	} else {
+		peerASN = uint32(c.U16())
+		c.Skip(2)
+	}
+	c.Skip(2) // Interface index
+	switch afi := c.U16(); afi {
+	case bgp.AFIIPv4:
+		c.Skip(2 * 4)
+	case bgp.AFIIPv6:
+		c.Skip(2 * 16)
+	default:
+		return fmt.Errorf("unknown AFI %d", afi)
+	}
+	if c.Err() != nil {
+		return c.Err()
+	}
+
+	decoder := bgp.Decoder{
+		Timestamp: timestamp,
+		PeerASN:   peerASN,
+		Collector: r.collector,
+		AS4:       as4,
+		AddPath:   addPath,
+	}
+	updates, err := decoder.ParseMessage(c.Rest())
+	if err != nil {
+		return err
+	}
+	r.pending = append(r.pending, updates...)
+	return nil
+}
+
+// parseTableDumpV2 parses TABLE_DUMP_V2 peer index tables and unicast RIB
+// entries. RIB entries are returned as announcements timestamped with the
+// dump time.
+func (r *Reader) parseTableDumpV2(subtype uint16, timestamp time.Time, body []byte) error {
+	var (
+		family  int
+		addPath bool
+	)
+	switch subtype {
+	case subtypePeerIndexTable:
+		return r.parsePeerIndexTable(body)
+	case subtypeRIBIPv4Unicast:
+		family = bgp.AFIIPv4
+	case subtypeRIBIPv6Unicast:
+		family = bgp.AFIIPv6
+	case subtypeRIBIPv4UnicastAddPath:
+		family, addPath = bgp.AFIIPv4, true
+	case subtypeRIBIPv6UnicastAddPath:
+		family, addPath = bgp.AFIIPv6, true
+	default:
+		// Multicast and generic RIBs
+		r.skipped++
+		return nil
+	}
+
+	c := bgp.NewCursor(body)
+	c.Skip(4) // Sequence number
+	prefix := c.Prefix(family)
+	entries := int(c.U16())
+	if c.Err() != nil {
+		return c.Err()
+	}
+
+	for i := 0; i < entries; i++ {
+		index := int(c.U16())
+		c.Skip(4) // Originated time
+		if addPath {
+			c.Skip(4) // Path identifier
+		}
+		attrs := c.Bytes(int(c.U16()))
+		if c.Err() != nil {
+			return c.Err()
+		}
+		if index >= len(r.peers) {
+			return fmt.Errorf("peer index %d not in peer table", index)
+		}
+
+		// RIB attributes always use 4-byte ASNs
+		decoder := bgp.Decoder{
+			Timestamp: timestamp,
+			PeerASN:   r.peers[index].asn,
+			Collector: r.collector,
+			AS4:       true,
+		}
+		update, err := decoder.ParseRIBEntry(prefix, attrs)
+		if err != nil {
+			return err
+		}
+		r.pending = append(r.pending, update)
+	}
+	return nil
+}
+
+// parsePeerIndexTable parses the TABLE_DUMP_V2 peer index table:
+//
+//	collector BGP ID, view name, peer count, then per peer:
+//	type, BGP ID, IP (4 or 16 bytes), AS (2 or 4 bytes)
+func (r *Reader) parsePeerIndexTable(body []byte) error {
+	c := bgp.NewCursor(body)
+	c.Skip(4)            // Collector BGP ID
+	c.Skip(int(c.U16())) // View name
+	count := int(c.U16())
+	peers := make([]peer, 0, count)
+	for i := 0; i < count; i++ {
+		peerType := c.U8()
+		c.Skip(4) // BGP ID
+		c.Addr(peerType&0x01 != 0)
+		var asn uint32
+		if peerType&0x02 != 0 {
+			asn = c.U32()
+		} else {
+			asn = uint32(c.U16())
+		}
+		peers = append(peers, peer{asn: asn})
+	}
+	if c.Err() != nil {
+		return c.Err()
+	}
+	r.peers = peers
+	return nil
+}
@@ -0,0 +1,264 @@
+package mrt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/bgp"
+	"github.com/hervehildenbrand/bgp-radar/pkg/bgp/bgptest"
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+func record(ts uint32, recordType, subtype uint16, body []byte) []byte {
+	return bgptest.Concat(bgptest.U32(ts), bgptest.U16(recordType), bgptest.U16(subtype), bgptest.U32(uint32(len(body))), body)
+}
+
+// bgp4mp builds a BGP4MP message body for an IPv4 session.
+func bgp4mp(as4 bool, peerASN uint32, msg []byte) []byte {
+	var asns []byte
+	if as4 {
+		asns = bgptest.Concat(bgptest.U32(peerASN), bgptest.U32(12654))
+	} else {
+		asns = bgptest.Concat(bgptest.U16(uint16(peerASN)), bgptest.U16(12654))
+	}
+	return bgptest.Concat(asns, bgptest.U16(0), bgptest.U16(bgp.AFIIPv4), []byte{192, 0, 2, 1}, []byte{192, 0, 2, 2}, msg)
+}
+
+func readAll(t *testing.T, r *Reader) []models.BGPUpdate {
+	t.Helper()
+	var updates []models.BGPUpdate
+	for {
+		update, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return updates
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		updates = append(updates, update)
+	}
+}
+
+func TestReader_BGP4MP(t *testing.T) {
+	attrs := bgptest.Concat(
+		bgptest.Attr(bgp.AttrASPath, bgptest.ASPath(true, 3356, 4200000000)),
+		bgptest.Attr(bgp.AttrNextHop, []byte{192, 0, 2, 1}),
+	)
+	msg := bgptest.Update(bgptest.NLRI("198.51.100.0/24"), attrs, bgptest.NLRI("203.0.113.0/24", "203.0.113.128/25"))
+	data := record(1700000000, TypeBGP4MP, subtypeMessageAS4, bgp4mp(true, 3356, msg))
+
+	updates := readAll(t, NewReader(bytes.NewReader(data), "rrc00"))
+	if len(updates) != 3 {
+		t.Fatalf("Expected 3 updates, got %d", len(updates))
+	}
+
+	ann := updates[0]
+	if !ann.Announcement || ann.Prefix != "203.0.113.0/24" || updates[1].Prefix != "203.0.113.128/25" {
+		t.Errorf("Unexpected announcements: %+v", updates[:2])
+	}
+	if ann.PeerASN != 3356 || ann.Collector != "rrc00" || ann.OriginASN != 4200000000 {
+		t.Errorf("Unexpected peer %d collector %s origin %d", ann.PeerASN, ann.Collector, ann.OriginASN)
+	}
+	if !ann.Timestamp.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Unexpected timestamp %v", ann.Timestamp)
+	}
+	if w := updates[2]; w.Announcement || w.Prefix != "198.51.100.0/24" {
+		t.Errorf("Unexpected withdrawal: %+v", w)
+	}
+}
+
+func TestReader_BGP4MPAS2(t *testing.T) {
+	attrs := bgptest.Attr(bgp.AttrASPath, bgptest.ASPath(false, 3356, 174))
+	data := record(1700000000, TypeBGP4MP, subtypeMessage, bgp4mp(false, 3356, bgptest.Update(nil, attrs, bgptest.NLRI("203.0.113.0/24"))))
+
+	updates := readAll(t, NewReader(bytes.NewReader(data), "rrc00"))
+	if len(updates) != 1 {
+		t.Fatalf("Expected 1 update, got %d", len(updates))
+	}
+	if u := updates[0]; u.PeerASN != 3356 || !reflect.DeepEqual(u.ASPath, []uint32{3356, 174}) {
+		t.Errorf("Expected peer 3356 with a two-byte AS path, got %d %v", u.PeerASN, u.ASPath)
+	}
+}
+
+func TestReader_BGP4MPETIPv6(t *testing.T) {
+	nextHop := netip.MustParseAddr("2001:db8::1").AsSlice()
+	mpReach := bgptest.Concat(bgptest.U16(bgp.AFIIPv6), []byte{bgp.SAFIUnicast, 16}, nextHop, []byte{0}, bgptest.NLRI("2001:db8:100::/48"))
+	mpUnreach := bgptest.Concat(bgptest.U16(bgp.AFIIPv6), []byte{bgp.SAFIUnicast}, bgptest.NLRI("2001:db8:200::/48"))
+	attrs := bgptest.Concat(
+		bgptest.Attr(bgp.AttrASPath, bgptest.ASPath(true, 6939, 13335)),
+		bgptest.Attr(bgp.AttrMPReachNLRI, mpReach),
+		bgptest.Attr(bgp.AttrMPUnreachNLRI, mpUnreach),
+	)
+	body := bgptest.Concat(bgptest.U32(250000), bgp4mp(true, 6939, bgptest.Update(nil, attrs, nil)))
+	data := record(1700000000, TypeBGP4MPET, subtypeMessageAS4, body)
+
+	updates := readAll(t, NewReader(bytes.NewReader(data), "rrc00"))
+	if len(updates) != 2 {
+		t.Fatalf("Expected 2 updates, got %d", len(updates))
+	}
+	if u := updates[0]; !u.Announcement || u.Prefix != "2001:db8:100::/48" || u.NextHop != "2001:db8::1" || u.OriginASN != 13335 {
+		t.Errorf("Unexpected announcement: %+v", u)
+	}
+	if u := updates[1]; u.Announcement || u.Prefix != "2001:db8:200::/48" {
+		t.Errorf("Unexpected withdrawal: %+v", u)
+	}
+	if want := time.Unix(1700000000, 250000000); !updates[0].Timestamp.Equal(want) {
+		t.Errorf("Expected microsecond timestamp %v, got %v", want, updates[0].Timestamp)
+	}
+}
+
+func TestReader_TableDumpV2(t *testing.T) {
+	peers := bgptest.Concat(
+		bgptest.U32(0), bgptest.U16(0), bgptest.U16(2),
+		[]byte{0x02}, bgptest.U32(1), []byte{192, 0, 2, 1}, bgptest.U32(3356), // IPv4, AS4
+		[]byte{0x01}, bgptest.U32(2), netip.MustParseAddr("2001:db8::1").AsSlice(), bgptest.U16(6939), // IPv6, AS2
+	)
+	entry := func(index uint16, asns ...uint32) []byte {
+		attrs := bgptest.Attr(bgp.AttrASPath, bgptest.ASPath(true, asns...))
+		return bgptest.Concat(bgptest.U16(index), bgptest.U32(1690000000), bgptest.U16(uint16(len(attrs))), attrs)
+	}
+	rib := bgptest.Concat(bgptest.U32(0), bgptest.NLRI("1.1.1.0/24"), bgptest.U16(2), entry(0, 3356, 13335), entry(1, 6939, 13335))
+
+	var data []byte
+	data = append(data, record(1700000000, TypeTableDumpV2, subtypePeerIndexTable, peers)...)
+	data = append(data, record(1700000000, TypeTableDumpV2, subtypeRIBIPv4Unicast, rib)...)
+
+	updates := readAll(t, NewReader(bytes.NewReader(data), "rrc00"))
+	if len(updates) != 2 {
+		t.Fatalf("Expected 2 RIB entries, got %d", len(updates))
+	}
+	for i, peerASN := range []uint32{3356, 6939} {
+		u := updates[i]
+		if !u.Announcement || u.Prefix != "1.1.1.0/24" || u.PeerASN != peerASN || u.OriginASN != 13335 {
+			t.Errorf("Unexpected RIB entry %d: %+v", i, u)
+		}
+		if !u.Timestamp.Equal(time.Unix(1700000000, 0)) {
+			t.Errorf("Expected dump time, got %v", u.Timestamp)
+		}
+	}
+}
+
+func TestReader_SkipsMalformed(t *testing.T) {
+	bad := bgp4mp(true, 3356, []byte{0xff, 0xff})
+	good := bgp4mp(true, 3356, bgptest.Update(bgptest.NLRI("203.0.113.0/24"), nil, nil))
+
+	var data []byte
+	data = append(data, record(1700000000, TypeBGP4MP, subtypeMessageAS4, bad)...)
+	data = append(data, record(1700000000, 12, 1, []byte{0, 0})...) // TABLE_DUMP
+	data = append(data, record(1700000000, TypeBGP4MP, subtypeMessageAS4, good)...)
+
+	r := NewReader(bytes.NewReader(data), "rrc00")
+	updates := readAll(t, r)
+	if len(updates) != 1 || updates[0].Prefix != "203.0.113.0/24" {
+		t.Fatalf("Expected the valid record only, got %+v", updates)
+	}
+	if r.parseErrors != 1 || r.skipped != 1 {
+		t.Errorf("Expected 1 parse error and 1 skipped record, got %d and %d", r.parseErrors, r.skipped)
+	}
+
+	truncated := record(1700000000, TypeBGP4MP, subtypeMessageAS4, good)
+	r = NewReader(bytes.NewReader(truncated[:len(truncated)-3]), "rrc00")
+	if _, err := r.Next(); err == nil || errors.Is(err, io.EOF) {
+		t.Errorf("Expected an error for a truncated record, got %v", err)
+	}
+}
+
+func writeGzip(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "updates.gz")
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(data)
+	gz.Close()
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestOpen_Gzip(t *testing.T) {
+	msg := bgptest.Update(nil, bgptest.Attr(bgp.AttrASPath, bgptest.ASPath(true, 3356, 13335)), bgptest.NLRI("1.1.1.0/24"))
+	path := writeGzip(t, record(1700000000, TypeBGP4MP, subtypeMessageAS4, bgp4mp(true, 3356, msg)))
+
+	r, err := Open(path, "rrc00")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer r.Close()
+
+	updates := readAll(t, r)
+	if len(updates) != 1 || updates[0].Prefix != "1.1.1.0/24" {
+		t.Errorf("Unexpected updates: %+v", updates)
+	}
+}
+
+func TestReplayer(t *testing.T) {
+	var data []byte
+	for i, prefix := range []string{"1.1.1.0/24", "8.8.8.0/24", "9.9.9.0/24"} {
+		msg := bgptest.Update(nil, bgptest.Attr(bgp.AttrASPath, bgptest.ASPath(true, 3356, 13335)), bgptest.NLRI(prefix))
+		data = append(data, record(1700000000+uint32(i)*3600, TypeBGP4MP, subtypeMessageAS4, bgp4mp(true, 3356, msg))...)
+	}
+	path := writeGzip(t, data)
+
+	// Hours apart, so only unpaced replay finishes in time
+	p := NewReplayer([]string{path, filepath.Join(t.TempDir(), "missing.gz")}, "rrc00", 0, 1)
+	p.Start()
+
+	var prefixes []string
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case update, ok := <-p.Updates():
+			if !ok {
+				done = true
+				break
+			}
+			prefixes = append(prefixes, update.Prefix)
+		case <-timeout:
+			t.Fatal("Replay did not finish")
+		}
+	}
+	<-p.Done()
+
+	if !reflect.DeepEqual(prefixes, []string{"1.1.1.0/24", "8.8.8.0/24", "9.9.9.0/24"}) {
+		t.Errorf("Unexpected replay order: %v", prefixes)
+	}
+	if stats := p.Stats(); stats["updates"] != uint64(3) || stats["files_done"] != uint64(2) {
+		t.Errorf("Unexpected stats: %v", stats)
+	}
+}
+
+func TestReplayer_Stop(t *testing.T) {
+	var data []byte
+	for i := 0; i < 2; i++ {
+		msg := bgptest.Update(nil, bgptest.Attr(bgp.AttrASPath, bgptest.ASPath(true, 3356, 13335)), bgptest.NLRI("1.1.1.0/24"))
+		data = append(data, record(1700000000+uint32(i)*3600, TypeBGP4MP, subtypeMessageAS4, bgp4mp(true, 3356, msg))...)
+	}
+
+	// Real-time pacing: the second update is due in an hour
+	p := NewReplayer([]string{writeGzip(t, data)}, "rrc00", 1, 10)
+	p.Start()
+	<-p.Updates()
+
+	stopped := make(chan struct{})
+	go func() {
+		p.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not interrupt pacing")
+	}
+	if _, ok := <-p.Updates(); ok {
+		t.Error("Expected the update channel to be closed")
+	}
+}
@@ -0,0 +1,167 @@
+package mrt
+
+import (
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+// Replayer feeds the updates of MRT files, in order, into an update channel
+// like rislive.MultiClient. Unlike the live client it never drops updates:
+// it waits for the detectors instead. The channel is closed once every file
+// has been replayed.
+type Replayer struct {
+	paths     []string
+	collector string
+	speed     float64
+	updates   chan models.BGPUpdate
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+
+	// Stats
+	filesDone   atomic.Uint64
+	replayed    atomic.Uint64
+	parseErrors atomic.Uint64
+	lastUpdate  atomic.Int64 // Unix nanoseconds of the last replayed update
+}
+
+// NewReplayer creates a replayer for the given files. Speed scales the pacing
+// relative to the update timestamps: 1 replays in real time, 60 at 60x, and
+// 0 as fast as the detectors allow.
+func NewReplayer(paths []string, collector string, speed float64, bufferSize int) *Replayer {
+	return &Replayer{
+		paths:     paths,
+		collector: collector,
+		speed:     speed,
+		updates:   make(chan models.BGPUpdate, bufferSize),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Updates returns the channel of replayed updates.
+func (p *Replayer) Updates() <-chan models.BGPUpdate {
+	return p.updates
+}
+
+// Start begins replaying in the background.
+func (p *Replayer) Start() {
+	go p.run()
+}
+
+// Stop stops replaying and waits for the replay goroutine to exit.
+func (p *Replayer) Stop() {
+	p.stopOnce.Do(func() { close(p.stop) })
+	<-p.done
+}
+
+// Done is closed once replay has finished or been stopped.
+func (p *Replayer) Done() <-chan struct{} {
+	return p.done
+}
+
+// Stats returns replay statistics.
+func (p *Replayer) Stats() map[string]interface{} {
+	stats := map[string]interface{}{
+		"files":        len(p.paths),
+		"files_done":   p.filesDone.Load(),
+		"updates":      p.replayed.Load(),
+		"parse_errors": p.parseErrors.Load(),
+		"channel_len":  len(p.updates),
+		"channel_cap":  cap(p.updates),
+	}
+	if ns := p.lastUpdate.Load(); ns != 0 {
+		stats["replay_time"] = time.Unix(0, ns).UTC().Format(time.RFC3339)
+	}
+	return stats
+}
+
+func (p *Replayer) run() {
+	defer close(p.done)
+	defer close(p.updates)
+
+	var pace pacer
+	for _, path := range p.paths {
+		if !p.replayFile(path, &pace) {
+			return
+		}
+		p.filesDone.Add(1)
+	}
+	log.Printf("Replay finished: %d files, %d updates", len(p.paths), p.replayed.Load())
+}
+
+// replayFile replays one file, returning false if the replay was stopped.
+func (p *Replayer) replayFile(path string, pace *pacer) bool {
+	r, err := Open(path, p.collector)
+	if err != nil {
+		log.Printf("Replay: failed to open %s: %v", path, err)
+		return true
+	}
+	defer r.Close()
+	log.Printf("Replaying %s", path)
+
+	for {
+		update, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			log.Printf("Replay: %s: %v", path, err)
+			break
+		}
+
+		if !pace.wait(update.Timestamp, p.speed, p.stop) {
+			return false
+		}
+		select {
+		case p.updates <- update:
+			p.replayed.Add(1)
+			p.lastUpdate.Store(update.Timestamp.UnixNano())
+		case <-p.stop:
+			return false
+		}
+	}
+
+	p.parseErrors.Add(r.parseErrors)
+	log.Printf("Replayed %s: %d records, %d updates, %d skipped, %d parse errors",
+		path, r.records, r.updates, r.skipped, r.parseErrors)
+	return true
+}
+
+// pacer spaces updates by their timestamps, scaled by the replay speed.
+type pacer struct {
+	base  time.Time // Timestamp of the first update
+	start time.Time // When the first update was replayed
+}
+
+// wait sleeps until the update at ts is due, returning false if stopped.
+func (p *pacer) wait(ts time.Time, speed float64, stop <-chan struct{}) bool {
+	if speed <= 0 {
+		return true
+	}
+	if p.start.IsZero() {
+		p.base, p.start = ts, time.Now()
+		return true
+	}
+
+	due := p.start.Add(time.Duration(float64(ts.Sub(p.base)) / speed))
+	delay := time.Until(due)
+	if delay <= 0 {
+		return true
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-stop:
+		return false
+	}
+}
@@ -34,7 +34,9 @@ type Client struct {
 
 	// Stats
 	messagesReceived uint64
-	updatesParsed    uint64
+	updatesParsed    uint64 // Prefixes, not messages
+	updatesDropped   uint64
+	parseErrors      uint64
 	errors           uint64
 	reconnects       uint64
 
@@ -81,6 +83,8 @@ func (c *Client) Stats() map[string]interface{} {
 		"connected":         c.connected.Load(),
 		"messages_received": atomic.LoadUint64(&c.messagesReceived),
 		"updates_parsed":    atomic.LoadUint64(&c.updatesParsed),
+		"updates_dropped":   atomic.LoadUint64(&c.updatesDropped),
+		"parse_errors":      atomic.LoadUint64(&c.parseErrors),
 		"errors":            atomic.LoadUint64(&c.errors),
 		"reconnects":        atomic.LoadUint64(&c.reconnects),
 	}
@@ -201,24 +205,25 @@ func (c *Client) connectAndStream() error {
 			log.Printf("[%s] Raw message: %s", c.collector, string(message[:msgLen]))
 		}
 
-		// Parse and send update
-		update, err := ParseMessage(message, c.collector)
+		// Parse and send updates (one per prefix)
+		updates, err := ParseMessage(message, c.collector)
 		if err != nil {
+			atomic.AddUint64(&c.parseErrors, 1)
 			// Not all messages are updates, this is fine
 			if atomic.LoadUint64(&c.messagesReceived) <= 10 {
 				log.Printf("[%s] Parse error: %v", c.collector, err)
 			}
 			continue
 		}
-		if update != nil {
-			atomic.AddUint64(&c.updatesParsed, 1)
+		for _, update := range updates {
+			parsed := atomic.AddUint64(&c.updatesParsed, 1)
 			// Non-blocking send to channel
 			select {
-			case c.updates <- *update:
+			case c.updates <- update:
 			default:
 				// Channel full, log occasionally
-				if atomic.LoadUint64(&c.updatesParsed)%10000 == 0 {
-					log.Printf("[%s] Update channel full, dropping update", c.collector)
+				if atomic.AddUint64(&c.updatesDropped, 1)%10000 == 1 {
+					log.Printf("[%s] Update channel full, dropping updates (parsed=%d)", c.collector, parsed)
 				}
 			}
 		}
@@ -283,7 +288,7 @@ func (mc *MultiClient) Stop() {
 
 // Stats returns aggregated statistics from all clients.
 func (mc *MultiClient) Stats() map[string]interface{} {
-	var totalMessages, totalUpdates, totalErrors, totalReconnects uint64
+	var totalMessages, totalUpdates, totalDropped, totalParseErrors, totalErrors, totalReconnects uint64
 	clientStats := make([]map[string]interface{}, len(mc.clients))
 
 	for i, client := range mc.clients {
@@ -291,18 +296,22 @@ func (mc *MultiClient) Stats() map[string]interface{} {
 		clientStats[i] = stats
 		totalMessages += stats["messages_received"].(uint64)
 		totalUpdates += stats["updates_parsed"].(uint64)
+		totalDropped += stats["updates_dropped"].(uint64)
+		totalParseErrors += stats["parse_errors"].(uint64)
 		totalErrors += stats["errors"].(uint64)
 		totalReconnects += stats["reconnects"].(uint64)
 	}
 
 	return map[string]interface{}{
-		"running":          mc.running.Load(),
-		"collectors":       clientStats,
-		"total_messages":   totalMessages,
-		"total_updates":    totalUpdates,
-		"total_errors":     totalErrors,
-		"total_reconnects": totalReconnects,
-		"channel_len":      len(mc.updates),
-		"channel_cap":      cap(mc.updates),
+		"running":            mc.running.Load(),
+		"collectors":         clientStats,
+		"total_messages":     totalMessages,
+		"total_updates":      totalUpdates,
+		"total_dropped":      totalDropped,
+		"total_parse_errors": totalParseErrors,
+		"total_errors":       totalErrors,
+		"total_reconnects":   totalReconnects,
+		"channel_len":        len(mc.updates),
+		"channel_cap":        cap(mc.updates),
 	}
 }
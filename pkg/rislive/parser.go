@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/hervehildenbrand/bgp-radar/pkg/models"
@@ -23,16 +24,23 @@ type RISUpdateData struct {
 	Announcements []RISAnnouncement `json:"announcements"`
 	Withdrawals   []string          `json:"withdrawals"`
 	Community     []json.RawMessage `json:"community"`
+	Origin        string            `json:"origin"`
+	MED           *uint32           `json:"med"`
+	LocalPref     *uint32           `json:"local_pref"`
+	Aggregator    string            `json:"aggregator"` // "ASN:address"
 }
 
-// RISAnnouncement represents announced prefixes.
+// RISAnnouncement represents announced prefixes sharing a next hop.
 type RISAnnouncement struct {
+	NextHop  string   `json:"next_hop"`
 	Prefixes []string `json:"prefixes"`
 }
 
-// ParseMessage parses a RIS Live WebSocket message into a BGPUpdate.
+// ParseMessage parses a RIS Live WebSocket message into BGP updates.
+// A single message may carry many prefixes, so one BGPUpdate is returned per
+// announced or withdrawn prefix, announcements first.
 // Returns nil if the message is not a BGP update (e.g., error, rrc_list).
-func ParseMessage(data []byte, collector string) (*models.BGPUpdate, error) {
+func ParseMessage(data []byte, collector string) ([]models.BGPUpdate, error) {
 	var msg RISMessage
 	if err := json.Unmarshal(data, &msg); err != nil {
 		return nil, fmt.Errorf("unmarshal message: %w", err)
@@ -52,42 +60,61 @@ func ParseMessage(data []byte, collector string) (*models.BGPUpdate, error) {
 	peerASN := parseASN(updateData.PeerASN)
 
 	// Parse AS path (may contain nested arrays for AS_SET)
-	asPath, err := parseASPath(updateData.Path)
+	segments, err := parseASPath(updateData.Path)
 	if err != nil {
 		return nil, fmt.Errorf("parse AS path: %w", err)
 	}
 
-	// Get origin ASN (last in path)
-	var originASN uint32
-	if len(asPath) > 0 {
-		originASN = asPath[len(asPath)-1]
-	}
+	// Get origin ASN (last in path, none if it is an AS_SET)
+	asPath, originASN := models.SequencePath(segments)
+	aggregator := parseAggregator(updateData.Aggregator)
+	origin := strings.ToLower(updateData.Origin)
 
 	// Parse communities
-	communities := parseCommunities(updateData.Community)
+	communities, large, extended := parseCommunities(updateData.Community)
 
 	// Convert timestamp
 	timestamp := time.Unix(int64(updateData.Timestamp), int64((updateData.Timestamp-float64(int64(updateData.Timestamp)))*1e9))
 
-	// Process announcements (return first prefix, most common case)
+	count := len(updateData.Withdrawals)
+	for _, ann := range updateData.Announcements {
+		count += len(ann.Prefixes)
+	}
+	if count == 0 {
+		return nil, nil
+	}
+	updates := make([]models.BGPUpdate, 0, count)
+
+	// Process announcements. AS path and communities are shared by every
+	// prefix in the message; the next hop is per announcement.
 	for _, ann := range updateData.Announcements {
 		for _, prefix := range ann.Prefixes {
-			return &models.BGPUpdate{
+			updates = append(updates, models.BGPUpdate{
 				Timestamp:    timestamp,
 				PeerASN:      peerASN,
 				Prefix:       prefix,
 				ASPath:       asPath,
 				OriginASN:    originASN,
 				Communities:  communities,
+				NextHop:      ann.NextHop,
 				Announcement: true,
 				Collector:    collector,
-			}, nil
+
+				LargeCommunities:    large,
+				ExtendedCommunities: extended,
+
+				ASPathSegments: segments,
+				Origin:         origin,
+				MED:            updateData.MED,
+				LocalPref:      updateData.LocalPref,
+				Aggregator:     aggregator,
+			})
 		}
 	}
 
 	// Process withdrawals
 	for _, prefix := range updateData.Withdrawals {
-		return &models.BGPUpdate{
+		updates = append(updates, models.BGPUpdate{
 			Timestamp:    timestamp,
 			PeerASN:      peerASN,
 			Prefix:       prefix,
@@ -96,10 +123,10 @@ func ParseMessage(data []byte, collector string) (*models.BGPUpdate, error) {
 			Communities:  nil,
 			Announcement: false,
 			Collector:    collector,
-		}, nil
+		})
 	}
 
-	return nil, nil
+	return updates, nil
 }
 
 // parseASN parses an ASN that can be either a string or number.
@@ -124,9 +151,10 @@ func parseASN(data json.RawMessage) uint32 {
 	return 0
 }
 
-// parseASPath flattens the AS path which may contain nested arrays (AS_SET).
-// Input can be: [174, 3356, 65001] or [[174], [3356, 65001], 65002]
-func parseASPath(data json.RawMessage) ([]uint32, error) {
+// parseASPath parses the AS path into segments: runs of ASNs are
+// AS_SEQUENCEs and nested arrays AS_SETs.
+// Input can be: [174, 3356, 65001] or [174, 3356, [65001, 65002]]
+func parseASPath(data json.RawMessage) ([]models.ASPathSegment, error) {
 	if data == nil || len(data) == 0 {
 		return nil, nil
 	}
@@ -134,7 +162,10 @@ func parseASPath(data json.RawMessage) ([]uint32, error) {
 	// Try parsing as simple array of numbers first
 	var simpleArray []uint32
 	if err := json.Unmarshal(data, &simpleArray); err == nil {
-		return simpleArray, nil
+		if len(simpleArray) == 0 {
+			return nil, nil
+		}
+		return []models.ASPathSegment{{ASNs: simpleArray}}, nil
 	}
 
 	// Try parsing as mixed array (may contain nested arrays)
@@ -143,48 +174,79 @@ func parseASPath(data json.RawMessage) ([]uint32, error) {
 		return nil, fmt.Errorf("cannot parse path: %w", err)
 	}
 
-	var result []uint32
+	var segments []models.ASPathSegment
 	for _, elem := range mixedArray {
-		// Try as single number
+		// Try as single number, extending the current sequence
 		var num uint32
 		if err := json.Unmarshal(elem, &num); err == nil {
-			result = append(result, num)
+			if n := len(segments); n > 0 && !segments[n-1].Set {
+				segments[n-1].ASNs = append(segments[n-1].ASNs, num)
+			} else {
+				segments = append(segments, models.ASPathSegment{ASNs: []uint32{num}})
+			}
 			continue
 		}
 
 		// Try as array of numbers (AS_SET)
 		var nums []uint32
-		if err := json.Unmarshal(elem, &nums); err == nil {
-			result = append(result, nums...)
+		if err := json.Unmarshal(elem, &nums); err == nil && len(nums) > 0 {
+			segments = append(segments, models.ASPathSegment{Set: true, ASNs: nums})
 			continue
 		}
 	}
 
-	return result, nil
+	return segments, nil
 }
 
-// parseCommunities converts community data to "ASN:value" string format.
-// Input can be: [[65535, 666], [3356, 9999]] or ["65535:666"]
-func parseCommunities(data []json.RawMessage) []string {
-	if data == nil {
+// parseAggregator parses an aggregator written "ASN:address".
+func parseAggregator(s string) *models.Aggregator {
+	asn, address, ok := strings.Cut(s, ":")
+	if !ok {
+		return nil
+	}
+	n, err := strconv.ParseUint(asn, 10, 32)
+	if err != nil {
 		return nil
 	}
+	return &models.Aggregator{ASN: uint32(n), Address: address}
+}
+
+// parseCommunities splits community data into standard communities in
+// "ASN:value" string format, large communities and extended communities.
+// Input can be: [[65535, 666], [6939, 666, 0]] or ["65535:666", "rt:64500:100"]
+func parseCommunities(data []json.RawMessage) ([]string, []models.LargeCommunity, []models.ExtendedCommunity) {
+	if data == nil {
+		return nil, nil, nil
+	}
 
 	var result []string
+	var large []models.LargeCommunity
+	var extended []models.ExtendedCommunity
 	for _, elem := range data {
-		// Try as [ASN, value] tuple
+		// Try as [ASN, value] or [ASN, value, value] tuple
 		var tuple []uint32
-		if err := json.Unmarshal(elem, &tuple); err == nil && len(tuple) == 2 {
-			result = append(result, strconv.FormatUint(uint64(tuple[0]), 10)+":"+strconv.FormatUint(uint64(tuple[1]), 10))
+		if err := json.Unmarshal(elem, &tuple); err == nil {
+			switch len(tuple) {
+			case 2:
+				result = append(result, strconv.FormatUint(uint64(tuple[0]), 10)+":"+strconv.FormatUint(uint64(tuple[1]), 10))
+			case 3:
+				large = append(large, models.LargeCommunity{GlobalAdmin: tuple[0], LocalData1: tuple[1], LocalData2: tuple[2]})
+			}
 			continue
 		}
 
 		// Try as string
 		var str string
 		if err := json.Unmarshal(elem, &str); err == nil {
-			result = append(result, str)
+			if c, err := models.ParseLargeCommunity(str); err == nil {
+				large = append(large, c)
+			} else if c, err := models.ParseExtendedCommunity(str); err == nil {
+				extended = append(extended, c)
+			} else {
+				result = append(result, str)
+			}
 		}
 	}
 
-	return result
+	return result, large, extended
 }
@@ -2,7 +2,10 @@ package rislive
 
 import (
 	"encoding/json"
+	"reflect"
 	"testing"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
 )
 
 func TestParseMessage_Announcement(t *testing.T) {
@@ -18,13 +21,14 @@ func TestParseMessage_Announcement(t *testing.T) {
 		}
 	}`)
 
-	update, err := ParseMessage(msg, "rrc00")
+	updates, err := ParseMessage(msg, "rrc00")
 	if err != nil {
 		t.Fatalf("ParseMessage failed: %v", err)
 	}
-	if update == nil {
-		t.Fatal("Expected update, got nil")
+	if len(updates) != 1 {
+		t.Fatalf("Expected 1 update, got %d", len(updates))
 	}
+	update := updates[0]
 
 	if update.Prefix != "1.1.1.0/24" {
 		t.Errorf("Expected prefix 1.1.1.0/24, got %s", update.Prefix)
@@ -62,13 +66,14 @@ func TestParseMessage_Withdrawal(t *testing.T) {
 		}
 	}`)
 
-	update, err := ParseMessage(msg, "rrc01")
+	updates, err := ParseMessage(msg, "rrc01")
 	if err != nil {
 		t.Fatalf("ParseMessage failed: %v", err)
 	}
-	if update == nil {
-		t.Fatal("Expected update, got nil")
+	if len(updates) != 1 {
+		t.Fatalf("Expected 1 update, got %d", len(updates))
 	}
+	update := updates[0]
 
 	if update.Prefix != "192.0.2.0/24" {
 		t.Errorf("Expected prefix 192.0.2.0/24, got %s", update.Prefix)
@@ -84,15 +89,64 @@ func TestParseMessage_Withdrawal(t *testing.T) {
 func TestParseMessage_NonRISMessage(t *testing.T) {
 	msg := []byte(`{"type": "ris_error", "data": {"message": "test"}}`)
 
-	update, err := ParseMessage(msg, "rrc00")
+	updates, err := ParseMessage(msg, "rrc00")
 	if err != nil {
 		t.Fatalf("ParseMessage failed: %v", err)
 	}
-	if update != nil {
+	if updates != nil {
 		t.Error("Expected nil for non-ris_message type")
 	}
 }
 
+func TestParseMessage_MultiplePrefixes(t *testing.T) {
+	msg := []byte(`{
+		"type": "ris_message",
+		"data": {
+			"timestamp": 1705320000.0,
+			"peer_asn": 6939,
+			"path": [6939, 3356, 13335],
+			"announcements": [
+				{"next_hop": "192.0.2.1", "prefixes": ["1.1.1.0/24", "1.0.0.0/24"]},
+				{"next_hop": "2001:db8::1", "prefixes": ["2606:4700::/32"]}
+			],
+			"withdrawals": ["198.51.100.0/24", "203.0.113.0/24"]
+		}
+	}`)
+
+	updates, err := ParseMessage(msg, "rrc00")
+	if err != nil {
+		t.Fatalf("ParseMessage failed: %v", err)
+	}
+
+	expected := []struct {
+		prefix       string
+		nextHop      string
+		announcement bool
+	}{
+		{"1.1.1.0/24", "192.0.2.1", true},
+		{"1.0.0.0/24", "192.0.2.1", true},
+		{"2606:4700::/32", "2001:db8::1", true},
+		{"198.51.100.0/24", "", false},
+		{"203.0.113.0/24", "", false},
+	}
+	if len(updates) != len(expected) {
+		t.Fatalf("Expected %d updates, got %d", len(expected), len(updates))
+	}
+	for i, exp := range expected {
+		u := updates[i]
+		if u.Prefix != exp.prefix || u.NextHop != exp.nextHop || u.Announcement != exp.announcement {
+			t.Errorf("Update[%d]: got (%s, %q, %v), want (%s, %q, %v)",
+				i, u.Prefix, u.NextHop, u.Announcement, exp.prefix, exp.nextHop, exp.announcement)
+		}
+		if exp.announcement && u.OriginASN != 13335 {
+			t.Errorf("Update[%d]: expected origin 13335, got %d", i, u.OriginASN)
+		}
+		if !exp.announcement && u.ASPath != nil {
+			t.Errorf("Update[%d]: expected no AS path on withdrawal", i)
+		}
+	}
+}
+
 func TestParseMessage_NestedASPath(t *testing.T) {
 	// AS path with AS_SET (nested array)
 	msg := []byte(`{
@@ -105,23 +159,70 @@ func TestParseMessage_NestedASPath(t *testing.T) {
 		}
 	}`)
 
-	update, err := ParseMessage(msg, "rrc00")
+	updates, err := ParseMessage(msg, "rrc00")
 	if err != nil {
 		t.Fatalf("ParseMessage failed: %v", err)
 	}
-	if update == nil {
-		t.Fatal("Expected update, got nil")
+	if len(updates) != 1 {
+		t.Fatalf("Expected 1 update, got %d", len(updates))
 	}
+	update := updates[0]
 
-	// Nested arrays should be flattened
-	expectedPath := []uint32{174, 3356, 7018, 13335}
-	if len(update.ASPath) != len(expectedPath) {
-		t.Fatalf("Expected AS path length %d, got %d", len(expectedPath), len(update.ASPath))
+	// Nested arrays are AS_SETs, kept out of the sequence path
+	expectedSegments := []models.ASPathSegment{
+		{Set: true, ASNs: []uint32{174}},
+		{Set: true, ASNs: []uint32{3356, 7018}},
+		{ASNs: []uint32{13335}},
+	}
+	if !reflect.DeepEqual(update.ASPathSegments, expectedSegments) {
+		t.Errorf("Expected AS path segments %v, got %v", expectedSegments, update.ASPathSegments)
+	}
+	if !reflect.DeepEqual(update.ASPath, []uint32{13335}) || update.OriginASN != 13335 {
+		t.Errorf("Expected AS path [13335] origin 13335, got %v origin %d", update.ASPath, update.OriginASN)
 	}
-	for i, asn := range expectedPath {
-		if update.ASPath[i] != asn {
-			t.Errorf("AS path[%d]: expected %d, got %d", i, asn, update.ASPath[i])
+}
+
+func TestParseMessage_PathAttributes(t *testing.T) {
+	msg := []byte(`{
+		"type": "ris_message",
+		"data": {
+			"timestamp": 1705320000.0,
+			"peer_asn": 174,
+			"path": [174, 3356, [64500, 64501]],
+			"origin": "IGP",
+			"med": 0,
+			"local_pref": 100,
+			"aggregator": "3356:192.0.2.1",
+			"announcements": [{"next_hop": "192.0.2.254", "prefixes": ["198.51.100.0/23"]}]
 		}
+	}`)
+
+	updates, err := ParseMessage(msg, "rrc00")
+	if err != nil {
+		t.Fatalf("ParseMessage failed: %v", err)
+	}
+	if len(updates) != 1 {
+		t.Fatalf("Expected 1 update, got %d", len(updates))
+	}
+	update := updates[0]
+
+	if !reflect.DeepEqual(update.ASPath, []uint32{174, 3356}) {
+		t.Errorf("Expected AS path [174 3356], got %v", update.ASPath)
+	}
+	if update.OriginASN != 0 || !update.AmbiguousOrigin() || !reflect.DeepEqual(update.OriginSet(), []uint32{64500, 64501}) {
+		t.Errorf("Expected an ambiguous origin {64500 64501}, got %d %v", update.OriginASN, update.OriginSet())
+	}
+	if update.Origin != models.OriginIGP {
+		t.Errorf("Expected origin igp, got %q", update.Origin)
+	}
+	if update.MED == nil || *update.MED != 0 || update.LocalPref == nil || *update.LocalPref != 100 {
+		t.Errorf("Expected MED 0 and local pref 100, got %v %v", update.MED, update.LocalPref)
+	}
+	if update.Aggregator == nil || *update.Aggregator != (models.Aggregator{ASN: 3356, Address: "192.0.2.1"}) {
+		t.Errorf("Expected aggregator 3356:192.0.2.1, got %v", update.Aggregator)
+	}
+	if update.NextHop != "192.0.2.254" {
+		t.Errorf("Expected next hop 192.0.2.254, got %s", update.NextHop)
 	}
 }
 
@@ -147,6 +248,24 @@ func TestParseASN(t *testing.T) {
 	}
 }
 
+func TestParseCommunities_LargeAndExtended(t *testing.T) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal([]byte(`[[65535, 666], [6939, 666, 0], "64500:1:2", "rt:64500:100"]`), &raw); err != nil {
+		t.Fatal(err)
+	}
+	standard, large, extended := parseCommunities(raw)
+	if len(standard) != 1 || standard[0] != "65535:666" {
+		t.Errorf("Expected standard communities [65535:666], got %v", standard)
+	}
+	want := []models.LargeCommunity{{GlobalAdmin: 6939, LocalData1: 666}, {GlobalAdmin: 64500, LocalData1: 1, LocalData2: 2}}
+	if !reflect.DeepEqual(large, want) {
+		t.Errorf("Expected large communities %v, got %v", want, large)
+	}
+	if len(extended) != 1 || extended[0].String() != "rt:64500:100" {
+		t.Errorf("Expected extended communities [rt:64500:100], got %v", extended)
+	}
+}
+
 func TestParseCommunities(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -177,7 +296,7 @@ func TestParseCommunities(t *testing.T) {
 				t.Fatalf("Failed to parse test input: %v", err)
 			}
 
-			result := parseCommunities(rawMessages)
+			result, _, _ := parseCommunities(rawMessages)
 			if len(result) != len(tt.expected) {
 				t.Fatalf("Expected %d communities, got %d", len(tt.expected), len(result))
 			}
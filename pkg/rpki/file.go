@@ -0,0 +1,155 @@
+package rpki
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/trie"
+)
+
+// vrpFile is the JSON export format shared by rpki-client (-j) and
+// Routinator (--format json / jsonext).
+type vrpFile struct {
+	ROAs []vrpEntry `json:"roas"`
+}
+
+type vrpEntry struct {
+	ASN       json.RawMessage `json:"asn"` // 13335 or "AS13335"
+	Prefix    string          `json:"prefix"`
+	MaxLength int             `json:"maxLength"`
+}
+
+// LoadFile reads VRPs from an rpki-client or Routinator JSON export.
+func LoadFile(path string) ([]VRP, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJSON(data)
+}
+
+// ParseJSON parses VRPs from an rpki-client or Routinator JSON export.
+// Malformed entries are skipped.
+func ParseJSON(data []byte) ([]VRP, error) {
+	var file vrpFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("unmarshal VRP file: %w", err)
+	}
+
+	vrps := make([]VRP, 0, len(file.ROAs))
+	for _, entry := range file.ROAs {
+		asn, ok := parseASN(entry.ASN)
+		if !ok {
+			continue
+		}
+		prefix, err := trie.ParsePrefix(entry.Prefix)
+		if err != nil {
+			continue
+		}
+		vrps = append(vrps, VRP{
+			Prefix:    prefix,
+			MaxLength: entry.MaxLength,
+			ASN:       asn,
+		})
+	}
+	return vrps, nil
+}
+
+// parseASN accepts 13335, "13335" and "AS13335".
+func parseASN(data json.RawMessage) (uint32, bool) {
+	var num uint32
+	if err := json.Unmarshal(data, &num); err == nil {
+		return num, true
+	}
+
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return 0, false
+	}
+	str = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(str)), "AS")
+	val, err := strconv.ParseUint(str, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(val), true
+}
+
+// FileSource periodically reloads a VRP JSON export into a Validator.
+type FileSource struct {
+	path      string
+	interval  time.Duration
+	validator *Validator
+	modTime   time.Time
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewFileSource creates a source that loads path into validator every interval.
+// The file is only re-parsed when its modification time changes.
+func NewFileSource(path string, interval time.Duration, validator *Validator) *FileSource {
+	return &FileSource{
+		path:      path,
+		interval:  interval,
+		validator: validator,
+		done:      make(chan struct{}),
+	}
+}
+
+// Load reads the file once and installs it in the validator.
+func (s *FileSource) Load() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	if !s.modTime.IsZero() && info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+
+	start := time.Now()
+	vrps, err := LoadFile(s.path)
+	if err != nil {
+		return err
+	}
+	s.validator.Replace(NewTable(vrps, info.ModTime().UTC().Format(time.RFC3339)))
+	s.modTime = info.ModTime()
+
+	log.Printf("RPKI: Loaded %d VRPs from %s in %v", len(vrps), s.path, time.Since(start))
+	return nil
+}
+
+// Start begins periodic reloading of the VRP file.
+func (s *FileSource) Start() {
+	if s.interval <= 0 {
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.Load(); err != nil {
+					log.Printf("RPKI: Failed to reload %s: %v", s.path, err)
+				}
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops periodic reloading.
+func (s *FileSource) Stop() {
+	close(s.done)
+	s.wg.Wait()
+}
@@ -0,0 +1,388 @@
+package rpki
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/netip"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RTR PDU types (RFC 8210 section 5)
+const (
+	pduSerialNotify  = 0
+	pduSerialQuery   = 1
+	pduResetQuery    = 2
+	pduCacheResponse = 3
+	pduIPv4Prefix    = 4
+	pduIPv6Prefix    = 6
+	pduEndOfData     = 7
+	pduCacheReset    = 8
+	pduRouterKey     = 9
+	pduErrorReport   = 10
+)
+
+const (
+	rtrVersion0 = 0
+	rtrVersion1 = 1
+
+	rtrHeaderLen     = 8
+	rtrMaxPDULen     = 64 * 1024
+	rtrFlagAnnounce  = 1
+	rtrErrNoData     = 2
+	rtrErrBadVersion = 4
+
+	rtrDialTimeout           = 10 * time.Second
+	rtrWriteTimeout          = 10 * time.Second
+	rtrDefaultRefresh        = time.Hour
+	rtrMinRefresh            = 30 * time.Second
+	rtrInitialReconnectDelay = 5 * time.Second
+	rtrMaxReconnectDelay     = 5 * time.Minute
+)
+
+// errCacheReset signals that the cache asked for a full reset.
+var errCacheReset = errors.New("cache reset")
+
+// RTRClient keeps a Validator in sync with an RPKI-to-Router cache (RFC 8210).
+type RTRClient struct {
+	addr      string
+	validator *Validator
+	done      chan struct{}
+	wg        sync.WaitGroup
+	running   atomic.Bool
+	connected atomic.Bool
+
+	// Session state, only touched by the run loop
+	version   uint8
+	sessionID uint16
+	serial    uint32
+	synced    bool
+	vrps      map[VRP]struct{}
+	refresh   time.Duration
+
+	// Stats
+	syncs      uint64
+	errors     uint64
+	reconnects uint64
+	lastSerial atomic.Uint32
+}
+
+// NewRTRClient creates a client for the RTR cache at addr (host:port).
+func NewRTRClient(addr string, validator *Validator) *RTRClient {
+	return &RTRClient{
+		addr:      addr,
+		validator: validator,
+		done:      make(chan struct{}),
+		version:   rtrVersion1,
+		vrps:      make(map[VRP]struct{}),
+		refresh:   rtrDefaultRefresh,
+	}
+}
+
+// Start connects to the cache in a goroutine, reconnecting on failure.
+func (c *RTRClient) Start() {
+	if c.running.Swap(true) {
+		return
+	}
+	c.wg.Add(1)
+	go c.runLoop()
+	log.Printf("RPKI: RTR client started (%s)", c.addr)
+}
+
+// Stop closes the session and waits for the client to exit.
+func (c *RTRClient) Stop() {
+	if !c.running.Swap(false) {
+		return
+	}
+	close(c.done)
+	c.wg.Wait()
+	log.Printf("RPKI: RTR client stopped")
+}
+
+// Stats returns client statistics.
+func (c *RTRClient) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"addr":       c.addr,
+		"connected":  c.connected.Load(),
+		"serial":     c.lastSerial.Load(),
+		"syncs":      atomic.LoadUint64(&c.syncs),
+		"errors":     atomic.LoadUint64(&c.errors),
+		"reconnects": atomic.LoadUint64(&c.reconnects),
+	}
+}
+
+func (c *RTRClient) runLoop() {
+	defer c.wg.Done()
+
+	reconnectDelay := rtrInitialReconnectDelay
+	for c.running.Load() {
+		err := c.connectAndSync()
+		if err != nil {
+			atomic.AddUint64(&c.errors, 1)
+			atomic.AddUint64(&c.reconnects, 1)
+			log.Printf("RPKI: RTR session error: %v, reconnecting in %v", err, reconnectDelay)
+		} else {
+			reconnectDelay = rtrInitialReconnectDelay
+		}
+
+		select {
+		case <-c.done:
+			return
+		case <-time.After(reconnectDelay):
+			reconnectDelay *= 2
+			if reconnectDelay > rtrMaxReconnectDelay {
+				reconnectDelay = rtrMaxReconnectDelay
+			}
+		}
+	}
+}
+
+func (c *RTRClient) connectAndSync() error {
+	conn, err := net.DialTimeout("tcp", c.addr, rtrDialTimeout)
+	if err != nil {
+		return fmt.Errorf("dial failed: %w", err)
+	}
+	defer conn.Close()
+
+	c.connected.Store(true)
+	defer c.connected.Store(false)
+
+	// Close the connection on shutdown to unblock reads
+	sessionDone := make(chan struct{})
+	defer close(sessionDone)
+	go func() {
+		select {
+		case <-c.done:
+			conn.Close()
+		case <-sessionDone:
+		}
+	}()
+
+	return c.session(conn)
+}
+
+// session runs the RTR protocol over an established connection until it fails
+// or the client is stopped.
+func (c *RTRClient) session(conn net.Conn) error {
+	reader := bufio.NewReader(conn)
+
+	query := func() error {
+		if c.synced {
+			return c.writePDU(conn, pduSerialQuery, c.sessionID, serialBody(c.serial))
+		}
+		return c.writePDU(conn, pduResetQuery, 0, nil)
+	}
+	if err := query(); err != nil {
+		return err
+	}
+
+	for c.running.Load() {
+		conn.SetReadDeadline(time.Now().Add(c.refresh))
+		needQuery, err := c.readResponse(reader)
+
+		var netErr net.Error
+		switch {
+		case err == nil:
+		case errors.Is(err, errCacheReset):
+			c.synced = false
+			needQuery = true
+		case errors.As(err, &netErr) && netErr.Timeout():
+			// Refresh interval elapsed
+			needQuery = true
+		default:
+			if !c.running.Load() {
+				return nil
+			}
+			return err
+		}
+
+		if needQuery {
+			if err := query(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readResponse reads PDUs until an End of Data, Serial Notify or Cache Reset.
+// It reports whether a new query should be sent to the cache.
+func (c *RTRClient) readResponse(r io.Reader) (bool, error) {
+	var pending map[VRP]struct{}
+
+	for {
+		version, pduType, field, body, err := readPDU(r)
+		if err != nil {
+			return false, err
+		}
+
+		switch pduType {
+		case pduSerialNotify:
+			// Ignore notifies that arrive in the middle of a response
+			if pending == nil {
+				return true, nil
+			}
+
+		case pduCacheResponse:
+			if version != c.version {
+				// Cache negotiated a lower version
+				c.version = version
+			}
+			if !c.synced || field != c.sessionID {
+				pending = make(map[VRP]struct{})
+				c.sessionID = field
+				c.synced = false
+			} else {
+				pending = make(map[VRP]struct{}, len(c.vrps))
+				for vrp := range c.vrps {
+					pending[vrp] = struct{}{}
+				}
+			}
+
+		case pduIPv4Prefix, pduIPv6Prefix:
+			if pending == nil {
+				return false, fmt.Errorf("prefix PDU outside of cache response")
+			}
+			vrp, announce, err := decodePrefixPDU(pduType, body)
+			if err != nil {
+				return false, err
+			}
+			if announce {
+				pending[vrp] = struct{}{}
+			} else {
+				delete(pending, vrp)
+			}
+
+		case pduEndOfData:
+			if pending == nil {
+				return false, fmt.Errorf("end of data outside of cache response")
+			}
+			if len(body) < 4 {
+				return false, fmt.Errorf("short end of data PDU")
+			}
+			c.serial = binary.BigEndian.Uint32(body[0:4])
+			if version >= rtrVersion1 && len(body) >= 8 {
+				refresh := time.Duration(binary.BigEndian.Uint32(body[4:8])) * time.Second
+				if refresh < rtrMinRefresh {
+					refresh = rtrMinRefresh
+				}
+				c.refresh = refresh
+			}
+			c.vrps = pending
+			c.synced = true
+			c.publish()
+			return false, nil
+
+		case pduCacheReset:
+			return false, errCacheReset
+
+		case pduRouterKey:
+			// BGPsec router keys are not used for origin validation
+
+		case pduErrorReport:
+			if field == rtrErrBadVersion && c.version > rtrVersion0 {
+				c.version = rtrVersion0
+				return false, fmt.Errorf("cache does not support RTR version 1, downgrading")
+			}
+			if field == rtrErrNoData {
+				return false, fmt.Errorf("cache has no data available yet")
+			}
+			return false, fmt.Errorf("cache reported error %d", field)
+
+		default:
+			return false, fmt.Errorf("unexpected PDU type %d", pduType)
+		}
+	}
+}
+
+// publish installs the current VRP set in the validator.
+func (c *RTRClient) publish() {
+	vrps := make([]VRP, 0, len(c.vrps))
+	for vrp := range c.vrps {
+		vrps = append(vrps, vrp)
+	}
+	c.validator.Replace(NewTable(vrps, "rtr-serial-"+strconv.FormatUint(uint64(c.serial), 10)))
+	c.lastSerial.Store(c.serial)
+
+	if atomic.AddUint64(&c.syncs, 1) == 1 {
+		log.Printf("RPKI: RTR synced %d VRPs (session=%d, serial=%d)", len(vrps), c.sessionID, c.serial)
+	}
+}
+
+func (c *RTRClient) writePDU(conn net.Conn, pduType uint8, field uint16, body []byte) error {
+	buf := make([]byte, rtrHeaderLen+len(body))
+	buf[0] = c.version
+	buf[1] = pduType
+	binary.BigEndian.PutUint16(buf[2:4], field)
+	binary.BigEndian.PutUint32(buf[4:8], uint32(len(buf)))
+	copy(buf[rtrHeaderLen:], body)
+
+	conn.SetWriteDeadline(time.Now().Add(rtrWriteTimeout))
+	if _, err := conn.Write(buf); err != nil {
+		return fmt.Errorf("write PDU %d: %w", pduType, err)
+	}
+	return nil
+}
+
+func serialBody(serial uint32) []byte {
+	body := make([]byte, 4)
+	binary.BigEndian.PutUint32(body, serial)
+	return body
+}
+
+// readPDU reads one PDU, returning its version, type, 16-bit header field
+// (session ID, flags or error code) and body.
+func readPDU(r io.Reader) (uint8, uint8, uint16, []byte, error) {
+	var header [rtrHeaderLen]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, 0, 0, nil, err
+	}
+
+	length := binary.BigEndian.Uint32(header[4:8])
+	if length < rtrHeaderLen || length > rtrMaxPDULen {
+		return 0, 0, 0, nil, fmt.Errorf("invalid PDU length %d", length)
+	}
+
+	body := make([]byte, length-rtrHeaderLen)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, 0, 0, nil, err
+	}
+	return header[0], header[1], binary.BigEndian.Uint16(header[2:4]), body, nil
+}
+
+// decodePrefixPDU decodes an IPv4 or IPv6 Prefix PDU body.
+func decodePrefixPDU(pduType uint8, body []byte) (VRP, bool, error) {
+	addrLen := 4
+	if pduType == pduIPv6Prefix {
+		addrLen = 16
+	}
+	if len(body) != 4+addrLen+4 {
+		return VRP{}, false, fmt.Errorf("invalid prefix PDU length %d", len(body))
+	}
+
+	flags, prefixLen, maxLen := body[0], int(body[1]), int(body[2])
+
+	var addr netip.Addr
+	if addrLen == 4 {
+		addr = netip.AddrFrom4([4]byte(body[4:8]))
+	} else {
+		addr = netip.AddrFrom16([16]byte(body[4:20]))
+	}
+	prefix := netip.PrefixFrom(addr, prefixLen)
+	if !prefix.IsValid() || maxLen < prefixLen || maxLen > addr.BitLen() {
+		return VRP{}, false, fmt.Errorf("invalid prefix %s-%d", prefix, maxLen)
+	}
+
+	return VRP{
+		Prefix:    prefix.Masked(),
+		MaxLength: maxLen,
+		ASN:       binary.BigEndian.Uint32(body[4+addrLen:]),
+	}, flags&rtrFlagAnnounce != 0, nil
+}
@@ -0,0 +1,134 @@
+package rpki
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+// rtrPDU builds a raw version 1 PDU.
+func rtrPDU(pduType uint8, field uint16, body []byte) []byte {
+	buf := make([]byte, rtrHeaderLen+len(body))
+	buf[0] = rtrVersion1
+	buf[1] = pduType
+	binary.BigEndian.PutUint16(buf[2:4], field)
+	binary.BigEndian.PutUint32(buf[4:8], uint32(len(buf)))
+	copy(buf[rtrHeaderLen:], body)
+	return buf
+}
+
+func ipv4PrefixPDU(announce bool, addr [4]byte, prefixLen, maxLen uint8, asn uint32) []byte {
+	body := make([]byte, 12)
+	if announce {
+		body[0] = rtrFlagAnnounce
+	}
+	body[1], body[2] = prefixLen, maxLen
+	copy(body[4:8], addr[:])
+	binary.BigEndian.PutUint32(body[8:12], asn)
+	return rtrPDU(pduIPv4Prefix, 0, body)
+}
+
+func endOfDataPDU(session uint16, serial uint32) []byte {
+	body := make([]byte, 16)
+	binary.BigEndian.PutUint32(body[0:4], serial)
+	binary.BigEndian.PutUint32(body[4:8], 3600)
+	binary.BigEndian.PutUint32(body[8:12], 600)
+	binary.BigEndian.PutUint32(body[12:16], 7200)
+	return rtrPDU(pduEndOfData, session, body)
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRTRClient_Session(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+
+	v := NewValidator()
+	c := NewRTRClient("test", v)
+	c.running.Store(true)
+
+	sessionErr := make(chan error, 1)
+	go func() {
+		sessionErr <- c.session(clientConn)
+	}()
+
+	// Initial sync: expect a Reset Query
+	_, pduType, _, _, err := readPDU(serverConn)
+	if err != nil || pduType != pduResetQuery {
+		t.Fatalf("Expected Reset Query, got type %d (err=%v)", pduType, err)
+	}
+	for _, pdu := range [][]byte{
+		rtrPDU(pduCacheResponse, 42, nil),
+		ipv4PrefixPDU(true, [4]byte{1, 1, 1, 0}, 24, 24, 13335),
+		ipv4PrefixPDU(true, [4]byte{8, 8, 8, 0}, 24, 24, 15169),
+		endOfDataPDU(42, 1),
+	} {
+		serverConn.Write(pdu)
+	}
+
+	waitFor(t, "initial sync", v.Ready)
+	if got := v.Validate("1.1.1.0/24", 13335); got.Status != models.RPKIValid {
+		t.Errorf("Validate after reset = %s, want valid", got.Status)
+	}
+
+	// Incremental update: notify, expect Serial Query, withdraw one VRP
+	serverConn.Write(rtrPDU(pduSerialNotify, 42, serialBody(2)))
+	_, pduType, session, body, err := readPDU(serverConn)
+	if err != nil || pduType != pduSerialQuery {
+		t.Fatalf("Expected Serial Query, got type %d (err=%v)", pduType, err)
+	}
+	if session != 42 || binary.BigEndian.Uint32(body) != 1 {
+		t.Errorf("Serial Query session=%d serial=%d, want 42/1", session, binary.BigEndian.Uint32(body))
+	}
+	for _, pdu := range [][]byte{
+		rtrPDU(pduCacheResponse, 42, nil),
+		ipv4PrefixPDU(false, [4]byte{1, 1, 1, 0}, 24, 24, 13335),
+		endOfDataPDU(42, 2),
+	} {
+		serverConn.Write(pdu)
+	}
+
+	waitFor(t, "incremental sync", func() bool { return c.lastSerial.Load() == 2 })
+	if got := v.Validate("1.1.1.0/24", 13335); got.Status != models.RPKINotFound {
+		t.Errorf("Validate after withdraw = %s, want not_found", got.Status)
+	}
+	if got := v.Validate("8.8.8.0/24", 64512); got.Status != models.RPKIInvalid {
+		t.Errorf("Validate other origin = %s, want invalid", got.Status)
+	}
+
+	// Closing the connection ends the session with an error
+	serverConn.Close()
+	select {
+	case err := <-sessionErr:
+		if err == nil {
+			t.Error("Expected session error after connection close")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Session did not exit after connection close")
+	}
+}
+
+func TestDecodePrefixPDU_Invalid(t *testing.T) {
+	// maxLength shorter than prefix length
+	body := make([]byte, 12)
+	body[0], body[1], body[2] = rtrFlagAnnounce, 24, 16
+	if _, _, err := decodePrefixPDU(pduIPv4Prefix, body); err == nil {
+		t.Error("Expected error for maxLength < prefix length")
+	}
+
+	if _, _, err := decodePrefixPDU(pduIPv6Prefix, body); err == nil {
+		t.Error("Expected error for truncated IPv6 prefix PDU")
+	}
+}
@@ -0,0 +1,165 @@
+// Package rpki provides RPKI Route Origin Validation (RFC 6811) against
+// Validated ROA Payloads loaded from a JSON export or an RTR cache (RFC 8210).
+package rpki
+
+import (
+	"net/netip"
+	"sync/atomic"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+	"github.com/hervehildenbrand/bgp-radar/pkg/trie"
+)
+
+// Reasons an announcement is RPKI invalid.
+const (
+	ReasonASN    = "as"     // No covering VRP authorizes the origin ASN
+	ReasonLength = "length" // Origin is authorized, but the prefix exceeds maxLength
+)
+
+// VRP is a Validated ROA Payload.
+type VRP struct {
+	Prefix    netip.Prefix
+	MaxLength int
+	ASN       uint32
+}
+
+// Result is the outcome of validating a route origin.
+type Result struct {
+	Status string // models.RPKIValid, RPKIInvalid, RPKINotFound or RPKIUnknown
+	Reason string // ReasonASN or ReasonLength when invalid
+}
+
+// Table is an immutable set of VRPs indexed for origin validation.
+type Table struct {
+	trie    *trie.Trie[[]VRP]
+	count   int
+	version string
+	loaded  time.Time
+}
+
+// NewTable builds a lookup table from a list of VRPs.
+// version is a free-form label (file name, RTR serial) reported in stats.
+func NewTable(vrps []VRP, version string) *Table {
+	t := &Table{
+		trie:    trie.New[[]VRP](),
+		version: version,
+		loaded:  time.Now(),
+	}
+	for _, vrp := range vrps {
+		if !vrp.Prefix.IsValid() {
+			continue
+		}
+		vrp.Prefix = vrp.Prefix.Masked()
+		if vrp.MaxLength < vrp.Prefix.Bits() {
+			vrp.MaxLength = vrp.Prefix.Bits()
+		}
+		existing, _ := t.trie.Get(vrp.Prefix)
+		t.trie.Insert(vrp.Prefix, append(existing, vrp))
+		t.count++
+	}
+	return t
+}
+
+// Count returns the number of VRPs in the table.
+func (t *Table) Count() int {
+	return t.count
+}
+
+// Validate performs route origin validation per RFC 6811.
+func (t *Table) Validate(prefix netip.Prefix, origin uint32) Result {
+	var covered, valid, originMatched bool
+
+	t.trie.WalkCovering(prefix, func(_ netip.Prefix, vrps []VRP) bool {
+		for _, vrp := range vrps {
+			covered = true
+			// AS0 ROAs never authorize an origin (RFC 6483)
+			if origin == 0 || vrp.ASN != origin {
+				continue
+			}
+			originMatched = true
+			if prefix.Bits() <= vrp.MaxLength {
+				valid = true
+				return false
+			}
+		}
+		return true
+	})
+
+	switch {
+	case !covered:
+		return Result{Status: models.RPKINotFound}
+	case valid:
+		return Result{Status: models.RPKIValid}
+	case originMatched:
+		return Result{Status: models.RPKIInvalid, Reason: ReasonLength}
+	default:
+		return Result{Status: models.RPKIInvalid, Reason: ReasonASN}
+	}
+}
+
+// Validator validates route origins against the most recently loaded VRP table.
+// Tables are swapped atomically, so lookups never block on a reload.
+// A nil Validator reports every route as unknown.
+type Validator struct {
+	table atomic.Pointer[Table]
+
+	// Stats
+	validations uint64
+	invalids    uint64
+}
+
+// NewValidator creates a validator with no VRPs loaded.
+func NewValidator() *Validator {
+	return &Validator{}
+}
+
+// Replace atomically installs a new VRP table.
+func (v *Validator) Replace(t *Table) {
+	v.table.Store(t)
+}
+
+// Ready reports whether a VRP table has been loaded.
+func (v *Validator) Ready() bool {
+	return v != nil && v.table.Load() != nil
+}
+
+// Validate returns the RPKI status of prefix originated by origin.
+func (v *Validator) Validate(prefix string, origin uint32) Result {
+	if !v.Ready() {
+		return Result{Status: models.RPKIUnknown}
+	}
+	p, err := trie.ParsePrefix(prefix)
+	if err != nil {
+		return Result{Status: models.RPKIUnknown}
+	}
+	return v.ValidatePrefix(p, origin)
+}
+
+// ValidatePrefix is Validate for an already parsed prefix.
+func (v *Validator) ValidatePrefix(prefix netip.Prefix, origin uint32) Result {
+	if !v.Ready() {
+		return Result{Status: models.RPKIUnknown}
+	}
+	result := v.table.Load().Validate(prefix, origin)
+	atomic.AddUint64(&v.validations, 1)
+	if result.Status == models.RPKIInvalid {
+		atomic.AddUint64(&v.invalids, 1)
+	}
+	return result
+}
+
+// Stats returns validator statistics.
+func (v *Validator) Stats() map[string]interface{} {
+	stats := map[string]interface{}{
+		"ready":       v.Ready(),
+		"validations": atomic.LoadUint64(&v.validations),
+		"invalids":    atomic.LoadUint64(&v.invalids),
+	}
+	if t := v.table.Load(); t != nil {
+		stats["vrps"] = t.count
+		stats["version"] = t.version
+		stats["loaded_at"] = t.loaded
+	}
+	return stats
+}
@@ -0,0 +1,116 @@
+package rpki
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+	"github.com/hervehildenbrand/bgp-radar/pkg/trie"
+)
+
+func mustVRP(t *testing.T, prefix string, maxLen int, asn uint32) VRP {
+	t.Helper()
+	p, err := trie.ParsePrefix(prefix)
+	if err != nil {
+		t.Fatalf("ParsePrefix(%s): %v", prefix, err)
+	}
+	return VRP{Prefix: p, MaxLength: maxLen, ASN: asn}
+}
+
+func TestValidator_Validate(t *testing.T) {
+	v := NewValidator()
+
+	// Nothing loaded yet
+	if got := v.Validate("1.1.1.0/24", 13335); got.Status != models.RPKIUnknown {
+		t.Errorf("Validate before load = %s, want %s", got.Status, models.RPKIUnknown)
+	}
+
+	v.Replace(NewTable([]VRP{
+		mustVRP(t, "1.1.1.0/24", 24, 13335),
+		mustVRP(t, "8.8.8.0/22", 24, 15169),
+		mustVRP(t, "192.0.2.0/24", 24, 0), // AS0: never routed
+		mustVRP(t, "2606:4700::/32", 48, 13335),
+	}, "test"))
+
+	tests := []struct {
+		name   string
+		prefix string
+		origin uint32
+		status string
+		reason string
+	}{
+		{"valid exact", "1.1.1.0/24", 13335, models.RPKIValid, ""},
+		{"valid within maxLength", "8.8.8.0/24", 15169, models.RPKIValid, ""},
+		{"invalid origin", "1.1.1.0/24", 64512, models.RPKIInvalid, ReasonASN},
+		{"invalid length", "1.1.1.128/25", 13335, models.RPKIInvalid, ReasonLength},
+		{"invalid more-specific other origin", "8.8.8.0/24", 64512, models.RPKIInvalid, ReasonASN},
+		{"AS0", "192.0.2.0/24", 64512, models.RPKIInvalid, ReasonASN},
+		{"not found", "203.0.113.0/24", 64512, models.RPKINotFound, ""},
+		{"valid IPv6", "2606:4700:10::/48", 13335, models.RPKIValid, ""},
+		{"invalid IPv6 length", "2606:4700:10::/64", 13335, models.RPKIInvalid, ReasonLength},
+		{"bad prefix", "not-a-prefix", 13335, models.RPKIUnknown, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := v.Validate(tt.prefix, tt.origin)
+			if got.Status != tt.status || got.Reason != tt.reason {
+				t.Errorf("Validate(%s, %d) = %+v, want {%s %s}", tt.prefix, tt.origin, got, tt.status, tt.reason)
+			}
+		})
+	}
+}
+
+func TestValidator_Nil(t *testing.T) {
+	var v *Validator
+	if got := v.Validate("1.1.1.0/24", 13335); got.Status != models.RPKIUnknown {
+		t.Errorf("nil Validator = %s, want %s", got.Status, models.RPKIUnknown)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	// Mix of rpki-client (numeric ASN) and Routinator ("AS" prefix) styles
+	content := `{
+		"metadata": {"buildtime": "2024-01-15T10:00:00Z"},
+		"roas": [
+			{"asn": 13335, "prefix": "1.1.1.0/24", "maxLength": 24, "ta": "apnic"},
+			{"asn": "AS15169", "prefix": "8.8.8.0/24", "maxLength": 24, "ta": "arin"},
+			{"asn": "bogus", "prefix": "9.9.9.0/24", "maxLength": 24},
+			{"asn": 64512, "prefix": "not-a-prefix", "maxLength": 24}
+		]
+	}`
+	path := filepath.Join(t.TempDir(), "vrps.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write VRP file: %v", err)
+	}
+
+	vrps, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	if len(vrps) != 2 {
+		t.Fatalf("Expected 2 VRPs, got %d", len(vrps))
+	}
+	if vrps[1].ASN != 15169 || vrps[1].Prefix.String() != "8.8.8.0/24" {
+		t.Errorf("Unexpected VRP: %+v", vrps[1])
+	}
+
+	v := NewValidator()
+	source := NewFileSource(path, 0, v)
+	if err := source.Load(); err != nil {
+		t.Fatalf("FileSource.Load() error = %v", err)
+	}
+	if got := v.Validate("8.8.8.0/24", 15169); got.Status != models.RPKIValid {
+		t.Errorf("Validate after file load = %s, want valid", got.Status)
+	}
+}
+
+func TestLoadFile_Invalid(t *testing.T) {
+	if _, err := LoadFile("/nonexistent/vrps.json"); err == nil {
+		t.Error("Expected error for nonexistent file")
+	}
+	if _, err := ParseJSON([]byte("not json")); err == nil {
+		t.Error("Expected error for malformed JSON")
+	}
+}
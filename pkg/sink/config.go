@@ -0,0 +1,229 @@
+package sink
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/database"
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// Sink kinds
+const (
+	KindLog      = "log"
+	KindJSONL    = "jsonl"
+	KindPostgres = "postgres"
+	KindRedis    = "redis"
+	KindWebhook  = "webhook"
+)
+
+// Config describes a sink: its kind, name, filter, queue size and
+// kind-specific options.
+type Config struct {
+	Kind      string
+	Name      string // Defaults to Kind
+	Filter    Filter
+	QueueSize int               // 0 for DefaultQueueSize
+	Options   map[string]string // e.g. path, stream, url, format
+}
+
+// kindOptions lists the options accepted by each kind.
+var kindOptions = map[string][]string{
+	KindLog:      nil,
+	KindJSONL:    {"path"},
+	KindPostgres: nil,
+	KindRedis:    {"stream", "maxlen", "channel"},
+	KindWebhook:  {"url", "format", "routing-key", "secret", "timeout", "retries", "backoff", "rate", "burst"},
+}
+
+// Option is a sink option as a key and value.
+type Option struct {
+	Key, Value string
+}
+
+// ParseSpec parses a sink specification: the kind followed by
+// comma-separated key=value options, e.g.
+// "webhook,url=https://example.net/hook,min-severity=high,type=hijack".
+// Filter options (type, country, asn, owner) may be repeated to match any of
+// several values.
+func ParseSpec(spec string) (Config, error) {
+	parts := strings.Split(spec, ",")
+	kind := strings.TrimSpace(parts[0])
+	options := make([]Option, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || value == "" {
+			return Config{}, fmt.Errorf("sink %s: expected key=value, got %q", kind, part)
+		}
+		options = append(options, Option{Key: key, Value: value})
+	}
+	return NewConfig(kind, options)
+}
+
+// NewConfig creates the config of a sink from its kind and options, as
+// given in a specification or a configuration file.
+func NewConfig(kind string, options []Option) (Config, error) {
+	cfg := Config{Kind: kind, Options: make(map[string]string)}
+	allowed, ok := kindOptions[cfg.Kind]
+	if !ok {
+		return Config{}, fmt.Errorf("unknown sink kind %q", cfg.Kind)
+	}
+	cfg.Name = cfg.Kind
+
+	for _, option := range options {
+		key, value := option.Key, option.Value
+		if value == "" {
+			return Config{}, fmt.Errorf("sink %s: empty option %q", cfg.Kind, key)
+		}
+		switch key {
+		case "name":
+			cfg.Name = value
+		case "queue":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return Config{}, fmt.Errorf("sink %s: invalid queue size %q", cfg.Kind, value)
+			}
+			cfg.QueueSize = n
+		case "type":
+			cfg.Filter.Types = append(cfg.Filter.Types, value)
+		case "min-severity":
+			if models.SeverityRank(value) < 0 {
+				return Config{}, fmt.Errorf("sink %s: invalid severity %q", cfg.Kind, value)
+			}
+			cfg.Filter.MinSeverity = value
+		case "country":
+			cfg.Filter.Countries = append(cfg.Filter.Countries, strings.ToUpper(value))
+		case "asn":
+			asn, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(value), "AS"), 10, 32)
+			if err != nil {
+				return Config{}, fmt.Errorf("sink %s: invalid ASN %q", cfg.Kind, value)
+			}
+			cfg.Filter.ASNs = append(cfg.Filter.ASNs, uint32(asn))
+		case "owner":
+			cfg.Filter.Owners = append(cfg.Filter.Owners, value)
+		default:
+			if !contains(allowed, key) {
+				return Config{}, fmt.Errorf("sink %s: unknown option %q", cfg.Kind, key)
+			}
+			cfg.Options[key] = value
+		}
+	}
+	return cfg, nil
+}
+
+// Deps holds the shared clients sinks may need.
+type Deps struct {
+	Database *database.EventWriter // Required by postgres sinks, which do not stop it
+	Redis    *redis.Client         // Required by redis sinks
+}
+
+// Build creates the sink described by the config.
+func (c Config) Build(deps Deps) (Sink, error) {
+	switch c.Kind {
+	case KindLog:
+		return NewLogSink(), nil
+	case KindJSONL:
+		return NewJSONLSink(c.Options["path"])
+	case KindPostgres:
+		if deps.Database == nil {
+			return nil, errors.New("postgres sink requires a database connection")
+		}
+		return sharedWriter{deps.Database}, nil
+	case KindRedis:
+		if deps.Redis == nil {
+			return nil, errors.New("redis sink requires a Redis connection")
+		}
+		cfg := RedisConfig{Stream: c.Options["stream"], Channel: c.Options["channel"]}
+		if v := c.Options["maxlen"]; v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid maxlen %q", v)
+			}
+			cfg.MaxLen = n
+		}
+		return NewRedisSink(deps.Redis, cfg), nil
+	case KindWebhook:
+		cfg, err := c.webhookConfig()
+		if err != nil {
+			return nil, err
+		}
+		return NewWebhookSink(cfg)
+	}
+	return nil, fmt.Errorf("unknown sink kind %q", c.Kind)
+}
+
+// sharedWriter is a database writer used as a sink. The writer outlives the
+// sinks rebuilt on configuration reloads, so stopping the sink leaves it
+// running; its owner stops it.
+type sharedWriter struct {
+	*database.EventWriter
+}
+
+// Stop does nothing.
+func (sharedWriter) Stop() {}
+
+// webhookConfig converts webhook options to a WebhookConfig.
+func (c Config) webhookConfig() (WebhookConfig, error) {
+	cfg := WebhookConfig{
+		URL:        c.Options["url"],
+		Format:     c.Options["format"],
+		RoutingKey: c.Options["routing-key"],
+		Secret:     c.Options["secret"],
+	}
+	for key, d := range map[string]*time.Duration{"timeout": &cfg.Timeout, "backoff": &cfg.Backoff} {
+		if v := c.Options[key]; v != "" {
+			var err error
+			if *d, err = time.ParseDuration(v); err != nil {
+				return cfg, fmt.Errorf("invalid %s %q", key, v)
+			}
+		}
+	}
+	if v := c.Options["retries"]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("invalid retries %q", v)
+		}
+		cfg.Retries = n
+		if n == 0 {
+			cfg.Retries = -1
+		}
+	}
+	if v := c.Options["rate"]; v != "" {
+		rate, err := parseRate(v)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Rate = rate
+	}
+	if v := c.Options["burst"]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return cfg, fmt.Errorf("invalid burst %q", v)
+		}
+		cfg.Burst = n
+	}
+	return cfg, nil
+}
+
+// parseRate parses a request rate as requests per second, or per minute or
+// hour with a "/m" or "/h" suffix, e.g. "1", "30/m".
+func parseRate(s string) (float64, error) {
+	value, unit, _ := strings.Cut(s, "/")
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate <= 0 {
+		return 0, fmt.Errorf("invalid rate %q", s)
+	}
+	switch unit {
+	case "", "s":
+		return rate, nil
+	case "m":
+		return rate / 60, nil
+	case "h":
+		return rate / 3600, nil
+	}
+	return 0, fmt.Errorf("invalid rate unit in %q", s)
+}
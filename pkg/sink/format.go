@@ -0,0 +1,201 @@
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+// Webhook payload formats
+const (
+	FormatGeneric   = "generic"
+	FormatSlack     = "slack"
+	FormatTeams     = "teams"
+	FormatPagerDuty = "pagerduty"
+)
+
+// PagerDutyEventsURL is the PagerDuty Events API v2 endpoint.
+const PagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// formatFunc builds the request body for an event.
+type formatFunc func(event models.BGPEvent, cfg WebhookConfig) ([]byte, error)
+
+var formats = map[string]formatFunc{
+	FormatGeneric:   formatGeneric,
+	FormatSlack:     formatSlack,
+	FormatTeams:     formatTeams,
+	FormatPagerDuty: formatPagerDuty,
+}
+
+// formatGeneric sends the event Record.
+func formatGeneric(event models.BGPEvent, _ WebhookConfig) ([]byte, error) {
+	return json.Marshal(NewRecord(event))
+}
+
+// summary describes an event in one line, e.g.
+// "[HIGH] hijack of 1.1.1.0/24 (AS13335, AU)".
+func summary(event models.BGPEvent) string {
+	var b strings.Builder
+	if event.IsActive {
+		fmt.Fprintf(&b, "[%s] ", strings.ToUpper(event.Severity))
+	} else {
+		b.WriteString("Resolved: ")
+	}
+	b.WriteString(strings.ReplaceAll(event.EventType, "_", " "))
+	if event.AffectedPrefix != "" {
+		b.WriteString(" of " + event.AffectedPrefix)
+	}
+	var subject []string
+	if event.AffectedASN != 0 {
+		subject = append(subject, fmt.Sprintf("AS%d", event.AffectedASN))
+	}
+	if event.CountryCode != "" && event.CountryCode != "XX" {
+		subject = append(subject, event.CountryCode)
+	}
+	if len(subject) > 0 {
+		b.WriteString(" (" + strings.Join(subject, ", ") + ")")
+	}
+	return b.String()
+}
+
+// fact is a labeled value shown in chat messages.
+type fact struct {
+	title, value string
+}
+
+// facts lists the event attributes shown in chat messages.
+func facts(event models.BGPEvent) []fact {
+	out := []fact{
+		{"Type", event.EventType},
+		{"Severity", event.Severity},
+		{"Category", event.EventCategory},
+	}
+	if event.AffectedPrefix != "" {
+		out = append(out, fact{"Prefix", event.AffectedPrefix})
+	}
+	if event.AffectedASN != 0 {
+		out = append(out, fact{"Affected ASN", fmt.Sprintf("AS%d", event.AffectedASN)})
+	}
+	if event.CountryCode != "" {
+		out = append(out, fact{"Country", event.CountryCode})
+	}
+	if event.RPKIStatus != "" {
+		out = append(out, fact{"RPKI", event.RPKIStatus})
+	}
+	out = append(out, fact{"Detected", event.DetectedAt.UTC().Format(time.RFC3339)})
+	if !event.EndedAt.IsZero() {
+		out = append(out, fact{"Ended", event.EndedAt.UTC().Format(time.RFC3339)})
+	}
+	return out
+}
+
+// formatSlack builds a Slack incoming webhook message with Block Kit.
+func formatSlack(event models.BGPEvent, _ WebhookConfig) ([]byte, error) {
+	text := summary(event)
+	fields := make([]map[string]string, 0, 10)
+	for _, f := range facts(event) {
+		fields = append(fields, map[string]string{"type": "mrkdwn", "text": "*" + f.title + "*\n" + f.value})
+	}
+	return json.Marshal(map[string]interface{}{
+		"text": text,
+		"blocks": []interface{}{
+			map[string]interface{}{
+				"type": "header",
+				"text": map[string]string{"type": "plain_text", "text": text},
+			},
+			map[string]interface{}{
+				"type":   "section",
+				"fields": fields,
+			},
+		},
+	})
+}
+
+// formatTeams builds a Microsoft Teams incoming webhook message carrying an
+// Adaptive Card.
+func formatTeams(event models.BGPEvent, _ WebhookConfig) ([]byte, error) {
+	color := "Good"
+	if event.IsActive {
+		color = "Warning"
+		if models.SeverityRank(event.Severity) >= models.SeverityRank(models.SeverityHigh) {
+			color = "Attention"
+		}
+	}
+	factSet := make([]map[string]string, 0, 10)
+	for _, f := range facts(event) {
+		factSet = append(factSet, map[string]string{"title": f.title, "value": f.value})
+	}
+	return json.Marshal(map[string]interface{}{
+		"type": "message",
+		"attachments": []interface{}{
+			map[string]interface{}{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content": map[string]interface{}{
+					"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+					"type":    "AdaptiveCard",
+					"version": "1.4",
+					"body": []interface{}{
+						map[string]interface{}{
+							"type":   "TextBlock",
+							"text":   summary(event),
+							"weight": "Bolder",
+							"size":   "Medium",
+							"color":  color,
+							"wrap":   true,
+						},
+						map[string]interface{}{"type": "FactSet", "facts": factSet},
+					},
+				},
+			},
+		},
+	})
+}
+
+// pagerDutySeverity maps event severities to PagerDuty's.
+var pagerDutySeverity = map[string]string{
+	models.SeverityCritical: "critical",
+	models.SeverityHigh:     "error",
+	models.SeverityMedium:   "warning",
+	models.SeverityLow:      "info",
+}
+
+// DedupKey identifies an anomaly across its start and end events: its type,
+// affected ASN and prefix, as the database matches end events.
+func DedupKey(event models.BGPEvent) string {
+	return fmt.Sprintf("bgp-radar/%s/%d/%s", event.EventType, event.AffectedASN, event.AffectedPrefix)
+}
+
+// formatPagerDuty builds a PagerDuty Events API v2 event: a trigger for an
+// active event, a resolve for its end.
+func formatPagerDuty(event models.BGPEvent, cfg WebhookConfig) ([]byte, error) {
+	body := map[string]interface{}{
+		"routing_key":  cfg.RoutingKey,
+		"dedup_key":    DedupKey(event),
+		"event_action": "resolve",
+	}
+	if event.IsActive {
+		severity, ok := pagerDutySeverity[event.Severity]
+		if !ok {
+			severity = "warning"
+		}
+		source := event.AffectedPrefix
+		if source == "" {
+			source = "bgp-radar"
+		}
+		body["event_action"] = "trigger"
+		body["payload"] = map[string]interface{}{
+			"summary":        summary(event),
+			"source":         source,
+			"severity":       severity,
+			"timestamp":      event.DetectedAt.UTC().Format(time.RFC3339),
+			"component":      fmt.Sprintf("AS%d", event.AffectedASN),
+			"class":          event.EventType,
+			"group":          event.EventCategory,
+			"custom_details": NewRecord(event),
+		}
+	}
+	return json.Marshal(body)
+}
@@ -0,0 +1,124 @@
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+// SchemaVersion is the version of the Record JSON schema. Fields may be
+// added within a version; it is incremented when fields are removed, renamed
+// or change meaning.
+const SchemaVersion = 1
+
+// Record is the JSON representation of an event written by the sinks.
+type Record struct {
+	SchemaVersion   int                    `json:"schema_version"`
+	ID              string                 `json:"id,omitempty"`
+	Type            string                 `json:"type"`
+	Severity        string                 `json:"severity"`
+	Category        string                 `json:"category"`
+	CountryCode     string                 `json:"country_code,omitempty"`
+	AffectedASN     uint32                 `json:"affected_asn"`
+	AffectedPrefix  string                 `json:"affected_prefix"`
+	RPKIStatus      string                 `json:"rpki_status"`
+	IsCrossBorder   bool                   `json:"is_cross_border,omitempty"`
+	AttackerCountry string                 `json:"attacker_country,omitempty"`
+	VictimCountry   string                 `json:"victim_country,omitempty"`
+	IsActive        bool                   `json:"is_active"`
+	DetectedAt      time.Time              `json:"detected_at"`
+	EndedAt         *time.Time             `json:"ended_at,omitempty"`
+	Details         map[string]interface{} `json:"details"`
+}
+
+// NewRecord converts an event to its JSON representation.
+func NewRecord(event models.BGPEvent) Record {
+	r := Record{
+		SchemaVersion:   SchemaVersion,
+		ID:              event.ID,
+		Type:            event.EventType,
+		Severity:        event.Severity,
+		Category:        event.EventCategory,
+		CountryCode:     event.CountryCode,
+		AffectedASN:     event.AffectedASN,
+		AffectedPrefix:  event.AffectedPrefix,
+		RPKIStatus:      event.RPKIStatus,
+		IsCrossBorder:   event.IsCrossBorder,
+		AttackerCountry: event.AttackerCountry,
+		VictimCountry:   event.VictimCountry,
+		IsActive:        event.IsActive,
+		DetectedAt:      event.DetectedAt,
+		Details:         event.Details,
+	}
+	if !event.EndedAt.IsZero() {
+		endedAt := event.EndedAt
+		r.EndedAt = &endedAt
+	}
+	return r
+}
+
+// LogSink logs events as JSON through the standard logger.
+type LogSink struct{}
+
+// NewLogSink creates a log sink.
+func NewLogSink() *LogSink {
+	return &LogSink{}
+}
+
+// Write logs the event.
+func (s *LogSink) Write(event models.BGPEvent) error {
+	b, err := json.Marshal(NewRecord(event))
+	if err != nil {
+		return err
+	}
+	log.Printf("EVENT: %s", b)
+	return nil
+}
+
+// Stop does nothing.
+func (s *LogSink) Stop() {}
+
+// JSONLSink writes events as JSON lines to a file or stdout.
+type JSONLSink struct {
+	path string
+	file *os.File
+}
+
+// NewJSONLSink creates a sink appending to the file at path, or writing to
+// stdout if path is "" or "-".
+func NewJSONLSink(path string) (*JSONLSink, error) {
+	if path == "" || path == "-" {
+		return &JSONLSink{path: "-", file: os.Stdout}, nil
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	return &JSONLSink{path: path, file: file}, nil
+}
+
+// Write appends the event as one line.
+func (s *JSONLSink) Write(event models.BGPEvent) error {
+	b, err := json.Marshal(NewRecord(event))
+	if err != nil {
+		return err
+	}
+	_, err = s.file.Write(append(b, '\n'))
+	return err
+}
+
+// Stop closes the file, unless writing to stdout.
+func (s *JSONLSink) Stop() {
+	if s.file != os.Stdout {
+		s.file.Close()
+	}
+}
+
+// Stats returns the output path.
+func (s *JSONLSink) Stats() map[string]interface{} {
+	return map[string]interface{}{"path": s.path}
+}
@@ -0,0 +1,101 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis defaults
+const (
+	DefaultRedisStream = "bgp-radar:events"
+	DefaultRedisMaxLen = 100000
+)
+
+const redisTimeout = 5 * time.Second
+
+// RedisConfig configures a Redis destination.
+type RedisConfig struct {
+	Stream string // Stream events are added to (default DefaultRedisStream)
+	MaxLen int64  // Approximate stream length kept (0 for DefaultRedisMaxLen)
+	// Channel, if set, is the prefix of the pub/sub channels events are also
+	// published to, one per event type: "<channel>:<type>".
+	Channel string
+}
+
+// RedisSink adds events to a Redis stream, trimmed to about MaxLen entries,
+// and optionally publishes them to a pub/sub channel per event type.
+//
+// Stream entries hold the schema_version, type and severity fields, for
+// consumers to route on without decoding, and the event field holding the
+// Record JSON. Pub/sub messages are the Record JSON.
+type RedisSink struct {
+	client *redis.Client
+	cfg    RedisConfig
+}
+
+// NewRedisSink creates a Redis sink.
+func NewRedisSink(client *redis.Client, cfg RedisConfig) *RedisSink {
+	if cfg.Stream == "" {
+		cfg.Stream = DefaultRedisStream
+	}
+	if cfg.MaxLen <= 0 {
+		cfg.MaxLen = DefaultRedisMaxLen
+	}
+	return &RedisSink{client: client, cfg: cfg}
+}
+
+// Write adds the event to the stream, then publishes it.
+func (s *RedisSink) Write(event models.BGPEvent) error {
+	record := NewRecord(event)
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	if err := s.client.XAdd(ctx, s.streamArgs(record, b)).Err(); err != nil {
+		return err
+	}
+	if s.cfg.Channel != "" {
+		return s.client.Publish(ctx, s.channel(record.Type), b).Err()
+	}
+	return nil
+}
+
+// streamArgs builds the XADD arguments for a record and its JSON.
+func (s *RedisSink) streamArgs(record Record, b []byte) *redis.XAddArgs {
+	return &redis.XAddArgs{
+		Stream: s.cfg.Stream,
+		MaxLen: s.cfg.MaxLen,
+		Approx: true,
+		Values: []interface{}{
+			"schema_version", strconv.Itoa(record.SchemaVersion),
+			"type", record.Type,
+			"severity", record.Severity,
+			"event", string(b),
+		},
+	}
+}
+
+// channel returns the pub/sub channel for an event type.
+func (s *RedisSink) channel(eventType string) string {
+	return s.cfg.Channel + ":" + eventType
+}
+
+// Stop does nothing; the Redis client is shared with the detectors.
+func (s *RedisSink) Stop() {}
+
+// Stats returns the stream and channel names.
+func (s *RedisSink) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"stream":  s.cfg.Stream,
+		"maxlen":  s.cfg.MaxLen,
+		"channel": s.cfg.Channel,
+	}
+}
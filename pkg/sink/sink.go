@@ -0,0 +1,261 @@
+// Package sink delivers detected events to outputs such as the log, JSONL
+// files, PostgreSQL, Redis and webhooks. Each output has its own bounded
+// queue and filter, so a slow output never stalls the others.
+package sink
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+// DefaultQueueSize is the number of events buffered per sink.
+const DefaultQueueSize = 1000
+
+// Sink is an event output. Implemented by database.EventWriter, LogSink,
+// JSONLSink, RedisSink and WebhookSink.
+type Sink interface {
+	// Write delivers an event. Each sink is written from its own
+	// goroutine, so Write may block.
+	Write(event models.BGPEvent) error
+	// Stop flushes pending events and releases the sink.
+	Stop()
+}
+
+// Filter selects the events delivered to a sink. Zero fields match any
+// event; list fields match any of their values.
+type Filter struct {
+	Types       []string
+	MinSeverity string
+	Countries   []string
+	ASNs        []uint32 // Affected ASNs
+	Owners      []string // Watchlist owners, from details.owner
+}
+
+// Matches reports whether event is selected by the filter.
+func (f Filter) Matches(event models.BGPEvent) bool {
+	if len(f.Types) > 0 && !contains(f.Types, event.EventType) {
+		return false
+	}
+	if f.MinSeverity != "" && models.SeverityRank(event.Severity) < models.SeverityRank(f.MinSeverity) {
+		return false
+	}
+	if len(f.Countries) > 0 && !contains(f.Countries, event.CountryCode) {
+		return false
+	}
+	if len(f.ASNs) > 0 && !contains(f.ASNs, event.AffectedASN) {
+		return false
+	}
+	if len(f.Owners) > 0 {
+		owner, _ := event.Details["owner"].(string)
+		if !contains(f.Owners, owner) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains[T comparable](values []T, v T) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// output is a sink with its queue and counters.
+type output struct {
+	name   string
+	sink   Sink
+	filter Filter
+	queue  chan models.BGPEvent
+
+	written  atomic.Uint64
+	filtered atomic.Uint64
+	dropped  atomic.Uint64
+	errors   atomic.Uint64
+}
+
+// Fanout writes every event to each sink whose filter matches it. Events
+// are queued per sink and dropped when a sink's queue is full.
+type Fanout struct {
+	outputs []*output
+	wg      sync.WaitGroup
+	running atomic.Bool
+}
+
+// NewFanout creates an empty fanout.
+func NewFanout() *Fanout {
+	return &Fanout{}
+}
+
+// Add registers a sink under name with its filter and queue size (0 for
+// DefaultQueueSize). It must be called before Start.
+func (f *Fanout) Add(name string, s Sink, filter Filter, queueSize int) {
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	f.outputs = append(f.outputs, &output{
+		name:   name,
+		sink:   s,
+		filter: filter,
+		queue:  make(chan models.BGPEvent, queueSize),
+	})
+}
+
+// Names returns the sink names in registration order.
+func (f *Fanout) Names() []string {
+	names := make([]string, len(f.outputs))
+	for i, o := range f.outputs {
+		names[i] = o.name
+	}
+	return names
+}
+
+// Start begins delivering queued events.
+func (f *Fanout) Start() {
+	if f.running.Swap(true) {
+		return
+	}
+	for _, o := range f.outputs {
+		f.wg.Add(1)
+		go f.deliver(o)
+	}
+}
+
+// Write queues an event for every matching sink without blocking.
+func (f *Fanout) Write(event models.BGPEvent) {
+	for _, o := range f.outputs {
+		if !o.filter.Matches(event) {
+			o.filtered.Add(1)
+			continue
+		}
+		select {
+		case o.queue <- event:
+		default:
+			if o.dropped.Add(1)%1000 == 1 {
+				log.Printf("[sink %s] Queue full, dropped %d events", o.name, o.dropped.Load())
+			}
+		}
+	}
+}
+
+func (f *Fanout) deliver(o *output) {
+	defer f.wg.Done()
+	for event := range o.queue {
+		if err := o.sink.Write(event); err != nil {
+			if o.errors.Add(1)%100 == 1 {
+				log.Printf("[sink %s] Write failed (%d errors): %v", o.name, o.errors.Load(), err)
+			}
+			continue
+		}
+		o.written.Add(1)
+	}
+}
+
+// interrupter is implemented by sinks whose Write may wait, e.g. to retry.
+// Interrupt makes them stop waiting so that Stop is not held up.
+type interrupter interface {
+	Interrupt()
+}
+
+// Stop delivers the queued events, then stops every sink. Sinks that wait
+// to retry are interrupted first: the events still queued are attempted
+// once, without retries. Write must not be called afterwards.
+func (f *Fanout) Stop() {
+	if !f.running.Swap(false) {
+		return
+	}
+	for _, o := range f.outputs {
+		close(o.queue)
+		if s, ok := o.sink.(interrupter); ok {
+			s.Interrupt()
+		}
+	}
+	f.wg.Wait()
+	for _, o := range f.outputs {
+		o.sink.Stop()
+	}
+}
+
+// Stats returns per-sink delivery statistics, keyed by name, including the
+// sink's own statistics if it has any.
+func (f *Fanout) Stats() map[string]interface{} {
+	sinks := make(map[string]interface{}, len(f.outputs))
+	for _, o := range f.outputs {
+		stats := map[string]interface{}{
+			"events_written":  o.written.Load(),
+			"events_filtered": o.filtered.Load(),
+			"events_dropped":  o.dropped.Load(),
+			"errors":          o.errors.Load(),
+			"queue_len":       len(o.queue),
+			"queue_cap":       cap(o.queue),
+		}
+		if s, ok := o.sink.(interface{ Stats() map[string]interface{} }); ok {
+			stats["sink"] = s.Stats()
+		}
+		sinks[o.name] = stats
+	}
+	return map[string]interface{}{"sinks": sinks}
+}
+
+// Switch writes events to a Fanout that may be replaced while events are
+// written, e.g. when the configuration is reloaded.
+type Switch struct {
+	mu      sync.RWMutex
+	current *Fanout
+}
+
+// NewSwitch creates a switch writing to f.
+func NewSwitch(f *Fanout) *Switch {
+	return &Switch{current: f}
+}
+
+// Write queues an event for every matching sink of the current fanout.
+func (s *Switch) Write(event models.BGPEvent) {
+	s.mu.RLock()
+	s.current.Write(event)
+	s.mu.RUnlock()
+}
+
+// Replace starts f, writes the following events to it, then stops the
+// previous fanout once it has delivered its queued events.
+func (s *Switch) Replace(f *Fanout) {
+	f.Start()
+	s.mu.Lock()
+	previous := s.current
+	s.current = f
+	s.mu.Unlock()
+	previous.Stop()
+}
+
+// Names returns the sink names of the current fanout.
+func (s *Switch) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.Names()
+}
+
+// Start starts the current fanout.
+func (s *Switch) Start() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.current.Start()
+}
+
+// Stop stops the current fanout. Write must not be called afterwards.
+func (s *Switch) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current.Stop()
+}
+
+// Stats returns the statistics of the current fanout.
+func (s *Switch) Stats() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.Stats()
+}
@@ -0,0 +1,259 @@
+package sink
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/database"
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+var (
+	_ Sink = (*database.EventWriter)(nil)
+	_ Sink = (*LogSink)(nil)
+	_ Sink = (*JSONLSink)(nil)
+	_ Sink = (*RedisSink)(nil)
+	_ Sink = (*WebhookSink)(nil)
+)
+
+// memorySink records events, optionally blocking until released.
+type memorySink struct {
+	mu      sync.Mutex
+	events  []models.BGPEvent
+	release chan struct{}
+	stopped bool
+}
+
+func (s *memorySink) Write(event models.BGPEvent) error {
+	if s.release != nil {
+		<-s.release
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *memorySink) Stop() { s.stopped = true }
+
+func (s *memorySink) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.events)
+}
+
+func event(eventType, severity, country string, asn uint32) models.BGPEvent {
+	return models.BGPEvent{
+		EventType:      eventType,
+		Severity:       severity,
+		CountryCode:    country,
+		AffectedASN:    asn,
+		AffectedPrefix: "192.0.2.0/24",
+		DetectedAt:     time.Unix(1700000000, 0).UTC(),
+		IsActive:       true,
+	}
+}
+
+func TestFilter(t *testing.T) {
+	f := Filter{Types: []string{"hijack", "leak"}, MinSeverity: "high", Countries: []string{"NL"}, ASNs: []uint32{64500, 64501}}
+
+	tests := []struct {
+		event models.BGPEvent
+		want  bool
+	}{
+		{event("hijack", "critical", "NL", 64501), true},
+		{event("leak", "high", "NL", 64500), true},
+		{event("blackhole", "critical", "NL", 64500), false},
+		{event("hijack", "medium", "NL", 64500), false},
+		{event("hijack", "high", "US", 64500), false},
+		{event("hijack", "high", "NL", 64502), false},
+	}
+	for _, tt := range tests {
+		if got := f.Matches(tt.event); got != tt.want {
+			t.Errorf("Matches(%s %s %s AS%d) = %v, want %v",
+				tt.event.EventType, tt.event.Severity, tt.event.CountryCode, tt.event.AffectedASN, got, tt.want)
+		}
+	}
+	if !(Filter{}).Matches(event("hijack", "low", "", 0)) {
+		t.Error("Expected the empty filter to match any event")
+	}
+
+	owned := event("unauthorized_origin", "critical", "", 64500)
+	owned.Details = map[string]interface{}{"owner": "dns-team"}
+	if !(Filter{Owners: []string{"dns-team"}}).Matches(owned) {
+		t.Error("Expected the owner filter to match the owner's events")
+	}
+	if (Filter{Owners: []string{"noc"}}).Matches(owned) || (Filter{Owners: []string{"noc"}}).Matches(event("hijack", "high", "", 0)) {
+		t.Error("Expected the owner filter to skip other events")
+	}
+}
+
+func TestParseSpec(t *testing.T) {
+	cfg, err := ParseSpec("webhook,name=oncall,url=https://example.net/hook,min-severity=high,type=hijack,type=leak,asn=AS64500,country=nl,owner=noc,queue=50")
+	if err != nil {
+		t.Fatalf("ParseSpec failed: %v", err)
+	}
+	if cfg.Kind != KindWebhook || cfg.Name != "oncall" || cfg.QueueSize != 50 || cfg.Options["url"] != "https://example.net/hook" {
+		t.Errorf("Unexpected config: %+v", cfg)
+	}
+	if strings.Join(cfg.Filter.Types, ",") != "hijack,leak" || cfg.Filter.MinSeverity != "high" ||
+		len(cfg.Filter.Countries) != 1 || cfg.Filter.Countries[0] != "NL" ||
+		len(cfg.Filter.ASNs) != 1 || cfg.Filter.ASNs[0] != 64500 ||
+		len(cfg.Filter.Owners) != 1 || cfg.Filter.Owners[0] != "noc" {
+		t.Errorf("Unexpected filter: %+v", cfg.Filter)
+	}
+
+	for _, spec := range []string{"kafka", "log,path=x", "webhook,url", "jsonl,min-severity=urgent", "log,asn=x", "log,queue=0"} {
+		if _, err := ParseSpec(spec); err == nil {
+			t.Errorf("Expected ParseSpec(%q) to fail", spec)
+		}
+	}
+	if _, err := (Config{Kind: KindPostgres}).Build(Deps{}); err == nil {
+		t.Error("Expected a postgres sink without a database to fail")
+	}
+}
+
+func TestFanout_SlowSinkDoesNotStallOthers(t *testing.T) {
+	slow := &memorySink{release: make(chan struct{})}
+	fast := &memorySink{}
+	filtered := &memorySink{}
+
+	f := NewFanout()
+	f.Add("slow", slow, Filter{}, 1)
+	f.Add("fast", fast, Filter{}, 10)
+	f.Add("critical", filtered, Filter{MinSeverity: "critical"}, 10)
+	f.Start()
+
+	// The slow sink holds one event and queues one; the rest are dropped
+	for i := 0; i < 5; i++ {
+		f.Write(event("hijack", "high", "NL", 64500))
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for fast.count() != 5 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the fast sink to receive 5 events, got %d", fast.count())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(slow.release)
+	f.Stop()
+	if !slow.stopped || !fast.stopped || !filtered.stopped {
+		t.Error("Expected every sink to be stopped")
+	}
+
+	sinks := f.Stats()["sinks"].(map[string]interface{})
+	slowStats := sinks["slow"].(map[string]interface{})
+	if slowStats["events_written"].(uint64)+slowStats["events_dropped"].(uint64) != 5 || slowStats["events_dropped"].(uint64) < 3 {
+		t.Errorf("Expected the slow sink to drop events, got %v", slowStats)
+	}
+	if n := sinks["critical"].(map[string]interface{})["events_filtered"]; n != uint64(5) || filtered.count() != 0 {
+		t.Errorf("Expected 5 filtered events, got %v", n)
+	}
+}
+
+func TestNewConfig(t *testing.T) {
+	cfg, err := NewConfig(KindWebhook, []Option{
+		{"url", "https://example.net/hook?a=1,b=2"},
+		{"type", "hijack"},
+		{"type", "leak"},
+	})
+	if err != nil {
+		t.Fatalf("NewConfig failed: %v", err)
+	}
+	if cfg.Options["url"] != "https://example.net/hook?a=1,b=2" || len(cfg.Filter.Types) != 2 {
+		t.Errorf("Unexpected config: %+v", cfg)
+	}
+	if _, err := NewConfig(KindLog, []Option{{"name", ""}}); err == nil {
+		t.Error("Expected an empty option to fail")
+	}
+}
+
+func TestSwitch_Replace(t *testing.T) {
+	first, second := &memorySink{}, &memorySink{}
+	f1 := NewFanout()
+	f1.Add("first", first, Filter{}, 10)
+	s := NewSwitch(f1)
+	s.Start()
+	s.Write(event("hijack", "high", "NL", 64500))
+
+	f2 := NewFanout()
+	f2.Add("second", second, Filter{}, 10)
+	s.Replace(f2)
+	if first.count() != 1 || !first.stopped {
+		t.Errorf("Expected the replaced sink to deliver its event and stop")
+	}
+	s.Write(event("hijack", "high", "NL", 64500))
+	s.Stop()
+	if second.count() != 1 || first.count() != 1 {
+		t.Errorf("Expected the following events to go to the new sink, got %d/%d", first.count(), second.count())
+	}
+	if names := s.Names(); len(names) != 1 || names[0] != "second" {
+		t.Errorf("Unexpected sink names %v", names)
+	}
+}
+
+func TestJSONLSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	s, err := NewJSONLSink(path)
+	if err != nil {
+		t.Fatalf("NewJSONLSink failed: %v", err)
+	}
+	s.Write(event("hijack", "high", "NL", 64500))
+	ended := event("hijack", "high", "NL", 64500)
+	ended.IsActive = false
+	ended.EndedAt = ended.DetectedAt.Add(time.Hour)
+	s.Write(ended)
+	s.Stop()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d", len(lines))
+	}
+	var r Record
+	if err := json.Unmarshal([]byte(lines[1]), &r); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if r.Type != "hijack" || r.AffectedASN != 64500 || r.IsActive || r.EndedAt == nil {
+		t.Errorf("Unexpected record: %+v", r)
+	}
+}
+
+func TestRedisSink_Message(t *testing.T) {
+	s := NewRedisSink(nil, RedisConfig{Channel: "bgp-radar"})
+	record := NewRecord(event("hijack", "high", "NL", 64500))
+	b, _ := json.Marshal(record)
+
+	args := s.streamArgs(record, b)
+	if args.Stream != DefaultRedisStream || args.MaxLen != DefaultRedisMaxLen || !args.Approx {
+		t.Errorf("Unexpected stream arguments: %+v", args)
+	}
+	values := args.Values.([]interface{})
+	want := []interface{}{"schema_version", "1", "type", "hijack", "severity", "high", "event", string(b)}
+	if len(values) != len(want) {
+		t.Fatalf("Expected %d values, got %v", len(want), values)
+	}
+	for i := range want {
+		if values[i] != want[i] {
+			t.Errorf("Value %d: expected %v, got %v", i, want[i], values[i])
+		}
+	}
+	if ch := s.channel(record.Type); ch != "bgp-radar:hijack" {
+		t.Errorf("Expected channel bgp-radar:hijack, got %s", ch)
+	}
+
+	var decoded map[string]interface{}
+	json.Unmarshal(b, &decoded)
+	if decoded["schema_version"] != float64(SchemaVersion) {
+		t.Errorf("Expected schema_version %d, got %v", SchemaVersion, decoded["schema_version"])
+	}
+}
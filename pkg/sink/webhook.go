@@ -0,0 +1,271 @@
+package sink
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+// Webhook defaults
+const (
+	DefaultWebhookTimeout = 10 * time.Second
+	DefaultWebhookRetries = 3
+	DefaultWebhookBackoff = time.Second
+
+	// maxBackoff caps the delay between retries, including delays
+	// requested by the server in a Retry-After header.
+	maxBackoff = time.Minute
+)
+
+// Signature headers set when a webhook secret is configured. The signature
+// is the hex HMAC-SHA256 of the timestamp, a dot and the request body.
+const (
+	SignatureHeader = "X-BGP-Radar-Signature"
+	TimestampHeader = "X-BGP-Radar-Timestamp"
+)
+
+// WebhookConfig configures a webhook destination.
+type WebhookConfig struct {
+	URL        string // Defaults to the PagerDuty Events API for the pagerduty format
+	Format     string // generic (default), slack, teams or pagerduty
+	RoutingKey string // PagerDuty integration key
+	Secret     string // HMAC-SHA256 signing key (optional)
+
+	Timeout time.Duration // Per request (0 for DefaultWebhookTimeout)
+	Retries int           // Retries after a failed request (-1 for none, 0 for DefaultWebhookRetries)
+	Backoff time.Duration // First retry delay, doubled per retry (0 for DefaultWebhookBackoff)
+
+	Rate  float64 // Maximum requests per second (0 for unlimited)
+	Burst int     // Requests allowed at once when under the rate (0 for 1)
+}
+
+// WebhookSink POSTs events to an HTTP endpoint in one of the supported
+// payload formats, retrying failures with exponential backoff. Once
+// interrupted, it no longer waits to retry or for the rate limit.
+type WebhookSink struct {
+	cfg     WebhookConfig
+	format  formatFunc
+	client  *http.Client
+	limiter *limiter
+
+	interrupt     chan struct{}
+	interruptOnce sync.Once
+
+	// Stats
+	requests atomic.Uint64
+	retries  atomic.Uint64
+	failures atomic.Uint64
+}
+
+// NewWebhookSink creates a webhook sink.
+func NewWebhookSink(cfg WebhookConfig) (*WebhookSink, error) {
+	if cfg.Format == "" {
+		cfg.Format = FormatGeneric
+	}
+	format, ok := formats[cfg.Format]
+	if !ok {
+		return nil, fmt.Errorf("unknown webhook format %q", cfg.Format)
+	}
+	if cfg.Format == FormatPagerDuty {
+		if cfg.RoutingKey == "" {
+			return nil, errors.New("pagerduty format requires a routing key")
+		}
+		if cfg.URL == "" {
+			cfg.URL = PagerDutyEventsURL
+		}
+	}
+	if cfg.URL == "" {
+		return nil, errors.New("webhook requires a url")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultWebhookTimeout
+	}
+	if cfg.Retries == 0 {
+		cfg.Retries = DefaultWebhookRetries
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = DefaultWebhookBackoff
+	}
+
+	s := &WebhookSink{
+		cfg:       cfg,
+		format:    format,
+		client:    &http.Client{Timeout: cfg.Timeout},
+		interrupt: make(chan struct{}),
+	}
+	if cfg.Rate > 0 {
+		s.limiter = newLimiter(cfg.Rate, cfg.Burst)
+	}
+	return s, nil
+}
+
+// Write posts the event, retrying network errors, 429 and 5xx responses.
+func (s *WebhookSink) Write(event models.BGPEvent) error {
+	body, err := s.format(event, s.cfg)
+	if err != nil {
+		return err
+	}
+
+	delay := s.cfg.Backoff
+	for attempt := 0; ; attempt++ {
+		if s.limiter != nil {
+			s.limiter.wait(s.interrupt)
+		}
+		retryAfter, err := s.post(body)
+		if err == nil {
+			return nil
+		}
+		var permanent permanentError
+		if errors.As(err, &permanent) || attempt >= s.cfg.Retries {
+			s.failures.Add(1)
+			return err
+		}
+
+		wait := delay
+		if retryAfter > wait {
+			wait = min(retryAfter, maxBackoff)
+		}
+		log.Printf("[webhook] %v, retrying in %v", err, wait)
+		if !s.sleep(wait) {
+			s.failures.Add(1)
+			return fmt.Errorf("%w, not retried: sink stopping", err)
+		}
+		s.retries.Add(1)
+		delay = time.Duration(math.Min(float64(delay*2), float64(maxBackoff)))
+	}
+}
+
+// sleep waits for d, returning false if the sink is interrupted first.
+func (s *WebhookSink) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-s.interrupt:
+		return false
+	}
+}
+
+// Interrupt makes pending and later writes give up waiting to retry or for
+// the rate limit, so that queued events are flushed without delay on
+// shutdown. Each event is still attempted once.
+func (s *WebhookSink) Interrupt() {
+	s.interruptOnce.Do(func() { close(s.interrupt) })
+}
+
+// permanentError is a failure retrying will not fix, e.g. a 4xx response.
+type permanentError struct{ error }
+
+// post sends one request, returning the delay requested by the server in a
+// Retry-After header, if any.
+func (s *WebhookSink) post(body []byte) (time.Duration, error) {
+	req, err := http.NewRequest(http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "bgp-radar")
+	if s.cfg.Secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, ts)
+		req.Header.Set(SignatureHeader, "sha256="+Sign(s.cfg.Secret, ts, body))
+	}
+
+	s.requests.Add(1)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		return 0, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		var retryAfter time.Duration
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			retryAfter = time.Duration(secs) * time.Second
+		}
+		return retryAfter, fmt.Errorf("webhook returned %s", resp.Status)
+	default:
+		return 0, permanentError{fmt.Errorf("webhook returned %s", resp.Status)}
+	}
+}
+
+// Sign returns the hex HMAC-SHA256 signature of a request body sent at the
+// given Unix timestamp, as set in SignatureHeader (after "sha256=").
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Stop interrupts pending writes and releases idle connections.
+func (s *WebhookSink) Stop() {
+	s.Interrupt()
+	s.client.CloseIdleConnections()
+}
+
+// Stats returns request statistics. The URL is left out as it often
+// embeds a secret token.
+func (s *WebhookSink) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"format":   s.cfg.Format,
+		"requests": s.requests.Load(),
+		"retries":  s.retries.Load(),
+		"failures": s.failures.Load(),
+	}
+}
+
+// limiter is a token bucket spacing requests to one destination. It is only
+// used from the sink's delivery goroutine.
+type limiter struct {
+	rate   float64 // Tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(rate float64, burst int) *limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &limiter{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// wait blocks until a request is allowed or interrupt is closed, then takes
+// a token.
+func (l *limiter) wait(interrupt <-chan struct{}) {
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	if l.tokens < 1 {
+		delay := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-interrupt:
+			timer.Stop()
+		}
+		l.tokens = 1
+		l.last = now.Add(delay)
+	}
+	l.tokens--
+}
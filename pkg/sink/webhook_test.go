@@ -0,0 +1,246 @@
+package sink
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// standIn is a local webhook endpoint recording requests and replying with
+// queued status codes (200 once exhausted).
+type standIn struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+	times    []time.Time
+}
+
+func newStandIn(t *testing.T, statuses ...int) *standIn {
+	s := &standIn{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, body)
+		s.times = append(s.times, time.Now())
+		status := http.StatusOK
+		if len(s.statuses) > 0 {
+			status, s.statuses = s.statuses[0], s.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *standIn) body(t *testing.T, i int) map[string]interface{} {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i >= len(s.bodies) {
+		t.Fatalf("Expected at least %d requests, got %d", i+1, len(s.bodies))
+	}
+	var v map[string]interface{}
+	if err := json.Unmarshal(s.bodies[i], &v); err != nil {
+		t.Fatalf("Invalid JSON body %s: %v", s.bodies[i], err)
+	}
+	return v
+}
+
+func newWebhook(t *testing.T, cfg WebhookConfig) *WebhookSink {
+	t.Helper()
+	s, err := NewWebhookSink(cfg)
+	if err != nil {
+		t.Fatalf("NewWebhookSink failed: %v", err)
+	}
+	t.Cleanup(s.Stop)
+	return s
+}
+
+func TestWebhookSink_Generic(t *testing.T) {
+	server := newStandIn(t)
+	s := newWebhook(t, WebhookConfig{URL: server.URL})
+
+	if err := s.Write(event("leak", "medium", "US", 64501)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	body := server.body(t, 0)
+	if body["type"] != "leak" || body["affected_prefix"] != "192.0.2.0/24" {
+		t.Errorf("Unexpected payload: %v", body)
+	}
+	if ct := server.requests[0].Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Unexpected content type %q", ct)
+	}
+	if server.requests[0].Header.Get(SignatureHeader) != "" {
+		t.Error("Expected no signature without a secret")
+	}
+}
+
+func TestWebhookSink_Slack(t *testing.T) {
+	server := newStandIn(t)
+	s := newWebhook(t, WebhookConfig{URL: server.URL, Format: FormatSlack})
+
+	s.Write(event("hijack", "critical", "NL", 64500))
+	body := server.body(t, 0)
+	if body["text"] != "[CRITICAL] hijack of 192.0.2.0/24 (AS64500, NL)" {
+		t.Errorf("Unexpected text %q", body["text"])
+	}
+	blocks := body["blocks"].([]interface{})
+	if len(blocks) != 2 || blocks[0].(map[string]interface{})["type"] != "header" {
+		t.Errorf("Unexpected blocks: %v", blocks)
+	}
+}
+
+func TestWebhookSink_Teams(t *testing.T) {
+	server := newStandIn(t)
+	s := newWebhook(t, WebhookConfig{URL: server.URL, Format: FormatTeams})
+
+	ended := event("blackhole", "low", "", 64500)
+	ended.IsActive = false
+	s.Write(ended)
+	body := server.body(t, 0)
+	card := body["attachments"].([]interface{})[0].(map[string]interface{})
+	if card["contentType"] != "application/vnd.microsoft.card.adaptive" {
+		t.Errorf("Unexpected attachment: %v", card)
+	}
+	title := card["content"].(map[string]interface{})["body"].([]interface{})[0].(map[string]interface{})
+	if title["text"] != "Resolved: blackhole of 192.0.2.0/24 (AS64500)" || title["color"] != "Good" {
+		t.Errorf("Unexpected title: %v", title)
+	}
+}
+
+func TestWebhookSink_PagerDuty(t *testing.T) {
+	server := newStandIn(t)
+	s := newWebhook(t, WebhookConfig{URL: server.URL, Format: FormatPagerDuty, RoutingKey: "key"})
+
+	started := event("hijack", "high", "NL", 64500)
+	ended := started
+	ended.IsActive = false
+	ended.EndedAt = started.DetectedAt.Add(time.Hour)
+	s.Write(started)
+	s.Write(ended)
+
+	trigger, resolve := server.body(t, 0), server.body(t, 1)
+	if trigger["event_action"] != "trigger" || trigger["routing_key"] != "key" {
+		t.Errorf("Unexpected trigger: %v", trigger)
+	}
+	payload := trigger["payload"].(map[string]interface{})
+	if payload["severity"] != "error" || payload["source"] != "192.0.2.0/24" || payload["class"] != "hijack" {
+		t.Errorf("Unexpected trigger payload: %v", payload)
+	}
+	if resolve["event_action"] != "resolve" || resolve["payload"] != nil {
+		t.Errorf("Unexpected resolve: %v", resolve)
+	}
+	if trigger["dedup_key"] != resolve["dedup_key"] || trigger["dedup_key"] == "" {
+		t.Errorf("Expected matching dedup keys, got %v and %v", trigger["dedup_key"], resolve["dedup_key"])
+	}
+
+	if _, err := NewWebhookSink(WebhookConfig{Format: FormatPagerDuty}); err == nil {
+		t.Error("Expected the pagerduty format to require a routing key")
+	}
+}
+
+func TestWebhookSink_Signature(t *testing.T) {
+	server := newStandIn(t)
+	s := newWebhook(t, WebhookConfig{URL: server.URL, Secret: "s3cret"})
+
+	s.Write(event("hijack", "high", "NL", 64500))
+	req := server.requests[0]
+	want := "sha256=" + Sign("s3cret", req.Header.Get(TimestampHeader), server.bodies[0])
+	if got := req.Header.Get(SignatureHeader); got != want {
+		t.Errorf("Expected signature %q, got %q", want, got)
+	}
+	if Sign("other", req.Header.Get(TimestampHeader), server.bodies[0]) == Sign("s3cret", req.Header.Get(TimestampHeader), server.bodies[0]) {
+		t.Error("Expected signatures to depend on the secret")
+	}
+}
+
+func TestWebhookSink_Retry(t *testing.T) {
+	server := newStandIn(t, http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK)
+	s := newWebhook(t, WebhookConfig{URL: server.URL, Retries: 2, Backoff: 10 * time.Millisecond})
+
+	if err := s.Write(event("hijack", "high", "NL", 64500)); err != nil {
+		t.Fatalf("Expected the third attempt to succeed, got %v", err)
+	}
+	if len(server.requests) != 3 {
+		t.Errorf("Expected 3 requests, got %d", len(server.requests))
+	}
+	if gap := server.times[2].Sub(server.times[1]); gap < 20*time.Millisecond {
+		t.Errorf("Expected the backoff to double, second retry after %v", gap)
+	}
+
+	// Client errors are not retried
+	server = newStandIn(t, http.StatusBadRequest)
+	s = newWebhook(t, WebhookConfig{URL: server.URL, Retries: 2, Backoff: time.Millisecond})
+	if err := s.Write(event("hijack", "high", "NL", 64500)); err == nil {
+		t.Error("Expected a 400 response to fail")
+	}
+	if len(server.requests) != 1 {
+		t.Errorf("Expected 1 request, got %d", len(server.requests))
+	}
+	if stats := s.Stats(); stats["failures"] != uint64(1) {
+		t.Errorf("Expected 1 failure, got %v", stats)
+	}
+}
+
+func TestWebhookSink_RateLimit(t *testing.T) {
+	server := newStandIn(t)
+	s := newWebhook(t, WebhookConfig{URL: server.URL, Rate: 20, Burst: 2})
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		s.Write(event("hijack", "high", "NL", 64500))
+	}
+	// Two requests at once, then one every 50ms
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("Expected rate limiting to space requests, 4 took %v", elapsed)
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	for spec, want := range map[string]float64{"2": 2, "0.5/s": 0.5, "30/m": 0.5, "360/h": 0.1} {
+		if got, err := parseRate(spec); err != nil || got != want {
+			t.Errorf("parseRate(%q) = %v, %v, want %v", spec, got, err, want)
+		}
+	}
+	for _, spec := range []string{"0", "x", "5/d"} {
+		if _, err := parseRate(spec); err == nil {
+			t.Errorf("Expected parseRate(%q) to fail", spec)
+		}
+	}
+}
+
+func TestWebhookSink_Interrupt(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+	s := newWebhook(t, WebhookConfig{URL: server.URL, Retries: 2, Backoff: time.Millisecond})
+
+	done := make(chan error, 1)
+	go func() { done <- s.Write(event("hijack", "high", "NL", 64500)) }()
+	time.Sleep(50 * time.Millisecond)
+	s.Interrupt()
+
+	// The hour requested by Retry-After is capped, and interrupted
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Expected the interrupted write to fail")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected Interrupt to end the retry wait")
+	}
+	if stats := s.Stats(); stats["failures"] != uint64(1) || stats["retries"] != uint64(0) {
+		t.Errorf("Expected 1 failure and no retry, got %v", stats)
+	}
+}
@@ -0,0 +1,123 @@
+// Package source combines BGP update feeds, such as RIS Live, BMP and MRT
+// replay, into the single update channel read by the detectors.
+package source
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+)
+
+// Source is a feed of BGP updates. Implemented by rislive.MultiClient,
+// bmp.Station, mrt.Replayer and Merger.
+type Source interface {
+	// Start begins receiving updates.
+	Start()
+	// Stop stops receiving updates and closes the update channel.
+	Stop()
+	// Updates returns the channel of received updates, closed once the
+	// source is stopped or exhausted.
+	Updates() <-chan models.BGPUpdate
+	// Stats returns source statistics.
+	Stats() map[string]interface{}
+}
+
+// Merger runs several sources at once, forwarding their updates into one
+// channel. Updates are tagged with the name of the source they came from,
+// unless already tagged. The channel is closed once every source is
+// exhausted or stopped.
+type Merger struct {
+	names   []string
+	sources []Source
+	counts  []*atomic.Uint64 // Updates forwarded per source
+
+	updates chan models.BGPUpdate
+	wg      sync.WaitGroup
+	running atomic.Bool
+}
+
+// NewMerger creates a merger with the given update channel buffer size.
+func NewMerger(bufferSize int) *Merger {
+	return &Merger{updates: make(chan models.BGPUpdate, bufferSize)}
+}
+
+// Add registers a source under name. It must be called before Start.
+func (m *Merger) Add(name string, src Source) {
+	m.names = append(m.names, name)
+	m.sources = append(m.sources, src)
+	m.counts = append(m.counts, new(atomic.Uint64))
+}
+
+// Names returns the source names in registration order.
+func (m *Merger) Names() []string {
+	return append([]string(nil), m.names...)
+}
+
+// Len returns the number of sources.
+func (m *Merger) Len() int {
+	return len(m.sources)
+}
+
+// Updates returns the merged update channel.
+func (m *Merger) Updates() <-chan models.BGPUpdate {
+	return m.updates
+}
+
+// Start starts every source and forwards their updates.
+func (m *Merger) Start() {
+	if m.running.Swap(true) {
+		return
+	}
+	for i, src := range m.sources {
+		m.wg.Add(1)
+		go m.forward(m.names[i], src, m.counts[i])
+		src.Start()
+	}
+	go func() {
+		m.wg.Wait()
+		close(m.updates)
+	}()
+	log.Printf("Update sources started: %v", m.names)
+}
+
+// forward copies updates from a source until its channel is closed.
+func (m *Merger) forward(name string, src Source, count *atomic.Uint64) {
+	defer m.wg.Done()
+	for update := range src.Updates() {
+		if update.Source == "" {
+			update.Source = name
+		}
+		m.updates <- update
+		count.Add(1)
+	}
+}
+
+// Stop stops every source and waits for their remaining updates to be
+// forwarded.
+func (m *Merger) Stop() {
+	if !m.running.Swap(false) {
+		return
+	}
+	for _, src := range m.sources {
+		src.Stop()
+	}
+	m.wg.Wait()
+}
+
+// Stats returns the statistics of every source, keyed by name, with the
+// number of updates forwarded from each, and the merged channel depth.
+func (m *Merger) Stats() map[string]interface{} {
+	sources := make(map[string]interface{}, len(m.sources))
+	for i, src := range m.sources {
+		stats := src.Stats()
+		stats["updates_forwarded"] = m.counts[i].Load()
+		sources[m.names[i]] = stats
+	}
+	return map[string]interface{}{
+		"sources":     sources,
+		"channel_len": len(m.updates),
+		"channel_cap": cap(m.updates),
+	}
+}
@@ -0,0 +1,84 @@
+package source
+
+import (
+	"testing"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/bmp"
+	"github.com/hervehildenbrand/bgp-radar/pkg/models"
+	"github.com/hervehildenbrand/bgp-radar/pkg/mrt"
+	"github.com/hervehildenbrand/bgp-radar/pkg/rislive"
+)
+
+var (
+	_ Source = (*rislive.MultiClient)(nil)
+	_ Source = (*bmp.Station)(nil)
+	_ Source = (*mrt.Replayer)(nil)
+	_ Source = (*Merger)(nil)
+)
+
+// fakeSource sends a fixed list of updates, then closes its channel.
+type fakeSource struct {
+	updates chan models.BGPUpdate
+	pending []models.BGPUpdate
+	stopped bool
+}
+
+func newFakeSource(updates ...models.BGPUpdate) *fakeSource {
+	return &fakeSource{updates: make(chan models.BGPUpdate, len(updates)), pending: updates}
+}
+
+func (f *fakeSource) Start() {
+	for _, update := range f.pending {
+		f.updates <- update
+	}
+	close(f.updates)
+}
+
+func (f *fakeSource) Stop()                            { f.stopped = true }
+func (f *fakeSource) Updates() <-chan models.BGPUpdate { return f.updates }
+func (f *fakeSource) Stats() map[string]interface{} {
+	return map[string]interface{}{"pending": len(f.pending)}
+}
+
+func TestMerger(t *testing.T) {
+	ris := newFakeSource(
+		models.BGPUpdate{Prefix: "1.1.1.0/24"},
+		models.BGPUpdate{Prefix: "8.8.8.0/24"},
+	)
+	bmpSource := newFakeSource(models.BGPUpdate{Prefix: "9.9.9.0/24", Source: "edge"})
+
+	m := NewMerger(10)
+	m.Add("ris", ris)
+	m.Add("bmp", bmpSource)
+	m.Start()
+
+	// The channel is closed once both sources are exhausted
+	got := make(map[string]string)
+	for update := range m.Updates() {
+		got[update.Prefix] = update.Source
+	}
+	want := map[string]string{"1.1.1.0/24": "ris", "8.8.8.0/24": "ris", "9.9.9.0/24": "edge"}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d updates, got %v", len(want), got)
+	}
+	for prefix, src := range want {
+		if got[prefix] != src {
+			t.Errorf("Expected %s from source %q, got %q", prefix, src, got[prefix])
+		}
+	}
+
+	m.Stop()
+	if !ris.stopped || !bmpSource.stopped {
+		t.Error("Expected every source to be stopped")
+	}
+
+	stats := m.Stats()
+	sources := stats["sources"].(map[string]interface{})
+	risStats := sources["ris"].(map[string]interface{})
+	if risStats["updates_forwarded"] != uint64(2) || risStats["pending"] != 2 {
+		t.Errorf("Unexpected ris stats: %v", risStats)
+	}
+	if stats["channel_cap"] != 10 {
+		t.Errorf("Expected channel capacity 10, got %v", stats["channel_cap"])
+	}
+}
@@ -0,0 +1,378 @@
+package state
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// DefaultSnapshotInterval is how often a local store writes changes to disk.
+const DefaultSnapshotInterval = time.Minute
+
+// bbolt buckets
+var (
+	bucketOrigins = []byte("origins")
+	bucketMOAS    = []byte("moas")
+)
+
+// entryLen is the encoded size of an origin with its expiry.
+const entryLen = 12
+
+// LocalConfig configures a local store.
+type LocalConfig struct {
+	Path             string        // bbolt file; empty keeps state in memory only
+	TTL              time.Duration // 0 for DefaultTTL
+	SnapshotInterval time.Duration // 0 for DefaultSnapshotInterval
+}
+
+// LocalStore keeps state in memory and, with a file, snapshots the changes
+// to an embedded bbolt database every SnapshotInterval and on Close. The
+// snapshot also compacts the state, dropping entries past their TTL, so
+// the state stays bounded by the prefixes seen within one TTL.
+type LocalStore struct {
+	cfg LocalConfig
+	db  *bolt.DB
+	now func() time.Time
+
+	mu      sync.RWMutex
+	origins map[string]originEntry
+	moas    map[string]map[uint32]int64 // prefix -> origin -> expiry (Unix seconds)
+
+	// Prefixes changed since the last snapshot
+	dirtyOrigins map[string]struct{}
+	dirtyMOAS    map[string]struct{}
+
+	stop      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+
+	// Stats
+	snapshots    atomic.Uint64
+	expired      atomic.Uint64
+	errors       atomic.Uint64
+	lastSnapshot atomic.Int64
+}
+
+type originEntry struct {
+	origin  uint32
+	expires int64 // Unix seconds
+}
+
+// NewMemoryStore creates a local store without a file.
+func NewMemoryStore(ttl time.Duration) *LocalStore {
+	s, _ := OpenLocal(LocalConfig{TTL: ttl})
+	return s
+}
+
+// OpenLocal opens a local store, loading the unexpired state of its file,
+// which is created if missing.
+func OpenLocal(cfg LocalConfig) (*LocalStore, error) {
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultTTL
+	}
+	if cfg.SnapshotInterval <= 0 {
+		cfg.SnapshotInterval = DefaultSnapshotInterval
+	}
+	s := &LocalStore{
+		cfg:          cfg,
+		now:          time.Now,
+		origins:      make(map[string]originEntry),
+		moas:         make(map[string]map[uint32]int64),
+		dirtyOrigins: make(map[string]struct{}),
+		dirtyMOAS:    make(map[string]struct{}),
+		stop:         make(chan struct{}),
+	}
+	if cfg.Path == "" {
+		return s, nil
+	}
+
+	db, err := bolt.Open(cfg.Path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", cfg.Path, err)
+	}
+	if err := s.load(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("load %s: %w", cfg.Path, err)
+	}
+	s.db = db
+	return s, nil
+}
+
+// load reads the unexpired entries of db, creating its buckets if needed.
+func (s *LocalStore) load(db *bolt.DB) error {
+	now := s.now().Unix()
+	return db.Update(func(tx *bolt.Tx) error {
+		origins, err := tx.CreateBucketIfNotExists(bucketOrigins)
+		if err != nil {
+			return err
+		}
+		moas, err := tx.CreateBucketIfNotExists(bucketMOAS)
+		if err != nil {
+			return err
+		}
+
+		err = origins.ForEach(func(k, v []byte) error {
+			if len(v) == entryLen {
+				if origin, expires := decodeEntry(v); expires > now {
+					s.origins[string(k)] = originEntry{origin: origin, expires: expires}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return moas.ForEach(func(k, v []byte) error {
+			set := make(map[uint32]int64)
+			for ; len(v) >= entryLen; v = v[entryLen:] {
+				if origin, expires := decodeEntry(v); expires > now {
+					set[origin] = expires
+				}
+			}
+			if len(set) > 0 {
+				s.moas[string(k)] = set
+			}
+			return nil
+		})
+	})
+}
+
+// Start snapshots and compacts the state every SnapshotInterval.
+func (s *LocalStore) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.cfg.SnapshotInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.Snapshot(); err != nil {
+					log.Printf("[state] Snapshot failed: %v", err)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the snapshot loop, writes a final snapshot and closes the file.
+func (s *LocalStore) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		s.wg.Wait()
+		err = s.Snapshot()
+		if s.db != nil {
+			if cerr := s.db.Close(); err == nil {
+				err = cerr
+			}
+		}
+	})
+	return err
+}
+
+// Origin returns the stored origin of prefix.
+func (s *LocalStore) Origin(prefix string) (uint32, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.origins[prefix]
+	if !ok || e.expires <= s.now().Unix() {
+		return 0, false
+	}
+	return e.origin, true
+}
+
+// SetOrigin stores the origin of prefix.
+func (s *LocalStore) SetOrigin(prefix string, origin uint32) {
+	expires := s.expiry()
+	s.mu.Lock()
+	s.origins[prefix] = originEntry{origin: origin, expires: expires}
+	s.dirtyOrigins[prefix] = struct{}{}
+	s.mu.Unlock()
+}
+
+// IsMOAS reports whether origin is in the MOAS set of prefix.
+func (s *LocalStore) IsMOAS(prefix string, origin uint32) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	expires, ok := s.moas[prefix][origin]
+	return ok && expires > s.now().Unix()
+}
+
+// AddMOAS adds origin to the MOAS set of prefix. Like a Redis set, adding
+// an origin renews the expiry of the whole set.
+func (s *LocalStore) AddMOAS(prefix string, origin uint32) {
+	expires := s.expiry()
+	s.mu.Lock()
+	set, ok := s.moas[prefix]
+	if !ok {
+		set = make(map[uint32]int64)
+		s.moas[prefix] = set
+	}
+	set[origin] = expires
+	for asn := range set {
+		set[asn] = expires
+	}
+	s.dirtyMOAS[prefix] = struct{}{}
+	s.mu.Unlock()
+}
+
+// MOAS returns the MOAS set of prefix.
+func (s *LocalStore) MOAS(prefix string) []uint32 {
+	now := s.now().Unix()
+	s.mu.RLock()
+	origins := make([]uint32, 0, len(s.moas[prefix]))
+	for asn, expires := range s.moas[prefix] {
+		if expires > now {
+			origins = append(origins, asn)
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(origins, func(i, j int) bool { return origins[i] < origins[j] })
+	return origins
+}
+
+func (s *LocalStore) expiry() int64 {
+	return s.now().Add(s.cfg.TTL).Unix()
+}
+
+// Compact drops the entries past their TTL.
+func (s *LocalStore) Compact() {
+	now := s.now().Unix()
+	var expired uint64
+
+	s.mu.Lock()
+	for prefix, e := range s.origins {
+		if e.expires <= now {
+			delete(s.origins, prefix)
+			s.dirtyOrigins[prefix] = struct{}{}
+			expired++
+		}
+	}
+	for prefix, set := range s.moas {
+		for asn, expires := range set {
+			if expires <= now {
+				delete(set, asn)
+				s.dirtyMOAS[prefix] = struct{}{}
+				expired++
+			}
+		}
+		if len(set) == 0 {
+			delete(s.moas, prefix)
+		}
+	}
+	s.mu.Unlock()
+	s.expired.Add(expired)
+}
+
+// Snapshot compacts the state and, with a file, writes the prefixes changed
+// since the last snapshot. Changes are kept for the next snapshot if the
+// write fails.
+func (s *LocalStore) Snapshot() error {
+	s.Compact()
+	if s.db == nil {
+		return nil
+	}
+
+	// Copy the changes under the lock, write them without it
+	s.mu.Lock()
+	origins := make(map[string][]byte, len(s.dirtyOrigins))
+	for prefix := range s.dirtyOrigins {
+		var v []byte // nil deletes the key
+		if e, ok := s.origins[prefix]; ok {
+			v = encodeEntry(nil, e.origin, e.expires)
+		}
+		origins[prefix] = v
+	}
+	moas := make(map[string][]byte, len(s.dirtyMOAS))
+	for prefix := range s.dirtyMOAS {
+		var v []byte
+		for asn, expires := range s.moas[prefix] {
+			v = encodeEntry(v, asn, expires)
+		}
+		moas[prefix] = v
+	}
+	s.dirtyOrigins = make(map[string]struct{})
+	s.dirtyMOAS = make(map[string]struct{})
+	s.mu.Unlock()
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if err := write(tx.Bucket(bucketOrigins), origins); err != nil {
+			return err
+		}
+		return write(tx.Bucket(bucketMOAS), moas)
+	})
+	if err != nil {
+		s.errors.Add(1)
+		s.mu.Lock()
+		for prefix := range origins {
+			s.dirtyOrigins[prefix] = struct{}{}
+		}
+		for prefix := range moas {
+			s.dirtyMOAS[prefix] = struct{}{}
+		}
+		s.mu.Unlock()
+		return err
+	}
+	s.snapshots.Add(1)
+	s.lastSnapshot.Store(s.now().Unix())
+	return nil
+}
+
+// write puts the values in the bucket, deleting the keys of nil values.
+func write(b *bolt.Bucket, values map[string][]byte) error {
+	for k, v := range values {
+		var err error
+		if v == nil {
+			err = b.Delete([]byte(k))
+		} else {
+			err = b.Put([]byte(k), v)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeEntry(b []byte, origin uint32, expires int64) []byte {
+	b = binary.BigEndian.AppendUint32(b, origin)
+	return binary.BigEndian.AppendUint64(b, uint64(expires))
+}
+
+func decodeEntry(b []byte) (uint32, int64) {
+	return binary.BigEndian.Uint32(b), int64(binary.BigEndian.Uint64(b[4:]))
+}
+
+// Stats returns store statistics.
+func (s *LocalStore) Stats() map[string]interface{} {
+	s.mu.RLock()
+	stats := map[string]interface{}{
+		"backend":       "memory",
+		"ttl":           s.cfg.TTL.String(),
+		"origins":       len(s.origins),
+		"moas_prefixes": len(s.moas),
+		"pending":       len(s.dirtyOrigins) + len(s.dirtyMOAS),
+		"expired":       s.expired.Load(),
+	}
+	s.mu.RUnlock()
+
+	if s.db != nil {
+		stats["backend"] = "bbolt"
+		stats["path"] = s.cfg.Path
+		stats["snapshots"] = s.snapshots.Load()
+		stats["snapshot_errors"] = s.errors.Load()
+		if t := s.lastSnapshot.Load(); t != 0 {
+			stats["last_snapshot"] = time.Unix(t, 0).UTC().Format(time.RFC3339)
+		}
+	}
+	return stats
+}
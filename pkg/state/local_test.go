@@ -0,0 +1,121 @@
+package state
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLocalStore(t *testing.T) {
+	s := NewMemoryStore(time.Hour)
+	defer s.Close()
+
+	if _, ok := s.Origin("192.0.2.0/24"); ok {
+		t.Fatal("Expected no origin for an unknown prefix")
+	}
+	s.SetOrigin("192.0.2.0/24", 64500)
+	if origin, ok := s.Origin("192.0.2.0/24"); !ok || origin != 64500 {
+		t.Errorf("Expected origin 64500, got %d (%v)", origin, ok)
+	}
+
+	s.AddMOAS("192.0.2.0/24", 64502)
+	s.AddMOAS("192.0.2.0/24", 64501)
+	if !s.IsMOAS("192.0.2.0/24", 64501) || s.IsMOAS("192.0.2.0/24", 64666) {
+		t.Error("Unexpected MOAS membership")
+	}
+	if got := s.MOAS("192.0.2.0/24"); !reflect.DeepEqual(got, []uint32{64501, 64502}) {
+		t.Errorf("Expected MOAS [64501 64502], got %v", got)
+	}
+}
+
+func TestLocalStore_Expiry(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	s := NewMemoryStore(time.Hour)
+	s.now = func() time.Time { return now }
+
+	s.SetOrigin("192.0.2.0/24", 64500)
+	s.AddMOAS("192.0.2.0/24", 64501)
+	now = now.Add(30 * time.Minute)
+	s.SetOrigin("198.51.100.0/24", 64500)
+
+	now = now.Add(45 * time.Minute)
+	if _, ok := s.Origin("192.0.2.0/24"); ok {
+		t.Error("Expected the origin to expire")
+	}
+	if s.IsMOAS("192.0.2.0/24", 64501) {
+		t.Error("Expected the MOAS set to expire")
+	}
+
+	s.Compact()
+	stats := s.Stats()
+	if stats["origins"] != 1 || stats["moas_prefixes"] != 0 || stats["expired"] != uint64(2) {
+		t.Errorf("Expected compaction to drop the expired entries, got %v", stats)
+	}
+	if _, ok := s.Origin("198.51.100.0/24"); !ok {
+		t.Error("Expected the recent origin to be kept")
+	}
+}
+
+func TestLocalStore_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	s, err := OpenLocal(LocalConfig{Path: path, TTL: time.Hour})
+	if err != nil {
+		t.Fatalf("OpenLocal failed: %v", err)
+	}
+	s.SetOrigin("192.0.2.0/24", 64500)
+	s.SetOrigin("198.51.100.0/24", 64666)
+	s.AddMOAS("192.0.2.0/24", 64501)
+	if err := s.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	// Changes after the last snapshot are flushed on Close
+	s.SetOrigin("198.51.100.0/24", 64500)
+	s.SetOrigin("203.0.113.0/24", 64502)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	s, err = OpenLocal(LocalConfig{Path: path, TTL: time.Hour})
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer s.Close()
+	for prefix, want := range map[string]uint32{"192.0.2.0/24": 64500, "198.51.100.0/24": 64500, "203.0.113.0/24": 64502} {
+		if origin, ok := s.Origin(prefix); !ok || origin != want {
+			t.Errorf("Expected %s origin %d after reopening, got %d (%v)", prefix, want, origin, ok)
+		}
+	}
+	if !s.IsMOAS("192.0.2.0/24", 64501) {
+		t.Error("Expected the MOAS set to be persisted")
+	}
+	if s.Stats()["backend"] != "bbolt" {
+		t.Errorf("Expected the bbolt backend, got %v", s.Stats()["backend"])
+	}
+}
+
+func TestLocalStore_ExpiredNotLoaded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	now := time.Unix(1700000000, 0)
+
+	s, err := OpenLocal(LocalConfig{Path: path, TTL: time.Hour})
+	if err != nil {
+		t.Fatalf("OpenLocal failed: %v", err)
+	}
+	s.now = func() time.Time { return now }
+	s.SetOrigin("192.0.2.0/24", 64500)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Reopened well after the TTL, with the real clock
+	s, err = OpenLocal(LocalConfig{Path: path, TTL: time.Hour})
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer s.Close()
+	if stats := s.Stats(); stats["origins"] != 0 {
+		t.Errorf("Expected expired entries to be skipped on load, got %v", stats)
+	}
+}
@@ -0,0 +1,87 @@
+package state
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore keeps state in Redis under bgp:prefix:<prefix>:origin (a
+// string) and bgp:prefix:<prefix>:origins (the MOAS set), so that several
+// instances may share it.
+type RedisStore struct {
+	client *redis.Client
+	ttl    time.Duration
+	ctx    context.Context
+}
+
+// NewRedisStore creates a store on a Redis client. A zero ttl selects
+// DefaultTTL.
+func NewRedisStore(client *redis.Client, ttl time.Duration) *RedisStore {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &RedisStore{client: client, ttl: ttl, ctx: context.Background()}
+}
+
+func originKey(prefix string) string { return "bgp:prefix:" + prefix + ":origin" }
+func moasKey(prefix string) string   { return "bgp:prefix:" + prefix + ":origins" }
+
+// Origin returns the stored origin of prefix.
+func (s *RedisStore) Origin(prefix string) (uint32, bool) {
+	val, err := s.client.Get(s.ctx, originKey(prefix)).Uint64()
+	if err != nil {
+		return 0, false
+	}
+	return uint32(val), true
+}
+
+// SetOrigin stores the origin of prefix.
+func (s *RedisStore) SetOrigin(prefix string, origin uint32) {
+	if err := s.client.Set(s.ctx, originKey(prefix), origin, s.ttl).Err(); err != nil {
+		log.Printf("Redis set error: %v", err)
+	}
+}
+
+// IsMOAS reports whether origin is in the MOAS set of prefix.
+func (s *RedisStore) IsMOAS(prefix string, origin uint32) bool {
+	return s.client.SIsMember(s.ctx, moasKey(prefix), origin).Val()
+}
+
+// AddMOAS adds origin to the MOAS set of prefix.
+func (s *RedisStore) AddMOAS(prefix string, origin uint32) {
+	key := moasKey(prefix)
+	s.client.SAdd(s.ctx, key, origin)
+	s.client.Expire(s.ctx, key, s.ttl)
+}
+
+// MOAS returns the MOAS set of prefix.
+func (s *RedisStore) MOAS(prefix string) []uint32 {
+	members, err := s.client.SMembers(s.ctx, moasKey(prefix)).Result()
+	if err != nil {
+		return nil
+	}
+	origins := make([]uint32, 0, len(members))
+	for _, member := range members {
+		if asn, err := strconv.ParseUint(member, 10, 32); err == nil {
+			origins = append(origins, uint32(asn))
+		}
+	}
+	sort.Slice(origins, func(i, j int) bool { return origins[i] < origins[j] })
+	return origins
+}
+
+// Stats returns the backend and TTL.
+func (s *RedisStore) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"backend": "redis",
+		"ttl":     s.ttl.String(),
+	}
+}
+
+// Close does nothing; the Redis client is shared.
+func (s *RedisStore) Close() error { return nil }
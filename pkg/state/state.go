@@ -0,0 +1,29 @@
+// Package state stores detector state that must outlive a process, such as
+// the known origins of prefixes and their accepted MOAS origins, in Redis
+// or in an embedded bbolt file.
+package state
+
+import "time"
+
+// DefaultTTL is how long state entries are kept after their last write.
+const DefaultTTL = 48 * time.Hour
+
+// Store holds prefix origins and MOAS (Multiple Origin AS) sets. Entries
+// expire once not written for the store's TTL. Implementations are safe for
+// concurrent use; lookups that fail report the entry as absent.
+type Store interface {
+	// Origin returns the stored origin of prefix.
+	Origin(prefix string) (uint32, bool)
+	// SetOrigin stores the origin of prefix.
+	SetOrigin(prefix string, origin uint32)
+	// IsMOAS reports whether origin is in the MOAS set of prefix.
+	IsMOAS(prefix string, origin uint32) bool
+	// AddMOAS adds origin to the MOAS set of prefix.
+	AddMOAS(prefix string, origin uint32)
+	// MOAS returns the MOAS set of prefix, in ascending order.
+	MOAS(prefix string) []uint32
+	// Stats returns store statistics.
+	Stats() map[string]interface{}
+	// Close flushes pending writes and releases the store.
+	Close() error
+}
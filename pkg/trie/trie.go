@@ -0,0 +1,293 @@
+// Package trie provides a path-compressed binary (patricia) trie keyed by
+// IP prefixes, supporting exact, longest-prefix and covering lookups.
+package trie
+
+import "net/netip"
+
+// Trie maps IPv4 and IPv6 prefixes to values.
+// It is not safe for concurrent use; callers must provide their own locking.
+type Trie[V any] struct {
+	root4 *node[V]
+	root6 *node[V]
+	size  int
+}
+
+type node[V any] struct {
+	prefix   netip.Prefix
+	value    V
+	hasValue bool
+	child    [2]*node[V]
+}
+
+// New creates an empty trie.
+func New[V any]() *Trie[V] {
+	return &Trie[V]{}
+}
+
+// Len returns the number of prefixes stored in the trie.
+func (t *Trie[V]) Len() int {
+	return t.size
+}
+
+func (t *Trie[V]) root(p netip.Prefix) **node[V] {
+	if p.Addr().Is4() {
+		return &t.root4
+	}
+	return &t.root6
+}
+
+// Insert stores value for prefix, replacing any existing value.
+// The prefix is masked before insertion, so 10.1.2.3/8 is stored as 10.0.0.0/8.
+func (t *Trie[V]) Insert(p netip.Prefix, value V) {
+	if !p.IsValid() {
+		return
+	}
+	p = p.Masked()
+	n := t.root(p)
+
+	for {
+		cur := *n
+		if cur == nil {
+			*n = &node[V]{prefix: p, value: value, hasValue: true}
+			t.size++
+			return
+		}
+
+		common := commonBits(cur.prefix, p)
+		switch {
+		case common == cur.prefix.Bits() && common == p.Bits():
+			// Exact match
+			if !cur.hasValue {
+				t.size++
+			}
+			cur.value = value
+			cur.hasValue = true
+			return
+
+		case common == cur.prefix.Bits():
+			// cur covers p, descend
+			n = &cur.child[bitAt(p.Addr(), common)]
+
+		case common == p.Bits():
+			// p covers cur, insert above it
+			leaf := &node[V]{prefix: p, value: value, hasValue: true}
+			leaf.child[bitAt(cur.prefix.Addr(), common)] = cur
+			*n = leaf
+			t.size++
+			return
+
+		default:
+			// Diverging branches, split with a glue node
+			glue := &node[V]{prefix: netip.PrefixFrom(p.Addr(), common).Masked()}
+			glue.child[bitAt(cur.prefix.Addr(), common)] = cur
+			glue.child[bitAt(p.Addr(), common)] = &node[V]{prefix: p, value: value, hasValue: true}
+			*n = glue
+			t.size++
+			return
+		}
+	}
+}
+
+// Get returns the value stored for exactly prefix.
+func (t *Trie[V]) Get(p netip.Prefix) (V, bool) {
+	var zero V
+	if !p.IsValid() {
+		return zero, false
+	}
+	p = p.Masked()
+
+	n := *t.root(p)
+	for n != nil {
+		common := commonBits(n.prefix, p)
+		if common < n.prefix.Bits() {
+			break
+		}
+		if n.prefix.Bits() == p.Bits() {
+			if n.hasValue {
+				return n.value, true
+			}
+			break
+		}
+		n = n.child[bitAt(p.Addr(), n.prefix.Bits())]
+	}
+	return zero, false
+}
+
+// Delete removes prefix from the trie and reports whether it was present.
+func (t *Trie[V]) Delete(p netip.Prefix) bool {
+	if !p.IsValid() {
+		return false
+	}
+	p = p.Masked()
+	root := t.root(p)
+
+	var deleted bool
+	*root = t.delete(*root, p, &deleted)
+	if deleted {
+		t.size--
+	}
+	return deleted
+}
+
+func (t *Trie[V]) delete(n *node[V], p netip.Prefix, deleted *bool) *node[V] {
+	if n == nil || commonBits(n.prefix, p) < n.prefix.Bits() {
+		return n
+	}
+
+	if n.prefix.Bits() == p.Bits() {
+		if !n.hasValue {
+			return n
+		}
+		var zero V
+		n.value = zero
+		n.hasValue = false
+		*deleted = true
+	} else {
+		b := bitAt(p.Addr(), n.prefix.Bits())
+		n.child[b] = t.delete(n.child[b], p, deleted)
+	}
+
+	// Collapse nodes that no longer carry a value
+	if n.hasValue {
+		return n
+	}
+	switch {
+	case n.child[0] == nil:
+		return n.child[1]
+	case n.child[1] == nil:
+		return n.child[0]
+	}
+	return n
+}
+
+// LongestMatch returns the most specific stored prefix covering p (including p
+// itself) and its value.
+func (t *Trie[V]) LongestMatch(p netip.Prefix) (netip.Prefix, V, bool) {
+	var (
+		best    netip.Prefix
+		bestVal V
+		found   bool
+	)
+	t.WalkCovering(p, func(prefix netip.Prefix, value V) bool {
+		best, bestVal, found = prefix, value, true
+		return true
+	})
+	return best, bestVal, found
+}
+
+// WalkCovering calls fn for every stored prefix that covers p, including p
+// itself, from least to most specific. Iteration stops when fn returns false.
+func (t *Trie[V]) WalkCovering(p netip.Prefix, fn func(netip.Prefix, V) bool) {
+	if !p.IsValid() {
+		return
+	}
+	p = p.Masked()
+
+	n := *t.root(p)
+	for n != nil {
+		if n.prefix.Bits() > p.Bits() || commonBits(n.prefix, p) < n.prefix.Bits() {
+			return
+		}
+		if n.hasValue && !fn(n.prefix, n.value) {
+			return
+		}
+		if n.prefix.Bits() == p.Bits() {
+			return
+		}
+		n = n.child[bitAt(p.Addr(), n.prefix.Bits())]
+	}
+}
+
+// WalkCovered calls fn for every stored prefix covered by p, including p
+// itself. Iteration stops when fn returns false.
+func (t *Trie[V]) WalkCovered(p netip.Prefix, fn func(netip.Prefix, V) bool) {
+	if !p.IsValid() {
+		return
+	}
+	p = p.Masked()
+
+	n := *t.root(p)
+	for n != nil && n.prefix.Bits() < p.Bits() {
+		if commonBits(n.prefix, p) < n.prefix.Bits() {
+			return
+		}
+		n = n.child[bitAt(p.Addr(), n.prefix.Bits())]
+	}
+	if n == nil || commonBits(n.prefix, p) < p.Bits() {
+		return
+	}
+	walk(n, fn)
+}
+
+// Walk calls fn for every stored prefix. Iteration stops when fn returns false.
+func (t *Trie[V]) Walk(fn func(netip.Prefix, V) bool) {
+	if walk(t.root4, fn) {
+		walk(t.root6, fn)
+	}
+}
+
+func walk[V any](n *node[V], fn func(netip.Prefix, V) bool) bool {
+	if n == nil {
+		return true
+	}
+	if n.hasValue && !fn(n.prefix, n.value) {
+		return false
+	}
+	return walk(n.child[0], fn) && walk(n.child[1], fn)
+}
+
+// commonBits returns the number of leading bits shared by a and b, capped at
+// the shorter of the two prefix lengths.
+func commonBits(a, b netip.Prefix) int {
+	limit := a.Bits()
+	if b.Bits() < limit {
+		limit = b.Bits()
+	}
+
+	x, y := a.Addr().As16(), b.Addr().As16()
+	offset := 0
+	if a.Addr().Is4() {
+		offset = 12 // IPv4 occupies the last 4 bytes of As16
+	}
+
+	bits := 0
+	for i := offset; i < 16 && bits < limit; i++ {
+		diff := x[i] ^ y[i]
+		if diff == 0 {
+			bits += 8
+			continue
+		}
+		for diff&0x80 == 0 {
+			bits++
+			diff <<= 1
+		}
+		break
+	}
+	if bits > limit {
+		bits = limit
+	}
+	return bits
+}
+
+// bitAt returns the i-th most significant bit of addr.
+func bitAt(addr netip.Addr, i int) int {
+	if addr.Is4() {
+		b := addr.As4()
+		return int(b[i/8]>>(7-uint(i%8))) & 1
+	}
+	b := addr.As16()
+	return int(b[i/8]>>(7-uint(i%8))) & 1
+}
+
+// ParsePrefix parses a CIDR prefix string, masking host bits and unmapping
+// IPv4-mapped IPv6 addresses.
+func ParsePrefix(s string) (netip.Prefix, error) {
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	if p.Addr().Is4In6() && p.Bits() >= 96 {
+		p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+	}
+	return p.Masked(), nil
+}
@@ -0,0 +1,177 @@
+package trie
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func mustPrefix(t *testing.T, s string) netip.Prefix {
+	t.Helper()
+	p, err := ParsePrefix(s)
+	if err != nil {
+		t.Fatalf("ParsePrefix(%s): %v", s, err)
+	}
+	return p
+}
+
+func TestTrie_InsertGet(t *testing.T) {
+	tr := New[uint32]()
+	prefixes := map[string]uint32{
+		"10.0.0.0/8":      1,
+		"10.1.0.0/16":     2,
+		"10.1.2.0/24":     3,
+		"10.128.0.0/9":    4,
+		"192.0.2.0/24":    5,
+		"2001:db8::/32":   6,
+		"2001:db8:1::/48": 7,
+	}
+	for s, v := range prefixes {
+		tr.Insert(mustPrefix(t, s), v)
+	}
+
+	if tr.Len() != len(prefixes) {
+		t.Errorf("Len() = %d, want %d", tr.Len(), len(prefixes))
+	}
+	for s, want := range prefixes {
+		got, ok := tr.Get(mustPrefix(t, s))
+		if !ok || got != want {
+			t.Errorf("Get(%s) = %d, %v; want %d, true", s, got, ok, want)
+		}
+	}
+
+	// Glue nodes and missing prefixes must not match
+	for _, s := range []string{"10.0.0.0/7", "10.1.0.0/17", "0.0.0.0/0", "2001:db8::/31"} {
+		if _, ok := tr.Get(mustPrefix(t, s)); ok {
+			t.Errorf("Get(%s) unexpectedly found a value", s)
+		}
+	}
+
+	// Replacing a value does not change the size
+	tr.Insert(mustPrefix(t, "10.1.0.0/16"), 20)
+	if got, _ := tr.Get(mustPrefix(t, "10.1.0.0/16")); got != 20 {
+		t.Errorf("Get after replace = %d, want 20", got)
+	}
+	if tr.Len() != len(prefixes) {
+		t.Errorf("Len() after replace = %d, want %d", tr.Len(), len(prefixes))
+	}
+}
+
+func TestTrie_LongestMatch(t *testing.T) {
+	tr := New[string]()
+	tr.Insert(mustPrefix(t, "10.0.0.0/8"), "a")
+	tr.Insert(mustPrefix(t, "10.1.0.0/16"), "b")
+	tr.Insert(mustPrefix(t, "10.1.2.0/24"), "c")
+
+	tests := []struct {
+		query  string
+		prefix string
+		value  string
+		found  bool
+	}{
+		{"10.1.2.128/25", "10.1.2.0/24", "c", true},
+		{"10.1.2.0/24", "10.1.2.0/24", "c", true},
+		{"10.1.3.0/24", "10.1.0.0/16", "b", true},
+		{"10.2.0.0/16", "10.0.0.0/8", "a", true},
+		{"11.0.0.0/24", "", "", false},
+		{"10.0.0.0/7", "", "", false},
+	}
+
+	for _, tt := range tests {
+		prefix, value, found := tr.LongestMatch(mustPrefix(t, tt.query))
+		if found != tt.found {
+			t.Errorf("LongestMatch(%s) found = %v, want %v", tt.query, found, tt.found)
+			continue
+		}
+		if found && (prefix.String() != tt.prefix || value != tt.value) {
+			t.Errorf("LongestMatch(%s) = %s %s, want %s %s", tt.query, prefix, value, tt.prefix, tt.value)
+		}
+	}
+}
+
+func TestTrie_WalkCoveringAndCovered(t *testing.T) {
+	tr := New[int]()
+	for i, s := range []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "10.2.0.0/16", "2001:db8::/32"} {
+		tr.Insert(mustPrefix(t, s), i)
+	}
+
+	var covering []string
+	tr.WalkCovering(mustPrefix(t, "10.1.2.0/24"), func(p netip.Prefix, _ int) bool {
+		covering = append(covering, p.String())
+		return true
+	})
+	want := []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24"}
+	if len(covering) != len(want) {
+		t.Fatalf("WalkCovering = %v, want %v", covering, want)
+	}
+	for i := range want {
+		if covering[i] != want[i] {
+			t.Errorf("WalkCovering[%d] = %s, want %s", i, covering[i], want[i])
+		}
+	}
+
+	covered := map[string]bool{}
+	tr.WalkCovered(mustPrefix(t, "10.0.0.0/8"), func(p netip.Prefix, _ int) bool {
+		covered[p.String()] = true
+		return true
+	})
+	if len(covered) != 4 || covered["2001:db8::/32"] {
+		t.Errorf("WalkCovered(10.0.0.0/8) = %v", covered)
+	}
+
+	count := 0
+	tr.Walk(func(netip.Prefix, int) bool {
+		count++
+		return true
+	})
+	if count != 5 {
+		t.Errorf("Walk visited %d prefixes, want 5", count)
+	}
+}
+
+func TestTrie_Delete(t *testing.T) {
+	tr := New[int]()
+	tr.Insert(mustPrefix(t, "10.0.0.0/8"), 1)
+	tr.Insert(mustPrefix(t, "10.1.0.0/16"), 2)
+	tr.Insert(mustPrefix(t, "10.128.0.0/16"), 3)
+
+	if !tr.Delete(mustPrefix(t, "10.0.0.0/8")) {
+		t.Fatal("Delete(10.0.0.0/8) = false, want true")
+	}
+	if tr.Delete(mustPrefix(t, "10.0.0.0/8")) {
+		t.Error("second Delete(10.0.0.0/8) = true, want false")
+	}
+	if tr.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", tr.Len())
+	}
+	if _, _, found := tr.LongestMatch(mustPrefix(t, "10.5.0.0/16")); found {
+		t.Error("LongestMatch found deleted covering prefix")
+	}
+	if v, ok := tr.Get(mustPrefix(t, "10.128.0.0/16")); !ok || v != 3 {
+		t.Errorf("Get(10.128.0.0/16) = %d, %v after delete", v, ok)
+	}
+
+	tr.Delete(mustPrefix(t, "10.1.0.0/16"))
+	tr.Delete(mustPrefix(t, "10.128.0.0/16"))
+	if tr.Len() != 0 || tr.root4 != nil {
+		t.Errorf("trie not empty after deleting everything: len=%d", tr.Len())
+	}
+}
+
+func TestParsePrefix(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"10.1.2.3/8", "10.0.0.0/8"},
+		{"::ffff:192.0.2.0/120", "192.0.2.0/24"},
+		{"2001:db8::1/32", "2001:db8::/32"},
+	}
+	for _, tt := range tests {
+		if got := mustPrefix(t, tt.input); got.String() != tt.expected {
+			t.Errorf("ParsePrefix(%s) = %s, want %s", tt.input, got, tt.expected)
+		}
+	}
+	if _, err := ParsePrefix("not-a-prefix"); err == nil {
+		t.Error("Expected error for invalid prefix")
+	}
+}
@@ -0,0 +1,183 @@
+// Package watchlist loads the prefixes an operator monitors, with their
+// authorized origins and upstreams, from a YAML file.
+//
+//	owner: noc                      # Default owner of the entries
+//	prefixes:
+//	  - prefix: 192.0.2.0/23
+//	    description: Anycast DNS
+//	    owner: dns-team
+//	    origins: [AS64500, 64501]   # Authorized origin ASNs
+//	    upstreams: [174, 3356]      # Allowed neighbors of the origin (optional)
+//	    max_length: 24              # Longest allowed more-specific (default: the prefix length)
+//	    min_peers: 3                # Peers that must see the prefix (default 1)
+package watchlist
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hervehildenbrand/bgp-radar/pkg/trie"
+	"gopkg.in/yaml.v3"
+)
+
+// Entry is a watched prefix.
+type Entry struct {
+	Prefix      netip.Prefix
+	Description string
+	Owner       string
+	Origins     []uint32
+	Upstreams   []uint32 // Empty allows any upstream
+	MaxLength   int
+	MinPeers    int
+}
+
+// Watchlist is an immutable set of watched prefixes, safe for concurrent use.
+type Watchlist struct {
+	entries []*Entry
+	index   *trie.Trie[*Entry]
+}
+
+// file is the YAML layout of a watchlist file.
+type file struct {
+	Owner    string `yaml:"owner"`
+	Prefixes []struct {
+		Prefix      string `yaml:"prefix"`
+		Description string `yaml:"description"`
+		Owner       string `yaml:"owner"`
+		Origins     []ASN  `yaml:"origins"`
+		Upstreams   []ASN  `yaml:"upstreams"`
+		MaxLength   int    `yaml:"max_length"`
+		MinPeers    int    `yaml:"min_peers"`
+	} `yaml:"prefixes"`
+}
+
+// ASN is an AS number written as 64500 or "AS64500".
+type ASN uint32
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (a *ASN) UnmarshalYAML(node *yaml.Node) error {
+	s := strings.TrimPrefix(strings.ToUpper(node.Value), "AS")
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || n == 0 {
+		return fmt.Errorf("line %d: invalid ASN %q", node.Line, node.Value)
+	}
+	*a = ASN(n)
+	return nil
+}
+
+// Load reads a watchlist file.
+func Load(path string) (*Watchlist, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return w, nil
+}
+
+// Parse parses a watchlist from YAML.
+func Parse(data []byte) (*Watchlist, error) {
+	var f file
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	if len(f.Prefixes) == 0 {
+		return nil, errors.New("no prefixes")
+	}
+
+	w := &Watchlist{index: trie.New[*Entry]()}
+	for i, p := range f.Prefixes {
+		prefix, err := trie.ParsePrefix(p.Prefix)
+		if err != nil {
+			return nil, fmt.Errorf("prefix %d: %w", i+1, err)
+		}
+		if _, dup := w.index.Get(prefix); dup {
+			return nil, fmt.Errorf("prefix %s listed twice", prefix)
+		}
+		if len(p.Origins) == 0 {
+			return nil, fmt.Errorf("prefix %s: no origins", prefix)
+		}
+		maxBits := 32
+		if prefix.Addr().Is6() {
+			maxBits = 128
+		}
+		if p.MaxLength == 0 {
+			p.MaxLength = prefix.Bits()
+		}
+		if p.MaxLength < prefix.Bits() || p.MaxLength > maxBits {
+			return nil, fmt.Errorf("prefix %s: invalid max_length %d", prefix, p.MaxLength)
+		}
+		if p.MinPeers < 0 {
+			return nil, fmt.Errorf("prefix %s: invalid min_peers %d", prefix, p.MinPeers)
+		}
+		if p.MinPeers == 0 {
+			p.MinPeers = 1
+		}
+		if p.Owner == "" {
+			p.Owner = f.Owner
+		}
+
+		entry := &Entry{
+			Prefix:      prefix,
+			Description: p.Description,
+			Owner:       p.Owner,
+			Origins:     asns(p.Origins),
+			Upstreams:   asns(p.Upstreams),
+			MaxLength:   p.MaxLength,
+			MinPeers:    p.MinPeers,
+		}
+		w.entries = append(w.entries, entry)
+		w.index.Insert(prefix, entry)
+	}
+	return w, nil
+}
+
+func asns(in []ASN) []uint32 {
+	out := make([]uint32, len(in))
+	for i, a := range in {
+		out[i] = uint32(a)
+	}
+	return out
+}
+
+// Match returns the most specific entry covering prefix, if any.
+func (w *Watchlist) Match(prefix netip.Prefix) (*Entry, bool) {
+	_, entry, ok := w.index.LongestMatch(prefix)
+	return entry, ok
+}
+
+// Entries returns the watched prefixes in file order.
+func (w *Watchlist) Entries() []*Entry {
+	return w.entries
+}
+
+// Len returns the number of watched prefixes.
+func (w *Watchlist) Len() int {
+	return len(w.entries)
+}
+
+// AuthorizedOrigin reports whether asn may originate the entry's prefixes.
+func (e *Entry) AuthorizedOrigin(asn uint32) bool {
+	return containsASN(e.Origins, asn)
+}
+
+// AllowedUpstream reports whether asn may be the neighbor of the origin.
+func (e *Entry) AllowedUpstream(asn uint32) bool {
+	return len(e.Upstreams) == 0 || containsASN(e.Upstreams, asn)
+}
+
+func containsASN(list []uint32, asn uint32) bool {
+	for _, a := range list {
+		if a == asn {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package watchlist
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	w, err := Parse([]byte(`
+owner: noc
+prefixes:
+  - prefix: 192.0.2.0/23
+    description: Anycast DNS
+    origins: [AS64500, 64501]
+    upstreams: [174]
+    max_length: 24
+  - prefix: 2001:db8::/32
+    owner: v6-team
+    origins: [64500]
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if w.Len() != 2 {
+		t.Fatalf("Expected 2 entries, got %d", w.Len())
+	}
+
+	e, ok := w.Match(netip.MustParsePrefix("192.0.3.0/24"))
+	if !ok || e.Prefix.String() != "192.0.2.0/23" {
+		t.Fatalf("Expected 192.0.3.0/24 to match 192.0.2.0/23, got %v", e)
+	}
+	if e.Owner != "noc" || e.MaxLength != 24 || e.MinPeers != 1 {
+		t.Errorf("Unexpected entry: %+v", e)
+	}
+	if !e.AuthorizedOrigin(64501) || e.AuthorizedOrigin(64666) {
+		t.Error("Unexpected authorized origins")
+	}
+	if !e.AllowedUpstream(174) || e.AllowedUpstream(3356) {
+		t.Error("Unexpected allowed upstreams")
+	}
+
+	v6, _ := w.Match(netip.MustParsePrefix("2001:db8::/32"))
+	if v6.Owner != "v6-team" || v6.MaxLength != 32 || !v6.AllowedUpstream(3356) {
+		t.Errorf("Unexpected IPv6 entry: %+v", v6)
+	}
+	if _, ok := w.Match(netip.MustParsePrefix("198.51.100.0/24")); ok {
+		t.Error("Expected no match outside the watchlist")
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for name, data := range map[string]string{
+		"empty":      `prefixes: []`,
+		"bad prefix": "prefixes:\n  - prefix: 192.0.2.0/33\n    origins: [64500]",
+		"no origins": "prefixes:\n  - prefix: 192.0.2.0/24",
+		"bad asn":    "prefixes:\n  - prefix: 192.0.2.0/24\n    origins: [ASX]",
+		"short max":  "prefixes:\n  - prefix: 192.0.2.0/24\n    origins: [64500]\n    max_length: 16",
+		"duplicate":  "prefixes:\n  - prefix: 192.0.2.0/24\n    origins: [64500]\n  - prefix: 192.0.2.0/24\n    origins: [64501]",
+		"not yaml":   "prefixes: [",
+	} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}